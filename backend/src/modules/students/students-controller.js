@@ -2,8 +2,8 @@ const asyncHandler = require("express-async-handler");
 const { getAllStudents, addNewStudent, getStudentDetail, setStudentStatus, updateStudent } = require("./students-service");
 
 const handleGetAllStudents = asyncHandler(async (req, res) => {
-    const { userId, classId, sectionId, name, sortBy, order, page, limit } = req.query;
-    const students = await getAllStudents({ userId, classId, sectionId, name, sortBy, order, page, limit });
+    const { userId, classId, sectionId, className, section, name, sortBy, order, page, limit } = req.query;
+    const students = await getAllStudents({ userId, classId, sectionId, className, section, name, sortBy, order, page, limit });
     res.status(200).json({ students });
 });
 
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"os"
@@ -11,12 +13,25 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/wbentaleb/student-report-service/internal/apikeys"
+	"github.com/wbentaleb/student-report-service/internal/auth"
 	"github.com/wbentaleb/student-report-service/internal/cache"
+	"github.com/wbentaleb/student-report-service/internal/clock"
 	"github.com/wbentaleb/student-report-service/internal/config"
 	"github.com/wbentaleb/student-report-service/internal/external"
 	"github.com/wbentaleb/student-report-service/internal/handler"
+	"github.com/wbentaleb/student-report-service/internal/jobs"
+	"github.com/wbentaleb/student-report-service/internal/metrics"
+	"github.com/wbentaleb/student-report-service/internal/middleware"
+	"github.com/wbentaleb/student-report-service/internal/ratelimit"
+	"github.com/wbentaleb/student-report-service/internal/registry"
+	"github.com/wbentaleb/student-report-service/internal/retry"
 	"github.com/wbentaleb/student-report-service/internal/server"
 	"github.com/wbentaleb/student-report-service/internal/service"
+	"github.com/wbentaleb/student-report-service/internal/signing"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+	"github.com/wbentaleb/student-report-service/internal/tracing"
+	"github.com/wbentaleb/student-report-service/internal/typeset"
 	"github.com/wbentaleb/student-report-service/pkg/logger"
 )
 
@@ -38,31 +53,220 @@ func main() {
 
 	log.Info("Starting student report service", zap.String("environment", cfg.Environment), zap.String("port", cfg.Port), zap.String("backend_url", cfg.BackendURL))
 
+	// OpenTelemetry tracing (exported when TRACING_OTLP_ENDPOINT is set)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: cfg.TracingServiceName,
+		EndpointURL: cfg.TracingEndpoint,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		log.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+	if cfg.TracingEndpoint != "" {
+		log.Info("Tracing enabled", zap.String("endpoint", cfg.TracingEndpoint), zap.Float64("sample_ratio", cfg.TracingSampleRatio))
+	}
+
+	// Prometheus metrics, served on /metrics
+	var serviceMetrics *metrics.Metrics
+	if cfg.EnableMetrics {
+		serviceMetrics = metrics.New()
+	}
+
 	// Initialize clients and services
-	backendClient := external.NewBackendClient(cfg.BackendURL, cfg.APIKey, log)
-	pdfService := service.NewPDFService(log)
+	retryBudget := retry.NewBudget(cfg.RetryBudgetRatio, cfg.RetryBudgetReserve)
+	retryPolicy := retry.NewPolicy(cfg.RetryAttempts, cfg.RetryBaseDelay, cfg.RetryMaxDelay, retryBudget, log)
+	var backendClient external.BackendService = external.NewBackendClient(cfg.BackendURL, cfg.APIKey, retryPolicy, log)
+	if serviceMetrics != nil {
+		backendClient = serviceMetrics.InstrumentBackend(backendClient)
+		serviceMetrics.RegisterRetries(retryPolicy)
+	}
+
+	// Guard the backend with a circuit breaker (disabled with a threshold of 0)
+	var breakerStats external.BreakerStatsProvider
+	if cfg.BreakerFailureThreshold > 0 {
+		breaker := external.NewCircuitBreaker(backendClient, cfg.BreakerFailureThreshold, cfg.BreakerSuccessThreshold, cfg.BreakerCooldown, nil, log)
+		backendClient = breaker
+		breakerStats = breaker
+		if serviceMetrics != nil {
+			serviceMetrics.RegisterBreaker(breaker)
+		}
+	}
+
+	// Load fonts (bundled DejaVu family plus FONT_DIR)
+	fontSet, err := typeset.LoadFontSet(cfg.FontDir)
+	if err != nil {
+		log.Fatal("Failed to load fonts", zap.Error(err))
+	}
+	familyNames := make([]string, 0, len(fontSet.Families()))
+	for _, family := range fontSet.Families() {
+		familyNames = append(familyNames, family.Name)
+	}
+	log.Info("Fonts loaded", zap.Strings("families", familyNames))
+
+	pdfService := service.NewPDFService(fontSet, log)
+
+	// Initialize report templates (built-in default plus TEMPLATE_DIR)
+	templateRegistry, err := templates.NewRegistry(cfg.TemplateDir, cfg.TemplateReload, cfg.TemplateOverrideDefault, log)
+	if err != nil {
+		log.Fatal("Failed to load report templates", zap.Error(err))
+	}
+	log.Info("Report templates loaded", zap.Strings("templates", templateRegistry.Names()))
 
-	// Initialize file cache
-	var pdfCache *cache.FileCache
+	// Initialize report cache
+	var pdfCache cache.PDFCache
 	if cfg.EnableCache {
-		var err error
-		pdfCache, err = cache.NewFileCache(cfg.CachePath, cfg.CacheTTL)
+		reportCache, err := newReportCache(cfg)
 		if err != nil {
-			log.Warn("Failed to initialize cache, continuing without cache", zap.Error(err))
+			log.Warn("Failed to initialize cache, continuing without cache", zap.String("backend", cfg.CacheBackend), zap.Error(err))
 		} else {
-			log.Info("Cache initialized", zap.String("path", cfg.CachePath), zap.Duration("ttl", cfg.CacheTTL))
+			pdfCache = reportCache
+			log.Info("Cache initialized", zap.String("backend", cfg.CacheBackend), zap.Duration("ttl", cfg.CacheTTL))
+		}
+	}
+
+	// Load the PDF signing certificate. Reports are signed by this certificate
+	// and signatures made with it are trusted on verification.
+	var pdfSigner service.PDFSigner
+	var trustedCerts []*x509.Certificate
+	if cfg.SigningCertFile != "" || cfg.SigningKeyFile != "" {
+		if cfg.SigningCertFile == "" || cfg.SigningKeyFile == "" {
+			log.Fatal("SIGNING_CERT_FILE and SIGNING_KEY_FILE must be set together")
+		}
+		signer, err := signing.LoadSigner(cfg.SigningCertFile, cfg.SigningKeyFile, cfg.SigningReason, cfg.SigningLocation)
+		if err != nil {
+			log.Fatal("Failed to load signing certificate", zap.Error(err))
+		}
+		pdfSigner = signer
+		trustedCerts = signer.Certificates()
+		log.Info("PDF signing enabled", zap.String("certificate", signer.Fingerprint()))
+	}
+	verifier := signing.NewVerifier(trustedCerts...)
+
+	// Initialize the report registry (every issued report ID can be verified)
+	reportRegistry, err := registry.NewFileRegistry(cfg.ReportRegistryPath)
+	if err != nil {
+		log.Fatal("Failed to initialize report registry", zap.Error(err))
+	}
+
+	// Initialize renderers, one per output format
+	renderers := []service.ReportRenderer{
+		pdfService,
+		service.NewHTMLRenderer(log),
+		service.NewCSVRenderer(log),
+		service.NewJSONRenderer(log),
+	}
+	if serviceMetrics != nil {
+		for i, renderer := range renderers {
+			renderers[i] = serviceMetrics.InstrumentRenderer(renderer)
+		}
+	}
+
+	// Initialize report service (orchestrates backend, renderers, and cache)
+	issuer := service.NewReportIssuer(clock.System(), cfg.DeterministicReports, cfg.ReportVerifyURL)
+	reportService := service.NewStudentReportService(backendClient, renderers, pdfCache, templateRegistry, pdfSigner, reportRegistry, issuer, cfg.ServeStale, log)
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	if cfg.ServeStale {
+		go reportService.RunStaleRefresh(refreshCtx, cfg.StaleRefreshInterval)
+		log.Info("Serving stale reports while the backend is unreachable", zap.Duration("refresh_interval", cfg.StaleRefreshInterval))
+	}
+	if serviceMetrics != nil {
+		serviceMetrics.RegisterCoalescing(reportService)
+		if pdfCache != nil {
+			serviceMetrics.RegisterCache(pdfCache)
 		}
 	}
+	batchService := service.NewBatchReportService(reportService, backendClient, pdfService, templateRegistry, pdfSigner, cfg.BatchConcurrency, cfg.BatchMaxStudents, log)
 
-	// Initialize report service (orchestrates backend, PDF, and cache)
-	reportService := service.NewStudentReportService(backendClient, pdfService, pdfCache, log)
+	// Initialize async report jobs (persisted on disk, resumed after restart)
+	jobStore, err := jobs.NewFileStore(cfg.JobsPath)
+	if err != nil {
+		log.Fatal("Failed to initialize job store", zap.Error(err))
+	}
+	jobManager := jobs.NewManager(jobStore, batchService, cfg.JobWorkers, cfg.JobQueueSize, cfg.JobTimeout, cfg.JobRetention, log)
+	if err := jobManager.Start(); err != nil {
+		log.Fatal("Failed to start job manager", zap.Error(err))
+	}
 
 	// Initialize handlers
-	healthHandler := handler.NewHealthHandler(backendClient)
+	healthHandler := handler.NewHealthHandler(backendClient, pdfCache, reportService, breakerStats)
 	reportHandler := handler.NewStudentReportHandler(reportService, log)
+	batchHandler := handler.NewBatchReportHandler(batchService, log)
+	jobHandler := handler.NewReportJobHandler(jobManager, log)
+	verificationHandler := handler.NewReportVerificationHandler(verifier, reportRegistry, log)
+
+	// Student update webhook; event IDs are remembered as long as a delivery
+	// with the same timestamp is accepted
+	var eventService *service.StudentEventService
+	var eventHandler *handler.StudentEventHandler
+	if cfg.WebhookSecret != "" {
+		eventService = service.NewStudentEventService(pdfCache, reportService, cfg.WebhookRerender, 2*cfg.WebhookTolerance, clock.System(), log)
+		eventHandler = handler.NewStudentEventHandler(eventService, cfg.WebhookSecret, cfg.WebhookTolerance, log)
+	}
+
+	// Inbound API keys of the integrations, managed with the admin key
+	var keyStore *apikeys.Store
+	var apiKeyHandler *handler.APIKeyHandler
+	if cfg.APIKeysFile != "" {
+		if keyStore, err = apikeys.NewStore(cfg.APIKeysFile); err != nil {
+			log.Fatal("Failed to load API keys", zap.Error(err))
+		}
+		if cfg.AdminAPIKey != "" {
+			apiKeyHandler = handler.NewAPIKeyHandler(keyStore, cfg.APIKeyRotationOverlap, log)
+		} else {
+			log.Warn("ADMIN_API_KEY is not set, API key administration endpoints are disabled")
+		}
+		log.Info("API keys enabled", zap.String("path", cfg.APIKeysFile), zap.Int("keys", len(keyStore.List())))
+	}
+
+	// Cache administration requires a cache, and the admin key or API keys
+	var cacheAdminHandler *handler.CacheAdminHandler
+	if pdfCache != nil && (cfg.AdminAPIKey != "" || keyStore != nil) {
+		cacheAdminHandler = handler.NewCacheAdminHandler(pdfCache, batchService, log)
+	} else if pdfCache != nil {
+		log.Warn("ADMIN_API_KEY is not set, cache administration endpoints are disabled")
+	}
+
+	// Caller authentication for the report endpoints
+	var tokenVerifier *auth.Verifier
+	if cfg.JWTSecret != "" || cfg.JWTJWKSFile != "" {
+		var keys map[string]*rsa.PublicKey
+		if cfg.JWTJWKSFile != "" {
+			if keys, err = auth.LoadJWKS(cfg.JWTJWKSFile); err != nil {
+				log.Fatal("Failed to load JWKS", zap.Error(err))
+			}
+		}
+		if tokenVerifier, err = auth.NewVerifier([]byte(cfg.JWTSecret), keys, cfg.JWTIssuer, cfg.JWTAudience); err != nil {
+			log.Fatal("Failed to initialize token verification", zap.Error(err))
+		}
+		log.Info("Report endpoints accept bearer JWTs", zap.Bool("hs256", cfg.JWTSecret != ""), zap.Int("rs256_keys", len(keys)))
+	} else if keyStore == nil {
+		log.Warn("Neither JWTs nor API keys are configured, report endpoints are open to anyone")
+	}
+
+	// Rate limiting, with buckets kept in RATE_LIMIT_BACKEND
+	var limiter *middleware.RateLimiter
+	if cfg.EnableRateLimit {
+		routes, err := ratelimit.ParseRoutes(cfg.RateLimitRoutes)
+		if err != nil {
+			log.Fatal("Invalid RATE_LIMIT_ROUTES", zap.Error(err))
+		}
+		limitStore, err := newRateLimitStore(cfg)
+		if err != nil {
+			log.Fatal("Failed to initialize rate limit store", zap.String("backend", cfg.RateLimitBackend), zap.Error(err))
+		}
+		// idle buckets of a shared store expire on their own
+		if memoryStore, ok := limitStore.(*ratelimit.MemoryStore); ok {
+			go memoryStore.RunSweeper(refreshCtx, cfg.RateLimitSweepInterval)
+		}
+		limiter = middleware.NewRateLimiter(limitStore, cfg.RateLimitPerMinute, routes, tokenVerifier, keyStore, log)
+		log.Info("Rate limiting enabled", zap.String("backend", cfg.RateLimitBackend), zap.Int("requests_per_minute", cfg.RateLimitPerMinute), zap.Int("route_policies", len(routes)))
+	}
 
 	// Setup HTTP server with router, middleware, and routes
-	router := server.NewRouter(cfg, log, healthHandler, reportHandler)
+	router, err := server.NewRouter(cfg, log, serviceMetrics, tokenVerifier, keyStore, limiter, healthHandler, reportHandler, batchHandler, jobHandler, verificationHandler, eventHandler, cacheAdminHandler, apiKeyHandler)
+	if err != nil {
+		log.Fatal("Failed to configure router", zap.Error(err))
+	}
 
 	// Server with graceful shutdown
 	srv := &http.Server{
@@ -95,5 +299,55 @@ func main() {
 		log.Error("Server forced to shutdown", zap.Error(err))
 	}
 
+	// Interrupted jobs stay queued on disk and resume on the next start
+	jobManager.Stop()
+	stopRefresh()
+	if eventService != nil {
+		eventService.Close()
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("Failed to flush traces", zap.Error(err))
+	}
+
 	log.Info("Server exited")
 }
+
+// newReportCache creates the cache backend selected by CACHE_BACKEND
+func newReportCache(cfg *config.Config) (cache.PDFCache, error) {
+	switch cfg.CacheBackend {
+	case cache.BackendFile:
+		return cache.NewFileCache(cfg.CachePath, cfg.CacheTTL, cache.FileCacheLimits{
+			MaxBytes:   cfg.CacheMaxBytes,
+			MaxEntries: cfg.CacheMaxEntries,
+			Policy:     cfg.CacheEviction,
+		})
+	case cache.BackendMemory:
+		return cache.NewMemoryCache(cfg.CacheMaxBytes, cfg.CacheTTL)
+	case cache.BackendRedis:
+		return cache.NewRedisCache(cfg.CacheRedisURL, cfg.CacheKeyPrefix, cfg.CacheTTL)
+	case cache.BackendS3:
+		return cache.NewS3Cache(cache.S3Options{
+			Endpoint:  cfg.CacheS3Endpoint,
+			Bucket:    cfg.CacheS3Bucket,
+			Region:    cfg.CacheS3Region,
+			AccessKey: cfg.CacheS3AccessKey,
+			SecretKey: cfg.CacheS3SecretKey,
+			UseSSL:    cfg.CacheS3UseSSL,
+			Prefix:    cfg.CacheKeyPrefix,
+		}, cfg.CacheTTL)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.CacheBackend)
+	}
+}
+
+// newRateLimitStore creates the rate limit store selected by RATE_LIMIT_BACKEND
+func newRateLimitStore(cfg *config.Config) (ratelimit.Store, error) {
+	switch cfg.RateLimitBackend {
+	case ratelimit.BackendMemory:
+		return ratelimit.NewMemoryStore(), nil
+	case ratelimit.BackendRedis:
+		return ratelimit.NewRedisStore(cfg.RateLimitRedisURL, cfg.RateLimitKeyPrefix)
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", cfg.RateLimitBackend)
+	}
+}
@@ -0,0 +1,264 @@
+package apikeys
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wbentaleb/student-report-service/internal/clock"
+	"github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/fsutil"
+)
+
+var (
+	// ErrKeyNotFound is returned when no key has the given ID
+	ErrKeyNotFound = stderrors.New("API key not found")
+	// ErrInvalidKey is returned for unknown, forged, expired and revoked keys
+	ErrInvalidKey = stderrors.New("invalid API key")
+)
+
+// Store keeps the API keys in a local JSON file, rewritten on every change
+type Store struct {
+	path  string
+	clock clock.Clock
+
+	mu   sync.RWMutex
+	keys map[string]*Key
+}
+
+// NewStore loads the keys at path; the file is created with the first key
+func NewStore(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create key store directory: %w", err)
+	}
+
+	s := &Store{path: path, clock: clock.System(), keys: make(map[string]*Key)}
+
+	data, err := os.ReadFile(path)
+	if stderrors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key store: %w", err)
+	}
+
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode key store: %w", err)
+	}
+	for _, key := range file.Keys {
+		s.keys[key.ID] = key
+	}
+	return s, nil
+}
+
+type storeFile struct {
+	Keys []*Key `json:"keys"`
+}
+
+// Create adds a key and returns it along with its token. expiresAt may be
+// nil for a key that does not expire.
+func (s *Store) Create(name string, scopes []string, rateLimit int, expiresAt *time.Time) (*Key, string, error) {
+	if err := validateKey(name, scopes, rateLimit); err != nil {
+		return nil, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now().UTC()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, "", &errors.ValidationError{Message: "expires_at must be in the future"}
+	}
+	for _, key := range s.keys {
+		if key.Name == name && key.Active(now) {
+			return nil, "", &errors.ValidationError{Message: fmt.Sprintf("an active key named %q already exists", name)}
+		}
+	}
+
+	id, token, hash, err := newToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	key := &Key{
+		ID:                 id,
+		Name:               name,
+		Hash:               hash,
+		Scopes:             slices.Clone(scopes),
+		RateLimitPerMinute: rateLimit,
+		CreatedAt:          now,
+		ExpiresAt:          expiresAt,
+	}
+
+	if err := s.commit(func(keys map[string]*Key) { keys[id] = key }); err != nil {
+		return nil, "", err
+	}
+	return key.clone(), token, nil
+}
+
+// Rotate replaces the key with a new one with the same name, scopes and
+// quota. The old key remains valid for overlap, so that callers can switch
+// to the new token without downtime.
+func (s *Store) Rotate(id string, overlap time.Duration) (*Key, string, error) {
+	if overlap < 0 {
+		return nil, "", &errors.ValidationError{Message: "overlap cannot be negative"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.keys[id]
+	if !ok {
+		return nil, "", ErrKeyNotFound
+	}
+	now := s.clock.Now().UTC()
+	if !old.Active(now) || old.RotatedTo != "" {
+		return nil, "", &errors.ValidationError{Message: "only active keys that were not rotated yet can be rotated"}
+	}
+
+	newID, token, hash, err := newToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	key := &Key{
+		ID:                 newID,
+		Name:               old.Name,
+		Hash:               hash,
+		Scopes:             slices.Clone(old.Scopes),
+		RateLimitPerMinute: old.RateLimitPerMinute,
+		CreatedAt:          now,
+	}
+	// rotating a key must not extend its lifetime
+	if old.ExpiresAt != nil {
+		expiresAt := *old.ExpiresAt
+		key.ExpiresAt = &expiresAt
+	}
+
+	// the old key never outlives its own expiry
+	retired := old.clone()
+	retired.RotatedTo = newID
+	if until := now.Add(overlap); retired.ExpiresAt == nil || until.Before(*retired.ExpiresAt) {
+		retired.ExpiresAt = &until
+	}
+
+	if err := s.commit(func(keys map[string]*Key) {
+		keys[id] = retired
+		keys[newID] = key
+	}); err != nil {
+		return nil, "", err
+	}
+	return key.clone(), token, nil
+}
+
+// Revoke disables the key at once. Revoking a revoked key is a no-op.
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	revoked := key.clone()
+	now := s.clock.Now().UTC()
+	revoked.RevokedAt = &now
+	return s.commit(func(keys map[string]*Key) { keys[id] = revoked })
+}
+
+// List returns every key, including expired and revoked ones, oldest first
+func (s *Store) List() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key.clone())
+	}
+	sortKeys(keys)
+	return keys
+}
+
+// Authenticate returns the active key matching token
+func (s *Store) Authenticate(token string) (*Key, error) {
+	id, secret, ok := parseToken(token)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	s.mu.RLock()
+	key, ok := s.keys[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.Hash)) != 1 || !key.Active(s.clock.Now()) {
+		return nil, ErrInvalidKey
+	}
+	return key.clone(), nil
+}
+
+// commit applies update to a copy of the keys, writes it to disk and only
+// then makes it visible. Callers hold the write lock.
+func (s *Store) commit(update func(keys map[string]*Key)) error {
+	keys := make(map[string]*Key, len(s.keys)+1)
+	for id, key := range s.keys {
+		keys[id] = key
+	}
+	update(keys)
+
+	file := storeFile{Keys: make([]*Key, 0, len(keys))}
+	for _, key := range keys {
+		file.Keys = append(file.Keys, key)
+	}
+	sortKeys(file.Keys)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode key store: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+
+	s.keys = keys
+	return nil
+}
+
+func validateKey(name string, scopes []string, rateLimit int) error {
+	if name == "" {
+		return &errors.ValidationError{Message: "name is required"}
+	}
+	if len(scopes) == 0 {
+		return &errors.ValidationError{Message: "at least one scope is required"}
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return &errors.ValidationError{Message: fmt.Sprintf("unknown scope %q", scope)}
+		}
+	}
+	if rateLimit < 0 {
+		return &errors.ValidationError{Message: "rate_limit_per_minute cannot be negative"}
+	}
+	return nil
+}
+
+func sortKeys(keys []*Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+}
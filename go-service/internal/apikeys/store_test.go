@@ -0,0 +1,214 @@
+package apikeys
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wbentaleb/student-report-service/internal/clock"
+	"github.com/wbentaleb/student-report-service/internal/errors"
+)
+
+// newTestStore returns a store whose clock is advanced by the returned function
+func newTestStore(t *testing.T) (*Store, string, func(time.Duration)) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys", "api-keys.json")
+	store, err := NewStore(path)
+	require.NoError(t, err)
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store.clock = clock.Func(func() time.Time { return now })
+	return store, path, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestStore_CreateAndAuthenticate(t *testing.T) {
+	store, path, _ := newTestStore(t)
+
+	key, token, err := store.Create("portal", []string{ScopeReportsRead}, 30, nil)
+	require.NoError(t, err)
+	assert.True(t, IsToken(token))
+	assert.Equal(t, []string{ScopeReportsRead}, key.Scopes)
+
+	authenticated, err := store.Authenticate(token)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, authenticated.ID)
+	assert.Equal(t, 30, authenticated.RateLimitPerMinute)
+	assert.True(t, authenticated.HasScope(ScopeReportsRead))
+	assert.False(t, authenticated.HasScope(ScopeCacheAdmin))
+
+	// only the hash of the secret is written to disk
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	_, secret, _ := parseToken(token)
+	assert.NotContains(t, string(data), secret)
+	assert.Contains(t, string(data), key.Hash)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestStore_RejectsInvalidTokens(t *testing.T) {
+	store, _, _ := newTestStore(t)
+	key, token, err := store.Create("portal", []string{ScopeReportsRead}, 0, nil)
+	require.NoError(t, err)
+
+	for _, token := range []string{
+		"",
+		"srk_",
+		"srk_" + key.ID,
+		"srk_" + key.ID + "_" + strings.Repeat("A", 43),
+		"srk_ffffffffffffffff_" + strings.SplitN(token, "_", 3)[2],
+		strings.TrimPrefix(token, "srk_"),
+	} {
+		_, err := store.Authenticate(token)
+		assert.ErrorIs(t, err, ErrInvalidKey, token)
+	}
+}
+
+func TestStore_CreateValidates(t *testing.T) {
+	store, _, _ := newTestStore(t)
+	past := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, create := range map[string]func() error{
+		"no name":        func() error { _, _, err := store.Create("", []string{ScopeReportsRead}, 0, nil); return err },
+		"no scope":       func() error { _, _, err := store.Create("portal", nil, 0, nil); return err },
+		"unknown scope":  func() error { _, _, err := store.Create("portal", []string{"reports:write"}, 0, nil); return err },
+		"negative quota": func() error { _, _, err := store.Create("portal", []string{ScopeReportsRead}, -1, nil); return err },
+		"expired":        func() error { _, _, err := store.Create("portal", []string{ScopeReportsRead}, 0, &past); return err },
+	} {
+		assert.True(t, errors.IsValidationError(create()), name)
+	}
+
+	_, _, err := store.Create("portal", []string{ScopeReportsRead}, 0, nil)
+	require.NoError(t, err)
+	_, _, err = store.Create("portal", []string{ScopeBatchWrite}, 0, nil)
+	assert.True(t, errors.IsValidationError(err), "names of active keys are unique")
+}
+
+func TestStore_Expiry(t *testing.T) {
+	store, _, advance := newTestStore(t)
+	expiresAt := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	_, token, err := store.Create("portal", []string{ScopeReportsRead}, 0, &expiresAt)
+	require.NoError(t, err)
+
+	advance(23 * time.Hour)
+	_, err = store.Authenticate(token)
+	require.NoError(t, err)
+
+	advance(time.Hour)
+	_, err = store.Authenticate(token)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestStore_RotateOverlaps(t *testing.T) {
+	store, _, advance := newTestStore(t)
+	old, oldToken, err := store.Create("portal", []string{ScopeReportsRead, ScopeBatchWrite}, 30, nil)
+	require.NoError(t, err)
+
+	advance(time.Minute)
+	key, token, err := store.Rotate(old.ID, time.Hour)
+	require.NoError(t, err)
+	assert.NotEqual(t, old.ID, key.ID)
+	assert.Equal(t, "portal", key.Name)
+	assert.Equal(t, old.Scopes, key.Scopes)
+	assert.Equal(t, 30, key.RateLimitPerMinute)
+
+	// both keys work during the overlap
+	_, err = store.Authenticate(oldToken)
+	require.NoError(t, err)
+	_, err = store.Authenticate(token)
+	require.NoError(t, err)
+
+	_, _, err = store.Rotate(old.ID, time.Hour)
+	assert.True(t, errors.IsValidationError(err), "a key is rotated once")
+
+	advance(time.Hour)
+	_, err = store.Authenticate(oldToken)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = store.Authenticate(token)
+	require.NoError(t, err)
+
+	keys := store.List()
+	require.Len(t, keys, 2)
+	assert.Equal(t, key.ID, keys[0].RotatedTo)
+
+	_, _, err = store.Rotate("missing", time.Hour)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestStore_RotateKeepsExpiry(t *testing.T) {
+	store, path, advance := newTestStore(t)
+	expiresAt := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	old, _, err := store.Create("portal", []string{ScopeReportsRead}, 30, &expiresAt)
+	require.NoError(t, err)
+
+	key, token, err := store.Rotate(old.ID, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, key.ExpiresAt)
+	assert.Equal(t, expiresAt, *key.ExpiresAt)
+	assert.Equal(t, []string{ScopeReportsRead}, key.Scopes)
+	assert.Equal(t, 30, key.RateLimitPerMinute)
+
+	reopened, err := NewStore(path)
+	require.NoError(t, err)
+	for _, stored := range reopened.List() {
+		if stored.ID == key.ID {
+			require.NotNil(t, stored.ExpiresAt)
+			assert.Equal(t, expiresAt, *stored.ExpiresAt)
+		}
+	}
+
+	// the replacement expires with the key it replaced
+	advance(24 * time.Hour)
+	_, err = store.Authenticate(token)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestStore_Revoke(t *testing.T) {
+	store, _, _ := newTestStore(t)
+	key, token, err := store.Create("portal", []string{ScopeReportsRead}, 0, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Revoke(key.ID))
+	require.NoError(t, store.Revoke(key.ID), "revoking twice is a no-op")
+
+	_, err = store.Authenticate(token)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	assert.ErrorIs(t, store.Revoke("missing"), ErrKeyNotFound)
+
+	// a revoked key frees its name
+	_, _, err = store.Create("portal", []string{ScopeReportsRead}, 0, nil)
+	assert.NoError(t, err)
+}
+
+func TestStore_PersistsAcrossRestarts(t *testing.T) {
+	store, path, _ := newTestStore(t)
+	kept, keptToken, err := store.Create("portal", []string{ScopeReportsRead}, 0, nil)
+	require.NoError(t, err)
+	revoked, revokedToken, err := store.Create("legacy", []string{ScopeReportsRead}, 0, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.Revoke(revoked.ID))
+
+	reopened, err := NewStore(path)
+	require.NoError(t, err)
+
+	key, err := reopened.Authenticate(keptToken)
+	require.NoError(t, err)
+	assert.Equal(t, kept.ID, key.ID)
+	_, err = reopened.Authenticate(revokedToken)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	assert.Len(t, reopened.List(), 2)
+}
+
+func TestNewStore_CorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-keys.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+
+	_, err := NewStore(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// leeway absorbs the clock skew between the token issuer and this service
+const leeway = 30 * time.Second
+
+// claims are the JWT claims read by the service. The identity of the caller
+// is the subject; role is one of admin, teacher or parent.
+type claims struct {
+	jwt.RegisteredClaims
+	Role       Role       `json:"role"`
+	StudentIDs stringList `json:"student_ids"`
+	Classes    stringList `json:"classes"`
+}
+
+// stringList accepts a list of strings or numbers, as issuers differ in how
+// they encode IDs
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var values []any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	list := make(stringList, 0, len(values))
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			list = append(list, v)
+		case float64:
+			list = append(list, fmt.Sprintf("%.0f", v))
+		default:
+			return fmt.Errorf("unexpected list element %v", value)
+		}
+	}
+	*l = list
+	return nil
+}
+
+// Verifier validates bearer JWTs signed with a shared HS256 secret or with
+// one of the RS256 keys of a JWKS
+type Verifier struct {
+	parser *jwt.Parser
+	secret []byte
+	keys   map[string]*rsa.PublicKey
+}
+
+// NewVerifier creates a verifier accepting tokens signed with secret (HS256)
+// or with keys (RS256), indexed by key ID. Either may be empty, not both.
+// The issuer and audience are checked when set.
+func NewVerifier(secret []byte, keys map[string]*rsa.PublicKey, issuer, audience string) (*Verifier, error) {
+	var methods []string
+	if len(secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if len(keys) > 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("a secret or a signing key is required")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	return &Verifier{
+		parser: jwt.NewParser(opts...),
+		secret: secret,
+		keys:   keys,
+	}, nil
+}
+
+// Verify checks the signature and claims of token and returns the caller it
+// identifies
+func (v *Verifier) Verify(token string) (*Principal, error) {
+	var c claims
+	if _, err := v.parser.ParseWithClaims(token, &c, v.key); err != nil {
+		return nil, err
+	}
+
+	if c.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	if !c.Role.claimable() {
+		return nil, fmt.Errorf("unknown role %q", c.Role)
+	}
+
+	return &Principal{
+		Subject:    c.Subject,
+		Role:       c.Role,
+		StudentIDs: c.StudentIDs,
+		Classes:    c.Classes,
+	}, nil
+}
+
+// key picks the key matching the token's algorithm and key ID
+func (v *Verifier) key(token *jwt.Token) (any, error) {
+	if token.Method == jwt.SigningMethodHS256 {
+		return v.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	// a JWKS with a single key does not need tokens to name it
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// LoadJWKS reads the RSA signature keys of the JSON Web Key Set at path,
+// indexed by key ID. Keys of other types or meant for encryption are skipped.
+func LoadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of key %q: %w", jwk.Kid, err)
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid exponent of key %q", jwk.Kid)
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no RSA signature keys in %s", path)
+	}
+	return keys, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":         "parent-7",
+		"role":        "parent",
+		"student_ids": []any{"42", 43},
+		"exp":         time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func signHS256(t *testing.T, secret []byte, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	require.NoError(t, err)
+	return token
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+// writeJWKS writes the public halves of keys as a JWKS and returns its path
+func writeJWKS(t *testing.T, keys map[string]*rsa.PrivateKey) string {
+	t.Helper()
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for kid, key := range keys {
+		set.Keys = append(set.Keys, map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	// keys meant for encryption are skipped
+	set.Keys = append(set.Keys, map[string]string{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"})
+
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func TestVerify_HS256(t *testing.T) {
+	verifier, err := NewVerifier(testSecret, nil, "", "")
+	require.NoError(t, err)
+
+	principal, err := verifier.Verify(signHS256(t, testSecret, validClaims()))
+
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Subject: "parent-7", Role: RoleParent, StudentIDs: []string{"42", "43"}}, principal)
+}
+
+func TestVerify_RejectsInvalidTokens(t *testing.T) {
+	verifier, err := NewVerifier(testSecret, nil, "https://idp.example.com", "student-reports")
+	require.NoError(t, err)
+
+	withClaims := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		claims := validClaims()
+		claims["iss"] = "https://idp.example.com"
+		claims["aud"] = "student-reports"
+		change(claims)
+		return claims
+	}
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{name: "wrong secret", token: signHS256(t, []byte("another secret of the same size!"), withClaims(func(jwt.MapClaims) {}))},
+		{name: "expired", token: signHS256(t, testSecret, withClaims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }))},
+		{name: "no expiry", token: signHS256(t, testSecret, withClaims(func(c jwt.MapClaims) { delete(c, "exp") }))},
+		{name: "wrong issuer", token: signHS256(t, testSecret, withClaims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }))},
+		{name: "wrong audience", token: signHS256(t, testSecret, withClaims(func(c jwt.MapClaims) { c["aud"] = "billing" }))},
+		{name: "no subject", token: signHS256(t, testSecret, withClaims(func(c jwt.MapClaims) { delete(c, "sub") }))},
+		{name: "unknown role", token: signHS256(t, testSecret, withClaims(func(c jwt.MapClaims) { c["role"] = "principal" }))},
+		{name: "unsigned", token: func() string {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodNone, withClaims(func(jwt.MapClaims) {})).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			require.NoError(t, err)
+			return token
+		}()},
+		{name: "RS256 without keys", token: signRS256(t, generateKey(t), "", withClaims(func(jwt.MapClaims) {}))},
+		{name: "malformed", token: "not.a.token"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			principal, err := verifier.Verify(tc.token)
+
+			assert.Error(t, err)
+			assert.Nil(t, principal)
+		})
+	}
+}
+
+func TestVerify_RS256FromJWKS(t *testing.T) {
+	first, second := generateKey(t), generateKey(t)
+	keys, err := LoadJWKS(writeJWKS(t, map[string]*rsa.PrivateKey{"first": first, "second": second}))
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	// tokens signed with RS256 keys are not mistaken for HS256 ones
+	verifier, err := NewVerifier(testSecret, keys, "", "")
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{"sub": "teacher-3", "role": "teacher", "classes": []string{"10"}, "exp": time.Now().Add(time.Hour).Unix()}
+	principal, err := verifier.Verify(signRS256(t, second, "second", claims))
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Subject: "teacher-3", Role: RoleTeacher, Classes: []string{"10"}}, principal)
+
+	_, err = verifier.Verify(signRS256(t, first, "second", claims))
+	assert.Error(t, err, "signed with another key than the one named")
+
+	_, err = verifier.Verify(signRS256(t, first, "", claims))
+	assert.Error(t, err, "the key must be named when the set has several")
+
+	_, err = verifier.Verify(signRS256(t, generateKey(t), "third", claims))
+	assert.Error(t, err)
+}
+
+func TestVerify_SingleKeyNeedsNoKeyID(t *testing.T) {
+	key := generateKey(t)
+	keys, err := LoadJWKS(writeJWKS(t, map[string]*rsa.PrivateKey{"only": key}))
+	require.NoError(t, err)
+	verifier, err := NewVerifier(nil, keys, "", "")
+	require.NoError(t, err)
+
+	principal, err := verifier.Verify(signRS256(t, key, "", jwt.MapClaims{"sub": "admin-1", "role": "admin", "exp": time.Now().Add(time.Hour).Unix()}))
+
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, principal.Role)
+
+	// HS256 is not accepted without a secret
+	_, err = verifier.Verify(signHS256(t, testSecret, validClaims()))
+	assert.Error(t, err)
+}
+
+func TestNewVerifier_RequiresAKey(t *testing.T) {
+	_, err := NewVerifier(nil, nil, "", "")
+	assert.Error(t, err)
+}
+
+func TestLoadJWKS_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"empty.json":     `{"keys": []}`,
+		"ec-only.json":   `{"keys": [{"kty": "EC", "kid": "ec", "crv": "P-256"}]}`,
+		"bad-n.json":     `{"keys": [{"kty": "RSA", "kid": "a", "n": "!!", "e": "AQAB"}]}`,
+		"not-json.json":  `keys`,
+		"bad-e.json":     `{"keys": [{"kty": "RSA", "kid": "a", "n": "AQAB", "e": ""}]}`,
+		"missing-n.json": `{"keys": [{"kty": "RSA", "kid": "a", "e": "AQAB"}]}`,
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+		_, err := LoadJWKS(path)
+		assert.Error(t, err, name)
+	}
+
+	_, err := LoadJWKS(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+)
+
+type Role string
+
+const (
+	// RoleAdmin may read every report
+	RoleAdmin Role = "admin"
+	// RoleTeacher may read the reports of the students in their classes
+	RoleTeacher Role = "teacher"
+	// RoleParent may read the reports of their children
+	RoleParent Role = "parent"
+	// RoleService is an integration authenticated with an API key. Its scopes
+	// decide which endpoints it may call; it may read every report.
+	RoleService Role = "service"
+)
+
+// claimable reports whether r can be claimed by a JWT. RoleService is only
+// granted to API keys.
+func (r Role) claimable() bool {
+	return r == RoleAdmin || r == RoleTeacher || r == RoleParent
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject string `json:"subject"`
+	Role    Role   `json:"role"`
+	// StudentIDs are the children of a parent
+	StudentIDs []string `json:"student_ids,omitempty"`
+	// Classes are the classes taught by a teacher
+	Classes []string `json:"classes,omitempty"`
+}
+
+// MayRequest reports whether p may ask for the report of the student before
+// the student's data is known. Only parents can be turned away this early.
+func (p *Principal) MayRequest(studentID string) bool {
+	switch p.Role {
+	case RoleAdmin, RoleTeacher, RoleService:
+		return true
+	case RoleParent:
+		return slices.Contains(p.StudentIDs, studentID)
+	default:
+		return false
+	}
+}
+
+// MayRead reports whether p may read the report of the student. student may
+// be nil when its data is not available, in which case only the grants that
+// do not depend on it apply.
+func (p *Principal) MayRead(studentID string, student *dto.Student) bool {
+	switch p.Role {
+	case RoleAdmin, RoleService:
+		return true
+	case RoleParent:
+		return slices.Contains(p.StudentIDs, studentID)
+	case RoleTeacher:
+		return student != nil && student.Class != "" && slices.Contains(p.Classes, student.Class)
+	default:
+		return false
+	}
+}
+
+// MayListClass reports whether p may list the students of a class, or of
+// every class when class is empty. Listing reveals the class roster, so
+// teachers are limited to their own classes and parents may not list any.
+func (p *Principal) MayListClass(class string) bool {
+	switch p.Role {
+	case RoleAdmin, RoleService:
+		return true
+	case RoleTeacher:
+		return class != "" && slices.Contains(p.Classes, class)
+	default:
+		return false
+	}
+}
+
+// Owns reports whether p may see a resource created by owner. Resources
+// without an owner were created while authentication was disabled and are
+// left to admins.
+func (p *Principal) Owns(owner *Principal) bool {
+	if p.Role == RoleAdmin {
+		return true
+	}
+	return owner != nil && owner.Subject == p.Subject
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the principal
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal carried by ctx. Requests made by the
+// service itself (cache warming, stale refreshes) and every request while
+// authentication is disabled carry none.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+)
+
+func TestPrincipal_MayRead(t *testing.T) {
+	student := &dto.Student{ID: 42, Class: "10"}
+	unclassed := &dto.Student{ID: 44}
+
+	admin := &Principal{Subject: "a", Role: RoleAdmin}
+	teacher := &Principal{Subject: "t", Role: RoleTeacher, Classes: []string{"10"}}
+	parent := &Principal{Subject: "p", Role: RoleParent, StudentIDs: []string{"42"}}
+	unknown := &Principal{Subject: "u", Role: "janitor"}
+
+	testCases := []struct {
+		name      string
+		principal *Principal
+		studentID string
+		student   *dto.Student
+		expected  bool
+	}{
+		{name: "admin", principal: admin, studentID: "42", student: student, expected: true},
+		{name: "admin without data", principal: admin, studentID: "42", expected: true},
+		{name: "teacher of the class", principal: teacher, studentID: "42", student: student, expected: true},
+		{name: "teacher without data", principal: teacher, studentID: "42"},
+		{name: "teacher, student without class", principal: teacher, studentID: "44", student: unclassed},
+		{name: "parent of the student", principal: parent, studentID: "42", student: student, expected: true},
+		{name: "parent without data", principal: parent, studentID: "42", expected: true},
+		{name: "parent of another student", principal: parent, studentID: "43"},
+		{name: "unknown role", principal: unknown, studentID: "42", student: student},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.principal.MayRead(tc.studentID, tc.student))
+		})
+	}
+
+	assert.True(t, teacher.MayRequest("43"), "a teacher's access is decided on the data")
+	assert.False(t, parent.MayRequest("43"))
+	assert.False(t, unknown.MayRequest("42"))
+}
+
+func TestPrincipal_MayListClass(t *testing.T) {
+	teacher := &Principal{Subject: "t", Role: RoleTeacher, Classes: []string{"10"}}
+	parent := &Principal{Subject: "p", Role: RoleParent, StudentIDs: []string{"42"}}
+
+	assert.True(t, (&Principal{Subject: "a", Role: RoleAdmin}).MayListClass(""))
+	assert.True(t, (&Principal{Subject: "s", Role: RoleService}).MayListClass("11"))
+	assert.True(t, teacher.MayListClass("10"))
+	assert.False(t, teacher.MayListClass("11"))
+	assert.False(t, teacher.MayListClass(""), "a section spans the classes")
+	assert.False(t, parent.MayListClass("10"))
+}
+
+func TestPrincipal_Owns(t *testing.T) {
+	owner := &Principal{Subject: "t", Role: RoleTeacher}
+
+	assert.True(t, (&Principal{Subject: "t", Role: RoleTeacher}).Owns(owner))
+	assert.False(t, (&Principal{Subject: "p", Role: RoleParent}).Owns(owner))
+	assert.False(t, owner.Owns(nil))
+	assert.True(t, (&Principal{Subject: "a", Role: RoleAdmin}).Owns(owner))
+	assert.True(t, (&Principal{Subject: "a", Role: RoleAdmin}).Owns(nil))
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	principal := &Principal{Subject: "a", Role: RoleAdmin}
+	found, ok := FromContext(NewContext(context.Background(), principal))
+	assert.True(t, ok)
+	assert.Same(t, principal, found)
+}
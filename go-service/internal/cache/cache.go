@@ -1,6 +1,61 @@
 package cache
 
+import "time"
+
+// PDFCache stores rendered reports per student. Storing a new content
+// version of a student's report drops the older versions, while the variants
+// of the same content (see VariantKey) are kept side by side.
 type PDFCache interface {
 	Get(studentID, hash string) ([]byte, bool)
 	Set(studentID string, data []byte, hash string) error
+
+	// Delete removes every cached report of a student
+	Delete(studentID string) error
+	// Purge removes every cached report
+	Purge() error
+	// Entries lists the cached reports that have not expired
+	Entries() ([]Entry, error)
+	// LatestVersion returns the content version (see VariantKey) of the
+	// reports cached for a student. It is read from the cache itself, so it
+	// survives restarts and is shared by the replicas using a shared cache.
+	LatestVersion(studentID string) (string, bool)
+
+	StatsProvider
+}
+
+// Entry describes a cached report
+type Entry struct {
+	StudentID string
+	Hash      string
+	Size      int64
+	ExpiresAt time.Time
+}
+
+// Cache backends selectable with CACHE_BACKEND
+const (
+	BackendFile   = "file"
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+	BackendS3     = "s3"
+)
+
+// Stats describes the contents of a cache and its activity since startup.
+// Evictions count entries removed to stay within the size limits,
+// expirations those removed because their TTL ran out. The activity of a
+// cache shared by several replicas is counted per replica, and its contents
+// are not listed, since Stats is read on every health check and scrape:
+// Shared is set and Entries and Bytes are left zero.
+type Stats struct {
+	Shared      bool
+	Entries     int
+	Bytes       int64
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+}
+
+// StatsProvider is implemented by caches that report Stats
+type StatsProvider interface {
+	Stats() Stats
 }
@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cacheBackend creates a cache with the given TTL for the conformance suite.
+// elapse advances the time seen by the cache past d.
+type cacheBackend func(t *testing.T, ttl time.Duration) (cache PDFCache, elapse func(d time.Duration))
+
+// sleepElapse is the elapse function of backends that read the system clock
+func sleepElapse(d time.Duration) {
+	time.Sleep(d)
+}
+
+// testPDFCacheConformance checks the behaviour every PDFCache backend shares
+func testPDFCacheConformance(t *testing.T, newCache cacheBackend) {
+	t.Run("miss", func(t *testing.T) {
+		cache, _ := newCache(t, time.Hour)
+
+		data, found := cache.Get("1", "abc")
+
+		assert.False(t, found)
+		assert.Nil(t, data)
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		cache, _ := newCache(t, time.Hour)
+		require.NoError(t, cache.Set("1", []byte("%PDF-1.4 report"), "abc.default.pdf"))
+
+		data, found := cache.Get("1", "abc.default.pdf")
+
+		assert.True(t, found)
+		assert.Equal(t, []byte("%PDF-1.4 report"), data)
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		cache, _ := newCache(t, time.Hour)
+		require.NoError(t, cache.Set("1", []byte("first"), "abc"))
+		require.NoError(t, cache.Set("1", []byte("second"), "abc"))
+
+		data, found := cache.Get("1", "abc")
+
+		assert.True(t, found)
+		assert.Equal(t, []byte("second"), data)
+	})
+
+	t.Run("students are isolated", func(t *testing.T) {
+		cache, _ := newCache(t, time.Hour)
+		require.NoError(t, cache.Set("1", []byte("one"), "abc"))
+		require.NoError(t, cache.Set("12", []byte("twelve"), "def"))
+
+		one, found := cache.Get("1", "abc")
+		require.True(t, found)
+		twelve, found := cache.Get("12", "def")
+		require.True(t, found)
+		_, found = cache.Get("12", "abc")
+
+		assert.Equal(t, []byte("one"), one)
+		assert.Equal(t, []byte("twelve"), twelve)
+		assert.False(t, found)
+	})
+
+	t.Run("new version replaces old versions", func(t *testing.T) {
+		cache, _ := newCache(t, time.Hour)
+		require.NoError(t, cache.Set("1", []byte("old pdf"), VariantKey("v1", "pdf")))
+		require.NoError(t, cache.Set("1", []byte("old html"), VariantKey("v1", "html")))
+		require.NoError(t, cache.Set("1", []byte("new pdf"), VariantKey("v2", "pdf")))
+
+		_, oldPDF := cache.Get("1", VariantKey("v1", "pdf"))
+		_, oldHTML := cache.Get("1", VariantKey("v1", "html"))
+		data, found := cache.Get("1", VariantKey("v2", "pdf"))
+
+		assert.False(t, oldPDF)
+		assert.False(t, oldHTML)
+		assert.True(t, found)
+		assert.Equal(t, []byte("new pdf"), data)
+	})
+
+	t.Run("variants of the same version are kept", func(t *testing.T) {
+		cache, _ := newCache(t, time.Hour)
+		require.NoError(t, cache.Set("1", []byte("pdf"), VariantKey("v1", "pdf")))
+		require.NoError(t, cache.Set("1", []byte("html"), VariantKey("v1", "html")))
+
+		pdf, pdfFound := cache.Get("1", VariantKey("v1", "pdf"))
+		html, htmlFound := cache.Get("1", VariantKey("v1", "html"))
+
+		assert.True(t, pdfFound)
+		assert.True(t, htmlFound)
+		assert.Equal(t, []byte("pdf"), pdf)
+		assert.Equal(t, []byte("html"), html)
+	})
+
+	t.Run("latest version", func(t *testing.T) {
+		cache, _ := newCache(t, time.Hour)
+		_, found := cache.LatestVersion("1")
+		require.False(t, found)
+
+		require.NoError(t, cache.Set("1", []byte("old pdf"), VariantKey("v1", "pdf")))
+		require.NoError(t, cache.Set("1", []byte("old html"), VariantKey("v1", "html")))
+		old, oldFound := cache.LatestVersion("1")
+		require.NoError(t, cache.Set("1", []byte("new pdf"), VariantKey("v2", "pdf")))
+		require.NoError(t, cache.Set("12", []byte("twelve"), VariantKey("v3", "pdf")))
+		latest, latestFound := cache.LatestVersion("1")
+
+		assert.True(t, oldFound)
+		assert.Equal(t, "v1", old)
+		assert.True(t, latestFound)
+		assert.Equal(t, "v2", latest)
+	})
+
+	t.Run("latest version forgotten", func(t *testing.T) {
+		cache, elapse := newCache(t, 50*time.Millisecond)
+		require.NoError(t, cache.Set("1", []byte("one"), VariantKey("v1", "pdf")))
+		require.NoError(t, cache.Set("2", []byte("two"), VariantKey("v2", "pdf")))
+		require.NoError(t, cache.Delete("1"))
+
+		_, deleted := cache.LatestVersion("1")
+		_, live := cache.LatestVersion("2")
+		elapse(100 * time.Millisecond)
+		_, expired := cache.LatestVersion("2")
+
+		assert.False(t, deleted)
+		assert.True(t, live)
+		assert.False(t, expired)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		cache, elapse := newCache(t, 50*time.Millisecond)
+		require.NoError(t, cache.Set("1", []byte("report"), "abc"))
+
+		_, found := cache.Get("1", "abc")
+		require.True(t, found)
+
+		elapse(100 * time.Millisecond)
+		_, found = cache.Get("1", "abc")
+		assert.False(t, found)
+	})
+
+	t.Run("returned data is not shared", func(t *testing.T) {
+		cache, _ := newCache(t, time.Hour)
+		input := []byte("report")
+		require.NoError(t, cache.Set("1", input, "abc"))
+		input[0] = 'X'
+
+		data, found := cache.Get("1", "abc")
+		require.True(t, found)
+		data[1] = 'X'
+
+		again, _ := cache.Get("1", "abc")
+		assert.Equal(t, []byte("report"), again)
+	})
+
+	t.Run("delete student", func(t *testing.T) {
+		cache, _ := newCache(t, time.Hour)
+		require.NoError(t, cache.Set("1", []byte("pdf"), VariantKey("v1", "pdf")))
+		require.NoError(t, cache.Set("1", []byte("html"), VariantKey("v1", "html")))
+		require.NoError(t, cache.Set("12", []byte("twelve"), "def"))
+
+		require.NoError(t, cache.Delete("1"))
+
+		_, pdfFound := cache.Get("1", VariantKey("v1", "pdf"))
+		_, htmlFound := cache.Get("1", VariantKey("v1", "html"))
+		_, otherFound := cache.Get("12", "def")
+		assert.False(t, pdfFound)
+		assert.False(t, htmlFound)
+		assert.True(t, otherFound)
+		assert.NoError(t, cache.Delete("99"))
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		cache, _ := newCache(t, time.Hour)
+		require.NoError(t, cache.Set("1", []byte("one"), "abc"))
+		require.NoError(t, cache.Set("12", []byte("twelve"), "def"))
+
+		require.NoError(t, cache.Purge())
+
+		_, oneFound := cache.Get("1", "abc")
+		_, twelveFound := cache.Get("12", "def")
+		assert.False(t, oneFound)
+		assert.False(t, twelveFound)
+		entries, err := cache.Entries()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("entries", func(t *testing.T) {
+		cache, _ := newCache(t, time.Hour)
+		before := time.Now()
+		require.NoError(t, cache.Set("1", []byte("one"), "abc"))
+		require.NoError(t, cache.Set("12", []byte("twelve"), "def"))
+
+		entries, err := cache.Entries()
+
+		require.NoError(t, err)
+		sort.Slice(entries, func(i, j int) bool { return entries[i].StudentID < entries[j].StudentID })
+		require.Len(t, entries, 2)
+		assert.Equal(t, "1", entries[0].StudentID)
+		assert.Equal(t, "abc", entries[0].Hash)
+		assert.Equal(t, int64(3), entries[0].Size)
+		assert.Equal(t, "12", entries[1].StudentID)
+		assert.Equal(t, "def", entries[1].Hash)
+		assert.Equal(t, int64(6), entries[1].Size)
+		for _, entry := range entries {
+			assert.WithinDuration(t, before.Add(time.Hour), entry.ExpiresAt, time.Minute)
+		}
+	})
+
+	t.Run("expired entries are not listed", func(t *testing.T) {
+		cache, elapse := newCache(t, 50*time.Millisecond)
+		require.NoError(t, cache.Set("1", []byte("report"), "abc"))
+
+		elapse(100 * time.Millisecond)
+		entries, err := cache.Entries()
+
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		cache, _ := newCache(t, time.Hour)
+		require.NoError(t, cache.Set("1", []byte("one"), "abc"))
+		require.NoError(t, cache.Set("12", []byte("twelve"), "def"))
+		cache.Get("1", "abc")
+		cache.Get("1", "missing")
+
+		stats := cache.Stats()
+
+		// shared caches are not listed for their statistics
+		if stats.Shared {
+			assert.Zero(t, stats.Entries)
+			assert.Zero(t, stats.Bytes)
+		} else {
+			assert.Equal(t, 2, stats.Entries)
+			assert.Equal(t, int64(9), stats.Bytes)
+		}
+		assert.Equal(t, uint64(1), stats.Hits)
+		assert.Equal(t, uint64(1), stats.Misses)
+	})
+}
+
+func TestFileCache_Conformance(t *testing.T) {
+	testPDFCacheConformance(t, func(t *testing.T, ttl time.Duration) (PDFCache, func(time.Duration)) {
+		cache, err := NewFileCache(t.TempDir(), ttl, FileCacheLimits{})
+		require.NoError(t, err)
+		return cache, sleepElapse
+	})
+}
@@ -3,43 +3,117 @@ package cache
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/fsutil"
 )
 
+// metadataSuffix names the sidecar file holding the metadata of a cached
+// report. The sidecar is written after the report, so a report without one
+// was never completely stored.
+const metadataSuffix = ".meta.json"
+
+// reportSuffix ends the name of a cached report. The cache holds every
+// format, so the suffix does not name one.
+const reportSuffix = ".report"
+
+// Eviction policies of a size-limited FileCache
+const (
+	EvictLRU = "lru" // least recently used
+	EvictLFU = "lfu" // least frequently used, ties broken by recency
+)
+
+// FileCacheLimits bounds the size of a FileCache. Zero values mean no limit.
+type FileCacheLimits struct {
+	MaxBytes   int64
+	MaxEntries int
+	Policy     string // EvictLRU (default) or EvictLFU
+}
+
+// FileCache stores reports on disk. Each report has a sidecar metadata file,
+// so the index is rebuilt from disk on startup and cached reports survive
+// restarts. Once the limits are exceeded, entries are evicted according to
+// the eviction policy.
 type FileCache struct {
 	ttl      time.Duration
 	basePath string
-	data     map[string]CacheEntry // In-memory data (id:hash → CacheEntry with file path)
+	limits   FileCacheLimits
+	data     map[string]*CacheEntry // In-memory data (id:hash → CacheEntry with file path)
+	size     int64                  // total bytes of the indexed reports
 	mu       sync.RWMutex
+
+	// studentLocks serialise the writes of a student's reports, which take
+	// place outside mu, so that an older version never replaces the files
+	// and index entries of a newer one
+	studentLocks [64]sync.Mutex
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
 }
 
 type CacheEntry struct {
 	FilePath  string
 	ExpiresAt time.Time
+	Size      int64
+
+	// usage, updated by Get while holding only the read lock
+	lastAccess atomic.Int64 // UnixNano
+	hits       atomic.Uint64
+}
+
+func newCacheEntry(filePath string, expiresAt time.Time, size int64, lastAccess time.Time) *CacheEntry {
+	entry := &CacheEntry{FilePath: filePath, ExpiresAt: expiresAt, Size: size}
+	entry.lastAccess.Store(lastAccess.UnixNano())
+	return entry
+}
+
+// cacheMetadata is the content of a sidecar file
+type cacheMetadata struct {
+	StudentID string    `json:"student_id"`
+	Hash      string    `json:"hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"checksum"` // SHA-256 of the report
 }
 
-func NewFileCache(basePath string, ttl time.Duration) (*FileCache, error) {
+func NewFileCache(basePath string, ttl time.Duration, limits FileCacheLimits) (*FileCache, error) {
+	if limits.MaxBytes < 0 || limits.MaxEntries < 0 {
+		return nil, fmt.Errorf("cache limits must not be negative")
+	}
+	switch limits.Policy {
+	case "":
+		limits.Policy = EvictLRU
+	case EvictLRU, EvictLFU:
+	default:
+		return nil, fmt.Errorf("unknown eviction policy %q", limits.Policy)
+	}
+
 	// Ensure cache directory exists
 	if err := os.MkdirAll(basePath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	// Clean up any existing PDF files from previous runs
-	if err := cleanupDirectory(basePath); err != nil {
-		return nil, fmt.Errorf("failed to cleanup cache directory: %w", err)
-	}
-
 	fc := &FileCache{
-		data:     make(map[string]CacheEntry),
+		data:     make(map[string]*CacheEntry),
 		basePath: basePath,
 		ttl:      ttl,
+		limits:   limits,
+	}
+
+	// Pick up the reports cached before the last restart
+	if err := fc.loadIndex(); err != nil {
+		return nil, fmt.Errorf("failed to load cache index: %w", err)
 	}
 
 	fc.startCleanupWorker()
@@ -47,26 +121,119 @@ func NewFileCache(basePath string, ttl time.Duration) (*FileCache, error) {
 	return fc, nil
 }
 
-func cleanupDirectory(dirPath string) error {
-	entries, err := os.ReadDir(dirPath)
+// loadIndex indexes the valid reports found in the cache directory. Expired
+// and corrupted reports, reports without metadata and interrupted writes are
+// removed.
+func (c *FileCache) loadIndex() error {
+	entries, err := os.ReadDir(c.basePath)
 	if err != nil {
 		return err
 	}
 
+	now := time.Now()
+	indexed := make(map[string]bool)
+	var loaded []*cacheMetadata
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), metadataSuffix) {
 			continue
 		}
 
-		filePath := filepath.Join(dirPath, entry.Name())
-		if err := os.Remove(filePath); err != nil {
+		metadataPath := filepath.Join(c.basePath, entry.Name())
+		filePath := strings.TrimSuffix(metadataPath, metadataSuffix)
+		metadata, err := readMetadata(metadataPath)
+		if err != nil || now.After(metadata.ExpiresAt) || !matchesMetadata(filePath, metadata) {
+			removeCacheFiles(filePath)
 			continue
 		}
+
+		// the modification time approximates the last use before the restart
+		lastAccess := now
+		if info, err := os.Stat(filePath); err == nil {
+			lastAccess = info.ModTime()
+		}
+		c.data[cacheKey(metadata.StudentID, metadata.Hash)] = newCacheEntry(filePath, metadata.ExpiresAt, metadata.Size, lastAccess)
+		indexed[filepath.Base(filePath)] = true
+		indexed[entry.Name()] = true
+		loaded = append(loaded, metadata)
+	}
+
+	// Clean up everything else, e.g. reports whose metadata was never written
+	for _, entry := range entries {
+		if !entry.IsDir() && !indexed[entry.Name()] {
+			os.Remove(filepath.Join(c.basePath, entry.Name()))
+		}
+	}
+
+	// A crash between storing a new version and removing the old one leaves
+	// both behind; the most recently stored version wins
+	latest := make(map[string]*cacheMetadata)
+	for _, metadata := range loaded {
+		if current, ok := latest[metadata.StudentID]; !ok || metadata.ExpiresAt.After(current.ExpiresAt) {
+			latest[metadata.StudentID] = metadata
+		}
+	}
+	for _, metadata := range loaded {
+		if contentVersion(metadata.Hash) != contentVersion(latest[metadata.StudentID].Hash) {
+			key := cacheKey(metadata.StudentID, metadata.Hash)
+			removeCacheFiles(c.data[key].FilePath)
+			delete(c.data, key)
+		}
 	}
 
+	for _, entry := range c.data {
+		c.size += entry.Size
+	}
+	// the limits may have been lowered since the last run
+	c.evict("")
+
 	return nil
 }
 
+func readMetadata(path string) (*cacheMetadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata cacheMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// matchesMetadata reports whether the report at path has the size and
+// checksum recorded in its metadata
+func matchesMetadata(path string, metadata *cacheMetadata) bool {
+	data, err := os.ReadFile(path)
+	if err != nil || int64(len(data)) != metadata.Size {
+		return false
+	}
+	return checksum(data) == metadata.Checksum
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// removeCacheFiles removes a cached report and its metadata
+func removeCacheFiles(filePath string) {
+	os.Remove(filePath)
+	os.Remove(filePath + metadataSuffix)
+}
+
+// reportFileName names the file of a cached report. Reports cached before
+// reportSuffix was introduced end in ".pdf" and are still loaded, as the
+// index is built from the metadata files.
+func reportFileName(studentID, hash string) string {
+	return fmt.Sprintf("student_%s_%s%s", studentID, hash, reportSuffix)
+}
+
+func cacheKey(studentID, hash string) string {
+	return fmt.Sprintf("%s:%s", studentID, hash)
+}
+
 func GenerateStudentHash(student *dto.Student) string {
 	data := fmt.Sprintf("%v:%v:%v:%v:%v",
 		student.Name,
@@ -80,58 +247,254 @@ func GenerateStudentHash(student *dto.Student) string {
 	return hex.EncodeToString(hash[:])[:16]
 }
 
+// VariantKey derives the cache hash of one rendering of the student content,
+// e.g. VariantKey(contentHash, "default-1a2b3c4d"). Every variant of the same
+// content shares the part before the first ".".
+func VariantKey(contentHash string, variants ...string) string {
+	return strings.Join(append([]string{contentHash}, variants...), ".")
+}
+
+func contentVersion(hash string) string {
+	return strings.SplitN(hash, ".", 2)[0]
+}
+
+// LatestVersion returns the content version of the student's entries, which
+// all share one since Set drops the older versions. The index is scanned, as
+// it is only needed while the backend is unreachable.
+func (c *FileCache) LatestVersion(studentID string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	prefix := cacheKey(studentID, "")
+	for key, entry := range c.data {
+		if strings.HasPrefix(key, prefix) && !now.After(entry.ExpiresAt) {
+			return contentVersion(strings.TrimPrefix(key, prefix)), true
+		}
+	}
+	return "", false
+}
+
 func (c *FileCache) Get(studentID, hash string) ([]byte, bool) {
 	c.mu.RLock()
-	key := fmt.Sprintf("%s:%s", studentID, hash)
+	key := cacheKey(studentID, hash)
 	entry, exists := c.data[key]
 	c.mu.RUnlock()
 
-	if !exists || time.Now().After(entry.ExpiresAt) {
+	now := time.Now()
+	if !exists || now.After(entry.ExpiresAt) {
+		c.misses.Add(1)
 		return nil, false
 	}
 
 	// Read file from disk
 	data, err := os.ReadFile(entry.FilePath)
 	if err != nil {
-		// File missing, clean up index
+		// File missing, clean up index unless the entry was replaced meanwhile
 		c.mu.Lock()
-		delete(c.data, key)
+		if c.data[key] == entry {
+			c.removeEntry(key, entry)
+		}
 		c.mu.Unlock()
+		c.misses.Add(1)
 		return nil, false
 	}
 
+	entry.lastAccess.Store(now.UnixNano())
+	entry.hits.Add(1)
+	c.hits.Add(1)
 	return data, true
 }
 
+// Set stores data unless it alone exceeds the byte limit, in which case the
+// report is simply not cached
 func (c *FileCache) Set(studentID string, data []byte, hash string) error {
-	key := fmt.Sprintf("%s:%s", studentID, hash)
-	filename := fmt.Sprintf("student_%s_%s.pdf", studentID, hash)
-	filePath := filepath.Join(c.basePath, filename)
+	if c.limits.MaxBytes > 0 && int64(len(data)) > c.limits.MaxBytes {
+		return fmt.Errorf("report of %d bytes exceeds the cache size of %d bytes", len(data), c.limits.MaxBytes)
+	}
+
+	studentLock := c.studentLock(studentID)
+	studentLock.Lock()
+	defer studentLock.Unlock()
+
+	key := cacheKey(studentID, hash)
+	filePath := filepath.Join(c.basePath, reportFileName(studentID, hash))
+	expiresAt := time.Now().Add(c.ttl)
 
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	metadata, err := json.Marshal(cacheMetadata{
+		StudentID: studentID,
+		Hash:      hash,
+		ExpiresAt: expiresAt,
+		Size:      int64(len(data)),
+		Checksum:  checksum(data),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache metadata: %w", err)
+	}
+
+	// The metadata is written last: until it is in place the report is not
+	// picked up after a restart
+	if err := fsutil.WriteFileAtomic(filePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write cache file: %w", err)
 	}
+	if err := fsutil.WriteFileAtomic(filePath+metadataSuffix, metadata, 0644); err != nil {
+		os.Remove(filePath)
+		return fmt.Errorf("failed to write cache metadata: %w", err)
+	}
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Clean old versions of same student
+	// Clean old versions of same student, keeping the other variants of the
+	// current content
+	prefix := studentID + ":"
+	version := contentVersion(hash)
 	for k, entry := range c.data {
 		// Parse studentID from the key (format: "studentID:hash")
-		if strings.HasPrefix(k, studentID+":") && k != key {
-			os.Remove(entry.FilePath) // Clean old file
-			delete(c.data, k)
+		if strings.HasPrefix(k, prefix) && contentVersion(strings.TrimPrefix(k, prefix)) != version {
+			removeCacheFiles(entry.FilePath) // Clean old file
+			c.removeEntry(k, entry)
+		}
+	}
+
+	// the files of a replaced entry were overwritten in place, unless they
+	// were stored under an older naming scheme
+	if previous, exists := c.data[key]; exists {
+		if previous.FilePath != filePath {
+			removeCacheFiles(previous.FilePath)
+		}
+		c.removeEntry(key, previous)
+	}
+	c.data[key] = newCacheEntry(filePath, expiresAt, int64(len(data)), time.Now())
+	c.size += int64(len(data))
+
+	c.evict(key)
+
+	return nil
+}
+
+// studentLock returns the lock serialising the writes of the student
+func (c *FileCache) studentLock(studentID string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(studentID))
+	return &c.studentLocks[h.Sum32()%uint32(len(c.studentLocks))]
+}
+
+// evict removes entries according to the eviction policy until the cache is
+// within its limits. The entry under keep, usually the one just stored, is
+// never evicted. Callers must hold the write lock.
+func (c *FileCache) evict(keep string) {
+	for c.overLimits() {
+		victimKey, victim := c.selectVictim(keep)
+		if victim == nil {
+			return
+		}
+		removeCacheFiles(victim.FilePath)
+		c.removeEntry(victimKey, victim)
+		c.evictions.Add(1)
+	}
+}
+
+func (c *FileCache) overLimits() bool {
+	return (c.limits.MaxBytes > 0 && c.size > c.limits.MaxBytes) ||
+		(c.limits.MaxEntries > 0 && len(c.data) > c.limits.MaxEntries)
+}
+
+// selectVictim scans the index for the entry to evict next. The scan is
+// linear, which is cheap next to the disk I/O of an eviction.
+func (c *FileCache) selectVictim(keep string) (string, *CacheEntry) {
+	var victimKey string
+	var victim *CacheEntry
+	for key, entry := range c.data {
+		if key == keep {
+			continue
+		}
+		if victim == nil || c.evictsBefore(entry, victim) {
+			victimKey, victim = key, entry
+		}
+	}
+	return victimKey, victim
+}
+
+func (c *FileCache) evictsBefore(a, b *CacheEntry) bool {
+	if c.limits.Policy == EvictLFU {
+		if aHits, bHits := a.hits.Load(), b.hits.Load(); aHits != bHits {
+			return aHits < bHits
 		}
 	}
+	return a.lastAccess.Load() < b.lastAccess.Load()
+}
+
+// removeEntry drops an entry from the index. Callers must hold the write lock.
+func (c *FileCache) removeEntry(key string, entry *CacheEntry) {
+	delete(c.data, key)
+	c.size -= entry.Size
+}
+
+// Stats returns the current size of the cache and its activity since startup
+func (c *FileCache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return Stats{
+		Entries:     len(c.data),
+		Bytes:       c.size,
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+	}
+}
+
+// Delete removes every cached report of a student
+func (c *FileCache) Delete(studentID string) error {
+	// a report being written when the student is invalidated is dropped too
+	studentLock := c.studentLock(studentID)
+	studentLock.Lock()
+	defer studentLock.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	c.data[key] = CacheEntry{
-		FilePath:  filePath,
-		ExpiresAt: time.Now().Add(c.ttl),
+	prefix := studentID + ":"
+	for key, entry := range c.data {
+		if strings.HasPrefix(key, prefix) {
+			removeCacheFiles(entry.FilePath)
+			c.removeEntry(key, entry)
+		}
 	}
+	return nil
+}
+
+// Purge removes every cached report
+func (c *FileCache) Purge() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
+	for key, entry := range c.data {
+		removeCacheFiles(entry.FilePath)
+		c.removeEntry(key, entry)
+	}
 	return nil
 }
 
+// Entries lists the cached reports that have not expired
+func (c *FileCache) Entries() ([]Entry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	entries := make([]Entry, 0, len(c.data))
+	for key, entry := range c.data {
+		if now.After(entry.ExpiresAt) {
+			continue
+		}
+		studentID, hash, _ := strings.Cut(key, ":")
+		entries = append(entries, Entry{StudentID: studentID, Hash: hash, Size: entry.Size, ExpiresAt: entry.ExpiresAt})
+	}
+	return entries, nil
+}
+
 func (c *FileCache) startCleanupWorker() {
 	ticker := time.NewTicker(time.Minute)
 
@@ -149,8 +512,9 @@ func (c *FileCache) removeExpiredFiles() {
 	now := time.Now()
 	for key, entry := range c.data {
 		if now.After(entry.ExpiresAt) {
-			os.Remove(entry.FilePath) // Remove file from disk
-			delete(c.data, key)       // Remove from index
+			removeCacheFiles(entry.FilePath) // Remove files from disk
+			c.removeEntry(key, entry)        // Remove from index
+			c.expirations.Add(1)
 		}
 	}
 }
@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
@@ -11,6 +13,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/fsutil"
 )
 
 func TestNewFileCache_Success(t *testing.T) {
@@ -19,7 +22,7 @@ func TestNewFileCache_Success(t *testing.T) {
 	ttl := 1 * time.Hour
 
 	// Execute
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 
 	// Assert
 	require.NoError(t, err)
@@ -39,7 +42,7 @@ func TestNewFileCache_CreateDirectory(t *testing.T) {
 	require.True(t, os.IsNotExist(err))
 
 	// Execute
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 
 	// Assert
 	require.NoError(t, err)
@@ -69,7 +72,7 @@ func TestNewFileCache_CleanupExistingFiles(t *testing.T) {
 	require.NoError(t, err)
 
 	// Execute
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 
 	// Assert
 	require.NoError(t, err)
@@ -125,7 +128,7 @@ func TestFileCache_SetAndGet_Success(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
 	ttl := 1 * time.Hour
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 	require.NoError(t, err)
 
 	studentID := "12345"
@@ -144,7 +147,7 @@ func TestFileCache_SetAndGet_Success(t *testing.T) {
 	assert.Equal(t, pdfData, retrievedData)
 
 	// Verify file exists on disk
-	expectedFile := filepath.Join(tempDir, "student_12345_abcd1234.pdf")
+	expectedFile := filepath.Join(tempDir, reportFileName("12345", "abcd1234"))
 	_, err = os.Stat(expectedFile)
 	assert.NoError(t, err)
 }
@@ -153,7 +156,7 @@ func TestFileCache_Get_NotFound(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
 	ttl := 1 * time.Hour
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 	require.NoError(t, err)
 
 	// Execute
@@ -168,7 +171,7 @@ func TestFileCache_Get_Expired(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
 	ttl := 100 * time.Millisecond // Short TTL for testing
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 	require.NoError(t, err)
 
 	studentID := "12345"
@@ -194,7 +197,7 @@ func TestFileCache_Get_FileMissing(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
 	ttl := 1 * time.Hour
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 	require.NoError(t, err)
 
 	studentID := "12345"
@@ -206,7 +209,7 @@ func TestFileCache_Get_FileMissing(t *testing.T) {
 	require.NoError(t, err)
 
 	// Manually delete the file
-	expectedFile := filepath.Join(tempDir, "student_12345_abcd1234.pdf")
+	expectedFile := filepath.Join(tempDir, reportFileName("12345", "abcd1234"))
 	err = os.Remove(expectedFile)
 	require.NoError(t, err)
 
@@ -228,7 +231,7 @@ func TestFileCache_Set_OverwriteOldVersion(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
 	ttl := 1 * time.Hour
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 	require.NoError(t, err)
 
 	studentID := "12345"
@@ -241,7 +244,7 @@ func TestFileCache_Set_OverwriteOldVersion(t *testing.T) {
 	err = cache.Set(studentID, oldData, oldHash)
 	require.NoError(t, err)
 
-	oldFile := filepath.Join(tempDir, "student_12345_oldhash.pdf")
+	oldFile := filepath.Join(tempDir, reportFileName("12345", "oldhash"))
 	_, err = os.Stat(oldFile)
 	require.NoError(t, err)
 
@@ -254,7 +257,7 @@ func TestFileCache_Set_OverwriteOldVersion(t *testing.T) {
 	assert.True(t, os.IsNotExist(err))
 
 	// Assert new version exists
-	newFile := filepath.Join(tempDir, "student_12345_newhash.pdf")
+	newFile := filepath.Join(tempDir, reportFileName("12345", "newhash"))
 	_, err = os.Stat(newFile)
 	assert.NoError(t, err)
 
@@ -269,11 +272,39 @@ func TestFileCache_Set_OverwriteOldVersion(t *testing.T) {
 	assert.Equal(t, newData, data)
 }
 
+func TestFileCache_Set_KeepsVariantsOfCurrentContent(t *testing.T) {
+	// Setup
+	tempDir := t.TempDir()
+	cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{})
+	require.NoError(t, err)
+
+	studentID := "12345"
+	oldHash := VariantKey("oldhash", "default-aaaa")
+	defaultHash := VariantKey("newhash", "default-aaaa")
+	brandedHash := VariantKey("newhash", "branded-bbbb")
+
+	require.NoError(t, cache.Set(studentID, []byte("old"), oldHash))
+	require.NoError(t, cache.Set(studentID, []byte("default"), defaultHash))
+	require.NoError(t, cache.Set(studentID, []byte("branded"), brandedHash))
+
+	// Assert the outdated content is gone while both variants remain
+	_, found := cache.Get(studentID, oldHash)
+	assert.False(t, found)
+
+	data, found := cache.Get(studentID, defaultHash)
+	assert.True(t, found)
+	assert.Equal(t, []byte("default"), data)
+
+	data, found = cache.Get(studentID, brandedHash)
+	assert.True(t, found)
+	assert.Equal(t, []byte("branded"), data)
+}
+
 func TestFileCache_Set_MultipleStudents(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
 	ttl := 1 * time.Hour
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 	require.NoError(t, err)
 
 	// Set data for multiple students
@@ -305,7 +336,7 @@ func TestFileCache_RemoveExpiredFiles(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
 	ttl := 100 * time.Millisecond
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 	require.NoError(t, err)
 
 	// Add entries
@@ -313,8 +344,8 @@ func TestFileCache_RemoveExpiredFiles(t *testing.T) {
 	cache.Set("student2", []byte("data2"), "hash2")
 
 	// Verify files exist
-	file1 := filepath.Join(tempDir, "student_student1_hash1.pdf")
-	file2 := filepath.Join(tempDir, "student_student2_hash2.pdf")
+	file1 := filepath.Join(tempDir, reportFileName("student1", "hash1"))
+	file2 := filepath.Join(tempDir, reportFileName("student2", "hash2"))
 	_, err = os.Stat(file1)
 	require.NoError(t, err)
 	_, err = os.Stat(file2)
@@ -342,7 +373,7 @@ func TestFileCache_ConcurrentAccess(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
 	ttl := 1 * time.Hour
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 	require.NoError(t, err)
 
 	// Concurrent operations
@@ -390,10 +421,14 @@ func TestFileCache_ConcurrentAccess(t *testing.T) {
 }
 
 func TestFileCache_Set_WriteError(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root ignores directory permissions")
+	}
+
 	// Setup - use a directory that will cause write errors
 	tempDir := t.TempDir()
 	ttl := 1 * time.Hour
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 	require.NoError(t, err)
 
 	// Make directory read-only to cause write error
@@ -420,13 +455,13 @@ func TestFileCache_CleanupWorker(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
 	ttl := 100 * time.Millisecond
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 	require.NoError(t, err)
 
 	// Add data
 	cache.Set("student1", []byte("data1"), "hash1")
 
-	file := filepath.Join(tempDir, "student_student1_hash1.pdf")
+	file := filepath.Join(tempDir, reportFileName("student1", "hash1"))
 	_, err = os.Stat(file)
 	require.NoError(t, err)
 
@@ -440,7 +475,7 @@ func TestFileCache_CleanupWorker(t *testing.T) {
 	assert.True(t, os.IsNotExist(err))
 }
 
-func TestCleanupDirectory_SkipsDirectories(t *testing.T) {
+func TestNewFileCache_SkipsDirectories(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
 
@@ -454,7 +489,7 @@ func TestCleanupDirectory_SkipsDirectories(t *testing.T) {
 	require.NoError(t, err)
 
 	// Execute
-	err = cleanupDirectory(tempDir)
+	_, err = NewFileCache(tempDir, time.Hour, FileCacheLimits{})
 	require.NoError(t, err)
 
 	// Assert subdirectory still exists
@@ -466,11 +501,480 @@ func TestCleanupDirectory_SkipsDirectories(t *testing.T) {
 	assert.True(t, os.IsNotExist(err))
 }
 
+// writeCacheFiles stores a report and its metadata the way FileCache.Set
+// does, but with a chosen expiry time
+func writeCacheFiles(t *testing.T, dir, studentID, hash string, data []byte, expiresAt time.Time) string {
+	t.Helper()
+	filePath := filepath.Join(dir, reportFileName(studentID, hash))
+	metadata, err := json.Marshal(cacheMetadata{
+		StudentID: studentID,
+		Hash:      hash,
+		ExpiresAt: expiresAt,
+		Size:      int64(len(data)),
+		Checksum:  checksum(data),
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filePath, data, 0644))
+	require.NoError(t, os.WriteFile(filePath+metadataSuffix, metadata, 0644))
+	return filePath
+}
+
+func TestFileCache_Set_WritesMetadata(t *testing.T) {
+	// Setup
+	tempDir := t.TempDir()
+	cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{})
+	require.NoError(t, err)
+
+	// Execute
+	require.NoError(t, cache.Set("12345", []byte("test pdf content"), "abcd1234"))
+
+	// Assert
+	metadata, err := readMetadata(filepath.Join(tempDir, reportFileName("12345", "abcd1234")+metadataSuffix))
+	require.NoError(t, err)
+	assert.Equal(t, "12345", metadata.StudentID)
+	assert.Equal(t, "abcd1234", metadata.Hash)
+	assert.Equal(t, int64(16), metadata.Size)
+	assert.Equal(t, checksum([]byte("test pdf content")), metadata.Checksum)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), metadata.ExpiresAt, time.Minute)
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestFileCache_Set_NamesFilesByFormatNeutrally(t *testing.T) {
+	// Setup
+	tempDir := t.TempDir()
+	cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{})
+	require.NoError(t, err)
+	html := []byte("<!DOCTYPE html><html><body>report</body></html>")
+
+	// Execute
+	require.NoError(t, cache.Set("12345", html, VariantKey("v1", "default", "html", "en")))
+
+	// Assert: the file is not named after a format it may not have
+	filePath := filepath.Join(tempDir, "student_12345_v1.default.html.en.report")
+	_, err = os.Stat(filePath)
+	assert.NoError(t, err)
+}
+
+func TestFileCache_ReplacesLegacyPDFFiles(t *testing.T) {
+	// Setup: a report cached under the ".pdf" name used before
+	tempDir := t.TempDir()
+	legacy := filepath.Join(tempDir, "student_12345_v1.pdf.pdf")
+	reportPath := writeCacheFiles(t, tempDir, "12345", "v1.pdf", []byte("%PDF-1.4 old"), time.Now().Add(time.Hour))
+	require.NoError(t, os.Rename(reportPath, legacy))
+	require.NoError(t, os.Rename(reportPath+metadataSuffix, legacy+metadataSuffix))
+
+	cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{})
+	require.NoError(t, err)
+	data, found := cache.Get("12345", "v1.pdf")
+	require.True(t, found)
+	require.Equal(t, []byte("%PDF-1.4 old"), data)
+
+	// Execute
+	require.NoError(t, cache.Set("12345", []byte("%PDF-1.4 new"), "v1.pdf"))
+
+	// Assert
+	data, found = cache.Get("12345", "v1.pdf")
+	assert.True(t, found)
+	assert.Equal(t, []byte("%PDF-1.4 new"), data)
+	_, err = os.Stat(legacy)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(legacy + metadataSuffix)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, int64(len("%PDF-1.4 new")), cache.Stats().Bytes)
+}
+
+func TestNewFileCache_ReloadsIndex(t *testing.T) {
+	// Setup
+	tempDir := t.TempDir()
+	cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{})
+	require.NoError(t, err)
+	require.NoError(t, cache.Set("12345", []byte("pdf"), VariantKey("v1", "pdf")))
+	require.NoError(t, cache.Set("12345", []byte("html"), VariantKey("v1", "html")))
+
+	// Execute: a restart
+	restarted, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{})
+	require.NoError(t, err)
+
+	// Assert
+	data, found := restarted.Get("12345", VariantKey("v1", "pdf"))
+	assert.True(t, found)
+	assert.Equal(t, []byte("pdf"), data)
+	data, found = restarted.Get("12345", VariantKey("v1", "html"))
+	assert.True(t, found)
+	assert.Equal(t, []byte("html"), data)
+	assert.Equal(t, cache.data["12345:v1.pdf"].ExpiresAt.Unix(), restarted.data["12345:v1.pdf"].ExpiresAt.Unix())
+}
+
+func TestNewFileCache_DiscardsInvalidEntries(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, filePath string)
+	}{
+		{
+			name: "expired",
+			corrupt: func(t *testing.T, filePath string) {
+				writeCacheFiles(t, filepath.Dir(filePath), "12345", "abcd1234", []byte("test pdf content"), time.Now().Add(-time.Minute))
+			},
+		},
+		{
+			name: "modified report",
+			corrupt: func(t *testing.T, filePath string) {
+				require.NoError(t, os.WriteFile(filePath, []byte("test pdf CONTENT"), 0644))
+			},
+		},
+		{
+			name: "truncated report",
+			corrupt: func(t *testing.T, filePath string) {
+				require.NoError(t, os.WriteFile(filePath, []byte("test pdf"), 0644))
+			},
+		},
+		{
+			name: "missing report",
+			corrupt: func(t *testing.T, filePath string) {
+				require.NoError(t, os.Remove(filePath))
+			},
+		},
+		{
+			name: "corrupted metadata",
+			corrupt: func(t *testing.T, filePath string) {
+				require.NoError(t, os.WriteFile(filePath+metadataSuffix, []byte(`{"student_id": "123`), 0644))
+			},
+		},
+		{
+			name: "missing metadata",
+			corrupt: func(t *testing.T, filePath string) {
+				require.NoError(t, os.Remove(filePath+metadataSuffix))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			tempDir := t.TempDir()
+			filePath := writeCacheFiles(t, tempDir, "12345", "abcd1234", []byte("test pdf content"), expiresAt)
+			tt.corrupt(t, filePath)
+
+			// Execute
+			cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{})
+			require.NoError(t, err)
+
+			// Assert
+			_, found := cache.Get("12345", "abcd1234")
+			assert.False(t, found)
+			entries, err := os.ReadDir(tempDir)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestNewFileCache_DiscardsInterruptedWrites(t *testing.T) {
+	// Setup: a valid entry next to a temporary file left by a crash
+	tempDir := t.TempDir()
+	writeCacheFiles(t, tempDir, "12345", "abcd1234", []byte("test pdf content"), time.Now().Add(time.Hour))
+	tmpFile := filepath.Join(tempDir, fsutil.TempPrefix+"123456")
+	require.NoError(t, os.WriteFile(tmpFile, []byte("test pdf"), 0644))
+
+	// Execute
+	cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{})
+	require.NoError(t, err)
+
+	// Assert
+	_, err = os.Stat(tmpFile)
+	assert.True(t, os.IsNotExist(err))
+	_, found := cache.Get("12345", "abcd1234")
+	assert.True(t, found)
+}
+
+func TestNewFileCache_KeepsLatestVersion(t *testing.T) {
+	// Setup: a crash between storing v2 and removing v1
+	tempDir := t.TempDir()
+	v1 := writeCacheFiles(t, tempDir, "12345", VariantKey("v1", "pdf"), []byte("old"), time.Now().Add(30*time.Minute))
+	writeCacheFiles(t, tempDir, "12345", VariantKey("v2", "pdf"), []byte("new"), time.Now().Add(time.Hour))
+	writeCacheFiles(t, tempDir, "12345", VariantKey("v2", "html"), []byte("new html"), time.Now().Add(45*time.Minute))
+
+	// Execute
+	cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{})
+	require.NoError(t, err)
+
+	// Assert
+	_, found := cache.Get("12345", VariantKey("v1", "pdf"))
+	assert.False(t, found)
+	_, err = os.Stat(v1)
+	assert.True(t, os.IsNotExist(err))
+
+	_, found = cache.Get("12345", VariantKey("v2", "pdf"))
+	assert.True(t, found)
+	_, found = cache.Get("12345", VariantKey("v2", "html"))
+	assert.True(t, found)
+}
+
+func TestNewFileCache_InvalidLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits FileCacheLimits
+	}{
+		{"negative bytes", FileCacheLimits{MaxBytes: -1}},
+		{"negative entries", FileCacheLimits{MaxEntries: -1}},
+		{"unknown policy", FileCacheLimits{Policy: "fifo"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFileCache(t.TempDir(), time.Hour, tt.limits)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFileCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	// Setup
+	tempDir := t.TempDir()
+	cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{MaxEntries: 2})
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Set("1", []byte("one"), "a"))
+	require.NoError(t, cache.Set("2", []byte("two"), "b"))
+	_, found := cache.Get("1", "a")
+	require.True(t, found)
+
+	// Execute
+	require.NoError(t, cache.Set("3", []byte("three"), "c"))
+
+	// Assert
+	_, found = cache.Get("2", "b")
+	assert.False(t, found)
+	_, err = os.Stat(filepath.Join(tempDir, reportFileName("2", "b")))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(tempDir, reportFileName("2", "b")+metadataSuffix))
+	assert.True(t, os.IsNotExist(err))
+
+	_, found = cache.Get("1", "a")
+	assert.True(t, found)
+	_, found = cache.Get("3", "c")
+	assert.True(t, found)
+	assert.Equal(t, uint64(1), cache.Stats().Evictions)
+}
+
+func TestFileCache_EvictsToByteLimit(t *testing.T) {
+	// Setup: room for two 10 byte reports
+	cache, err := NewFileCache(t.TempDir(), time.Hour, FileCacheLimits{MaxBytes: 25})
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Set("1", make([]byte, 10), "a"))
+	require.NoError(t, cache.Set("2", make([]byte, 10), "b"))
+
+	// Execute
+	require.NoError(t, cache.Set("3", make([]byte, 10), "c"))
+
+	// Assert
+	_, found := cache.Get("1", "a")
+	assert.False(t, found)
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, int64(20), stats.Bytes)
+	assert.Equal(t, uint64(1), stats.Evictions)
+}
+
+func TestFileCache_EvictsLeastFrequentlyUsed(t *testing.T) {
+	// Setup
+	cache, err := NewFileCache(t.TempDir(), time.Hour, FileCacheLimits{MaxEntries: 2, Policy: EvictLFU})
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Set("1", []byte("one"), "a"))
+	require.NoError(t, cache.Set("2", []byte("two"), "b"))
+	for i := 0; i < 3; i++ {
+		_, found := cache.Get("1", "a")
+		require.True(t, found)
+	}
+	// student 2 is the most recently used, but the least frequently
+	_, found := cache.Get("2", "b")
+	require.True(t, found)
+
+	// Execute
+	require.NoError(t, cache.Set("3", []byte("three"), "c"))
+
+	// Assert
+	_, found = cache.Get("2", "b")
+	assert.False(t, found)
+	_, found = cache.Get("1", "a")
+	assert.True(t, found)
+}
+
+func TestFileCache_Set_ReportLargerThanLimit(t *testing.T) {
+	cache, err := NewFileCache(t.TempDir(), time.Hour, FileCacheLimits{MaxBytes: 10})
+	require.NoError(t, err)
+	require.NoError(t, cache.Set("1", make([]byte, 5), "a"))
+
+	err = cache.Set("2", make([]byte, 11), "b")
+
+	assert.Error(t, err)
+	_, found := cache.Get("1", "a")
+	assert.True(t, found, "an oversized report must not evict the cache")
+}
+
+func TestFileCache_Set_ReplacingEntryKeepsSizeAccurate(t *testing.T) {
+	cache, err := NewFileCache(t.TempDir(), time.Hour, FileCacheLimits{})
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Set("1", make([]byte, 40), VariantKey("v1", "pdf")))
+	require.NoError(t, cache.Set("1", make([]byte, 30), VariantKey("v1", "pdf")))
+	require.NoError(t, cache.Set("1", make([]byte, 20), VariantKey("v1", "html")))
+	require.NoError(t, cache.Set("1", make([]byte, 10), VariantKey("v2", "pdf")))
+
+	stats := cache.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, int64(10), stats.Bytes)
+}
+
+func TestNewFileCache_EnforcesLimitsOnLoad(t *testing.T) {
+	// Setup
+	tempDir := t.TempDir()
+	writeCacheFiles(t, tempDir, "1", "a", []byte("one"), time.Now().Add(time.Hour))
+	writeCacheFiles(t, tempDir, "2", "b", []byte("two"), time.Now().Add(time.Hour))
+	writeCacheFiles(t, tempDir, "3", "c", []byte("three"), time.Now().Add(time.Hour))
+
+	// Execute
+	cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{MaxEntries: 1})
+	require.NoError(t, err)
+
+	// Assert
+	stats := cache.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, uint64(2), stats.Evictions)
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestFileCache_Stats(t *testing.T) {
+	// Setup
+	cache, err := NewFileCache(t.TempDir(), 100*time.Millisecond, FileCacheLimits{})
+	require.NoError(t, err)
+	require.NoError(t, cache.Set("1", []byte("report"), "a"))
+
+	// Execute
+	cache.Get("1", "a")
+	cache.Get("1", "a")
+	cache.Get("2", "b")
+	time.Sleep(150 * time.Millisecond)
+	cache.removeExpiredFiles()
+
+	// Assert
+	assert.Equal(t, Stats{Hits: 2, Misses: 1, Expirations: 1}, cache.Stats())
+}
+
+func TestFileCache_ConcurrentEviction(t *testing.T) {
+	// Setup
+	tempDir := t.TempDir()
+	cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{MaxEntries: 5, MaxBytes: 1000})
+	require.NoError(t, err)
+
+	// Execute: concurrent writers and readers of more students than fit
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			studentID := fmt.Sprint(id)
+			for j := 0; j < 10; j++ {
+				assert.NoError(t, cache.Set(studentID, make([]byte, 100), fmt.Sprintf("v%d", j)))
+				if data, found := cache.Get(studentID, fmt.Sprintf("v%d", j)); found {
+					assert.Len(t, data, 100)
+				}
+				cache.Get(fmt.Sprint((id+1)%20), "v0")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Assert the index matches the limits and the files on disk
+	stats := cache.Stats()
+	assert.LessOrEqual(t, stats.Entries, 5)
+	assert.Equal(t, int64(stats.Entries*100), stats.Bytes)
+
+	var total int64
+	for _, entry := range cache.data {
+		info, err := os.Stat(entry.FilePath)
+		require.NoError(t, err)
+		total += info.Size()
+	}
+	assert.Equal(t, stats.Bytes, total)
+}
+
+func TestFileCache_ConcurrentWritesOfOneStudent(t *testing.T) {
+	// Setup
+	tempDir := t.TempDir()
+	cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{})
+	require.NoError(t, err)
+
+	// Execute: overlapping writes of two versions of one student, with
+	// reports of different sizes under the same key
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			hash := VariantKey(fmt.Sprintf("v%d", i%2), "pdf")
+			assert.NoError(t, cache.Set("1", make([]byte, i+1), hash))
+		}(i)
+	}
+	wg.Wait()
+
+	// Assert the last write is indexed as it is stored on disk
+	require.Len(t, cache.data, 1)
+	for _, entry := range cache.data {
+		metadata, err := readMetadata(entry.FilePath + metadataSuffix)
+		require.NoError(t, err)
+		assert.True(t, matchesMetadata(entry.FilePath, metadata))
+		assert.Equal(t, metadata.Size, entry.Size)
+		assert.Equal(t, entry.Size, cache.Stats().Bytes)
+	}
+	files, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestFileCache_WritesOfOneStudentAreSerialised(t *testing.T) {
+	// Setup: a write of the student is in progress
+	tempDir := t.TempDir()
+	cache, err := NewFileCache(tempDir, time.Hour, FileCacheLimits{})
+	require.NoError(t, err)
+	studentLock := cache.studentLock("1")
+	studentLock.Lock()
+
+	// Execute
+	done := make(chan error, 1)
+	go func() { done <- cache.Set("1", []byte("v2 report"), "v2.pdf") }()
+
+	// Assert: the next write touches no file until the first one is done
+	select {
+	case <-done:
+		t.Fatal("the write did not wait for the write in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+	_, err = os.Stat(filepath.Join(tempDir, reportFileName("1", "v2.pdf")))
+	assert.True(t, os.IsNotExist(err))
+
+	studentLock.Unlock()
+	require.NoError(t, <-done)
+	data, found := cache.Get("1", "v2.pdf")
+	assert.True(t, found)
+	assert.Equal(t, []byte("v2 report"), data)
+}
+
 func TestFileCache_LargePDFData(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
 	ttl := 1 * time.Hour
-	cache, err := NewFileCache(tempDir, ttl)
+	cache, err := NewFileCache(tempDir, ttl, FileCacheLimits{})
 	require.NoError(t, err)
 
 	// Create large PDF data (5MB)
@@ -511,7 +1015,7 @@ func BenchmarkGenerateStudentHash(b *testing.B) {
 
 func BenchmarkFileCache_Set(b *testing.B) {
 	tempDir := b.TempDir()
-	cache, _ := NewFileCache(tempDir, 1*time.Hour)
+	cache, _ := NewFileCache(tempDir, 1*time.Hour, FileCacheLimits{})
 
 	pdfData := []byte("benchmark pdf content")
 
@@ -525,7 +1029,7 @@ func BenchmarkFileCache_Set(b *testing.B) {
 
 func BenchmarkFileCache_Get(b *testing.B) {
 	tempDir := b.TempDir()
-	cache, _ := NewFileCache(tempDir, 1*time.Hour)
+	cache, _ := NewFileCache(tempDir, 1*time.Hour, FileCacheLimits{})
 
 	// Pre-populate cache
 	studentID := "12345"
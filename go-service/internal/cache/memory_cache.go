@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemoryCache keeps reports in process memory and evicts the least recently
+// used ones once their total size exceeds the byte budget. Reports are copied
+// in and out, so callers may modify the slices they pass or receive.
+type MemoryCache struct {
+	ttl      time.Duration
+	maxBytes int64
+	size     int64
+	lru      *list.List                          // front is most recently used
+	entries  map[string]*list.Element            // studentID:hash → *memoryEntry
+	students map[string]map[string]*list.Element // studentID → its entries
+	mu       sync.Mutex
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+type memoryEntry struct {
+	studentID string
+	hash      string
+	data      []byte
+	expiresAt time.Time
+}
+
+func NewMemoryCache(maxBytes int64, ttl time.Duration) (*MemoryCache, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("memory cache size must be positive, got %d", maxBytes)
+	}
+
+	return &MemoryCache{
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		students: make(map[string]map[string]*list.Element),
+	}, nil
+}
+
+func (c *MemoryCache) Get(studentID, hash string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[studentID+":"+hash]
+	if !exists {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		c.expirations.Add(1)
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	c.hits.Add(1)
+	return append([]byte(nil), entry.data...), true
+}
+
+// LatestVersion returns the content version of the student's entries, which
+// all share one since Set drops the older versions
+func (c *MemoryCache) LatestVersion(studentID string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for hash, element := range c.students[studentID] {
+		if !now.After(element.Value.(*memoryEntry).expiresAt) {
+			return contentVersion(hash), true
+		}
+	}
+	return "", false
+}
+
+// Set stores data unless it alone exceeds the byte budget, in which case the
+// report is simply not cached
+func (c *MemoryCache) Set(studentID string, data []byte, hash string) error {
+	if int64(len(data)) > c.maxBytes {
+		return fmt.Errorf("report of %d bytes exceeds the memory cache size of %d bytes", len(data), c.maxBytes)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop old versions of the same student, and the entry being replaced
+	version := contentVersion(hash)
+	for entryHash, element := range c.students[studentID] {
+		if entryHash == hash || contentVersion(entryHash) != version {
+			c.remove(element)
+		}
+	}
+
+	entry := &memoryEntry{
+		studentID: studentID,
+		hash:      hash,
+		data:      append([]byte(nil), data...),
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	element := c.lru.PushFront(entry)
+	c.entries[studentID+":"+hash] = element
+	if c.students[studentID] == nil {
+		c.students[studentID] = make(map[string]*list.Element)
+	}
+	c.students[studentID][hash] = element
+	c.size += int64(len(data))
+
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+	return nil
+}
+
+// Delete removes every cached report of a student
+func (c *MemoryCache) Delete(studentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, element := range c.students[studentID] {
+		c.remove(element)
+	}
+	return nil
+}
+
+// Purge removes every cached report
+func (c *MemoryCache) Purge() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	c.students = make(map[string]map[string]*list.Element)
+	c.size = 0
+	return nil
+}
+
+// Entries lists the cached reports that have not expired, most recently
+// used first
+func (c *MemoryCache) Entries() ([]Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]Entry, 0, c.lru.Len())
+	for element := c.lru.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*memoryEntry)
+		if now.After(entry.expiresAt) {
+			continue
+		}
+		entries = append(entries, Entry{
+			StudentID: entry.studentID,
+			Hash:      entry.hash,
+			Size:      int64(len(entry.data)),
+			ExpiresAt: entry.expiresAt,
+		})
+	}
+	return entries, nil
+}
+
+// Stats returns the current size of the cache and its activity since startup
+func (c *MemoryCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{
+		Entries:     len(c.entries),
+		Bytes:       c.size,
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+	}
+}
+
+func (c *MemoryCache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*memoryEntry)
+	delete(c.entries, entry.studentID+":"+entry.hash)
+	delete(c.students[entry.studentID], entry.hash)
+	if len(c.students[entry.studentID]) == 0 {
+		delete(c.students, entry.studentID)
+	}
+	c.size -= int64(len(entry.data))
+}
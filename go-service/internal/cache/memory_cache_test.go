@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCache_Conformance(t *testing.T) {
+	testPDFCacheConformance(t, func(t *testing.T, ttl time.Duration) (PDFCache, func(time.Duration)) {
+		cache, err := NewMemoryCache(1<<20, ttl)
+		require.NoError(t, err)
+		return cache, sleepElapse
+	})
+}
+
+func TestNewMemoryCache_InvalidSize(t *testing.T) {
+	_, err := NewMemoryCache(0, time.Hour)
+
+	assert.Error(t, err)
+}
+
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	// Setup: room for three 10 byte reports
+	cache, err := NewMemoryCache(30, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Set("1", make([]byte, 10), "a"))
+	require.NoError(t, cache.Set("2", make([]byte, 10), "b"))
+	require.NoError(t, cache.Set("3", make([]byte, 10), "c"))
+
+	// Execute: touch student 1 so that student 2 is the least recently used
+	_, found := cache.Get("1", "a")
+	require.True(t, found)
+	require.NoError(t, cache.Set("4", make([]byte, 10), "d"))
+
+	// Assert
+	_, found1 := cache.Get("1", "a")
+	_, found2 := cache.Get("2", "b")
+	_, found3 := cache.Get("3", "c")
+	_, found4 := cache.Get("4", "d")
+	assert.True(t, found1)
+	assert.False(t, found2)
+	assert.True(t, found3)
+	assert.True(t, found4)
+	assert.Equal(t, int64(30), cache.size)
+}
+
+func TestMemoryCache_ReplacedEntriesFreeTheirBytes(t *testing.T) {
+	cache, err := NewMemoryCache(100, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Set("1", make([]byte, 40), VariantKey("v1", "pdf")))
+	require.NoError(t, cache.Set("1", make([]byte, 30), VariantKey("v1", "pdf")))
+	require.NoError(t, cache.Set("1", make([]byte, 20), VariantKey("v2", "pdf")))
+
+	assert.Equal(t, int64(20), cache.size)
+	assert.Len(t, cache.entries, 1)
+	assert.Len(t, cache.students, 1)
+}
+
+func TestMemoryCache_ReportLargerThanBudget(t *testing.T) {
+	cache, err := NewMemoryCache(10, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, cache.Set("1", make([]byte, 5), "a"))
+
+	err = cache.Set("2", make([]byte, 11), "b")
+
+	assert.Error(t, err)
+	_, found := cache.Get("1", "a")
+	assert.True(t, found, "an oversized report must not evict the cache")
+}
@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisCache stores reports in a Redis-compatible server so that every
+// replica of the service shares them. Entries expire through the server's
+// own TTL.
+//
+// Keys are <prefix>report:<studentID>:<hash>; the set <prefix>student:<studentID>
+// lists the hashes cached for a student so that old versions can be dropped.
+type RedisCache struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewRedisCache connects to the server at url, e.g.
+// redis://:password@localhost:6379/0, and checks that it is reachable
+func NewRedisCache(url, prefix string, ttl time.Duration) (*RedisCache, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+
+	client := redis.NewClient(options)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisCache{client: client, prefix: prefix, ttl: ttl}, nil
+}
+
+func (c *RedisCache) Get(studentID, hash string) ([]byte, bool) {
+	data, err := c.client.Get(context.Background(), c.reportKey(studentID, hash)).Bytes()
+	if err != nil {
+		// redis.Nil is a miss, anything else is treated as one
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return data, true
+}
+
+func (c *RedisCache) Set(studentID string, data []byte, hash string) error {
+	ctx := context.Background()
+	studentKey := c.studentKey(studentID)
+
+	cached, err := c.client.SMembers(ctx, studentKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to list cached reports: %w", err)
+	}
+
+	_, err = c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		// Clean old versions of same student, keeping the other variants of
+		// the current content
+		version := contentVersion(hash)
+		for _, cachedHash := range cached {
+			if contentVersion(cachedHash) != version {
+				pipe.Del(ctx, c.reportKey(studentID, cachedHash))
+				pipe.SRem(ctx, studentKey, cachedHash)
+			}
+		}
+
+		pipe.Set(ctx, c.reportKey(studentID, hash), data, c.ttl)
+		pipe.SAdd(ctx, studentKey, hash)
+		pipe.PExpire(ctx, studentKey, c.ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// Delete removes every cached report of a student
+func (c *RedisCache) Delete(studentID string) error {
+	ctx := context.Background()
+	studentKey := c.studentKey(studentID)
+
+	cached, err := c.client.SMembers(ctx, studentKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to list cached reports: %w", err)
+	}
+
+	keys := []string{studentKey}
+	for _, hash := range cached {
+		keys = append(keys, c.reportKey(studentID, hash))
+	}
+	if err := c.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to delete cache entries: %w", err)
+	}
+	return nil
+}
+
+// Purge removes every cached report and student set under the key prefix
+func (c *RedisCache) Purge() error {
+	ctx := context.Background()
+	for _, pattern := range []string{c.keyPattern("report:"), c.keyPattern("student:")} {
+		iter := c.client.Scan(ctx, 0, pattern, 100).Iterator()
+		var keys []string
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return fmt.Errorf("failed to list cache entries: %w", err)
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		if err := c.client.Del(ctx, keys...).Err(); err != nil {
+			return fmt.Errorf("failed to delete cache entries: %w", err)
+		}
+	}
+	return nil
+}
+
+// LatestVersion reads the content version from the student's set, which
+// only lists hashes of the version last stored by any replica
+func (c *RedisCache) LatestVersion(studentID string) (string, bool) {
+	hash, err := c.client.SRandMember(context.Background(), c.studentKey(studentID)).Result()
+	if err != nil {
+		return "", false
+	}
+	return contentVersion(hash), true
+}
+
+// Entries lists the cached reports of all replicas
+func (c *RedisCache) Entries() ([]Entry, error) {
+	ctx := context.Background()
+
+	var keys []string
+	iter := c.client.Scan(ctx, 0, c.keyPattern("report:"), 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list cache entries: %w", err)
+	}
+
+	sizes := make([]*redis.IntCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			sizes[i] = pipe.StrLen(ctx, key)
+			ttls[i] = pipe.PTTL(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect cache entries: %w", err)
+	}
+
+	now := time.Now()
+	entries := make([]Entry, 0, len(keys))
+	for i, key := range keys {
+		// keys expiring between the scan and the inspection are skipped
+		ttl := ttls[i].Val()
+		if ttl <= 0 {
+			continue
+		}
+		studentID, hash, _ := strings.Cut(strings.TrimPrefix(key, c.prefix+"report:"), ":")
+		entries = append(entries, Entry{
+			StudentID: studentID,
+			Hash:      hash,
+			Size:      sizes[i].Val(),
+			ExpiresAt: now.Add(ttl),
+		})
+	}
+	return entries, nil
+}
+
+// Stats returns the activity of this replica. Evictions and expirations are
+// handled by the server and not counted.
+func (c *RedisCache) Stats() Stats {
+	return Stats{Shared: true, Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// Close releases the connections to the server
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
+
+func (c *RedisCache) reportKey(studentID, hash string) string {
+	return c.prefix + "report:" + studentID + ":" + hash
+}
+
+func (c *RedisCache) studentKey(studentID string) string {
+	return c.prefix + "student:" + studentID
+}
+
+// keyPattern matches every key starting with the prefix followed by kind
+func (c *RedisCache) keyPattern(kind string) string {
+	return globEscaper.Replace(c.prefix+kind) + "*"
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisCache(t *testing.T, ttl time.Duration) (*RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	cache, err := NewRedisCache("redis://"+server.Addr()+"/0", "test:", ttl)
+	require.NoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	return cache, server
+}
+
+func TestRedisCache_Conformance(t *testing.T) {
+	testPDFCacheConformance(t, func(t *testing.T, ttl time.Duration) (PDFCache, func(time.Duration)) {
+		cache, server := newTestRedisCache(t, ttl)
+		return cache, server.FastForward
+	})
+}
+
+func TestNewRedisCache_InvalidURL(t *testing.T) {
+	_, err := NewRedisCache("localhost:6379", "", time.Hour)
+
+	assert.Error(t, err)
+}
+
+func TestNewRedisCache_Unreachable(t *testing.T) {
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	_, err := NewRedisCache("redis://"+addr, "", time.Hour)
+
+	assert.Error(t, err)
+}
+
+func TestRedisCache_Keys(t *testing.T) {
+	cache, server := newTestRedisCache(t, time.Hour)
+
+	require.NoError(t, cache.Set("42", []byte("report"), "abc.pdf"))
+
+	data, err := server.Get("test:report:42:abc.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "report", data)
+	members, err := server.Members("test:student:42")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc.pdf"}, members)
+	assert.Equal(t, time.Hour, server.TTL("test:report:42:abc.pdf"))
+}
+
+func TestRedisCache_ServerDown(t *testing.T) {
+	cache, server := newTestRedisCache(t, time.Hour)
+	server.Close()
+
+	_, found := cache.Get("42", "abc")
+
+	assert.False(t, found)
+	assert.Error(t, cache.Set("42", []byte("report"), "abc"))
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// expiresAtMetadata is the object metadata holding the expiry time of an entry
+const expiresAtMetadata = "Expires-At"
+
+// S3Options locates the bucket of an S3Cache
+type S3Options struct {
+	Endpoint  string // host[:port], without scheme
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	Prefix    string // prepended to every object key
+}
+
+// S3Cache stores reports as objects in an S3-compatible bucket so that every
+// replica of the service shares them. Objects are named
+// <prefix><studentID>/<hash>.
+//
+// Object storage has no per-object TTL: expired objects are treated as
+// misses and removed when read. A bucket lifecycle rule should delete
+// objects that are never read again.
+type S3Cache struct {
+	client *minio.Client
+	bucket string
+	prefix string
+	ttl    time.Duration
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	expirations atomic.Uint64
+}
+
+// NewS3Cache connects to the bucket described by opts and checks that it
+// exists
+func NewS3Cache(opts S3Options, ttl time.Duration) (*S3Cache, error) {
+	client, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+		Secure: opts.UseSSL,
+		Region: opts.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid object storage configuration: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, opts.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach object storage: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket %q does not exist", opts.Bucket)
+	}
+
+	return &S3Cache{client: client, bucket: opts.Bucket, prefix: opts.Prefix, ttl: ttl}, nil
+}
+
+func (c *S3Cache) Get(studentID, hash string) ([]byte, bool) {
+	ctx := context.Background()
+	key := c.objectKey(studentID, hash)
+
+	object, err := c.client.GetObject(ctx, c.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		// includes NoSuchKey, which only surfaces on the first read
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	info, err := object.Stat()
+	if err != nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+	if !isLive(info) {
+		c.client.RemoveObject(ctx, c.bucket, key, minio.RemoveObjectOptions{})
+		c.expirations.Add(1)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return data, true
+}
+
+func (c *S3Cache) Set(studentID string, data []byte, hash string) error {
+	ctx := context.Background()
+
+	_, err := c.client.PutObject(ctx, c.bucket, c.objectKey(studentID, hash), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType:  http.DetectContentType(data),
+		UserMetadata: map[string]string{expiresAtMetadata: time.Now().Add(c.ttl).UTC().Format(time.RFC3339Nano)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write cache object: %w", err)
+	}
+
+	// Clean old versions of same student, keeping the other variants of the
+	// current content
+	version := contentVersion(hash)
+	studentPrefix := c.objectKey(studentID, "")
+	err = c.removeObjects(ctx, studentPrefix, func(key string) bool {
+		return contentVersion(strings.TrimPrefix(key, studentPrefix)) != version
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove old cache objects: %w", err)
+	}
+	return nil
+}
+
+// Delete removes every cached report of a student
+func (c *S3Cache) Delete(studentID string) error {
+	err := c.removeObjects(context.Background(), c.objectKey(studentID, ""), func(string) bool { return true })
+	if err != nil {
+		return fmt.Errorf("failed to remove cache objects: %w", err)
+	}
+	return nil
+}
+
+// Purge removes every object under the key prefix
+func (c *S3Cache) Purge() error {
+	err := c.removeObjects(context.Background(), c.prefix, func(string) bool { return true })
+	if err != nil {
+		return fmt.Errorf("failed to remove cache objects: %w", err)
+	}
+	return nil
+}
+
+// LatestVersion returns the content version of the first live object of the
+// student, since Set removes the objects of the older versions
+func (c *S3Cache) LatestVersion(studentID string) (string, bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	studentPrefix := c.objectKey(studentID, "")
+	for object := range c.client.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{Prefix: studentPrefix, Recursive: true}) {
+		if object.Err != nil {
+			return "", false
+		}
+		info, err := c.client.StatObject(ctx, c.bucket, object.Key, minio.StatObjectOptions{})
+		if err == nil && isLive(info) {
+			return contentVersion(strings.TrimPrefix(object.Key, studentPrefix)), true
+		}
+	}
+	return "", false
+}
+
+// Entries lists the cached reports that have not expired. The expiry time
+// is object metadata, so every object is inspected.
+func (c *S3Cache) Entries() ([]Entry, error) {
+	ctx := context.Background()
+
+	var entries []Entry
+	for object := range c.client.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{Prefix: c.prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list cache objects: %w", object.Err)
+		}
+		info, err := c.client.StatObject(ctx, c.bucket, object.Key, minio.StatObjectOptions{})
+		if err != nil || !isLive(info) {
+			// removed or expired since it was listed
+			continue
+		}
+
+		studentID, hash, _ := strings.Cut(strings.TrimPrefix(object.Key, c.prefix), "/")
+		expiresAt, _ := time.Parse(time.RFC3339Nano, info.UserMetadata[expiresAtMetadata])
+		entries = append(entries, Entry{StudentID: studentID, Hash: hash, Size: info.Size, ExpiresAt: expiresAt})
+	}
+	return entries, nil
+}
+
+// Stats returns the activity of this replica
+func (c *S3Cache) Stats() Stats {
+	return Stats{
+		Shared:      true,
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Expirations: c.expirations.Load(),
+	}
+}
+
+// removeObjects removes the objects under prefix selected by remove
+func (c *S3Cache) removeObjects(ctx context.Context, prefix string, remove func(key string) bool) error {
+	var errs []error
+	for object := range c.client.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			errs = append(errs, object.Err)
+			break
+		}
+		if !remove(object.Key) {
+			continue
+		}
+		if err := c.client.RemoveObject(ctx, c.bucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isLive reports whether the object has not expired
+func isLive(info minio.ObjectInfo) bool {
+	expiresAt, err := time.Parse(time.RFC3339Nano, info.UserMetadata[expiresAtMetadata])
+	return err == nil && !time.Now().After(expiresAt)
+}
+
+func (c *S3Cache) objectKey(studentID, hash string) string {
+	return c.prefix + studentID + "/" + hash
+}
@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is an in-process stand-in for the parts of the S3 API the cache
+// uses: path-style HEAD bucket, PUT, GET and DELETE object and ListObjectsV2.
+// Requests are not authenticated.
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]fakeObject
+}
+
+type fakeObject struct {
+	data     []byte
+	metadata http.Header
+}
+
+func newFakeS3(t *testing.T, buckets ...string) *httptest.Server {
+	fake := &fakeS3{buckets: make(map[string]map[string]fakeObject)}
+	for _, bucket := range buckets {
+		fake.buckets[bucket] = make(map[string]fakeObject)
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	bucket, exists := f.buckets[bucketName]
+	if !exists {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, bucket, r.URL.Query())
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err == nil && strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			data, err = decodeAWSChunked(data)
+		}
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		metadata := http.Header{}
+		for name, values := range r.Header {
+			if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+				metadata[name] = values
+			}
+		}
+		bucket[key] = fakeObject{data: data, metadata: metadata}
+		w.Header().Set("ETag", `"`+strconv.Itoa(len(data))+`"`)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, exists := bucket[key]
+		if !exists {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		for name, values := range object.metadata {
+			w.Header()[name] = values
+		}
+		w.Header().Set("ETag", `"`+strconv.Itoa(len(object.data))+`"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, bucket map[string]fakeObject, query url.Values) {
+	type content struct {
+		Key  string
+		Size int
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Prefix      string
+		KeyCount    int
+		IsTruncated bool
+		Contents    []content
+	}{Prefix: query.Get("prefix")}
+
+	for key, object := range bucket {
+		if strings.HasPrefix(key, result.Prefix) {
+			result.Contents = append(result.Contents, content{Key: key, Size: len(object.data)})
+		}
+	}
+	sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// decodeAWSChunked strips the framing of a streaming signed upload:
+// <hex size>;chunk-signature=<sig>\r\n<data>\r\n ... 0;chunk-signature=<sig>\r\n
+func decodeAWSChunked(body []byte) ([]byte, error) {
+	var data []byte
+	for {
+		header, rest, found := bytes.Cut(body, []byte("\r\n"))
+		if !found {
+			return nil, io.ErrUnexpectedEOF
+		}
+		sizeHex, _, _ := bytes.Cut(header, []byte(";"))
+		size, err := strconv.ParseInt(string(sizeHex), 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+		if int64(len(rest)) < size+2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		data = append(data, rest[:size]...)
+		body = rest[size+2:]
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+	}{Code: code})
+}
+
+func newTestS3Cache(t *testing.T, ttl time.Duration) (*S3Cache, *httptest.Server) {
+	t.Helper()
+	server := newFakeS3(t, "reports")
+	cache, err := NewS3Cache(S3Options{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Bucket:    "reports",
+		Region:    "us-east-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Prefix:    "cache/",
+	}, ttl)
+	require.NoError(t, err)
+	return cache, server
+}
+
+func TestS3Cache_Conformance(t *testing.T) {
+	testPDFCacheConformance(t, func(t *testing.T, ttl time.Duration) (PDFCache, func(time.Duration)) {
+		cache, _ := newTestS3Cache(t, ttl)
+		return cache, sleepElapse
+	})
+}
+
+func TestNewS3Cache_MissingBucket(t *testing.T) {
+	server := newFakeS3(t)
+
+	_, err := NewS3Cache(S3Options{
+		Endpoint: strings.TrimPrefix(server.URL, "http://"),
+		Bucket:   "reports",
+		Region:   "us-east-1",
+	}, time.Hour)
+
+	assert.Error(t, err)
+}
+
+func TestS3Cache_ObjectKeys(t *testing.T) {
+	cache, server := newTestS3Cache(t, time.Hour)
+
+	require.NoError(t, cache.Set("42", []byte("%PDF-1.4"), "abc.pdf"))
+
+	resp, err := http.Get(server.URL + "/reports/cache/42/abc.pdf")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("X-Amz-Meta-Expires-At"))
+}
+
+func TestS3Cache_ExpiredObjectsAreRemoved(t *testing.T) {
+	cache, server := newTestS3Cache(t, time.Millisecond)
+	require.NoError(t, cache.Set("42", []byte("report"), "abc"))
+	time.Sleep(5 * time.Millisecond)
+
+	_, found := cache.Get("42", "abc")
+
+	assert.False(t, found)
+	resp, err := http.Get(server.URL + "/reports/cache/42/abc")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
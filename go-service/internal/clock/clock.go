@@ -0,0 +1,28 @@
+// Package clock supplies the current time to the code that depends on it,
+// so that tests can control it.
+package clock
+
+import "time"
+
+// Clock supplies the current time
+type Clock interface {
+	Now() time.Time
+}
+
+type system struct{}
+
+func (system) Now() time.Time {
+	return time.Now()
+}
+
+// System returns a Clock that reads the system time
+func System() Clock {
+	return system{}
+}
+
+// Func adapts a function to a Clock
+type Func func() time.Time
+
+func (f Func) Now() time.Time {
+	return f()
+}
@@ -15,18 +15,134 @@ type Config struct {
 	LogLevel    string `envconfig:"LOG_LEVEL" default:"info"`
 
 	// Backend Client
-	BackendURL    string `envconfig:"BACKEND_URL" default:"http://localhost:5007"`
-	APIKey        string `envconfig:"INTERNAL_API_KEY" required:"true"`
-	RetryAttempts int    `envconfig:"RETRY_ATTEMPTS" default:"3"`
-
-	// Rate Limiting (per minute, per IP address)
-	EnableRateLimit    bool `envconfig:"ENABLE_RATE_LIMIT" default:"true"`
-	RateLimitPerMinute int  `envconfig:"RATE_LIMIT_PER_MINUTE" default:"100"`
-
-	// Cache Configuration
-	EnableCache bool          `envconfig:"ENABLE_CACHE" default:"true"`
-	CachePath   string        `envconfig:"CACHE_PATH" default:"./cache/pdf-reports"`
-	CacheTTL    time.Duration `envconfig:"CACHE_TTL" default:"1h"`
+	BackendURL string `envconfig:"BACKEND_URL" default:"http://localhost:5007"`
+	APIKey     string `envconfig:"INTERNAL_API_KEY" required:"true"`
+
+	// Backend Retries (RETRY_ATTEMPTS counts the first call; retries are capped
+	// at RETRY_BUDGET_RATIO of the calls made, plus RETRY_BUDGET_RESERVE saved
+	// up for bursts)
+	RetryAttempts      int           `envconfig:"RETRY_ATTEMPTS" default:"3"`
+	RetryBaseDelay     time.Duration `envconfig:"RETRY_BASE_DELAY" default:"1s"`
+	RetryMaxDelay      time.Duration `envconfig:"RETRY_MAX_DELAY" default:"10s"`
+	RetryBudgetRatio   float64       `envconfig:"RETRY_BUDGET_RATIO" default:"0.1"`
+	RetryBudgetReserve int           `envconfig:"RETRY_BUDGET_RESERVE" default:"10"`
+
+	// Backend Circuit Breaker (opens after BREAKER_FAILURE_THRESHOLD consecutive
+	// failures, disabled when 0; probes the backend after BREAKER_COOLDOWN and
+	// closes after BREAKER_SUCCESS_THRESHOLD successful probes)
+	BreakerFailureThreshold int           `envconfig:"BREAKER_FAILURE_THRESHOLD" default:"5"`
+	BreakerSuccessThreshold int           `envconfig:"BREAKER_SUCCESS_THRESHOLD" default:"2"`
+	BreakerCooldown         time.Duration `envconfig:"BREAKER_COOLDOWN" default:"30s"`
+
+	// Metrics (Prometheus exposition on /metrics)
+	EnableMetrics bool `envconfig:"ENABLE_METRICS" default:"true"`
+
+	// Tracing (OpenTelemetry spans are exported over OTLP/HTTP when an endpoint
+	// URL is set, e.g. http://collector:4318)
+	TracingEndpoint    string  `envconfig:"TRACING_OTLP_ENDPOINT" default:""`
+	TracingServiceName string  `envconfig:"TRACING_SERVICE_NAME" default:"student-report-service"`
+	TracingSampleRatio float64 `envconfig:"TRACING_SAMPLE_RATIO" default:"1"`
+
+	// Client IP (read from CLIENT_IP_HEADER, X-Forwarded-For, X-Real-IP or
+	// Forwarded, only on requests from TRUSTED_PROXIES, a comma separated list
+	// of IPs or CIDRs; the peer address is used when no proxy is trusted)
+	TrustedProxies []string `envconfig:"TRUSTED_PROXIES" default:""`
+	ClientIPHeader string   `envconfig:"CLIENT_IP_HEADER" default:"X-Forwarded-For"`
+
+	// Rate Limiting (token buckets per IP address or API key, refilled at
+	// RATE_LIMIT_PER_MINUTE; RATE_LIMIT_ROUTES sets per-route limits as
+	// <route>=<requests per minute> pairs, 0 exempting the route, and
+	// RATE_LIMIT_BACKEND is memory or redis to share the buckets across
+	// replicas)
+	EnableRateLimit        bool          `envconfig:"ENABLE_RATE_LIMIT" default:"true"`
+	RateLimitPerMinute     int           `envconfig:"RATE_LIMIT_PER_MINUTE" default:"100"`
+	RateLimitRoutes        string        `envconfig:"RATE_LIMIT_ROUTES" default:"/health=0,/metrics=0"`
+	RateLimitBackend       string        `envconfig:"RATE_LIMIT_BACKEND" default:"memory"`
+	RateLimitRedisURL      string        `envconfig:"RATE_LIMIT_REDIS_URL" default:"redis://localhost:6379/0"`
+	RateLimitKeyPrefix     string        `envconfig:"RATE_LIMIT_KEY_PREFIX" default:"ratelimit:"`
+	RateLimitSweepInterval time.Duration `envconfig:"RATE_LIMIT_SWEEP_INTERVAL" default:"1m"`
+
+	// Cache Configuration (CACHE_BACKEND is file, memory, redis or s3)
+	EnableCache     bool          `envconfig:"ENABLE_CACHE" default:"true"`
+	CacheBackend    string        `envconfig:"CACHE_BACKEND" default:"file"`
+	CachePath       string        `envconfig:"CACHE_PATH" default:"./cache/pdf-reports"`
+	CacheTTL        time.Duration `envconfig:"CACHE_TTL" default:"1h"`
+	CacheMaxBytes   int64         `envconfig:"CACHE_MAX_BYTES" default:"268435456"`
+	CacheMaxEntries int           `envconfig:"CACHE_MAX_ENTRIES" default:"0"`
+	CacheEviction   string        `envconfig:"CACHE_EVICTION_POLICY" default:"lru"`
+	CacheKeyPrefix  string        `envconfig:"CACHE_KEY_PREFIX" default:"student-reports/"`
+
+	// Caller Authentication (the report endpoints require a bearer JWT once an
+	// HS256 secret or a JWKS file of RS256 keys is set; the issuer and audience
+	// are checked when set)
+	JWTSecret   string `envconfig:"JWT_HS256_SECRET" default:""`
+	JWTJWKSFile string `envconfig:"JWT_JWKS_FILE" default:""`
+	JWTIssuer   string `envconfig:"JWT_ISSUER" default:""`
+	JWTAudience string `envconfig:"JWT_AUDIENCE" default:""`
+
+	// Inbound API Keys (kept in API_KEYS_FILE, disabled unless set; once set the
+	// report endpoints require a JWT or a key granted their scope, and rotated
+	// keys stay valid for API_KEY_ROTATION_OVERLAP unless told otherwise)
+	APIKeysFile           string        `envconfig:"API_KEYS_FILE" default:""`
+	APIKeyRotationOverlap time.Duration `envconfig:"API_KEY_ROTATION_OVERLAP" default:"24h"`
+
+	// Administration API (disabled unless a key is set; sent as a bearer token)
+	AdminAPIKey string `envconfig:"ADMIN_API_KEY" default:""`
+
+	// Student Update Webhook (disabled unless a secret is set). Deliveries are
+	// signed with WEBHOOK_SECRET and rejected when their timestamp is off by
+	// more than WEBHOOK_TOLERANCE.
+	WebhookSecret    string        `envconfig:"WEBHOOK_SECRET" default:""`
+	WebhookTolerance time.Duration `envconfig:"WEBHOOK_TOLERANCE" default:"5m"`
+	WebhookRerender  bool          `envconfig:"WEBHOOK_RERENDER" default:"false"`
+
+	// Stale Reports (cached reports are served while the backend is unreachable
+	// and refreshed once it is back)
+	ServeStale           bool          `envconfig:"SERVE_STALE" default:"false"`
+	StaleRefreshInterval time.Duration `envconfig:"STALE_REFRESH_INTERVAL" default:"30s"`
+
+	// Redis Cache
+	CacheRedisURL string `envconfig:"CACHE_REDIS_URL" default:"redis://localhost:6379/0"`
+
+	// Object Storage Cache (any S3-compatible service)
+	CacheS3Endpoint  string `envconfig:"CACHE_S3_ENDPOINT" default:"s3.amazonaws.com"`
+	CacheS3Bucket    string `envconfig:"CACHE_S3_BUCKET" default:""`
+	CacheS3Region    string `envconfig:"CACHE_S3_REGION" default:"us-east-1"`
+	CacheS3AccessKey string `envconfig:"CACHE_S3_ACCESS_KEY" default:""`
+	CacheS3SecretKey string `envconfig:"CACHE_S3_SECRET_KEY" default:""`
+	CacheS3UseSSL    bool   `envconfig:"CACHE_S3_USE_SSL" default:"true"`
+
+	// Report Templates (custom templates are loaded from TEMPLATE_DIR)
+	TemplateDir             string `envconfig:"TEMPLATE_DIR" default:""`
+	TemplateReload          bool   `envconfig:"TEMPLATE_RELOAD" default:"false"`
+	TemplateOverrideDefault bool   `envconfig:"TEMPLATE_OVERRIDE_DEFAULT" default:"false"`
+
+	// Fonts (TrueType families in FONT_DIR extend the bundled DejaVu family)
+	FontDir string `envconfig:"FONT_DIR" default:""`
+
+	// PDF Signing (reports are signed when both the certificate and key are set)
+	SigningCertFile string `envconfig:"SIGNING_CERT_FILE" default:""`
+	SigningKeyFile  string `envconfig:"SIGNING_KEY_FILE" default:""`
+	SigningReason   string `envconfig:"SIGNING_REASON" default:"Official student report"`
+	SigningLocation string `envconfig:"SIGNING_LOCATION" default:""`
+
+	// Report Registry (issued report IDs, checked through the QR code in the footer)
+	ReportRegistryPath string `envconfig:"REPORT_REGISTRY_PATH" default:"./cache/report-registry"`
+	ReportVerifyURL    string `envconfig:"REPORT_VERIFY_URL" default:"http://localhost:8080/api/v1/reports/verify"`
+
+	// Deterministic Reports (identical student data renders byte-identical reports)
+	DeterministicReports bool `envconfig:"DETERMINISTIC_REPORTS" default:"false"`
+
+	// Batch Reports
+	BatchConcurrency int `envconfig:"BATCH_CONCURRENCY" default:"4"`
+	BatchMaxStudents int `envconfig:"BATCH_MAX_STUDENTS" default:"200"`
+
+	// Report Jobs
+	JobsPath     string        `envconfig:"JOBS_PATH" default:"./cache/report-jobs"`
+	JobWorkers   int           `envconfig:"JOB_WORKERS" default:"2"`
+	JobQueueSize int           `envconfig:"JOB_QUEUE_SIZE" default:"100"`
+	JobTimeout   time.Duration `envconfig:"JOB_TIMEOUT" default:"30m"`
+	JobRetention time.Duration `envconfig:"JOB_RETENTION" default:"24h"`
 }
 
 func Load() (*Config, error) {
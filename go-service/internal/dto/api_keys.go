@@ -0,0 +1,37 @@
+package dto
+
+import "time"
+
+// APIKeyCreateRequest describes a new inbound API key
+type APIKeyCreateRequest struct {
+	Name               string     `json:"name"`
+	Scopes             []string   `json:"scopes"`
+	RateLimitPerMinute int        `json:"rate_limit_per_minute"` // RATE_LIMIT_PER_MINUTE when 0
+	ExpiresAt          *time.Time `json:"expires_at"`            // never when omitted
+}
+
+// APIKeyRotateRequest sets how long the rotated key remains valid alongside
+// its replacement, as a duration such as "24h"
+type APIKeyRotateRequest struct {
+	Overlap string `json:"overlap"`
+}
+
+// APIKeyResponse describes an API key. The token is only returned when the
+// key is created or rotated.
+type APIKeyResponse struct {
+	ID                 string     `json:"id"`
+	Name               string     `json:"name"`
+	Scopes             []string   `json:"scopes"`
+	RateLimitPerMinute int        `json:"rate_limit_per_minute,omitempty"`
+	Status             string     `json:"status"`
+	CreatedAt          time.Time  `json:"created_at"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	RevokedAt          *time.Time `json:"revoked_at,omitempty"`
+	RotatedTo          string     `json:"rotated_to,omitempty"`
+	Token              string     `json:"token,omitempty"`
+}
+
+// APIKeyListResponse lists every API key, including expired and revoked ones
+type APIKeyListResponse struct {
+	Keys []APIKeyResponse `json:"keys"`
+}
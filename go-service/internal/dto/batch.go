@@ -0,0 +1,41 @@
+package dto
+
+// BatchReportRequest selects the students included in a bulk report export.
+// Students can be listed explicitly, selected by class/section, or both.
+type BatchReportRequest struct {
+	StudentIDs []string `json:"student_ids"`
+	Class      string   `json:"class"`
+	Section    string   `json:"section"`
+	Output     string   `json:"output"`   // "zip" (default) or "pdf"
+	Template   string   `json:"template"` // report template, default when empty
+	Profile    string   `json:"profile"`  // redaction profile, the caller's default when empty
+}
+
+// BatchManifest describes the outcome of every student in a batch export
+type BatchManifest struct {
+	Output    string               `json:"output"`
+	Total     int                  `json:"total"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	Entries   []BatchManifestEntry `json:"entries"`
+}
+
+type BatchManifestEntry struct {
+	StudentID string `json:"student_id"`
+	Status    string `json:"status"`
+	FileName  string `json:"file_name,omitempty"`
+	Page      int    `json:"page,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// StudentFilter narrows the student listing returned by the backend
+type StudentFilter struct {
+	Class   string
+	Section string
+}
+
+// StudentSummary is the reduced student record returned by the backend listing
+type StudentSummary struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
@@ -0,0 +1,37 @@
+package dto
+
+import "time"
+
+// CacheEntry describes a cached report
+type CacheEntry struct {
+	StudentID string    `json:"student_id"`
+	Hash      string    `json:"hash"`
+	SizeBytes int64     `json:"size_bytes"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CacheListResponse lists the cached reports along with the cache statistics
+type CacheListResponse struct {
+	Stats   CacheStats   `json:"stats"`
+	Entries []CacheEntry `json:"entries"`
+}
+
+// CacheWarmRequest selects the students whose reports are rendered ahead of
+// time. Students can be listed explicitly, selected by class/section, or both.
+type CacheWarmRequest struct {
+	StudentIDs []string `json:"student_ids"`
+	Class      string   `json:"class"`
+	Section    string   `json:"section"`
+	Template   string   `json:"template"` // default when empty
+	Format     string   `json:"format"`   // pdf when empty
+	Language   string   `json:"language"` // en when empty
+	Profile    string   `json:"profile"`  // full when empty
+}
+
+// CacheWarmResponse describes the outcome of every student of a warm-up
+type CacheWarmResponse struct {
+	Total   int                  `json:"total"`
+	Warmed  int                  `json:"warmed"`
+	Failed  int                  `json:"failed"`
+	Entries []BatchManifestEntry `json:"entries"`
+}
@@ -0,0 +1,17 @@
+package dto
+
+import "time"
+
+// StudentUpdatedEvent is sent by the backend whenever a student record
+// changes. UpdatedAt is the time of the change; events older than one
+// already applied are ignored.
+type StudentUpdatedEvent struct {
+	EventID   string    `json:"event_id"`
+	StudentID string    `json:"student_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EventResponse acknowledges an event with the outcome of its processing
+type EventResponse struct {
+	Status string `json:"status"`
+}
@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+// JobResponse exposes the state of an asynchronous report job
+type JobResponse struct {
+	ID          string         `json:"id"`
+	Status      string         `json:"status"`
+	Progress    JobProgress    `json:"progress"`
+	Manifest    *BatchManifest `json:"manifest,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	DownloadURL string         `json:"download_url,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+type JobProgress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+}
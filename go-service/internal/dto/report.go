@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+// ReportDocument is the JSON rendering of a student report. Sections and
+// fields follow the layout of the template used to render it.
+type ReportDocument struct {
+	ReportID        string          `json:"report_id"`
+	VerificationURL string          `json:"verification_url,omitempty"`
+	StudentID       int             `json:"student_id"`
+	Template        string          `json:"template"`
+	Locale          string          `json:"locale"`
+	Profile         string          `json:"profile,omitempty"`
+	Title           string          `json:"title"`
+	GeneratedAt     time.Time       `json:"generated_at"`
+	Sections        []ReportSection `json:"sections"`
+}
+
+type ReportSection struct {
+	Title  string        `json:"title"`
+	Fields []ReportField `json:"fields"`
+}
+
+// ReportField carries both the raw student value and its display text
+type ReportField struct {
+	Label   string      `json:"label"`
+	Field   string      `json:"field"`
+	Value   interface{} `json:"value"`
+	Display string      `json:"display"`
+}
@@ -6,7 +6,38 @@ type ErrorResponse struct {
 }
 
 type HealthResponse struct {
-	Status  string          `json:"status"`
-	Service string          `json:"service"`
-	Backend map[string]bool `json:"backend"`
+	Status     string           `json:"status"`
+	Service    string           `json:"service"`
+	Backend    map[string]bool  `json:"backend"`
+	Cache      *CacheStats      `json:"cache,omitempty"`
+	Coalescing *CoalescingStats `json:"coalescing,omitempty"`
+	Breaker    *BreakerStats    `json:"circuit_breaker,omitempty"`
+}
+
+// CacheStats reports the size of the report cache and its activity since
+// startup. The size of a shared cache is not reported.
+type CacheStats struct {
+	Shared      bool   `json:"shared"`
+	Entries     *int   `json:"entries,omitempty"`
+	Bytes       *int64 `json:"bytes,omitempty"`
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+}
+
+// CoalescingStats reports how many report requests since startup shared the
+// in-flight backend fetch or rendering of another request
+type CoalescingStats struct {
+	DeduplicatedFetches uint64 `json:"deduplicated_fetches"`
+	DeduplicatedRenders uint64 `json:"deduplicated_renders"`
+}
+
+// BreakerStats reports the state of the circuit breaker guarding the backend
+// and its activity since startup
+type BreakerStats struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Opened              uint64 `json:"opened"`
+	Rejected            uint64 `json:"rejected"`
 }
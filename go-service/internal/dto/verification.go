@@ -0,0 +1,41 @@
+package dto
+
+import "time"
+
+// SignatureVerificationResponse describes the digital signature of an
+// uploaded PDF report
+type SignatureVerificationResponse struct {
+	Signed   bool        `json:"signed"`
+	Valid    bool        `json:"valid"`
+	Modified bool        `json:"modified"`
+	Trusted  bool        `json:"trusted"`
+	Signer   *SignerInfo `json:"signer,omitempty"`
+	SignedAt *time.Time  `json:"signed_at,omitempty"`
+	Problem  string      `json:"problem,omitempty"`
+}
+
+// SignerInfo identifies the certificate a report was signed with
+type SignerInfo struct {
+	CommonName   string    `json:"common_name"`
+	Organization string    `json:"organization,omitempty"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	Fingerprint  string    `json:"fingerprint"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+}
+
+// ReportAuthenticityResponse confirms that a report ID was issued by the
+// service. ContentHash is the SHA-256 of the document as it was served, so a
+// copy can be checked byte for byte.
+type ReportAuthenticityResponse struct {
+	ReportID    string    `json:"report_id"`
+	Authentic   bool      `json:"authentic"`
+	StudentID   int       `json:"student_id"`
+	Format      string    `json:"format"`
+	Template    string    `json:"template"`
+	Locale      string    `json:"locale"`
+	Profile     string    `json:"profile,omitempty"`
+	IssuedAt    time.Time `json:"issued_at"`
+	ContentHash string    `json:"content_hash"`
+}
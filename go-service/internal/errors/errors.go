@@ -22,6 +22,10 @@ func (e *ServiceError) Error() string {
 	return fmt.Sprintf("%s service error: %v", e.Service, e.Err)
 }
 
+func (e *ServiceError) Unwrap() error {
+	return e.Err
+}
+
 type PDFGenerationError struct {
 	Err error
 }
@@ -48,3 +52,31 @@ func IsPDFGenerationError(err error) bool {
 	var pdfErr *PDFGenerationError
 	return errors.As(err, &pdfErr)
 }
+
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func IsValidationError(err error) bool {
+	var validationErr *ValidationError
+	return errors.As(err, &validationErr)
+}
+
+// ForbiddenError is returned when the caller is authenticated but may not
+// access the resource
+type ForbiddenError struct {
+	Resource string
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("access to %s denied", e.Resource)
+}
+
+func IsForbidden(err error) bool {
+	var forbiddenErr *ForbiddenError
+	return errors.As(err, &forbiddenErr)
+}
@@ -6,12 +6,20 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/wbentaleb/student-report-service/internal/dto"
 	"github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/retry"
+	"github.com/wbentaleb/student-report-service/internal/tracing"
 )
 
 // BackendClient handles communication with the Node.js backend
@@ -19,63 +27,107 @@ type BackendClient struct {
 	baseURL    string
 	apiKey     string
 	httpClient *http.Client
+	retry      *retry.Policy
 	logger     *zap.Logger
 }
 
-func NewBackendClient(baseURL, apiKey string, logger *zap.Logger) *BackendClient {
+func NewBackendClient(baseURL, apiKey string, retryPolicy *retry.Policy, logger *zap.Logger) *BackendClient {
 	return &BackendClient{
 		baseURL: baseURL,
 		apiKey:  apiKey,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		retry:  retryPolicy,
 		logger: logger,
 	}
 }
 
-func (c *BackendClient) GetStudent(ctx context.Context, id string) (*dto.Student, error) {
-	var lastErr error
-	delays := []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second}
-
-	for attempt := 0; attempt < 3; attempt++ {
-		student, err := c.getStudent(ctx, id)
-		if err == nil {
-			c.logger.Info("Successfully fetched student",
-				zap.String("student_id", id),
-				zap.Int("attempt", attempt+1))
-			return student, nil
-		}
-
-		lastErr = err
-
-		// Don't retry on non-retryable errors
-		if errors.IsNotFound(err) {
-			c.logger.Warn("Student not found",
-				zap.String("student_id", id))
-			return nil, err
-		}
-
-		// Retry on transient errors
-		if attempt < 2 {
-			c.logger.Warn("Request failed, retrying",
-				zap.String("student_id", id),
-				zap.Int("attempt", attempt+1),
-				zap.Duration("delay", delays[attempt]),
-				zap.Error(err))
-			time.Sleep(delays[attempt])
-		}
-	}
-
-	c.logger.Error("Failed to fetch student after all retries",
-		zap.String("student_id", id),
-		zap.Error(lastErr))
-	return nil, fmt.Errorf("failed after 3 attempts: %w", lastErr)
+func (c *BackendClient) GetStudent(ctx context.Context, id string) (_ *dto.Student, err error) {
+	ctx, span := tracing.Start(ctx, "backend.GetStudent", attribute.String("student.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	endpoint := fmt.Sprintf("%s/api/v1/students/%s", c.baseURL, id)
+
+	var body []byte
+	err = c.retry.Do(ctx, c.attempts("GET /api/v1/students/:id", endpoint, "Student", &body), zap.String("student_id", id))
+	if errors.IsNotFound(err) {
+		c.logger.Warn("Student not found",
+			zap.String("student_id", id))
+		return nil, err
+	}
+	if err != nil {
+		c.logger.Error("Failed to fetch student",
+			zap.String("student_id", id),
+			zap.Error(err))
+		return nil, err
+	}
+
+	var decoded dto.Student
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	c.logger.Info("Successfully fetched student",
+		zap.String("student_id", id))
+	return &decoded, nil
 }
 
-func (c *BackendClient) getStudent(ctx context.Context, id string) (*dto.Student, error) {
-	url := fmt.Sprintf("%s/api/v1/students/%s", c.baseURL, id)
+// ListStudents returns the students matching the class/section filter
+func (c *BackendClient) ListStudents(ctx context.Context, filter dto.StudentFilter) (_ []dto.StudentSummary, err error) {
+	ctx, span := tracing.Start(ctx, "backend.ListStudents",
+		attribute.String("student.class", filter.Class),
+		attribute.String("student.section", filter.Section))
+	defer func() { tracing.End(span, err) }()
+
+	query := url.Values{}
+	if filter.Class != "" {
+		query.Set("className", filter.Class)
+	}
+	if filter.Section != "" {
+		query.Set("section", filter.Section)
+	}
+	endpoint := fmt.Sprintf("%s/api/v1/students?%s", c.baseURL, query.Encode())
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	// The backend answers 404 when no student matches the filter
+	var body []byte
+	err = c.retry.Do(ctx, c.attempts("GET /api/v1/students", endpoint, "Students", &body), zap.String("class", filter.Class), zap.String("section", filter.Section))
+	if err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		Students []dto.StudentSummary `json:"students"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return payload.Students, nil
+}
+
+// attempts returns the operation retried by the retry policy: each attempt
+// fetches endpoint into body within its own client span
+func (c *BackendClient) attempts(name, endpoint, resource string, body *[]byte) func(context.Context) error {
+	attempt := 0
+	return func(ctx context.Context) error {
+		attempt++
+		ctx, span := tracing.StartClient(ctx, name,
+			semconv.HTTPRequestMethodGet,
+			semconv.URLFull(endpoint),
+			attribute.Int("retry.attempt", attempt))
+		var err error
+		*body, err = c.get(ctx, endpoint, resource)
+		tracing.End(span, err)
+		return err
+	}
+}
+
+// get fetches endpoint once and returns the body of a 200 response. A 404 is
+// reported as resource not found; network errors, 429 and 5xx responses are
+// marked as transient so the retry policy can try again.
+func (c *BackendClient) get(ctx context.Context, endpoint, resource string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -83,43 +135,47 @@ func (c *BackendClient) getStudent(ctx context.Context, id string) (*dto.Student
 	// Add API key for authentication
 	req.Header.Set("X-API-Key", c.apiKey)
 	req.Header.Set("Content-Type", "application/json")
+	// continue the trace in the backend (W3C traceparent)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return nil, retry.Transient(&errors.ServiceError{Service: "backend", Err: err}, 0)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, retry.Transient(&errors.ServiceError{
+			Service: "backend",
+			Err:     fmt.Errorf("failed to read response body: %w", err),
+		}, 0)
 	}
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, &errors.NotFoundError{Resource: "Student"}
+	trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return body, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, &errors.NotFoundError{Resource: resource}
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, &errors.ServiceError{
-			Service: "backend",
-			Err:     fmt.Errorf("returned status %d: %s", resp.StatusCode, string(body)),
-		}
+	err = &errors.ServiceError{
+		Service: "backend",
+		Err:     fmt.Errorf("returned status %d: %s", resp.StatusCode, string(body)),
 	}
-
-	var student dto.Student
-	if err := json.Unmarshal(body, &student); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return nil, retry.Transient(err, retry.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()))
 	}
-
-	return &student, nil
+	return nil, err
 }
 
 func (c *BackendClient) CheckHealth(ctx context.Context) bool {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	url := fmt.Sprintf("%s/health", c.baseURL)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	endpoint := fmt.Sprintf("%s/health", c.baseURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return false
 	}
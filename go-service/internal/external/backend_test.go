@@ -0,0 +1,163 @@
+package external
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/retry"
+)
+
+// newTestBackend serves the given statuses in turn, then the student
+func newTestBackend(t *testing.T, statuses ...int) (*BackendClient, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		if call <= len(statuses) {
+			if statuses[call-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "0")
+			}
+			w.WriteHeader(statuses[call-1])
+			return
+		}
+		w.Write([]byte(`{"id": 42, "name": "Jane Doe"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	policy := retry.NewPolicy(3, time.Millisecond, 10*time.Millisecond, nil, zap.NewNop())
+	return NewBackendClient(server.URL, "test-key", policy, zap.NewNop()), &calls
+}
+
+func TestGetStudent_RetriesTransientStatuses(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			client, calls := newTestBackend(t, status, status)
+
+			student, err := client.GetStudent(context.Background(), "42")
+
+			require.NoError(t, err)
+			assert.Equal(t, &dto.Student{ID: 42, Name: "Jane Doe"}, student)
+			assert.Equal(t, int32(3), calls.Load())
+		})
+	}
+}
+
+func TestGetStudent_ClientErrorsAreNotRetried(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			client, calls := newTestBackend(t, status)
+
+			_, err := client.GetStudent(context.Background(), "42")
+
+			assert.True(t, errors.IsServiceError(err))
+			assert.Equal(t, int32(1), calls.Load())
+		})
+	}
+}
+
+func TestGetStudent_NotFoundIsNotRetried(t *testing.T) {
+	client, calls := newTestBackend(t, http.StatusNotFound)
+
+	_, err := client.GetStudent(context.Background(), "42")
+
+	assert.True(t, errors.IsNotFound(err))
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestGetStudent_GivesUpAfterAttempts(t *testing.T) {
+	client, calls := newTestBackend(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+
+	_, err := client.GetStudent(context.Background(), "42")
+
+	assert.True(t, errors.IsServiceError(err))
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestGetStudent_NetworkErrorsAreRetried(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	var attempts atomic.Int32
+	policy := retry.NewPolicy(2, time.Millisecond, time.Millisecond, nil, zap.NewNop())
+	client := NewBackendClient(server.URL, "test-key", policy, zap.NewNop())
+	client.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		attempts.Add(1)
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	_, err := client.GetStudent(context.Background(), "42")
+
+	assert.True(t, errors.IsServiceError(err))
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// recordSpans installs a tracer provider keeping the ended spans in memory
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return exporter
+}
+
+func TestGetStudent_TracesEveryAttempt(t *testing.T) {
+	exporter := recordSpans(t)
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		if len(traceparents) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"id": 42}`))
+	}))
+	defer server.Close()
+	policy := retry.NewPolicy(3, time.Millisecond, time.Millisecond, nil, zap.NewNop())
+	client := NewBackendClient(server.URL, "test-key", policy, zap.NewNop())
+
+	_, err := client.GetStudent(context.Background(), "42")
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	first, second, parent := spans[0], spans[1], spans[2]
+	assert.Equal(t, "backend.GetStudent", parent.Name)
+	for i, attempt := range []tracetest.SpanStub{first, second} {
+		assert.Equal(t, "GET /api/v1/students/:id", attempt.Name)
+		assert.Equal(t, trace.SpanKindClient, attempt.SpanKind)
+		assert.Equal(t, parent.SpanContext.SpanID(), attempt.Parent.SpanID())
+		assert.Contains(t, attempt.Attributes, attribute.Int("retry.attempt", i+1))
+
+		// the backend continues the trace from the attempt's span
+		expected := "00-" + attempt.SpanContext.TraceID().String() + "-" + attempt.SpanContext.SpanID().String() + "-01"
+		assert.Equal(t, expected, traceparents[i])
+	}
+	assert.Contains(t, first.Attributes, attribute.Int("http.response.status_code", http.StatusBadGateway))
+	assert.Len(t, first.Events, 1, "the failed attempt records its error")
+}
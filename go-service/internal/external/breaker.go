@@ -0,0 +1,202 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/clock"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	serviceErrors "github.com/wbentaleb/student-report-service/internal/errors"
+)
+
+// ErrCircuitOpen is the cause of the ServiceError returned while the circuit
+// breaker rejects calls
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // calls go through
+	BreakerOpen                         // calls fail fast until the cool-down has passed
+	BreakerHalfOpen                     // one probe call at a time goes through
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerStats reports the state of a circuit breaker and its activity since
+// startup
+type BreakerStats struct {
+	State               BreakerState
+	ConsecutiveFailures int
+	Opened              uint64 // times the breaker opened
+	Rejected            uint64 // calls failed fast while open
+}
+
+// BreakerStatsProvider is implemented by backends guarded by a circuit breaker
+type BreakerStatsProvider interface {
+	BreakerStats() BreakerStats
+}
+
+// CircuitBreaker guards a BackendService so that an outage fails requests at
+// once instead of tying them up in retries. After failureThreshold
+// consecutive backend failures the breaker opens and calls fail with a
+// ServiceError; once cooldown has passed it lets probe calls through one at a
+// time, and closes again after successThreshold of them succeed. Only
+// ServiceErrors count as failures, so students not found and calls abandoned
+// by their caller do not. Health checks always reach the backend.
+type CircuitBreaker struct {
+	backend          BackendService
+	failureThreshold int
+	successThreshold int
+	cooldown         time.Duration
+	clock            clock.Clock
+	logger           *zap.Logger
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	successes int
+	openedAt  time.Time
+	probing   bool
+	opened    uint64
+	rejected  uint64
+}
+
+// NewCircuitBreaker wraps backend. A nil clock reads the system time.
+func NewCircuitBreaker(backend BackendService, failureThreshold, successThreshold int, cooldown time.Duration, clk clock.Clock, logger *zap.Logger) *CircuitBreaker {
+	if clk == nil {
+		clk = clock.System()
+	}
+	return &CircuitBreaker{
+		backend:          backend,
+		failureThreshold: max(failureThreshold, 1),
+		successThreshold: max(successThreshold, 1),
+		cooldown:         cooldown,
+		clock:            clk,
+		logger:           logger,
+	}
+}
+
+func (b *CircuitBreaker) GetStudent(ctx context.Context, id string) (*dto.Student, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	student, err := b.backend.GetStudent(ctx, id)
+	b.record(ctx, err)
+	return student, err
+}
+
+func (b *CircuitBreaker) ListStudents(ctx context.Context, filter dto.StudentFilter) ([]dto.StudentSummary, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	students, err := b.backend.ListStudents(ctx, filter)
+	b.record(ctx, err)
+	return students, err
+}
+
+func (b *CircuitBreaker) CheckHealth(ctx context.Context) bool {
+	return b.backend.CheckHealth(ctx)
+}
+
+// BreakerStats returns the current state of the breaker
+func (b *CircuitBreaker) BreakerStats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.coolDown()
+	return BreakerStats{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		Opened:              b.opened,
+		Rejected:            b.rejected,
+	}
+}
+
+// allow reports whether a call may go through, claiming the probe slot when
+// half-open
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.coolDown()
+
+	switch {
+	case b.state == BreakerClosed:
+		return nil
+	case b.state == BreakerHalfOpen && !b.probing:
+		b.probing = true
+		return nil
+	}
+	b.rejected++
+	return &serviceErrors.ServiceError{Service: "backend", Err: ErrCircuitOpen}
+}
+
+// coolDown moves an open breaker to half-open once the cool-down has passed
+func (b *CircuitBreaker) coolDown() {
+	if b.state == BreakerOpen && b.clock.Now().Sub(b.openedAt) >= b.cooldown {
+		b.state = BreakerHalfOpen
+		b.successes = 0
+		b.probing = false
+		b.logger.Info("Circuit breaker half-open, probing the backend")
+	}
+}
+
+func (b *CircuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := serviceErrors.IsServiceError(err)
+	if failed && ctx.Err() != nil {
+		// the caller gave up; this says nothing about the backend
+		if b.state == BreakerHalfOpen {
+			b.probing = false
+		}
+		return
+	}
+
+	switch b.state {
+	case BreakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.open()
+		}
+	case BreakerHalfOpen:
+		b.probing = false
+		if failed {
+			b.open()
+			return
+		}
+		b.successes++
+		if b.successes >= b.successThreshold {
+			b.state = BreakerClosed
+			b.failures = 0
+			b.logger.Info("Circuit breaker closed, backend recovered")
+		}
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = b.clock.Now()
+	b.opened++
+	b.logger.Warn("Circuit breaker opened, failing backend calls fast",
+		zap.Int("consecutive_failures", b.failures),
+		zap.Duration("cooldown", b.cooldown))
+}
@@ -0,0 +1,205 @@
+package external
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	serviceErrors "github.com/wbentaleb/student-report-service/internal/errors"
+)
+
+var errBackendDown = &serviceErrors.ServiceError{Service: "backend", Err: assert.AnError}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// stubBackend answers every call with err and counts the calls that reach it
+type stubBackend struct {
+	err   error
+	calls int
+}
+
+func (s *stubBackend) GetStudent(ctx context.Context, id string) (*dto.Student, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &dto.Student{Name: "Jane Doe"}, nil
+}
+
+func (s *stubBackend) ListStudents(ctx context.Context, filter dto.StudentFilter) ([]dto.StudentSummary, error) {
+	s.calls++
+	return nil, s.err
+}
+
+func (s *stubBackend) CheckHealth(ctx context.Context) bool {
+	return s.err == nil
+}
+
+func newTestBreaker(backend *stubBackend) (*CircuitBreaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)}
+	return NewCircuitBreaker(backend, 3, 2, 30*time.Second, clock, zap.NewNop()), clock
+}
+
+// tripBreaker fails the backend until the breaker opens
+func tripBreaker(t *testing.T, breaker *CircuitBreaker, backend *stubBackend) {
+	t.Helper()
+	backend.err = errBackendDown
+	for i := 0; i < 3; i++ {
+		breaker.GetStudent(context.Background(), "42")
+	}
+	require.Equal(t, BreakerOpen, breaker.BreakerStats().State)
+}
+
+func TestCircuitBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	backend := &stubBackend{err: errBackendDown}
+	breaker, _ := newTestBreaker(backend)
+
+	breaker.GetStudent(context.Background(), "42")
+	breaker.GetStudent(context.Background(), "42")
+	assert.Equal(t, BreakerClosed, breaker.BreakerStats().State)
+	breaker.GetStudent(context.Background(), "42")
+
+	stats := breaker.BreakerStats()
+	assert.Equal(t, BreakerOpen, stats.State)
+	assert.Equal(t, 3, stats.ConsecutiveFailures)
+	assert.Equal(t, uint64(1), stats.Opened)
+}
+
+func TestCircuitBreaker_SuccessResetsFailures(t *testing.T) {
+	backend := &stubBackend{err: errBackendDown}
+	breaker, _ := newTestBreaker(backend)
+
+	breaker.GetStudent(context.Background(), "42")
+	breaker.GetStudent(context.Background(), "42")
+	backend.err = nil
+	breaker.GetStudent(context.Background(), "42")
+	backend.err = errBackendDown
+	breaker.GetStudent(context.Background(), "42")
+
+	assert.Equal(t, BreakerClosed, breaker.BreakerStats().State)
+	assert.Equal(t, 1, breaker.BreakerStats().ConsecutiveFailures)
+}
+
+func TestCircuitBreaker_ClientErrorsDoNotCount(t *testing.T) {
+	backend := &stubBackend{err: &serviceErrors.NotFoundError{Resource: "Student"}}
+	breaker, _ := newTestBreaker(backend)
+
+	for i := 0; i < 5; i++ {
+		_, err := breaker.GetStudent(context.Background(), "42")
+		assert.True(t, serviceErrors.IsNotFound(err))
+	}
+
+	assert.Equal(t, BreakerClosed, breaker.BreakerStats().State)
+}
+
+func TestCircuitBreaker_AbandonedCallsDoNotCount(t *testing.T) {
+	backend := &stubBackend{err: errBackendDown}
+	breaker, _ := newTestBreaker(backend)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for i := 0; i < 5; i++ {
+		breaker.GetStudent(ctx, "42")
+	}
+
+	assert.Equal(t, BreakerClosed, breaker.BreakerStats().State)
+}
+
+func TestCircuitBreaker_FailsFastWhileOpen(t *testing.T) {
+	backend := &stubBackend{}
+	breaker, clock := newTestBreaker(backend)
+	tripBreaker(t, breaker, backend)
+
+	clock.now = clock.now.Add(29 * time.Second)
+	_, err := breaker.GetStudent(context.Background(), "42")
+	_, listErr := breaker.ListStudents(context.Background(), dto.StudentFilter{Class: "10"})
+
+	assert.True(t, serviceErrors.IsServiceError(err))
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorIs(t, listErr, ErrCircuitOpen)
+	assert.Equal(t, 3, backend.calls)
+	assert.Equal(t, uint64(2), breaker.BreakerStats().Rejected)
+}
+
+func TestCircuitBreaker_HalfOpenAfterCooldown(t *testing.T) {
+	backend := &stubBackend{}
+	breaker, clock := newTestBreaker(backend)
+	tripBreaker(t, breaker, backend)
+
+	clock.now = clock.now.Add(30 * time.Second)
+
+	assert.Equal(t, BreakerHalfOpen, breaker.BreakerStats().State)
+}
+
+func TestCircuitBreaker_ClosesAfterSuccessfulProbes(t *testing.T) {
+	backend := &stubBackend{}
+	breaker, clock := newTestBreaker(backend)
+	tripBreaker(t, breaker, backend)
+	clock.now = clock.now.Add(30 * time.Second)
+	backend.err = nil
+
+	_, err := breaker.GetStudent(context.Background(), "42")
+	require.NoError(t, err)
+	assert.Equal(t, BreakerHalfOpen, breaker.BreakerStats().State)
+	_, err = breaker.GetStudent(context.Background(), "42")
+	require.NoError(t, err)
+
+	stats := breaker.BreakerStats()
+	assert.Equal(t, BreakerClosed, stats.State)
+	assert.Zero(t, stats.ConsecutiveFailures)
+}
+
+func TestCircuitBreaker_FailedProbeReopens(t *testing.T) {
+	backend := &stubBackend{}
+	breaker, clock := newTestBreaker(backend)
+	tripBreaker(t, breaker, backend)
+	clock.now = clock.now.Add(30 * time.Second)
+
+	breaker.GetStudent(context.Background(), "42")
+
+	stats := breaker.BreakerStats()
+	assert.Equal(t, BreakerOpen, stats.State)
+	assert.Equal(t, uint64(2), stats.Opened)
+
+	// the cool-down starts over
+	clock.now = clock.now.Add(29 * time.Second)
+	_, err := breaker.GetStudent(context.Background(), "42")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+}
+
+func TestCircuitBreaker_OneProbeAtATime(t *testing.T) {
+	backend := &stubBackend{}
+	breaker, clock := newTestBreaker(backend)
+	tripBreaker(t, breaker, backend)
+	clock.now = clock.now.Add(30 * time.Second)
+
+	// the first call claims the probe slot until it completes
+	require.NoError(t, breaker.allow())
+	_, err := breaker.GetStudent(context.Background(), "42")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	backend.err = nil
+	breaker.record(context.Background(), nil)
+	_, err = breaker.GetStudent(context.Background(), "42")
+	assert.NoError(t, err)
+}
+
+func TestCircuitBreaker_HealthChecksBypassTheBreaker(t *testing.T) {
+	backend := &stubBackend{}
+	breaker, _ := newTestBreaker(backend)
+	tripBreaker(t, breaker, backend)
+	backend.err = nil
+
+	assert.True(t, breaker.CheckHealth(context.Background()))
+}
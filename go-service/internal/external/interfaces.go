@@ -8,5 +8,6 @@ import (
 
 type BackendService interface {
 	GetStudent(ctx context.Context, id string) (*dto.Student, error)
+	ListStudents(ctx context.Context, filter dto.StudentFilter) ([]dto.StudentSummary, error)
 	CheckHealth(ctx context.Context) bool
 }
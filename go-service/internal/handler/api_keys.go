@@ -0,0 +1,144 @@
+package handler
+
+import (
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/apikeys"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/errors"
+)
+
+// APIKeyHandler lets operators create, rotate and revoke the API keys of
+// the integrations calling the service
+type APIKeyHandler struct {
+	keys           *apikeys.Store
+	defaultOverlap time.Duration
+	logger         *zap.Logger
+}
+
+func NewAPIKeyHandler(keys *apikeys.Store, defaultOverlap time.Duration, logger *zap.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		keys:           keys,
+		defaultOverlap: defaultOverlap,
+		logger:         logger,
+	}
+}
+
+// List returns every key without its secret
+func (h *APIKeyHandler) List(c *gin.Context) {
+	now := time.Now()
+	response := dto.APIKeyListResponse{Keys: []dto.APIKeyResponse{}}
+	for _, key := range h.keys.List() {
+		response.Keys = append(response.Keys, toAPIKeyResponse(key, "", now))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Create adds a key and returns its token, which cannot be retrieved later
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req dto.APIKeyCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	key, token, err := h.keys.Create(req.Name, req.Scopes, req.RateLimitPerMinute, req.ExpiresAt)
+	if err != nil {
+		h.handleKeyError(c, err)
+		return
+	}
+
+	h.logger.Info("API key created",
+		zap.String("key_id", key.ID),
+		zap.String("name", key.Name),
+		zap.Strings("scopes", key.Scopes))
+	c.JSON(http.StatusCreated, toAPIKeyResponse(key, token, time.Now()))
+}
+
+// Rotate replaces a key; the old key keeps working for the requested
+// overlap, or the default one
+func (h *APIKeyHandler) Rotate(c *gin.Context) {
+	var req dto.APIKeyRotateRequest
+	// the body is optional
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+
+	overlap := h.defaultOverlap
+	if req.Overlap != "" {
+		parsed, err := time.ParseDuration(req.Overlap)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "overlap must be a duration such as 24h"})
+			return
+		}
+		overlap = parsed
+	}
+
+	id := c.Param("id")
+	key, token, err := h.keys.Rotate(id, overlap)
+	if err != nil {
+		h.handleKeyError(c, err)
+		return
+	}
+
+	h.logger.Info("API key rotated",
+		zap.String("key_id", id),
+		zap.String("new_key_id", key.ID),
+		zap.Duration("overlap", overlap))
+	c.JSON(http.StatusCreated, toAPIKeyResponse(key, token, time.Now()))
+}
+
+// Revoke disables a key at once
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.keys.Revoke(id); err != nil {
+		h.handleKeyError(c, err)
+		return
+	}
+
+	h.logger.Info("API key revoked", zap.String("key_id", id))
+	c.Status(http.StatusNoContent)
+}
+
+func (h *APIKeyHandler) handleKeyError(c *gin.Context, err error) {
+	switch {
+	case stderrors.Is(err, apikeys.ErrKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+	case errors.IsValidationError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("API key request failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+func toAPIKeyResponse(key *apikeys.Key, token string, now time.Time) dto.APIKeyResponse {
+	status := "active"
+	switch {
+	case key.RevokedAt != nil:
+		status = "revoked"
+	case !key.Active(now):
+		status = "expired"
+	}
+
+	return dto.APIKeyResponse{
+		ID:                 key.ID,
+		Name:               key.Name,
+		Scopes:             key.Scopes,
+		RateLimitPerMinute: key.RateLimitPerMinute,
+		Status:             status,
+		CreatedAt:          key.CreatedAt,
+		ExpiresAt:          key.ExpiresAt,
+		RevokedAt:          key.RevokedAt,
+		RotatedTo:          key.RotatedTo,
+		Token:              token,
+	}
+}
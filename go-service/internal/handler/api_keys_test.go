@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/apikeys"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+)
+
+func setupAPIKeyRouter(t *testing.T) (*gin.Engine, *apikeys.Store) {
+	t.Helper()
+	store, err := apikeys.NewStore(filepath.Join(t.TempDir(), "api-keys.json"))
+	require.NoError(t, err)
+	handler := NewAPIKeyHandler(store, 24*time.Hour, zap.NewNop())
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/admin/keys", handler.List)
+	router.POST("/api/v1/admin/keys", handler.Create)
+	router.POST("/api/v1/admin/keys/:id/rotate", handler.Rotate)
+	router.DELETE("/api/v1/admin/keys/:id", handler.Revoke)
+	return router, store
+}
+
+func sendAPIKeyRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyHandler_Create(t *testing.T) {
+	router, store := setupAPIKeyRouter(t)
+
+	w := sendAPIKeyRequest(router, http.MethodPost, "/api/v1/admin/keys",
+		`{"name":"portal","scopes":["reports:read"],"rate_limit_per_minute":30}`)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var response dto.APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "portal", response.Name)
+	assert.Equal(t, "active", response.Status)
+	assert.Equal(t, 30, response.RateLimitPerMinute)
+
+	key, err := store.Authenticate(response.Token)
+	require.NoError(t, err)
+	assert.Equal(t, response.ID, key.ID)
+}
+
+func TestAPIKeyHandler_CreateInvalid(t *testing.T) {
+	router, _ := setupAPIKeyRouter(t)
+
+	for _, body := range []string{
+		`{`,
+		`{"name":"portal","scopes":["reports:write"]}`,
+		`{"name":"","scopes":["reports:read"]}`,
+	} {
+		w := sendAPIKeyRequest(router, http.MethodPost, "/api/v1/admin/keys", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestAPIKeyHandler_ListOmitsTokens(t *testing.T) {
+	router, store := setupAPIKeyRouter(t)
+	_, _, err := store.Create("portal", []string{apikeys.ScopeReportsRead}, 0, nil)
+	require.NoError(t, err)
+
+	w := sendAPIKeyRequest(router, http.MethodGet, "/api/v1/admin/keys", "")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response dto.APIKeyListResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Keys, 1)
+	assert.Empty(t, response.Keys[0].Token)
+	assert.NotContains(t, w.Body.String(), "hash")
+}
+
+func TestAPIKeyHandler_Rotate(t *testing.T) {
+	router, store := setupAPIKeyRouter(t)
+	old, oldToken, err := store.Create("portal", []string{apikeys.ScopeReportsRead}, 0, nil)
+	require.NoError(t, err)
+
+	w := sendAPIKeyRequest(router, http.MethodPost, "/api/v1/admin/keys/"+old.ID+"/rotate", `{"overlap":"1h"}`)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var response dto.APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEqual(t, old.ID, response.ID)
+	_, err = store.Authenticate(response.Token)
+	require.NoError(t, err)
+
+	// the old key remains valid until the overlap ends
+	previous, err := store.Authenticate(oldToken)
+	require.NoError(t, err)
+	require.NotNil(t, previous.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *previous.ExpiresAt, time.Minute)
+	assert.Equal(t, response.ID, previous.RotatedTo)
+}
+
+func TestAPIKeyHandler_RotateDefaultsOverlap(t *testing.T) {
+	router, store := setupAPIKeyRouter(t)
+	old, oldToken, err := store.Create("portal", []string{apikeys.ScopeReportsRead}, 0, nil)
+	require.NoError(t, err)
+
+	w := sendAPIKeyRequest(router, http.MethodPost, "/api/v1/admin/keys/"+old.ID+"/rotate", "")
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	previous, err := store.Authenticate(oldToken)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *previous.ExpiresAt, time.Minute)
+}
+
+func TestAPIKeyHandler_RotateInvalid(t *testing.T) {
+	router, store := setupAPIKeyRouter(t)
+	old, _, err := store.Create("portal", []string{apikeys.ScopeReportsRead}, 0, nil)
+	require.NoError(t, err)
+
+	w := sendAPIKeyRequest(router, http.MethodPost, "/api/v1/admin/keys/"+old.ID+"/rotate", `{"overlap":"a day"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendAPIKeyRequest(router, http.MethodPost, "/api/v1/admin/keys/missing/rotate", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAPIKeyHandler_Revoke(t *testing.T) {
+	router, store := setupAPIKeyRouter(t)
+	key, token, err := store.Create("portal", []string{apikeys.ScopeReportsRead}, 0, nil)
+	require.NoError(t, err)
+
+	w := sendAPIKeyRequest(router, http.MethodDelete, "/api/v1/admin/keys/"+key.ID, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	_, err = store.Authenticate(token)
+	assert.ErrorIs(t, err, apikeys.ErrInvalidKey)
+
+	w = sendAPIKeyRequest(router, http.MethodGet, "/api/v1/admin/keys", "")
+	assert.Contains(t, w.Body.String(), `"status":"revoked"`)
+
+	w = sendAPIKeyRequest(router, http.MethodDelete, "/api/v1/admin/keys/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
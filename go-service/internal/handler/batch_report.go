@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/service"
+)
+
+type BatchReportHandler struct {
+	batchService service.BatchReportGenerator
+	logger       *zap.Logger
+}
+
+func NewBatchReportHandler(batchService service.BatchReportGenerator, logger *zap.Logger) *BatchReportHandler {
+	return &BatchReportHandler{
+		batchService: batchService,
+		logger:       logger,
+	}
+}
+
+func (h *BatchReportHandler) Handle(c *gin.Context) {
+	var req dto.BatchReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if err := validateBatchRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.batchService.GenerateBatch(c.Request.Context(), req, nil)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename="+report.FileName)
+	c.Header("X-Batch-Total", strconv.Itoa(report.Manifest.Total))
+	c.Header("X-Batch-Succeeded", strconv.Itoa(report.Manifest.Succeeded))
+	c.Header("X-Batch-Failed", strconv.Itoa(report.Manifest.Failed))
+	c.Data(http.StatusOK, report.ContentType, report.Data)
+}
+
+func validateBatchRequest(req dto.BatchReportRequest) error {
+	if len(req.StudentIDs) == 0 && req.Class == "" && req.Section == "" {
+		return fmt.Errorf("student_ids or a class/section filter is required")
+	}
+	for _, id := range req.StudentIDs {
+		if err := validateStudentID(id); err != nil {
+			return fmt.Errorf("invalid student ID %q: %w", id, err)
+		}
+	}
+	if req.Output != "" && req.Output != service.BatchOutputZIP && req.Output != service.BatchOutputPDF {
+		return fmt.Errorf("output must be either zip or pdf")
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	serviceErrors "github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/service"
+)
+
+type MockBatchReportGenerator struct {
+	mock.Mock
+}
+
+func (m *MockBatchReportGenerator) GenerateBatch(ctx context.Context, req dto.BatchReportRequest, progress service.ProgressFunc) (*service.BatchReport, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.BatchReport), args.Error(1)
+}
+
+func setupBatchRouter(handler *BatchReportHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/reports/batch", handler.Handle)
+	return router
+}
+
+func TestBatchHandle_Success(t *testing.T) {
+	// Setup
+	mockService := new(MockBatchReportGenerator)
+	router := setupBatchRouter(NewBatchReportHandler(mockService, zap.NewNop()))
+
+	expectedReq := dto.BatchReportRequest{StudentIDs: []string{"1", "2"}}
+	mockService.On("GenerateBatch", mock.Anything, expectedReq).Return(&service.BatchReport{
+		Data:        []byte("zip content"),
+		FileName:    "student_reports.zip",
+		ContentType: "application/zip",
+		Manifest:    dto.BatchManifest{Total: 2, Succeeded: 1, Failed: 1},
+	}, nil)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/reports/batch", strings.NewReader(`{"student_ids":["1","2"]}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=student_reports.zip", rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "2", rec.Header().Get("X-Batch-Total"))
+	assert.Equal(t, "1", rec.Header().Get("X-Batch-Failed"))
+	assert.Equal(t, "zip content", rec.Body.String())
+
+	mockService.AssertExpectations(t)
+}
+
+func TestBatchHandle_InvalidRequests(t *testing.T) {
+	mockService := new(MockBatchReportGenerator)
+	router := setupBatchRouter(NewBatchReportHandler(mockService, zap.NewNop()))
+
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{name: "malformed JSON", body: `{`, expected: "invalid request body"},
+		{name: "no selection", body: `{}`, expected: "student_ids or a class/section filter is required"},
+		{name: "non-numeric ID", body: `{"student_ids":["12a"]}`, expected: "student ID must be numeric"},
+		{name: "unknown output", body: `{"student_ids":["1"],"output":"tar"}`, expected: "output must be either zip or pdf"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/v1/reports/batch", strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), tc.expected)
+		})
+	}
+
+	mockService.AssertNotCalled(t, "GenerateBatch")
+}
+
+func TestBatchHandle_ServiceErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "no students", err: &serviceErrors.NotFoundError{Resource: "Students"}, status: http.StatusNotFound},
+		{name: "too many students", err: &serviceErrors.ValidationError{Message: "batch exceeds the maximum of 2 students"}, status: http.StatusBadRequest},
+		{name: "backend down", err: &serviceErrors.ServiceError{Service: "backend"}, status: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockBatchReportGenerator)
+			router := setupBatchRouter(NewBatchReportHandler(mockService, zap.NewNop()))
+			mockService.On("GenerateBatch", mock.Anything, mock.Anything).Return(nil, tc.err)
+
+			req, _ := http.NewRequest("POST", "/api/v1/reports/batch", strings.NewReader(`{"class":"10"}`))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.status, rec.Code)
+		})
+	}
+}
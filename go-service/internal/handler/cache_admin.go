@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/cache"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/service"
+)
+
+// CacheAdminHandler lets operators inspect, purge and warm the report cache
+type CacheAdminHandler struct {
+	pdfCache cache.PDFCache
+	warmer   service.CacheWarmer
+	logger   *zap.Logger
+}
+
+func NewCacheAdminHandler(pdfCache cache.PDFCache, warmer service.CacheWarmer, logger *zap.Logger) *CacheAdminHandler {
+	return &CacheAdminHandler{
+		pdfCache: pdfCache,
+		warmer:   warmer,
+		logger:   logger,
+	}
+}
+
+// List returns the cached reports, ordered by student ID and hash, with the
+// cache statistics
+func (h *CacheAdminHandler) List(c *gin.Context) {
+	entries, err := h.pdfCache.Entries()
+	if err != nil {
+		h.logger.Error("Failed to list cache entries", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].StudentID != entries[j].StudentID {
+			return entries[i].StudentID < entries[j].StudentID
+		}
+		return entries[i].Hash < entries[j].Hash
+	})
+
+	// the size of a shared cache is only known from its listing
+	stats := toCacheStats(h.pdfCache.Stats())
+	if stats.Shared {
+		count, size := len(entries), int64(0)
+		for _, entry := range entries {
+			size += entry.Size
+		}
+		stats.Entries, stats.Bytes = &count, &size
+	}
+
+	response := dto.CacheListResponse{
+		Stats:   stats,
+		Entries: make([]dto.CacheEntry, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		response.Entries = append(response.Entries, dto.CacheEntry{
+			StudentID: entry.StudentID,
+			Hash:      entry.Hash,
+			SizeBytes: entry.Size,
+			ExpiresAt: entry.ExpiresAt.UTC(),
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PurgeStudent removes every cached report of a student
+func (h *CacheAdminHandler) PurgeStudent(c *gin.Context) {
+	studentID := c.Param("id")
+	if err := validateStudentID(studentID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.pdfCache.Delete(studentID); err != nil {
+		h.logger.Error("Failed to purge cached reports", zap.String("student_id", studentID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	h.logger.Info("Cached reports purged", zap.String("student_id", studentID))
+	c.Status(http.StatusNoContent)
+}
+
+// PurgeAll empties the cache
+func (h *CacheAdminHandler) PurgeAll(c *gin.Context) {
+	if err := h.pdfCache.Purge(); err != nil {
+		h.logger.Error("Failed to purge the report cache", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	h.logger.Info("Report cache purged")
+	c.Status(http.StatusNoContent)
+}
+
+// Warm renders the reports of the requested students into the cache
+func (h *CacheAdminHandler) Warm(c *gin.Context) {
+	var req dto.CacheWarmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if err := validateWarmRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.warmer.WarmCache(c.Request.Context(), req)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func validateWarmRequest(req dto.CacheWarmRequest) error {
+	if len(req.StudentIDs) == 0 && req.Class == "" && req.Section == "" {
+		return fmt.Errorf("student_ids or a class/section filter is required")
+	}
+	for _, id := range req.StudentIDs {
+		if err := validateStudentID(id); err != nil {
+			return fmt.Errorf("invalid student ID %q: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func toCacheStats(stats cache.Stats) dto.CacheStats {
+	response := dto.CacheStats{
+		Shared:      stats.Shared,
+		Hits:        stats.Hits,
+		Misses:      stats.Misses,
+		Evictions:   stats.Evictions,
+		Expirations: stats.Expirations,
+	}
+	if !stats.Shared {
+		response.Entries = &stats.Entries
+		response.Bytes = &stats.Bytes
+	}
+	return response
+}
@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/cache"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	serviceErrors "github.com/wbentaleb/student-report-service/internal/errors"
+)
+
+type MockPDFCache struct {
+	mock.Mock
+}
+
+func (m *MockPDFCache) Get(studentID, hash string) ([]byte, bool) {
+	args := m.Called(studentID, hash)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1)
+	}
+	return args.Get(0).([]byte), args.Bool(1)
+}
+
+func (m *MockPDFCache) Set(studentID string, data []byte, hash string) error {
+	args := m.Called(studentID, data, hash)
+	return args.Error(0)
+}
+
+func (m *MockPDFCache) Delete(studentID string) error {
+	args := m.Called(studentID)
+	return args.Error(0)
+}
+
+func (m *MockPDFCache) Purge() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockPDFCache) Entries() ([]cache.Entry, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]cache.Entry), args.Error(1)
+}
+
+func (m *MockPDFCache) LatestVersion(studentID string) (string, bool) {
+	args := m.Called(studentID)
+	return args.String(0), args.Bool(1)
+}
+
+func (m *MockPDFCache) Stats() cache.Stats {
+	args := m.Called()
+	return args.Get(0).(cache.Stats)
+}
+
+type MockCacheWarmer struct {
+	mock.Mock
+}
+
+func (m *MockCacheWarmer) WarmCache(ctx context.Context, req dto.CacheWarmRequest) (*dto.CacheWarmResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.CacheWarmResponse), args.Error(1)
+}
+
+func setupCacheAdminRouter(handler *CacheAdminHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/admin/cache", handler.List)
+	router.DELETE("/api/v1/admin/cache", handler.PurgeAll)
+	router.DELETE("/api/v1/admin/cache/students/:id", handler.PurgeStudent)
+	router.POST("/api/v1/admin/cache/warm", handler.Warm)
+	return router
+}
+
+func TestCacheAdmin_List(t *testing.T) {
+	mockCache := new(MockPDFCache)
+	router := setupCacheAdminRouter(NewCacheAdminHandler(mockCache, nil, zap.NewNop()))
+
+	expiresAt := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	mockCache.On("Entries").Return([]cache.Entry{
+		{StudentID: "12", Hash: "def", Size: 20, ExpiresAt: expiresAt},
+		{StudentID: "1", Hash: "abc", Size: 10, ExpiresAt: expiresAt},
+	}, nil)
+	mockCache.On("Stats").Return(cache.Stats{Entries: 2, Bytes: 30, Hits: 5})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/cache", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response dto.CacheListResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	entries, bytes := 2, int64(30)
+	assert.Equal(t, dto.CacheStats{Entries: &entries, Bytes: &bytes, Hits: 5}, response.Stats)
+	assert.Equal(t, []dto.CacheEntry{
+		{StudentID: "1", Hash: "abc", SizeBytes: 10, ExpiresAt: expiresAt},
+		{StudentID: "12", Hash: "def", SizeBytes: 20, ExpiresAt: expiresAt},
+	}, response.Entries)
+}
+
+func TestCacheAdmin_ListSharedCache(t *testing.T) {
+	mockCache := new(MockPDFCache)
+	router := setupCacheAdminRouter(NewCacheAdminHandler(mockCache, nil, zap.NewNop()))
+
+	mockCache.On("Entries").Return([]cache.Entry{
+		{StudentID: "1", Hash: "abc", Size: 10},
+		{StudentID: "12", Hash: "def", Size: 20},
+	}, nil)
+	mockCache.On("Stats").Return(cache.Stats{Shared: true, Hits: 5})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/cache", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response dto.CacheListResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	// the size is taken from the listing
+	entries, bytes := 2, int64(30)
+	assert.Equal(t, dto.CacheStats{Shared: true, Entries: &entries, Bytes: &bytes, Hits: 5}, response.Stats)
+}
+
+func TestCacheAdmin_ListError(t *testing.T) {
+	mockCache := new(MockPDFCache)
+	router := setupCacheAdminRouter(NewCacheAdminHandler(mockCache, nil, zap.NewNop()))
+	mockCache.On("Entries").Return(nil, errors.New("connection refused"))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/cache", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestCacheAdmin_PurgeStudent(t *testing.T) {
+	mockCache := new(MockPDFCache)
+	router := setupCacheAdminRouter(NewCacheAdminHandler(mockCache, nil, zap.NewNop()))
+	mockCache.On("Delete", "42").Return(nil)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/admin/cache/students/42", nil))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	mockCache.AssertExpectations(t)
+}
+
+func TestCacheAdmin_PurgeStudent_InvalidID(t *testing.T) {
+	mockCache := new(MockPDFCache)
+	router := setupCacheAdminRouter(NewCacheAdminHandler(mockCache, nil, zap.NewNop()))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/admin/cache/students/abc", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockCache.AssertNotCalled(t, "Delete", mock.Anything)
+}
+
+func TestCacheAdmin_PurgeAll(t *testing.T) {
+	mockCache := new(MockPDFCache)
+	router := setupCacheAdminRouter(NewCacheAdminHandler(mockCache, nil, zap.NewNop()))
+	mockCache.On("Purge").Return(nil)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/admin/cache", nil))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	mockCache.AssertExpectations(t)
+}
+
+func TestCacheAdmin_Warm(t *testing.T) {
+	warmer := new(MockCacheWarmer)
+	router := setupCacheAdminRouter(NewCacheAdminHandler(new(MockPDFCache), warmer, zap.NewNop()))
+
+	req := dto.CacheWarmRequest{StudentIDs: []string{"1"}, Class: "Grade 5", Format: "html"}
+	warmer.On("WarmCache", mock.Anything, req).Return(&dto.CacheWarmResponse{
+		Total:   1,
+		Warmed:  1,
+		Entries: []dto.BatchManifestEntry{{StudentID: "1", Status: "ok"}},
+	}, nil)
+
+	body, _ := json.Marshal(req)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/cache/warm", bytes.NewReader(body)))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response dto.CacheWarmResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Warmed)
+	warmer.AssertExpectations(t)
+}
+
+func TestCacheAdmin_Warm_InvalidRequest(t *testing.T) {
+	warmer := new(MockCacheWarmer)
+	router := setupCacheAdminRouter(NewCacheAdminHandler(new(MockPDFCache), warmer, zap.NewNop()))
+
+	for name, body := range map[string]string{
+		"malformed":       `{`,
+		"no students":     `{"format": "pdf"}`,
+		"invalid student": `{"student_ids": ["1", "x"]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/cache/warm", bytes.NewBufferString(body)))
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+	warmer.AssertNotCalled(t, "WarmCache", mock.Anything, mock.Anything)
+}
+
+func TestCacheAdmin_Warm_ServiceError(t *testing.T) {
+	warmer := new(MockCacheWarmer)
+	router := setupCacheAdminRouter(NewCacheAdminHandler(new(MockPDFCache), warmer, zap.NewNop()))
+	warmer.On("WarmCache", mock.Anything, mock.Anything).Return(nil, &serviceErrors.ValidationError{Message: `unsupported format "docx"`})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/cache/warm", bytes.NewBufferString(`{"student_ids": ["1"], "format": "docx"}`)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "docx")
+}
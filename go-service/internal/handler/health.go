@@ -5,17 +5,29 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/wbentaleb/student-report-service/internal/cache"
 	"github.com/wbentaleb/student-report-service/internal/dto"
 	"github.com/wbentaleb/student-report-service/internal/external"
+	"github.com/wbentaleb/student-report-service/internal/service"
 )
 
 type HealthHandler struct {
 	backendClient external.BackendService
+	cacheStats    cache.StatsProvider
+	coalescing    service.CoalescingStatsProvider
+	breaker       external.BreakerStatsProvider
 }
 
-func NewHealthHandler(backendClient external.BackendService) *HealthHandler {
+// NewHealthHandler creates the health check handler. cacheStats may be nil
+// when caching is disabled, coalescing may be nil when report requests are
+// not coalesced, and breaker may be nil when the backend is not guarded by a
+// circuit breaker.
+func NewHealthHandler(backendClient external.BackendService, cacheStats cache.StatsProvider, coalescing service.CoalescingStatsProvider, breaker external.BreakerStatsProvider) *HealthHandler {
 	return &HealthHandler{
 		backendClient: backendClient,
+		cacheStats:    cacheStats,
+		coalescing:    coalescing,
+		breaker:       breaker,
 	}
 }
 
@@ -34,6 +46,27 @@ func (h *HealthHandler) Handle(c *gin.Context) {
 			"reachable": backendHealthy,
 		},
 	}
+	if h.cacheStats != nil {
+		stats := toCacheStats(h.cacheStats.Stats())
+		response.Cache = &stats
+	}
+	if h.coalescing != nil {
+		stats := h.coalescing.CoalescingStats()
+		response.Coalescing = &dto.CoalescingStats{
+			DeduplicatedFetches: stats.DeduplicatedFetches,
+			DeduplicatedRenders: stats.DeduplicatedRenders,
+		}
+	}
+
+	if h.breaker != nil {
+		stats := h.breaker.BreakerStats()
+		response.Breaker = &dto.BreakerStats{
+			State:               stats.State.String(),
+			ConsecutiveFailures: stats.ConsecutiveFailures,
+			Opened:              stats.Opened,
+			Rejected:            stats.Rejected,
+		}
+	}
 
 	c.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wbentaleb/student-report-service/internal/cache"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/external"
+	"github.com/wbentaleb/student-report-service/internal/service"
+)
+
+type MockBackendService struct {
+	mock.Mock
+}
+
+func (m *MockBackendService) GetStudent(ctx context.Context, id string) (*dto.Student, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.Student), args.Error(1)
+}
+
+func (m *MockBackendService) ListStudents(ctx context.Context, filter dto.StudentFilter) ([]dto.StudentSummary, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dto.StudentSummary), args.Error(1)
+}
+
+func (m *MockBackendService) CheckHealth(ctx context.Context) bool {
+	args := m.Called(ctx)
+	return args.Bool(0)
+}
+
+type stubCacheStats cache.Stats
+
+func (s stubCacheStats) Stats() cache.Stats {
+	return cache.Stats(s)
+}
+
+type stubCoalescingStats service.CoalescingStats
+
+func (s stubCoalescingStats) CoalescingStats() service.CoalescingStats {
+	return service.CoalescingStats(s)
+}
+
+type stubBreakerStats external.BreakerStats
+
+func (s stubBreakerStats) BreakerStats() external.BreakerStats {
+	return external.BreakerStats(s)
+}
+
+func serveHealth(t *testing.T, handler *HealthHandler) dto.HealthResponse {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/health", handler.Handle)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response dto.HealthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestHealthHandler_WithCacheStats(t *testing.T) {
+	backend := new(MockBackendService)
+	backend.On("CheckHealth", mock.Anything).Return(true)
+	stats := stubCacheStats{Entries: 3, Bytes: 1024, Hits: 10, Misses: 4, Evictions: 2, Expirations: 1}
+
+	response := serveHealth(t, NewHealthHandler(backend, stats, nil, nil))
+
+	assert.Equal(t, "healthy", response.Status)
+	require.NotNil(t, response.Cache)
+	entries, bytes := 3, int64(1024)
+	assert.Equal(t, dto.CacheStats{Entries: &entries, Bytes: &bytes, Hits: 10, Misses: 4, Evictions: 2, Expirations: 1}, *response.Cache)
+}
+
+func TestHealthHandler_SharedCacheSizeOmitted(t *testing.T) {
+	backend := new(MockBackendService)
+	backend.On("CheckHealth", mock.Anything).Return(true)
+	stats := stubCacheStats{Shared: true, Hits: 10, Misses: 4}
+
+	response := serveHealth(t, NewHealthHandler(backend, stats, nil, nil))
+
+	require.NotNil(t, response.Cache)
+	assert.Equal(t, dto.CacheStats{Shared: true, Hits: 10, Misses: 4}, *response.Cache)
+}
+
+func TestHealthHandler_BackendDown(t *testing.T) {
+	backend := new(MockBackendService)
+	backend.On("CheckHealth", mock.Anything).Return(false)
+
+	response := serveHealth(t, NewHealthHandler(backend, nil, nil, nil))
+
+	assert.Equal(t, "degraded", response.Status)
+	assert.False(t, response.Backend["reachable"])
+	assert.Nil(t, response.Cache)
+}
+
+func TestHealthHandler_WithCoalescingStats(t *testing.T) {
+	backend := new(MockBackendService)
+	backend.On("CheckHealth", mock.Anything).Return(true)
+	stats := stubCoalescingStats{DeduplicatedFetches: 7, DeduplicatedRenders: 4}
+
+	response := serveHealth(t, NewHealthHandler(backend, nil, stats, nil))
+
+	require.NotNil(t, response.Coalescing)
+	assert.Equal(t, dto.CoalescingStats{DeduplicatedFetches: 7, DeduplicatedRenders: 4}, *response.Coalescing)
+	assert.Nil(t, response.Cache)
+}
+
+func TestHealthHandler_WithBreakerStats(t *testing.T) {
+	backend := new(MockBackendService)
+	backend.On("CheckHealth", mock.Anything).Return(false)
+	stats := stubBreakerStats{State: external.BreakerOpen, ConsecutiveFailures: 5, Opened: 2, Rejected: 40}
+
+	response := serveHealth(t, NewHealthHandler(backend, nil, nil, stats))
+
+	require.NotNil(t, response.Breaker)
+	assert.Equal(t, dto.BreakerStats{State: "open", ConsecutiveFailures: 5, Opened: 2, Rejected: 40}, *response.Breaker)
+}
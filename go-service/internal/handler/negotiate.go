@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/service"
+)
+
+// reportMediaTypes maps the media types served by the report route to the
+// renderer formats. The first entry is the default.
+var reportMediaTypes = []struct {
+	mediaType string
+	format    string
+}{
+	{"application/pdf", service.FormatPDF},
+	{"text/html", service.FormatHTML},
+	{"text/csv", service.FormatCSV},
+	{"application/json", service.FormatJSON},
+}
+
+// acceptValue is one entry of an Accept-style header
+type acceptValue struct {
+	value string
+	q     float64
+}
+
+// parseAcceptHeader returns the entries of an Accept-style header, most
+// preferred first. Entries with q=0 are dropped.
+func parseAcceptHeader(header string) []acceptValue {
+	var values []acceptValue
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			name, raw, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.EqualFold(name, "q") {
+				if parsed, err := strconv.ParseFloat(raw, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		values = append(values, acceptValue{value: value, q: q})
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].q > values[j].q
+	})
+	return values
+}
+
+// negotiateReportFormat picks the output format from ?format= or, failing
+// that, the Accept header. ok is false when nothing acceptable is offered.
+// HTML is only negotiated when PDF is not acceptable: browsers prefer
+// text/html but accept anything, and links to reports have always opened
+// the PDF.
+func negotiateReportFormat(c *gin.Context) (format string, ok bool) {
+	if format := c.Query("format"); format != "" {
+		return format, true
+	}
+
+	header := c.GetHeader("Accept")
+	if header == "" {
+		return service.FormatPDF, true
+	}
+
+	accepted := parseAcceptHeader(header)
+	for _, value := range accepted {
+		for _, offered := range reportMediaTypes {
+			if !mediaTypeMatches(value.value, offered.mediaType) {
+				continue
+			}
+			if offered.format == service.FormatHTML && accepts(accepted, "application/pdf") {
+				return service.FormatPDF, true
+			}
+			return offered.format, true
+		}
+	}
+	return "", false
+}
+
+// accepts reports whether mediaType matches one of the accepted values
+func accepts(accepted []acceptValue, mediaType string) bool {
+	for _, value := range accepted {
+		if mediaTypeMatches(value.value, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateLocale picks the report language from ?lang= or, failing that, the
+// first supported language of the Accept-Language header. An empty result
+// selects the default language. Unsupported ?lang= values are passed through
+// so that the service can reject them.
+func negotiateLocale(c *gin.Context) string {
+	if lang := c.Query("lang"); lang != "" {
+		return lang
+	}
+
+	for _, accepted := range parseAcceptHeader(c.GetHeader("Accept-Language")) {
+		if locale, ok := i18n.Lookup(accepted.value); ok {
+			return locale.Tag
+		}
+	}
+	return ""
+}
+
+func mediaTypeMatches(accepted, offered string) bool {
+	if accepted == "*/*" || accepted == offered {
+		return true
+	}
+	if prefix, found := strings.CutSuffix(accepted, "/*"); found {
+		return strings.HasPrefix(offered, prefix+"/")
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/auth"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	serviceErrors "github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/jobs"
+)
+
+type ReportJobHandler struct {
+	jobQueue jobs.Queue
+	logger   *zap.Logger
+}
+
+func NewReportJobHandler(jobQueue jobs.Queue, logger *zap.Logger) *ReportJobHandler {
+	return &ReportJobHandler{
+		jobQueue: jobQueue,
+		logger:   logger,
+	}
+}
+
+func (h *ReportJobHandler) Create(c *gin.Context) {
+	var req dto.BatchReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if err := validateBatchRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// a class the caller may not list is refused now rather than failing the
+	// job later
+	owner, authenticated := auth.FromContext(c.Request.Context())
+	if authenticated && (req.Class != "" || req.Section != "") && !owner.MayListClass(req.Class) {
+		h.handleJobError(c, &serviceErrors.ForbiddenError{Resource: "Class"})
+		return
+	}
+	job, err := h.jobQueue.Submit(req, owner)
+	if err != nil {
+		h.handleJobError(c, err)
+		return
+	}
+
+	c.Header("Location", jobURL(job.ID))
+	c.JSON(http.StatusAccepted, toJobResponse(job))
+}
+
+func (h *ReportJobHandler) Get(c *gin.Context) {
+	job, err := h.jobQueue.Get(c.Param("id"))
+	if err == nil {
+		err = authorizeJob(c, job)
+	}
+	if err != nil {
+		h.handleJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toJobResponse(job))
+}
+
+func (h *ReportJobHandler) Download(c *gin.Context) {
+	job, data, err := h.jobQueue.Result(c.Param("id"))
+	if job != nil {
+		if authErr := authorizeJob(c, job); authErr != nil {
+			err = authErr
+		}
+	}
+	if err != nil {
+		h.handleJobError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename="+job.FileName)
+	c.Data(http.StatusOK, job.ContentType, data)
+}
+
+func (h *ReportJobHandler) handleJobError(c *gin.Context, err error) {
+	switch {
+	case serviceErrors.IsForbidden(err):
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: "Forbidden", RequestID: c.GetString("RequestID")})
+	case errors.Is(err, jobs.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+	case errors.Is(err, jobs.ErrJobNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": "Job has not completed"})
+	case errors.Is(err, jobs.ErrQueueFull), errors.Is(err, jobs.ErrManagerClosed):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Job queue unavailable, retry later"})
+	default:
+		h.logger.Error("Job request failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+// authorizeJob checks that the caller, if authenticated, submitted the job
+func authorizeJob(c *gin.Context, job *jobs.Job) error {
+	principal, ok := auth.FromContext(c.Request.Context())
+	if ok && !principal.Owns(job.Owner) {
+		return &serviceErrors.ForbiddenError{Resource: "Job"}
+	}
+	return nil
+}
+
+func toJobResponse(job *jobs.Job) dto.JobResponse {
+	response := dto.JobResponse{
+		ID:        job.ID,
+		Status:    string(job.Status),
+		Progress:  job.Progress,
+		Manifest:  job.Manifest,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+	if job.Status == jobs.StatusCompleted {
+		response.DownloadURL = jobURL(job.ID) + "/download"
+	}
+	return response
+}
+
+func jobURL(id string) string {
+	return fmt.Sprintf("/api/v1/reports/jobs/%s", id)
+}
@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/auth"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/jobs"
+)
+
+type MockJobQueue struct {
+	mock.Mock
+}
+
+func (m *MockJobQueue) Submit(req dto.BatchReportRequest, owner *auth.Principal) (*jobs.Job, error) {
+	args := m.Called(req, owner)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*jobs.Job), args.Error(1)
+}
+
+func (m *MockJobQueue) Get(id string) (*jobs.Job, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*jobs.Job), args.Error(1)
+}
+
+func (m *MockJobQueue) Result(id string) (*jobs.Job, []byte, error) {
+	args := m.Called(id)
+	var job *jobs.Job
+	if args.Get(0) != nil {
+		job = args.Get(0).(*jobs.Job)
+	}
+	var data []byte
+	if args.Get(1) != nil {
+		data = args.Get(1).([]byte)
+	}
+	return job, data, args.Error(2)
+}
+
+func setupJobRouter(handler *ReportJobHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/reports/jobs", handler.Create)
+	router.GET("/api/v1/reports/jobs/:id", handler.Get)
+	router.GET("/api/v1/reports/jobs/:id/download", handler.Download)
+	return router
+}
+
+func TestReportJob_Create(t *testing.T) {
+	// Setup
+	mockQueue := new(MockJobQueue)
+	router := setupJobRouter(NewReportJobHandler(mockQueue, zap.NewNop()))
+
+	req := dto.BatchReportRequest{Class: "10", Output: "pdf"}
+	mockQueue.On("Submit", req, (*auth.Principal)(nil)).Return(&jobs.Job{ID: "abc", Status: jobs.StatusQueued, CreatedAt: time.Now()}, nil)
+
+	// Execute
+	httpReq, _ := http.NewRequest("POST", "/api/v1/reports/jobs", strings.NewReader(`{"class":"10","output":"pdf"}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httpReq)
+
+	// Assert
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "/api/v1/reports/jobs/abc", rec.Header().Get("Location"))
+
+	var response dto.JobResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "abc", response.ID)
+	assert.Equal(t, "queued", response.Status)
+	assert.Empty(t, response.DownloadURL)
+
+	mockQueue.AssertExpectations(t)
+}
+
+func TestReportJob_CreateInvalid(t *testing.T) {
+	mockQueue := new(MockJobQueue)
+	router := setupJobRouter(NewReportJobHandler(mockQueue, zap.NewNop()))
+
+	httpReq, _ := http.NewRequest("POST", "/api/v1/reports/jobs", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockQueue.AssertNotCalled(t, "Submit")
+}
+
+func TestReportJob_CreateQueueFull(t *testing.T) {
+	mockQueue := new(MockJobQueue)
+	router := setupJobRouter(NewReportJobHandler(mockQueue, zap.NewNop()))
+	mockQueue.On("Submit", mock.Anything, mock.Anything).Return(nil, jobs.ErrQueueFull)
+
+	httpReq, _ := http.NewRequest("POST", "/api/v1/reports/jobs", strings.NewReader(`{"student_ids":["1"]}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httpReq)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestReportJob_GetCompleted(t *testing.T) {
+	// Setup
+	mockQueue := new(MockJobQueue)
+	router := setupJobRouter(NewReportJobHandler(mockQueue, zap.NewNop()))
+
+	mockQueue.On("Get", "abc").Return(&jobs.Job{
+		ID:       "abc",
+		Status:   jobs.StatusCompleted,
+		Progress: dto.JobProgress{Completed: 3, Total: 3},
+		Manifest: &dto.BatchManifest{Total: 3, Succeeded: 2, Failed: 1},
+	}, nil)
+
+	// Execute
+	httpReq, _ := http.NewRequest("GET", "/api/v1/reports/jobs/abc", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httpReq)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response dto.JobResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "completed", response.Status)
+	assert.Equal(t, 3, response.Progress.Completed)
+	assert.Equal(t, 1, response.Manifest.Failed)
+	assert.Equal(t, "/api/v1/reports/jobs/abc/download", response.DownloadURL)
+}
+
+func TestReportJob_GetNotFound(t *testing.T) {
+	mockQueue := new(MockJobQueue)
+	router := setupJobRouter(NewReportJobHandler(mockQueue, zap.NewNop()))
+	mockQueue.On("Get", "missing").Return(nil, jobs.ErrJobNotFound)
+
+	httpReq, _ := http.NewRequest("GET", "/api/v1/reports/jobs/missing", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httpReq)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Job not found")
+}
+
+func TestReportJob_Download(t *testing.T) {
+	// Setup
+	mockQueue := new(MockJobQueue)
+	router := setupJobRouter(NewReportJobHandler(mockQueue, zap.NewNop()))
+	mockQueue.On("Result", "abc").Return(&jobs.Job{
+		ID:          "abc",
+		Status:      jobs.StatusCompleted,
+		FileName:    "student_reports.pdf",
+		ContentType: "application/pdf",
+	}, []byte("%PDF-merged"), nil)
+
+	// Execute
+	httpReq, _ := http.NewRequest("GET", "/api/v1/reports/jobs/abc/download", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httpReq)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=student_reports.pdf", rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "%PDF-merged", rec.Body.String())
+}
+
+func TestReportJob_DownloadNotReady(t *testing.T) {
+	mockQueue := new(MockJobQueue)
+	router := setupJobRouter(NewReportJobHandler(mockQueue, zap.NewNop()))
+	mockQueue.On("Result", "abc").Return(&jobs.Job{ID: "abc", Status: jobs.StatusRunning}, nil, jobs.ErrJobNotReady)
+
+	httpReq, _ := http.NewRequest("GET", "/api/v1/reports/jobs/abc/download", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httpReq)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+// withPrincipal authenticates every request of router as principal
+func withPrincipal(principal *auth.Principal) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), principal))
+	}
+}
+
+func TestReportJob_CreateRecordsOwner(t *testing.T) {
+	teacher := &auth.Principal{Subject: "teacher-3", Role: auth.RoleTeacher, Classes: []string{"10"}}
+	mockQueue := new(MockJobQueue)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/reports/jobs", withPrincipal(teacher), NewReportJobHandler(mockQueue, zap.NewNop()).Create)
+	mockQueue.On("Submit", dto.BatchReportRequest{Class: "10"}, teacher).Return(&jobs.Job{ID: "abc", Status: jobs.StatusQueued}, nil)
+
+	httpReq, _ := http.NewRequest("POST", "/api/v1/reports/jobs", strings.NewReader(`{"class":"10"}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httpReq)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	mockQueue.AssertExpectations(t)
+}
+
+func TestReportJob_CreateRejectsClassOutsideScope(t *testing.T) {
+	teacher := &auth.Principal{Subject: "teacher-3", Role: auth.RoleTeacher, Classes: []string{"10"}}
+	mockQueue := new(MockJobQueue)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/reports/jobs", withPrincipal(teacher), NewReportJobHandler(mockQueue, zap.NewNop()).Create)
+
+	httpReq, _ := http.NewRequest("POST", "/api/v1/reports/jobs", strings.NewReader(`{"class":"11"}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httpReq)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	mockQueue.AssertNotCalled(t, "Submit")
+}
+
+func TestReportJob_OnlyOwnerSeesJob(t *testing.T) {
+	owner := &auth.Principal{Subject: "teacher-3", Role: auth.RoleTeacher}
+	job := &jobs.Job{ID: "abc", Status: jobs.StatusCompleted, Owner: owner, FileName: "reports.zip", ContentType: "application/zip"}
+
+	testCases := []struct {
+		name      string
+		principal *auth.Principal
+		expected  int
+	}{
+		{name: "owner", principal: &auth.Principal{Subject: "teacher-3", Role: auth.RoleTeacher}, expected: http.StatusOK},
+		{name: "admin", principal: &auth.Principal{Subject: "admin-1", Role: auth.RoleAdmin}, expected: http.StatusOK},
+		{name: "another teacher", principal: &auth.Principal{Subject: "teacher-4", Role: auth.RoleTeacher}, expected: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockQueue := new(MockJobQueue)
+			mockQueue.On("Get", "abc").Return(job, nil)
+			mockQueue.On("Result", "abc").Return(job, []byte("zip"), nil)
+			handler := NewReportJobHandler(mockQueue, zap.NewNop())
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(withPrincipal(tc.principal))
+			router.GET("/api/v1/reports/jobs/:id", handler.Get)
+			router.GET("/api/v1/reports/jobs/:id/download", handler.Download)
+
+			for _, path := range []string{"/api/v1/reports/jobs/abc", "/api/v1/reports/jobs/abc/download"} {
+				httpReq, _ := http.NewRequest("GET", path, nil)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, httpReq)
+
+				assert.Equal(t, tc.expected, rec.Code, path)
+				if tc.expected == http.StatusForbidden {
+					assert.NotContains(t, rec.Body.String(), "zip")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/registry"
+	"github.com/wbentaleb/student-report-service/internal/service"
+	"github.com/wbentaleb/student-report-service/internal/signing"
+)
+
+// maxVerifyUploadSize bounds the size of PDFs accepted for verification
+const maxVerifyUploadSize = 20 << 20
+
+type ReportVerificationHandler struct {
+	verifier service.SignatureVerifier
+	registry registry.Registry
+	logger   *zap.Logger
+}
+
+func NewReportVerificationHandler(verifier service.SignatureVerifier, reportRegistry registry.Registry, logger *zap.Logger) *ReportVerificationHandler {
+	return &ReportVerificationHandler{
+		verifier: verifier,
+		registry: reportRegistry,
+		logger:   logger,
+	}
+}
+
+// VerifyReport confirms that a report ID, typically read from the QR code in
+// a report footer, was issued by this service and returns its metadata
+func (h *ReportVerificationHandler) VerifyReport(c *gin.Context) {
+	reportID := c.Param("reportId")
+	if !registry.ValidID(reportID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid report ID"})
+		return
+	}
+
+	record, err := h.registry.Get(reportID)
+	if err != nil {
+		if errors.Is(err, registry.ErrReportNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+			return
+		}
+		h.logger.Error("Report lookup failed", zap.String("report_id", reportID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toAuthenticityResponse(record))
+}
+
+// VerifySignature checks the digital signature of a PDF uploaded either as the
+// "file" field of a multipart form or as the raw request body
+func (h *ReportVerificationHandler) VerifySignature(c *gin.Context) {
+	data, err := readUploadedPDF(c)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "PDF exceeds the maximum upload size"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a PDF file is required"})
+		return
+	}
+
+	result, err := h.verifier.Verify(data)
+	if err != nil {
+		if errors.Is(err, signing.ErrNotPDF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "uploaded file is not a PDF"})
+			return
+		}
+		h.logger.Error("Signature verification failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toVerificationResponse(result))
+}
+
+func readUploadedPDF(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxVerifyUploadSize)
+
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return io.ReadAll(c.Request.Body)
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+func toVerificationResponse(result *signing.Verification) dto.SignatureVerificationResponse {
+	response := dto.SignatureVerificationResponse{
+		Signed:   result.Signed,
+		Valid:    result.Valid,
+		Modified: result.Modified,
+		Trusted:  result.Trusted,
+		Problem:  result.Problem,
+	}
+	if !result.SignedAt.IsZero() {
+		signedAt := result.SignedAt
+		response.SignedAt = &signedAt
+	}
+
+	if cert := result.Signer; cert != nil {
+		fingerprint := sha256.Sum256(cert.Raw)
+		response.Signer = &dto.SignerInfo{
+			CommonName:   cert.Subject.CommonName,
+			Organization: strings.Join(cert.Subject.Organization, ", "),
+			Issuer:       cert.Issuer.String(),
+			SerialNumber: cert.SerialNumber.Text(16),
+			Fingerprint:  hex.EncodeToString(fingerprint[:]),
+			NotBefore:    cert.NotBefore,
+			NotAfter:     cert.NotAfter,
+		}
+	}
+	return response
+}
+
+func toAuthenticityResponse(record *registry.Record) dto.ReportAuthenticityResponse {
+	return dto.ReportAuthenticityResponse{
+		ReportID:    record.ID,
+		Authentic:   true,
+		StudentID:   record.StudentID,
+		Format:      record.Format,
+		Template:    record.Template,
+		Locale:      record.Locale,
+		Profile:     record.Profile,
+		IssuedAt:    record.IssuedAt,
+		ContentHash: record.ContentHash,
+	}
+}
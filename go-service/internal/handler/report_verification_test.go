@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/registry"
+	"github.com/wbentaleb/student-report-service/internal/signing"
+)
+
+type MockSignatureVerifier struct {
+	mock.Mock
+}
+
+func (m *MockSignatureVerifier) Verify(pdf []byte) (*signing.Verification, error) {
+	args := m.Called(pdf)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*signing.Verification), args.Error(1)
+}
+
+type MockReportRegistry struct {
+	mock.Mock
+}
+
+func (m *MockReportRegistry) Register(record *registry.Record) error {
+	args := m.Called(record)
+	return args.Error(0)
+}
+
+func (m *MockReportRegistry) Get(id string) (*registry.Record, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*registry.Record), args.Error(1)
+}
+
+func setupVerificationRouter(handler *ReportVerificationHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/reports/verify", handler.VerifySignature)
+	router.GET("/api/v1/reports/verify/:reportId", handler.VerifyReport)
+	return router
+}
+
+func multipartUpload(t *testing.T, field string, data []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(field, "report.pdf")
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return &body, writer.FormDataContentType()
+}
+
+func TestVerifySignature_SignedReport(t *testing.T) {
+	// Setup
+	mockVerifier := new(MockSignatureVerifier)
+	router := setupVerificationRouter(NewReportVerificationHandler(mockVerifier, nil, zap.NewNop()))
+
+	pdfData := []byte("%PDF-1.4 signed")
+	signedAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	signer := &x509.Certificate{
+		Raw:          []byte("certificate"),
+		Subject:      pkix.Name{CommonName: "Registrar", Organization: []string{"Test School"}},
+		Issuer:       pkix.Name{CommonName: "Test CA"},
+		SerialNumber: big.NewInt(255),
+	}
+	mockVerifier.On("Verify", pdfData).Return(&signing.Verification{
+		Signed:   true,
+		Valid:    true,
+		Trusted:  true,
+		Signer:   signer,
+		SignedAt: signedAt,
+	}, nil)
+
+	tests := []struct {
+		name        string
+		body        func() (*bytes.Buffer, string)
+		contentType string
+	}{
+		{
+			name: "multipart upload",
+			body: func() (*bytes.Buffer, string) { return multipartUpload(t, "file", pdfData) },
+		},
+		{
+			name: "raw body",
+			body: func() (*bytes.Buffer, string) { return bytes.NewBuffer(pdfData), "application/pdf" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := tt.body()
+			req, _ := http.NewRequest("POST", "/api/v1/reports/verify", body)
+			req.Header.Set("Content-Type", contentType)
+			rec := httptest.NewRecorder()
+
+			// Execute
+			router.ServeHTTP(rec, req)
+
+			// Assert
+			require.Equal(t, http.StatusOK, rec.Code)
+			var response dto.SignatureVerificationResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.True(t, response.Signed)
+			assert.True(t, response.Valid)
+			assert.False(t, response.Modified)
+			assert.True(t, response.Trusted)
+			require.NotNil(t, response.SignedAt)
+			assert.True(t, signedAt.Equal(*response.SignedAt))
+			require.NotNil(t, response.Signer)
+			assert.Equal(t, "Registrar", response.Signer.CommonName)
+			assert.Equal(t, "Test School", response.Signer.Organization)
+			assert.Equal(t, "CN=Test CA", response.Signer.Issuer)
+			assert.Equal(t, "ff", response.Signer.SerialNumber)
+			assert.Len(t, response.Signer.Fingerprint, 64)
+		})
+	}
+}
+
+func TestVerifySignature_Unsigned(t *testing.T) {
+	mockVerifier := new(MockSignatureVerifier)
+	router := setupVerificationRouter(NewReportVerificationHandler(mockVerifier, nil, zap.NewNop()))
+
+	mockVerifier.On("Verify", mock.Anything).Return(&signing.Verification{}, nil)
+
+	req, _ := http.NewRequest("POST", "/api/v1/reports/verify", bytes.NewBufferString("%PDF-1.4"))
+	req.Header.Set("Content-Type", "application/pdf")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"signed": false, "valid": false, "modified": false, "trusted": false}`, rec.Body.String())
+}
+
+func TestVerifySignature_InvalidUploads(t *testing.T) {
+	mockVerifier := new(MockSignatureVerifier)
+	router := setupVerificationRouter(NewReportVerificationHandler(mockVerifier, nil, zap.NewNop()))
+
+	mockVerifier.On("Verify", mock.Anything).Return(nil, signing.ErrNotPDF)
+
+	wrongField, wrongFieldType := multipartUpload(t, "document", []byte("%PDF-1.4"))
+
+	tests := []struct {
+		name        string
+		body        *bytes.Buffer
+		contentType string
+		wantStatus  int
+	}{
+		{name: "missing file field", body: wrongField, contentType: wrongFieldType, wantStatus: http.StatusBadRequest},
+		{name: "not a PDF", body: bytes.NewBufferString("hello"), contentType: "application/pdf", wantStatus: http.StatusBadRequest},
+		{name: "too large", body: bytes.NewBuffer(make([]byte, maxVerifyUploadSize+1)), contentType: "application/pdf", wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/v1/reports/verify", tt.body)
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestVerifyReport_Issued(t *testing.T) {
+	// Setup
+	mockRegistry := new(MockReportRegistry)
+	router := setupVerificationRouter(NewReportVerificationHandler(nil, mockRegistry, zap.NewNop()))
+
+	issuedAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	mockRegistry.On("Get", "SR-42-1709285400-0a1b2c3d").Return(&registry.Record{
+		ID:          "SR-42-1709285400-0a1b2c3d",
+		StudentID:   42,
+		Format:      "pdf",
+		Template:    "default",
+		Locale:      "en",
+		IssuedAt:    issuedAt,
+		ContentHash: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}, nil)
+
+	// Execute
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reports/verify/SR-42-1709285400-0a1b2c3d", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response dto.ReportAuthenticityResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Authentic)
+	assert.Equal(t, "SR-42-1709285400-0a1b2c3d", response.ReportID)
+	assert.Equal(t, 42, response.StudentID)
+	assert.Equal(t, "pdf", response.Format)
+	assert.Equal(t, "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", response.ContentHash)
+	assert.True(t, issuedAt.Equal(response.IssuedAt))
+}
+
+func TestVerifyReport_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		reportID       string
+		setupMock      func(*MockReportRegistry)
+		expectedStatus int
+	}{
+		{
+			name:           "malformed ID",
+			reportID:       "not-a-report",
+			setupMock:      func(m *MockReportRegistry) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "unknown ID",
+			reportID: "SR-42-1709285400-ffffffff",
+			setupMock: func(m *MockReportRegistry) {
+				m.On("Get", "SR-42-1709285400-ffffffff").Return(nil, registry.ErrReportNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:     "registry failure",
+			reportID: "SR-42-1709285400-0a1b2c3d",
+			setupMock: func(m *MockReportRegistry) {
+				m.On("Get", "SR-42-1709285400-0a1b2c3d").Return(nil, errors.New("permission denied"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRegistry := new(MockReportRegistry)
+			tt.setupMock(mockRegistry)
+			router := setupVerificationRouter(NewReportVerificationHandler(nil, mockRegistry, zap.NewNop()))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/reports/verify/"+tt.reportID, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockRegistry.AssertExpectations(t)
+		})
+	}
+}
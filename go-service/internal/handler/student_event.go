@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/service"
+)
+
+// Headers of a signed webhook delivery
+const (
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+	webhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// maxEventSize bounds the size of webhook payloads
+const maxEventSize = 64 << 10
+
+// StudentEventHandler receives the webhooks the backend sends when student
+// records change. Every delivery is signed with the shared secret: the
+// signature header carries "sha256=" followed by the hex HMAC-SHA256 of
+// "<timestamp>.<body>", and deliveries whose timestamp is further than
+// tolerance from the current time are rejected as replays.
+type StudentEventHandler struct {
+	events    service.StudentEventProcessor
+	secret    []byte
+	tolerance time.Duration
+	logger    *zap.Logger
+}
+
+func NewStudentEventHandler(events service.StudentEventProcessor, secret string, tolerance time.Duration, logger *zap.Logger) *StudentEventHandler {
+	return &StudentEventHandler{
+		events:    events,
+		secret:    []byte(secret),
+		tolerance: tolerance,
+		logger:    logger,
+	}
+}
+
+// StudentUpdated handles POST /api/v1/events/student-updated
+func (h *StudentEventHandler) StudentUpdated(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxEventSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if err := h.verifySignature(c.GetHeader(webhookTimestampHeader), c.GetHeader(webhookSignatureHeader), body, time.Now()); err != nil {
+		h.logger.Warn("Rejected webhook delivery", zap.String("client_ip", c.ClientIP()), zap.Error(err))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	var event dto.StudentUpdatedEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if event.EventID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "event_id is required"})
+		return
+	}
+	if err := validateStudentID(event.StudentID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status, err := h.events.StudentUpdated(c.Request.Context(), event)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.EventResponse{Status: status})
+}
+
+func (h *StudentEventHandler) verifySignature(timestamp, signature string, body []byte, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing or malformed %s header", webhookTimestampHeader)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > h.tolerance || age < -h.tolerance {
+		return fmt.Errorf("timestamp outside the tolerance of %s", h.tolerance)
+	}
+
+	presented, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("missing or malformed %s header", webhookSignatureHeader)
+	}
+	if !hmac.Equal(presented, webhookSignature(h.secret, timestamp, body)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// webhookSignature computes the HMAC-SHA256 of "<timestamp>.<body>"
+func webhookSignature(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
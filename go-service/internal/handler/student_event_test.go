@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/clock"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/service"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+// newEventServer serves the webhook endpoint backed by a real event service
+func newEventServer(t *testing.T, pdfCache *MockPDFCache) *httptest.Server {
+	t.Helper()
+	events := service.NewStudentEventService(pdfCache, nil, false, 10*time.Minute, clock.System(), zap.NewNop())
+	handler := NewStudentEventHandler(events, testWebhookSecret, 5*time.Minute, zap.NewNop())
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/events/student-updated", handler.StudentUpdated)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// deliver posts body signed as the backend would at the given time
+func deliver(t *testing.T, server *httptest.Server, body []byte, signedAt time.Time, secret string) (int, dto.EventResponse) {
+	t.Helper()
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/events/student-updated", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(webhookSignature([]byte(secret), timestamp, body)))
+
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var response dto.EventResponse
+	json.NewDecoder(resp.Body).Decode(&response)
+	return resp.StatusCode, response
+}
+
+func eventBody(eventID, studentID string, updatedAt time.Time) []byte {
+	body, _ := json.Marshal(dto.StudentUpdatedEvent{EventID: eventID, StudentID: studentID, UpdatedAt: updatedAt})
+	return body
+}
+
+func TestStudentEvent_InvalidatesCache(t *testing.T) {
+	pdfCache := new(MockPDFCache)
+	pdfCache.On("Delete", "42").Return(nil)
+	server := newEventServer(t, pdfCache)
+
+	status, response := deliver(t, server, eventBody("evt-1", "42", time.Now()), time.Now(), testWebhookSecret)
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, service.EventProcessed, response.Status)
+	pdfCache.AssertExpectations(t)
+}
+
+func TestStudentEvent_DuplicateAndOutOfOrderDeliveries(t *testing.T) {
+	pdfCache := new(MockPDFCache)
+	pdfCache.On("Delete", "42").Return(nil)
+	server := newEventServer(t, pdfCache)
+	earlier := time.Now().Add(-time.Minute)
+	later := time.Now()
+
+	_, first := deliver(t, server, eventBody("evt-2", "42", later), time.Now(), testWebhookSecret)
+	_, redelivered := deliver(t, server, eventBody("evt-2", "42", later), time.Now(), testWebhookSecret)
+	_, late := deliver(t, server, eventBody("evt-1", "42", earlier), time.Now(), testWebhookSecret)
+
+	assert.Equal(t, service.EventProcessed, first.Status)
+	assert.Equal(t, service.EventDuplicate, redelivered.Status)
+	assert.Equal(t, service.EventOutdated, late.Status)
+	pdfCache.AssertNumberOfCalls(t, "Delete", 1)
+}
+
+func TestStudentEvent_RejectsInvalidSignatures(t *testing.T) {
+	pdfCache := new(MockPDFCache)
+	server := newEventServer(t, pdfCache)
+	body := eventBody("evt-1", "42", time.Now())
+
+	testCases := []struct {
+		name     string
+		signedAt time.Time
+		secret   string
+	}{
+		{name: "wrong secret", signedAt: time.Now(), secret: "other-secret"},
+		{name: "replayed", signedAt: time.Now().Add(-10 * time.Minute), secret: testWebhookSecret},
+		{name: "from the future", signedAt: time.Now().Add(10 * time.Minute), secret: testWebhookSecret},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, _ := deliver(t, server, body, tc.signedAt, tc.secret)
+
+			assert.Equal(t, http.StatusUnauthorized, status)
+		})
+	}
+	pdfCache.AssertNotCalled(t, "Delete", "42")
+}
+
+func TestStudentEvent_RejectsMissingHeadersAndTamperedBody(t *testing.T) {
+	server := newEventServer(t, new(MockPDFCache))
+	body := eventBody("evt-1", "42", time.Now())
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := "sha256=" + hex.EncodeToString(webhookSignature([]byte(testWebhookSecret), timestamp, body))
+
+	testCases := []struct {
+		name      string
+		body      []byte
+		timestamp string
+		signature string
+	}{
+		{name: "no headers", body: body},
+		{name: "no signature", body: body, timestamp: timestamp},
+		{name: "malformed signature", body: body, timestamp: timestamp, signature: "md5=abc"},
+		{name: "tampered body", body: eventBody("evt-1", "43", time.Now()), timestamp: timestamp, signature: signature},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/v1/events/student-updated", bytes.NewReader(tc.body))
+			req.Header.Set("X-Webhook-Timestamp", tc.timestamp)
+			req.Header.Set("X-Webhook-Signature", tc.signature)
+
+			resp, err := server.Client().Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+	}
+}
+
+func TestStudentEvent_InvalidPayload(t *testing.T) {
+	server := newEventServer(t, new(MockPDFCache))
+
+	for name, body := range map[string][]byte{
+		"malformed":          []byte(`{`),
+		"missing event ID":   eventBody("", "42", time.Now()),
+		"invalid student ID": eventBody("evt-1", "abc", time.Now()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			status, _ := deliver(t, server, body, time.Now(), testWebhookSecret)
+
+			assert.Equal(t, http.StatusBadRequest, status)
+		})
+	}
+}
+
+func TestStudentEvent_InvalidationFailure(t *testing.T) {
+	pdfCache := new(MockPDFCache)
+	pdfCache.On("Delete", "42").Return(errors.New("connection refused")).Once()
+	pdfCache.On("Delete", "42").Return(nil).Once()
+	server := newEventServer(t, pdfCache)
+	body := eventBody("evt-1", "42", time.Now())
+
+	failed, _ := deliver(t, server, body, time.Now(), testWebhookSecret)
+	retried, response := deliver(t, server, body, time.Now(), testWebhookSecret)
+
+	assert.Equal(t, http.StatusInternalServerError, failed)
+	assert.Equal(t, http.StatusOK, retried)
+	assert.Equal(t, service.EventProcessed, response.Status)
+}
@@ -4,10 +4,13 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 
+	"github.com/wbentaleb/student-report-service/internal/dto"
 	"github.com/wbentaleb/student-report-service/internal/errors"
 	"github.com/wbentaleb/student-report-service/internal/service"
+	"github.com/wbentaleb/student-report-service/internal/tracing"
 )
 
 type StudentReportHandler struct {
@@ -24,24 +27,66 @@ func NewStudentReportHandler(reportService service.ReportService, logger *zap.Lo
 
 func (h *StudentReportHandler) Handle(c *gin.Context) {
 	studentID := c.Param("id")
+	ctx, span := tracing.Start(c.Request.Context(), "StudentReportHandler.Handle", attribute.String("student.id", studentID))
+	defer span.End()
 
 	if err := validateStudentID(studentID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	pdfData, fileName, err := h.reportService.GenerateStudentReport(c.Request.Context(), studentID)
+	format, ok := negotiateReportFormat(c)
+	if !ok {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "Requested format is not available"})
+		return
+	}
+
+	opts := service.ReportOptions{
+		Template: c.Query("template"),
+		Format:   format,
+		Locale:   negotiateLocale(c),
+		Profile:  c.Query("profile"),
+	}
+
+	span.SetAttributes(
+		attribute.String("report.format", opts.Format),
+		attribute.String("report.template", opts.Template),
+		attribute.String("report.locale", opts.Locale),
+		attribute.String("report.profile", opts.Profile))
+
+	report, err := h.reportService.GenerateStudentReport(ctx, studentID, opts)
 	if err != nil {
-		h.handleServiceError(c, err)
+		tracing.RecordError(span, err)
+		handleServiceError(c, err)
 		return
 	}
+	span.SetAttributes(attribute.Bool("report.stale", report.Stale))
 
-	c.Header("Content-Disposition", "attachment; filename="+fileName)
-	c.Data(http.StatusOK, "application/pdf", pdfData)
+	// HTML is meant for inline previews, every other format is downloaded
+	disposition := "attachment"
+	if format == service.FormatHTML {
+		disposition = "inline"
+	}
+
+	// the backend was unreachable and the last cached report was served
+	if report.Stale {
+		c.Header("Warning", `110 - "Response is Stale"`)
+		c.Header("X-Report-Stale", "true")
+	}
+
+	c.Header("Vary", "Accept, Accept-Language")
+	c.Header("Content-Language", report.Language)
+	c.Header("X-Report-Profile", report.Profile)
+	c.Header("Content-Disposition", disposition+"; filename="+report.FileName)
+	c.Data(http.StatusOK, report.ContentType, report.Data)
 }
 
-func (h *StudentReportHandler) handleServiceError(c *gin.Context, err error) {
+func handleServiceError(c *gin.Context, err error) {
 	switch {
+	case errors.IsValidationError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.IsForbidden(err):
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: "Forbidden", RequestID: c.GetString("RequestID")})
 	case errors.IsNotFound(err):
 		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
 	case errors.IsServiceError(err):
@@ -12,9 +12,16 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	serviceErrors "github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/service"
 )
 
 // Mock ReportService
@@ -22,17 +29,22 @@ type MockReportService struct {
 	mock.Mock
 }
 
-func (m *MockReportService) GenerateStudentReport(ctx context.Context, studentID string) (pdfData []byte, fileName string, err error) {
-	args := m.Called(ctx, studentID)
+func (m *MockReportService) GenerateStudentReport(ctx context.Context, studentID string, opts service.ReportOptions) (*service.Report, error) {
+	args := m.Called(ctx, studentID, opts)
 	if args.Get(0) == nil {
-		return nil, args.String(1), args.Error(2)
+		return nil, args.Error(1)
 	}
-	return args.Get(0).([]byte), args.String(1), args.Error(2)
+	return args.Get(0).(*service.Report), args.Error(1)
+}
+
+func pdfReport(data []byte, fileName string) *service.Report {
+	return &service.Report{Data: data, FileName: fileName, ContentType: "application/pdf"}
 }
 
 func setupTestRouter(handler *StudentReportHandler) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("RequestID", "test-request-id") })
 	router.GET("/api/v1/students/:id/report", handler.Handle)
 	return router
 }
@@ -60,7 +72,7 @@ func TestHandle_Success(t *testing.T) {
 	fileName := "student_12345_report.pdf"
 
 	// Setup mock
-	mockService.On("GenerateStudentReport", mock.Anything, studentID).Return(pdfData, fileName, nil)
+	mockService.On("GenerateStudentReport", mock.Anything, studentID, mock.Anything).Return(pdfReport(pdfData, fileName), nil)
 
 	// Create request
 	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report", nil)
@@ -74,10 +86,223 @@ func TestHandle_Success(t *testing.T) {
 	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
 	assert.Equal(t, "attachment; filename=student_12345_report.pdf", rec.Header().Get("Content-Disposition"))
 	assert.Equal(t, pdfData, rec.Body.Bytes())
+	assert.Empty(t, rec.Header().Get("X-Report-Stale"))
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandle_StaleReport(t *testing.T) {
+	mockService := new(MockReportService)
+	router := setupTestRouter(NewStudentReportHandler(mockService, zap.NewNop()))
+
+	report := pdfReport([]byte("cached pdf content"), "student_12345_report.pdf")
+	report.Stale = true
+	mockService.On("GenerateStudentReport", mock.Anything, "12345", mock.Anything).Return(report, nil)
+
+	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `110 - "Response is Stale"`, rec.Header().Get("Warning"))
+	assert.Equal(t, "true", rec.Header().Get("X-Report-Stale"))
+	assert.Equal(t, []byte("cached pdf content"), rec.Body.Bytes())
+}
+
+func TestHandle_TemplateQueryParam(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockService := new(MockReportService)
+	handler := NewStudentReportHandler(mockService, logger)
+	router := setupTestRouter(handler)
+
+	opts := service.ReportOptions{Template: "branded", Format: service.FormatPDF}
+	mockService.On("GenerateStudentReport", mock.Anything, "12345", opts).Return(pdfReport([]byte("pdf"), "student_12345_report.pdf"), nil)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report?template=branded", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestHandle_ProfileQueryParam(t *testing.T) {
+	mockService := new(MockReportService)
+	handler := NewStudentReportHandler(mockService, zap.NewNop())
+	router := setupTestRouter(handler)
+
+	opts := service.ReportOptions{Format: service.FormatPDF, Profile: "transcript"}
+	report := pdfReport([]byte("pdf"), "student_12345_report.pdf")
+	report.Profile = "transcript"
+	mockService.On("GenerateStudentReport", mock.Anything, "12345", opts).Return(report, nil)
+
+	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report?profile=transcript", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
 
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "transcript", rec.Header().Get("X-Report-Profile"))
 	mockService.AssertExpectations(t)
 }
 
+func TestHandle_FormatNegotiation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		url         string
+		accept      string
+		format      string
+		contentType string
+		disposition string
+	}{
+		{"default", "/api/v1/students/12345/report", "", service.FormatPDF, "application/pdf", "attachment; filename=student_12345_report.pdf"},
+		{"query parameter", "/api/v1/students/12345/report?format=csv", "application/json", service.FormatCSV, "text/csv; charset=utf-8", "attachment; filename=student_12345_report.csv"},
+		{"accept header", "/api/v1/students/12345/report", "application/json", service.FormatJSON, "application/json; charset=utf-8", "attachment; filename=student_12345_report.json"},
+		{"accept quality", "/api/v1/students/12345/report", "application/pdf;q=0.5, text/csv", service.FormatCSV, "text/csv; charset=utf-8", "attachment; filename=student_12345_report.csv"},
+		{"html when pdf is not acceptable", "/api/v1/students/12345/report", "text/html, application/json;q=0.5", service.FormatHTML, "text/html; charset=utf-8", "inline; filename=student_12345_report.html"},
+		{"pdf over preferred html", "/api/v1/students/12345/report", "text/html, application/pdf;q=0.5", service.FormatPDF, "application/pdf", "attachment; filename=student_12345_report.pdf"},
+		{"browser", "/api/v1/students/12345/report", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7", service.FormatPDF, "application/pdf", "attachment; filename=student_12345_report.pdf"},
+		{"application wildcard", "/api/v1/students/12345/report", "text/html, application/*;q=0.1", service.FormatPDF, "application/pdf", "attachment; filename=student_12345_report.pdf"},
+		{"html query parameter", "/api/v1/students/12345/report?format=html", "*/*", service.FormatHTML, "text/html; charset=utf-8", "inline; filename=student_12345_report.html"},
+		{"wildcard", "/api/v1/students/12345/report", "*/*", service.FormatPDF, "application/pdf", "attachment; filename=student_12345_report.pdf"},
+		{"type wildcard", "/api/v1/students/12345/report", "image/png, text/*", service.FormatHTML, "text/html; charset=utf-8", "inline; filename=student_12345_report.html"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockService := new(MockReportService)
+			handler := NewStudentReportHandler(mockService, zap.NewNop())
+			router := setupTestRouter(handler)
+
+			report := &service.Report{
+				Data:        []byte("report"),
+				FileName:    "student_12345_report." + tc.format,
+				ContentType: tc.contentType,
+			}
+			opts := service.ReportOptions{Format: tc.format}
+			mockService.On("GenerateStudentReport", mock.Anything, "12345", opts).Return(report, nil)
+
+			// Execute
+			req, _ := http.NewRequest("GET", tc.url, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tc.contentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, tc.disposition, rec.Header().Get("Content-Disposition"))
+			assert.Equal(t, "Accept, Accept-Language", rec.Header().Get("Vary"))
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandle_NotAcceptable(t *testing.T) {
+	// Setup
+	mockService := new(MockReportService)
+	handler := NewStudentReportHandler(mockService, zap.NewNop())
+	router := setupTestRouter(handler)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report", nil)
+	req.Header.Set("Accept", "image/png, application/pdf;q=0")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	mockService.AssertNotCalled(t, "GenerateStudentReport")
+}
+
+func TestHandle_LanguageNegotiation(t *testing.T) {
+	testCases := []struct {
+		name           string
+		url            string
+		acceptLanguage string
+		locale         string
+	}{
+		{"default", "/api/v1/students/12345/report", "", ""},
+		{"query parameter", "/api/v1/students/12345/report?lang=ar", "fr", "ar"},
+		{"accept-language", "/api/v1/students/12345/report", "fr-CA, en;q=0.8", "fr"},
+		{"accept-language quality", "/api/v1/students/12345/report", "en;q=0.5, ar", "ar"},
+		{"unsupported languages skipped", "/api/v1/students/12345/report", "de, es;q=0.9, fr;q=0.1", "fr"},
+		{"nothing supported", "/api/v1/students/12345/report", "de, *", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockService := new(MockReportService)
+			handler := NewStudentReportHandler(mockService, zap.NewNop())
+			router := setupTestRouter(handler)
+
+			report := pdfReport([]byte("%PDF-1.4"), "student_12345_report.pdf")
+			report.Language = tc.locale
+			opts := service.ReportOptions{Format: service.FormatPDF, Locale: tc.locale}
+			mockService.On("GenerateStudentReport", mock.Anything, "12345", opts).Return(report, nil)
+
+			// Execute
+			req, _ := http.NewRequest("GET", tc.url, nil)
+			if tc.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tc.acceptLanguage)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tc.locale, rec.Header().Get("Content-Language"))
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandle_UnsupportedLanguage(t *testing.T) {
+	// Setup
+	mockService := new(MockReportService)
+	handler := NewStudentReportHandler(mockService, zap.NewNop())
+	router := setupTestRouter(handler)
+
+	opts := service.ReportOptions{Format: service.FormatPDF, Locale: "de"}
+	mockService.On("GenerateStudentReport", mock.Anything, "12345", opts).
+		Return(nil, &serviceErrors.ValidationError{Message: `unsupported language "de"`})
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report?lang=de", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "unsupported language")
+}
+
+func TestHandle_UnknownTemplate(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockService := new(MockReportService)
+	handler := NewStudentReportHandler(mockService, logger)
+	router := setupTestRouter(handler)
+
+	validationErr := &serviceErrors.ValidationError{Message: `unknown template "missing"`}
+	mockService.On("GenerateStudentReport", mock.Anything, "12345", mock.Anything).Return(nil, validationErr)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report?template=missing", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "unknown template")
+}
+
 func TestHandle_InvalidStudentID_Empty(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
@@ -155,7 +380,7 @@ func TestHandle_StudentNotFound(t *testing.T) {
 	notFoundErr := &serviceErrors.NotFoundError{Resource: "Student"}
 
 	// Setup mock
-	mockService.On("GenerateStudentReport", mock.Anything, studentID).Return(nil, "", notFoundErr)
+	mockService.On("GenerateStudentReport", mock.Anything, studentID, mock.Anything).Return(nil, notFoundErr)
 
 	// Create request
 	req, _ := http.NewRequest("GET", "/api/v1/students/99999/report", nil)
@@ -171,6 +396,19 @@ func TestHandle_StudentNotFound(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestHandle_Forbidden(t *testing.T) {
+	mockService := new(MockReportService)
+	router := setupTestRouter(NewStudentReportHandler(mockService, zap.NewNop()))
+	mockService.On("GenerateStudentReport", mock.Anything, "12345", mock.Anything).Return(nil, &serviceErrors.ForbiddenError{Resource: "Student report"})
+
+	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.JSONEq(t, `{"error": "Forbidden", "request_id": "test-request-id"}`, rec.Body.String())
+}
+
 func TestHandle_ServiceError(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
@@ -182,7 +420,7 @@ func TestHandle_ServiceError(t *testing.T) {
 	serviceErr := &serviceErrors.ServiceError{Service: "Backend", Err: errors.New("backend unavailable")}
 
 	// Setup mock
-	mockService.On("GenerateStudentReport", mock.Anything, studentID).Return(nil, "", serviceErr)
+	mockService.On("GenerateStudentReport", mock.Anything, studentID, mock.Anything).Return(nil, serviceErr)
 
 	// Create request
 	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report", nil)
@@ -209,7 +447,7 @@ func TestHandle_PDFGenerationError(t *testing.T) {
 	pdfErr := serviceErrors.NewPDFGenerationError(errors.New("pdf generation failed"))
 
 	// Setup mock
-	mockService.On("GenerateStudentReport", mock.Anything, studentID).Return(nil, "", pdfErr)
+	mockService.On("GenerateStudentReport", mock.Anything, studentID, mock.Anything).Return(nil, pdfErr)
 
 	// Create request
 	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report", nil)
@@ -236,7 +474,7 @@ func TestHandle_GenericError(t *testing.T) {
 	genericErr := errors.New("unexpected error")
 
 	// Setup mock
-	mockService.On("GenerateStudentReport", mock.Anything, studentID).Return(nil, "", genericErr)
+	mockService.On("GenerateStudentReport", mock.Anything, studentID, mock.Anything).Return(nil, genericErr)
 
 	// Create request
 	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report", nil)
@@ -317,7 +555,7 @@ func TestHandle_ValidStudentIDs(t *testing.T) {
 				// Setup mock for valid IDs
 				pdfData := []byte("test pdf")
 				fileName := "test.pdf"
-				mockService.On("GenerateStudentReport", mock.Anything, tc.studentID).Return(pdfData, fileName, nil).Maybe()
+				mockService.On("GenerateStudentReport", mock.Anything, tc.studentID, mock.Anything).Return(pdfReport(pdfData, fileName), nil).Maybe()
 			}
 
 			// Create request
@@ -353,7 +591,7 @@ func TestHandle_LargePDFResponse(t *testing.T) {
 	fileName := "large_report.pdf"
 
 	// Setup mock
-	mockService.On("GenerateStudentReport", mock.Anything, studentID).Return(largePDF, fileName, nil)
+	mockService.On("GenerateStudentReport", mock.Anything, studentID, mock.Anything).Return(pdfReport(largePDF, fileName), nil)
 
 	// Create request
 	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report", nil)
@@ -379,10 +617,10 @@ func TestHandle_ContextCancellation(t *testing.T) {
 	studentID := "12345"
 
 	// Setup mock that checks context
-	mockService.On("GenerateStudentReport", mock.Anything, studentID).Run(func(args mock.Arguments) {
+	mockService.On("GenerateStudentReport", mock.Anything, studentID, mock.Anything).Run(func(args mock.Arguments) {
 		ctx := args.Get(0).(context.Context)
 		require.NotNil(t, ctx)
-	}).Return([]byte("pdf"), "file.pdf", nil)
+	}).Return(pdfReport([]byte("pdf"), "file.pdf"), nil)
 
 	// Create request with context
 	ctx, cancel := context.WithCancel(context.Background())
@@ -417,7 +655,7 @@ func TestHandle_EmptyPDFData(t *testing.T) {
 	fileName := "empty.pdf"
 
 	// Setup mock
-	mockService.On("GenerateStudentReport", mock.Anything, studentID).Return(emptyPDF, fileName, nil)
+	mockService.On("GenerateStudentReport", mock.Anything, studentID, mock.Anything).Return(pdfReport(emptyPDF, fileName), nil)
 
 	// Create request
 	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report", nil)
@@ -448,7 +686,7 @@ func TestHandle_SpecialCharactersInFileName(t *testing.T) {
 	fileName := "student_12345_report's & \"quotes\".pdf"
 
 	// Setup mock
-	mockService.On("GenerateStudentReport", mock.Anything, studentID).Return(pdfData, fileName, nil)
+	mockService.On("GenerateStudentReport", mock.Anything, studentID, mock.Anything).Return(pdfReport(pdfData, fileName), nil)
 
 	// Create request
 	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report", nil)
@@ -475,7 +713,7 @@ func BenchmarkHandle_Success(b *testing.B) {
 	pdfData := bytes.Repeat([]byte("test"), 250) // 1KB PDF
 	fileName := "report.pdf"
 
-	mockService.On("GenerateStudentReport", mock.Anything, "12345").Return(pdfData, fileName, nil)
+	mockService.On("GenerateStudentReport", mock.Anything, "12345", mock.Anything).Return(pdfReport(pdfData, fileName), nil)
 
 	gin.SetMode(gin.ReleaseMode)
 
@@ -500,3 +738,31 @@ func BenchmarkStudentIDValidation(b *testing.B) {
 		_ = validateStudentID(testID)
 	}
 }
+
+func TestHandle_TracesTheRequest(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	mockService := new(MockReportService)
+	router := setupTestRouter(NewStudentReportHandler(mockService, zap.NewNop()))
+	var serviceSpan trace.SpanContext
+	mockService.On("GenerateStudentReport", mock.Anything, "12345", mock.Anything).
+		Run(func(args mock.Arguments) {
+			serviceSpan = trace.SpanContextFromContext(args.Get(0).(context.Context))
+		}).
+		Return(nil, &serviceErrors.ServiceError{Service: "backend", Err: errors.New("connection refused")})
+
+	req, _ := http.NewRequest("GET", "/api/v1/students/12345/report?template=default", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "StudentReportHandler.Handle", span.Name)
+	assert.Equal(t, span.SpanContext.SpanID(), serviceSpan.SpanID(), "the service runs within the handler span")
+	assert.Contains(t, span.Attributes, attribute.String("student.id", "12345"))
+	assert.Contains(t, span.Attributes, attribute.String("report.template", "default"))
+	assert.Equal(t, codes.Error, span.Status.Code)
+}
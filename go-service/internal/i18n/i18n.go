@@ -0,0 +1,161 @@
+// Package i18n holds the message catalogs used to localise reports. Messages
+// are keyed by their English text, so template labels without a translation
+// are printed as written.
+package i18n
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultTag is the locale used when a request does not ask for one
+const DefaultTag = "en"
+
+//go:embed locales/*.yaml
+var catalogFiles embed.FS
+
+// Locale is the message catalog and date formats of a language
+type Locale struct {
+	Tag            string            `yaml:"tag"`
+	Name           string            `yaml:"name"`
+	Direction      string            `yaml:"direction"`
+	DateFormat     string            `yaml:"date_format"`
+	DateTimeFormat string            `yaml:"date_time_format"`
+	Months         []string          `yaml:"months"`
+	Messages       map[string]string `yaml:"messages"`
+
+	fingerprint string
+}
+
+// Parse decodes and validates a YAML catalog
+func Parse(data []byte) (*Locale, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var locale Locale
+	if err := decoder.Decode(&locale); err != nil {
+		return nil, fmt.Errorf("failed to decode catalog: %w", err)
+	}
+
+	if locale.Tag == "" || locale.Tag != strings.ToLower(locale.Tag) {
+		return nil, fmt.Errorf("catalog tag %q must be a lower case language tag", locale.Tag)
+	}
+	if locale.Direction != "ltr" && locale.Direction != "rtl" {
+		return nil, fmt.Errorf("catalog %q: direction must be ltr or rtl", locale.Tag)
+	}
+	if locale.DateFormat == "" || locale.DateTimeFormat == "" {
+		return nil, fmt.Errorf("catalog %q: date formats are required", locale.Tag)
+	}
+	if len(locale.Months) != 12 {
+		return nil, fmt.Errorf("catalog %q: expected 12 month names, got %d", locale.Tag, len(locale.Months))
+	}
+
+	hash := sha256.Sum256(data)
+	locale.fingerprint = hex.EncodeToString(hash[:])[:8]
+	return &locale, nil
+}
+
+var locales = func() map[string]*Locale {
+	entries, err := catalogFiles.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("failed to read built-in catalogs: %v", err))
+	}
+
+	byTag := make(map[string]*Locale, len(entries))
+	for _, entry := range entries {
+		data, err := catalogFiles.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("failed to read built-in catalog %s: %v", entry.Name(), err))
+		}
+		locale, err := Parse(data)
+		if err != nil {
+			panic(fmt.Sprintf("invalid built-in catalog %s: %v", entry.Name(), err))
+		}
+		byTag[locale.Tag] = locale
+	}
+	if _, ok := byTag[DefaultTag]; !ok {
+		panic("missing built-in catalog " + DefaultTag)
+	}
+	return byTag
+}()
+
+// Default returns the English locale
+func Default() *Locale {
+	return locales[DefaultTag]
+}
+
+// Lookup returns the locale matching a language tag such as "fr" or "fr-CA".
+// Region subtags fall back to the base language.
+func Lookup(tag string) (*Locale, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if locale, ok := locales[tag]; ok {
+		return locale, true
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	locale, ok := locales[base]
+	return locale, ok
+}
+
+// Tags lists the available locales
+func Tags() []string {
+	tags := make([]string, 0, len(locales))
+	for tag := range locales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// RTL reports whether the language is written right to left
+func (l *Locale) RTL() bool {
+	return l.Direction == "rtl"
+}
+
+// CacheKey identifies the rendered variant produced by this locale. It
+// changes whenever the catalog does.
+func (l *Locale) CacheKey() string {
+	return l.Tag + "-" + l.fingerprint
+}
+
+// Translate returns the translation of an English message, or the message
+// itself when the catalog has none
+func (l *Locale) Translate(message string) string {
+	if translated, ok := l.Messages[message]; ok {
+		return translated
+	}
+	return message
+}
+
+// Sprintf translates format, then formats it like fmt.Sprintf
+func (l *Locale) Sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(l.Translate(format), args...)
+}
+
+// FormatDate prints the day, month and year of t
+func (l *Locale) FormatDate(t time.Time) string {
+	return l.format(t, l.DateFormat)
+}
+
+// FormatDateTime prints the date and time of day of t
+func (l *Locale) FormatDateTime(t time.Time) string {
+	return l.format(t, l.DateTimeFormat)
+}
+
+// format applies a time layout written with the English month name
+// ("January") and substitutes the month name of the locale
+func (l *Locale) format(t time.Time, layout string) string {
+	formatted := t.Format(layout)
+	if strings.Contains(layout, "January") {
+		formatted = strings.Replace(formatted, t.Month().String(), l.Months[t.Month()-1], 1)
+	}
+	return formatted
+}
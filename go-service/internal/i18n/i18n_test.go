@@ -0,0 +1,128 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    string
+		wantErr bool
+	}{
+		{tag: "en", want: "en"},
+		{tag: "fr", want: "fr"},
+		{tag: "fr-CA", want: "fr"},
+		{tag: "ar_MA", want: "ar"},
+		{tag: "AR", want: "ar"},
+		{tag: "de", wantErr: true},
+		{tag: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			locale, ok := Lookup(tt.tag)
+			if tt.wantErr {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tt.want, locale.Tag)
+		})
+	}
+}
+
+func TestTags(t *testing.T) {
+	assert.Equal(t, []string{"ar", "en", "fr"}, Tags())
+}
+
+func TestCatalogs_TranslateEveryMessage(t *testing.T) {
+	for _, tag := range Tags() {
+		if tag == DefaultTag {
+			continue
+		}
+		locale, _ := Lookup(tag)
+		for message := range locale.Messages {
+			assert.NotEqual(t, message, locale.Translate(message), "%s: %q is not translated", tag, message)
+		}
+		// Every catalog covers the same messages as the French one
+		french, _ := Lookup("fr")
+		for message := range french.Messages {
+			assert.Contains(t, locale.Messages, message, "%s: missing %q", tag, message)
+		}
+	}
+}
+
+func TestTranslate_FallsBackToMessage(t *testing.T) {
+	locale, _ := Lookup("fr")
+
+	assert.Equal(t, "Informations personnelles", locale.Translate("Personal Information"))
+	assert.Equal(t, "Bus Route", locale.Translate("Bus Route"))
+	assert.Equal(t, "Bus Route", Default().Translate("Bus Route"))
+}
+
+func TestSprintf(t *testing.T) {
+	locale, _ := Lookup("fr")
+
+	assert.Equal(t, "Identifiant du rapport : SR-7-42-0a1b2c3d", locale.Sprintf("Report ID: %s", "SR-7-42-0a1b2c3d"))
+}
+
+func TestFormatDate(t *testing.T) {
+	date := time.Date(2024, time.August, 5, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		tag          string
+		wantDate     string
+		wantDateTime string
+	}{
+		{tag: "en", wantDate: "August 5, 2024", wantDateTime: "August 5, 2024 at 2:30 PM"},
+		{tag: "fr", wantDate: "5 août 2024", wantDateTime: "5 août 2024 à 14:30"},
+		{tag: "ar", wantDate: "5 أغسطس 2024", wantDateTime: "5 أغسطس 2024، 14:30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			locale, _ := Lookup(tt.tag)
+
+			assert.Equal(t, tt.wantDate, locale.FormatDate(date))
+			assert.Equal(t, tt.wantDateTime, locale.FormatDateTime(date))
+		})
+	}
+}
+
+func TestLocale_Direction(t *testing.T) {
+	arabic, _ := Lookup("ar")
+
+	assert.True(t, arabic.RTL())
+	assert.False(t, Default().RTL())
+}
+
+func TestCacheKey_DiffersPerLocale(t *testing.T) {
+	french, _ := Lookup("fr")
+
+	assert.NotEqual(t, Default().CacheKey(), french.CacheKey())
+	assert.Contains(t, french.CacheKey(), "fr-")
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{name: "missing tag", source: "direction: ltr\ndate_format: x\ndate_time_format: x\nmonths: [a, b, c, d, e, f, g, h, i, j, k, l]"},
+		{name: "bad direction", source: "tag: de\ndirection: up\ndate_format: x\ndate_time_format: x\nmonths: [a, b, c, d, e, f, g, h, i, j, k, l]"},
+		{name: "missing months", source: "tag: de\ndirection: ltr\ndate_format: x\ndate_time_format: x\nmonths: [a]"},
+		{name: "unknown key", source: "tag: de\nlanguage: German"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.source))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/wbentaleb/student-report-service/internal/auth"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+// Job is an asynchronous batch report request and its outcome
+type Job struct {
+	ID          string                 `json:"id"`
+	Status      Status                 `json:"status"`
+	Request     dto.BatchReportRequest `json:"request"`
+	Owner       *auth.Principal        `json:"owner,omitempty"`
+	Progress    dto.JobProgress        `json:"progress"`
+	Manifest    *dto.BatchManifest     `json:"manifest,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	FileName    string                 `json:"file_name,omitempty"`
+	ContentType string                 `json:"content_type,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+// Finished reports whether the job reached a terminal status
+func (j *Job) Finished() bool {
+	return j.Status == StatusCompleted || j.Status == StatusFailed
+}
+
+func (j *Job) clone() *Job {
+	c := *j
+	if j.Manifest != nil {
+		manifest := *j.Manifest
+		c.Manifest = &manifest
+	}
+	return &c
+}
@@ -0,0 +1,358 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/auth"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/service"
+)
+
+var (
+	ErrQueueFull     = errors.New("job queue is full")
+	ErrJobNotReady   = errors.New("job has not completed")
+	ErrManagerClosed = errors.New("job manager is not running")
+)
+
+// Queue is the job API consumed by the HTTP handlers
+type Queue interface {
+	Submit(req dto.BatchReportRequest, owner *auth.Principal) (*Job, error)
+	Get(id string) (*Job, error)
+	Result(id string) (*Job, []byte, error)
+}
+
+// Manager runs batch report jobs on a fixed pool of workers. Job state is
+// written to the Store on every transition, so unfinished jobs are picked
+// up again by Start after a restart.
+type Manager struct {
+	store      Store
+	generator  service.BatchReportGenerator
+	workers    int
+	jobTimeout time.Duration
+	retention  time.Duration
+	queue      chan string
+	logger     *zap.Logger
+
+	mu      sync.Mutex // serialises read-modify-write cycles on the store
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running atomic.Bool // read by Submit from the request handlers
+}
+
+func NewManager(
+	store Store,
+	generator service.BatchReportGenerator,
+	workers int,
+	queueSize int,
+	jobTimeout time.Duration,
+	retention time.Duration,
+	logger *zap.Logger,
+) *Manager {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	return &Manager{
+		store:      store,
+		generator:  generator,
+		workers:    workers,
+		jobTimeout: jobTimeout,
+		retention:  retention,
+		queue:      make(chan string, queueSize),
+		logger:     logger,
+	}
+}
+
+// Start launches the workers and re-queues the jobs left unfinished by a
+// previous run.
+func (m *Manager) Start() error {
+	pending, err := m.recover()
+	if err != nil {
+		return err
+	}
+
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.running.Store(true)
+
+	for i := 0; i < m.workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+
+	m.wg.Add(1)
+	go m.requeue(pending)
+
+	if m.retention > 0 {
+		m.wg.Add(1)
+		go m.pruneWorker()
+	}
+
+	m.logger.Info("Job manager started",
+		zap.Int("workers", m.workers),
+		zap.Int("recovered_jobs", len(pending)))
+	return nil
+}
+
+// Stop cancels in-flight jobs and waits for the workers to exit. Interrupted
+// jobs stay in the running state and are resumed on the next Start.
+func (m *Manager) Stop() {
+	if !m.running.CompareAndSwap(true, false) {
+		return
+	}
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Submit queues req. The reports are generated on behalf of owner, who may be
+// nil when authentication is disabled.
+func (m *Manager) Submit(req dto.BatchReportRequest, owner *auth.Principal) (*Job, error) {
+	if !m.running.Load() {
+		return nil, ErrManagerClosed
+	}
+
+	now := time.Now().UTC()
+	job := &Job{
+		ID:        uuid.New().String(),
+		Status:    StatusQueued,
+		Request:   req,
+		Owner:     owner,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := m.store.Save(job); err != nil {
+		return nil, fmt.Errorf("failed to persist job: %w", err)
+	}
+
+	select {
+	case m.queue <- job.ID:
+	default:
+		if err := m.store.Delete(job.ID); err != nil {
+			m.logger.Warn("Failed to remove rejected job", zap.String("job_id", job.ID), zap.Error(err))
+		}
+		return nil, ErrQueueFull
+	}
+
+	m.logger.Info("Job queued", zap.String("job_id", job.ID))
+	return job, nil
+}
+
+func (m *Manager) Get(id string) (*Job, error) {
+	// Job IDs are used as file names by the store
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrJobNotFound
+	}
+	return m.store.Get(id)
+}
+
+func (m *Manager) Result(id string) (*Job, []byte, error) {
+	job, err := m.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != StatusCompleted {
+		return job, nil, ErrJobNotReady
+	}
+
+	data, err := m.store.GetResult(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, data, nil
+}
+
+// recover returns the IDs of every unfinished job, oldest first
+func (m *Manager) recover() ([]string, error) {
+	jobs, err := m.store.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jobs: %w", err)
+	}
+
+	var pending []*Job
+	for _, job := range jobs {
+		if !job.Finished() {
+			pending = append(pending, job)
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+
+	ids := make([]string, 0, len(pending))
+	for _, job := range pending {
+		ids = append(ids, job.ID)
+	}
+	return ids, nil
+}
+
+func (m *Manager) requeue(ids []string) {
+	defer m.wg.Done()
+
+	for _, id := range ids {
+		select {
+		case m.queue <- id:
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case id := <-m.queue:
+			m.process(id)
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Manager) process(id string) {
+	job, err := m.update(id, func(job *Job) {
+		job.Status = StatusRunning
+		job.Progress = dto.JobProgress{}
+	})
+	if err != nil {
+		m.logger.Error("Failed to start job", zap.String("job_id", id), zap.Error(err))
+		return
+	}
+
+	// reports are generated with the rights of whoever submitted the job
+	ctx := m.ctx
+	if job.Owner != nil {
+		ctx = auth.NewContext(ctx, job.Owner)
+	}
+	if m.jobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.jobTimeout)
+		defer cancel()
+	}
+
+	m.logger.Info("Job started", zap.String("job_id", id))
+
+	report, err := m.generator.GenerateBatch(ctx, job.Request, func(completed, total int) {
+		if _, err := m.update(id, func(job *Job) {
+			job.Progress = dto.JobProgress{Completed: completed, Total: total}
+		}); err != nil {
+			m.logger.Warn("Failed to record job progress", zap.String("job_id", id), zap.Error(err))
+		}
+	})
+
+	// Shutting down: leave the job running so the next Start resumes it
+	if m.ctx.Err() != nil {
+		m.logger.Info("Job interrupted by shutdown", zap.String("job_id", id))
+		return
+	}
+
+	// failed students do not fail the batch, so a batch cut short by the job
+	// timeout is only told apart by its context
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = m.store.SaveResult(id, report.Data)
+	}
+
+	if err != nil {
+		m.logger.Error("Job failed", zap.String("job_id", id), zap.Error(err))
+		if _, updateErr := m.update(id, func(job *Job) {
+			job.Status = StatusFailed
+			job.Error = describeJobError(err)
+		}); updateErr != nil {
+			m.logger.Error("Failed to record job failure", zap.String("job_id", id), zap.Error(updateErr))
+		}
+		return
+	}
+
+	if _, err := m.update(id, func(job *Job) {
+		job.Status = StatusCompleted
+		job.Manifest = &report.Manifest
+		job.FileName = report.FileName
+		job.ContentType = report.ContentType
+		job.Progress = dto.JobProgress{Completed: report.Manifest.Total, Total: report.Manifest.Total}
+	}); err != nil {
+		m.logger.Error("Failed to record job completion", zap.String("job_id", id), zap.Error(err))
+		return
+	}
+
+	m.logger.Info("Job completed",
+		zap.String("job_id", id),
+		zap.Int("succeeded", report.Manifest.Succeeded),
+		zap.Int("failed", report.Manifest.Failed))
+}
+
+func (m *Manager) update(id string, apply func(job *Job)) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, err := m.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	apply(job)
+	job.UpdatedAt = time.Now().UTC()
+
+	if err := m.store.Save(job); err != nil {
+		return nil, err
+	}
+	return job.clone(), nil
+}
+
+func (m *Manager) pruneWorker() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	m.pruneFinishedJobs()
+	for {
+		select {
+		case <-ticker.C:
+			m.pruneFinishedJobs()
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// pruneFinishedJobs removes finished jobs older than the retention period
+func (m *Manager) pruneFinishedJobs() {
+	jobs, err := m.store.List()
+	if err != nil {
+		m.logger.Warn("Failed to list jobs for pruning", zap.Error(err))
+		return
+	}
+
+	cutoff := time.Now().Add(-m.retention)
+	for _, job := range jobs {
+		if job.Finished() && job.UpdatedAt.Before(cutoff) {
+			if err := m.store.Delete(job.ID); err != nil {
+				m.logger.Warn("Failed to prune job", zap.String("job_id", job.ID), zap.Error(err))
+			}
+		}
+	}
+}
+
+func describeJobError(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "Job timed out"
+	}
+	return service.DescribeReportError(err)
+}
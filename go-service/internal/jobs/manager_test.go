@@ -0,0 +1,298 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/auth"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	serviceErrors "github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/service"
+)
+
+type MockBatchReportGenerator struct {
+	mock.Mock
+}
+
+func (m *MockBatchReportGenerator) GenerateBatch(ctx context.Context, req dto.BatchReportRequest, progress service.ProgressFunc) (*service.BatchReport, error) {
+	args := m.Called(ctx, req, progress)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.BatchReport), args.Error(1)
+}
+
+func newTestManager(t *testing.T, store Store, generator service.BatchReportGenerator) *Manager {
+	t.Helper()
+	manager := NewManager(store, generator, 1, 10, time.Minute, 0, zap.NewNop())
+	require.NoError(t, manager.Start())
+	t.Cleanup(manager.Stop)
+	return manager
+}
+
+func waitForStatus(t *testing.T, manager *Manager, id string, status Status) *Job {
+	t.Helper()
+	var job *Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = manager.Get(id)
+		return err == nil && job.Status == status
+	}, 2*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestManager_ProcessesJob(t *testing.T) {
+	// Setup
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	generator := new(MockBatchReportGenerator)
+
+	req := dto.BatchReportRequest{StudentIDs: []string{"1", "2"}}
+	generator.On("GenerateBatch", mock.Anything, req, mock.Anything).Run(func(args mock.Arguments) {
+		progress := args.Get(2).(service.ProgressFunc)
+		progress(1, 2)
+		progress(2, 2)
+	}).Return(&service.BatchReport{
+		Data:        []byte("zip content"),
+		FileName:    "student_reports.zip",
+		ContentType: "application/zip",
+		Manifest:    dto.BatchManifest{Total: 2, Succeeded: 2},
+	}, nil)
+
+	manager := newTestManager(t, store, generator)
+
+	// Execute
+	job, err := manager.Submit(req, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, job.Status)
+
+	// Assert
+	done := waitForStatus(t, manager, job.ID, StatusCompleted)
+	assert.Equal(t, dto.JobProgress{Completed: 2, Total: 2}, done.Progress)
+	require.NotNil(t, done.Manifest)
+	assert.Equal(t, 2, done.Manifest.Succeeded)
+
+	finished, data, err := manager.Result(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("zip content"), data)
+	assert.Equal(t, "student_reports.zip", finished.FileName)
+}
+
+func TestManager_GeneratesOnBehalfOfOwner(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	owner := &auth.Principal{Subject: "teacher-3", Role: auth.RoleTeacher, Classes: []string{"10"}}
+
+	generator := new(MockBatchReportGenerator)
+	generator.On("GenerateBatch", mock.MatchedBy(func(ctx context.Context) bool {
+		principal, ok := auth.FromContext(ctx)
+		return ok && principal.Subject == "teacher-3"
+	}), mock.Anything, mock.Anything).Return(&service.BatchReport{Data: []byte("zip content")}, nil)
+
+	manager := newTestManager(t, store, generator)
+
+	job, err := manager.Submit(dto.BatchReportRequest{Class: "10"}, owner)
+	require.NoError(t, err)
+
+	done := waitForStatus(t, manager, job.ID, StatusCompleted)
+	assert.Equal(t, owner, done.Owner, "the owner is persisted with the job")
+	generator.AssertExpectations(t)
+}
+
+func TestManager_RecordsFailure(t *testing.T) {
+	// Setup
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	generator := new(MockBatchReportGenerator)
+	generator.On("GenerateBatch", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, &serviceErrors.NotFoundError{Resource: "Students"})
+
+	manager := newTestManager(t, store, generator)
+
+	// Execute
+	job, err := manager.Submit(dto.BatchReportRequest{Class: "99"}, nil)
+	require.NoError(t, err)
+
+	// Assert
+	failed := waitForStatus(t, manager, job.ID, StatusFailed)
+	assert.Equal(t, "Students not found", failed.Error)
+
+	_, _, err = manager.Result(job.ID)
+	assert.ErrorIs(t, err, ErrJobNotReady)
+}
+
+func TestManager_RecordsTimeout(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	// like a batch, every student fails once the deadline passes but the
+	// batch itself succeeds
+	generator := new(MockBatchReportGenerator)
+	generator.On("GenerateBatch", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return(&service.BatchReport{
+		Data:     []byte("zip"),
+		Manifest: dto.BatchManifest{Total: 1, Failed: 1},
+	}, nil)
+
+	manager := NewManager(store, generator, 1, 10, 50*time.Millisecond, 0, zap.NewNop())
+	require.NoError(t, manager.Start())
+	t.Cleanup(manager.Stop)
+
+	job, err := manager.Submit(dto.BatchReportRequest{StudentIDs: []string{"1"}}, nil)
+	require.NoError(t, err)
+
+	failed := waitForStatus(t, manager, job.ID, StatusFailed)
+	assert.Equal(t, "Job timed out", failed.Error)
+	assert.Nil(t, failed.Manifest)
+
+	_, _, err = manager.Result(job.ID)
+	assert.ErrorIs(t, err, ErrJobNotReady)
+}
+
+func TestManager_ResumesUnfinishedJobs(t *testing.T) {
+	// Setup - jobs left behind by a previous process
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	interrupted := &Job{ID: "5f0c4b6e-1d7a-4c47-9d4e-0a6f1f1d2c01", Status: StatusRunning, CreatedAt: time.Now().Add(-time.Minute)}
+	finished := &Job{ID: "5f0c4b6e-1d7a-4c47-9d4e-0a6f1f1d2c02", Status: StatusCompleted, CreatedAt: time.Now().Add(-time.Hour)}
+	require.NoError(t, store.Save(interrupted))
+	require.NoError(t, store.Save(finished))
+
+	generator := new(MockBatchReportGenerator)
+	generator.On("GenerateBatch", mock.Anything, mock.Anything, mock.Anything).Return(&service.BatchReport{
+		Data:        []byte("zip"),
+		FileName:    "student_reports.zip",
+		ContentType: "application/zip",
+	}, nil)
+
+	// Execute
+	manager := newTestManager(t, store, generator)
+
+	// Assert
+	waitForStatus(t, manager, interrupted.ID, StatusCompleted)
+	generator.AssertNumberOfCalls(t, "GenerateBatch", 1)
+}
+
+func TestManager_StopLeavesJobResumable(t *testing.T) {
+	// Setup
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	generator := new(MockBatchReportGenerator)
+	generator.On("GenerateBatch", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		close(started)
+		<-args.Get(0).(context.Context).Done()
+	}).Return(nil, context.Canceled)
+
+	manager := NewManager(store, generator, 1, 10, time.Minute, 0, zap.NewNop())
+	require.NoError(t, manager.Start())
+
+	job, err := manager.Submit(dto.BatchReportRequest{StudentIDs: []string{"1"}}, nil)
+	require.NoError(t, err)
+	<-started
+
+	// Execute
+	manager.Stop()
+
+	// Assert
+	stored, err := store.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, stored.Status)
+}
+
+func TestManager_QueueFull(t *testing.T) {
+	// Setup - the single worker blocks on the first job
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	generator := new(MockBatchReportGenerator)
+	generator.On("GenerateBatch", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		<-release
+	}).Return(&service.BatchReport{}, nil)
+
+	manager := NewManager(store, generator, 1, 1, time.Minute, 0, zap.NewNop())
+	require.NoError(t, manager.Start())
+	defer manager.Stop()
+	defer close(release)
+
+	first, err := manager.Submit(dto.BatchReportRequest{StudentIDs: []string{"1"}}, nil)
+	require.NoError(t, err)
+	waitForStatus(t, manager, first.ID, StatusRunning)
+
+	_, err = manager.Submit(dto.BatchReportRequest{StudentIDs: []string{"2"}}, nil)
+	require.NoError(t, err)
+
+	// Execute
+	_, err = manager.Submit(dto.BatchReportRequest{StudentIDs: []string{"3"}}, nil)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrQueueFull)
+}
+
+func TestManager_SubmitWhileStopping(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	generator := new(MockBatchReportGenerator)
+	generator.On("GenerateBatch", mock.Anything, mock.Anything, mock.Anything).Return(&service.BatchReport{}, nil)
+	manager := NewManager(store, generator, 1, 100, time.Minute, 0, zap.NewNop())
+	require.NoError(t, manager.Start())
+
+	// handlers may still submit jobs while the manager is stopped
+	submitted := make(chan error, 1)
+	go func() {
+		var err error
+		for err == nil {
+			_, err = manager.Submit(dto.BatchReportRequest{StudentIDs: []string{"1"}}, nil)
+		}
+		submitted <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	manager.Stop()
+
+	err = <-submitted
+	if !errors.Is(err, ErrQueueFull) {
+		assert.ErrorIs(t, err, ErrManagerClosed)
+	}
+	_, err = manager.Submit(dto.BatchReportRequest{StudentIDs: []string{"1"}}, nil)
+	assert.ErrorIs(t, err, ErrManagerClosed)
+}
+
+func TestManager_GetRejectsInvalidIDs(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	manager := NewManager(store, new(MockBatchReportGenerator), 1, 1, time.Minute, 0, zap.NewNop())
+
+	_, err = manager.Get("../../etc/passwd")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestManager_PruneFinishedJobs(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, store.Save(&Job{ID: "old", Status: StatusCompleted, UpdatedAt: time.Now().Add(-48 * time.Hour)}))
+	require.NoError(t, store.Save(&Job{ID: "recent", Status: StatusCompleted, UpdatedAt: time.Now()}))
+	require.NoError(t, store.Save(&Job{ID: "queued", Status: StatusQueued, UpdatedAt: time.Now().Add(-48 * time.Hour)}))
+
+	manager := NewManager(store, new(MockBatchReportGenerator), 1, 1, time.Minute, 24*time.Hour, zap.NewNop())
+	manager.pruneFinishedJobs()
+
+	jobs, err := store.List()
+	require.NoError(t, err)
+	ids := []string{}
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	assert.ElementsMatch(t, []string{"recent", "queued"}, ids)
+}
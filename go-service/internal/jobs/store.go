@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wbentaleb/student-report-service/internal/fsutil"
+)
+
+// ErrJobNotFound is returned by a Store when the job does not exist
+var ErrJobNotFound = errors.New("job not found")
+
+// Store persists jobs and their results so they survive a restart
+type Store interface {
+	Save(job *Job) error
+	Get(id string) (*Job, error)
+	List() ([]*Job, error)
+	Delete(id string) error
+	SaveResult(id string, data []byte) error
+	GetResult(id string) ([]byte, error)
+}
+
+// FileStore keeps one JSON document per job and the result next to it
+type FileStore struct {
+	basePath string
+}
+
+func NewFileStore(basePath string) (*FileStore, error) {
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %w", err)
+	}
+	return &FileStore{basePath: basePath}, nil
+}
+
+func (s *FileStore) Save(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %w", err)
+	}
+	return fsutil.WriteFileAtomic(s.jobPath(job.ID), data, 0600)
+}
+
+func (s *FileStore) Get(id string) (*Job, error) {
+	data, err := os.ReadFile(s.jobPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job: %w", err)
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to decode job %s: %w", id, err)
+	}
+	return &job, nil
+}
+
+func (s *FileStore) List() ([]*Job, error) {
+	entries, err := os.ReadDir(s.basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	var jobs []*Job
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		job, err := s.Get(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			// Skip unreadable documents instead of failing the whole listing
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func (s *FileStore) Delete(id string) error {
+	if err := os.Remove(s.resultPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete job result: %w", err)
+	}
+	if err := os.Remove(s.jobPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) SaveResult(id string, data []byte) error {
+	return fsutil.WriteFileAtomic(s.resultPath(id), data, 0600)
+}
+
+func (s *FileStore) GetResult(id string) ([]byte, error) {
+	data, err := os.ReadFile(s.resultPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job result: %w", err)
+	}
+	return data, nil
+}
+
+func (s *FileStore) jobPath(id string) string {
+	return filepath.Join(s.basePath, id+".json")
+}
+
+func (s *FileStore) resultPath(id string) string {
+	return filepath.Join(s.basePath, id+".result")
+}
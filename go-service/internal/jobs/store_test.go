@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+)
+
+func TestFileStore_SaveAndGet(t *testing.T) {
+	// Setup
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	job := &Job{
+		ID:        "job-1",
+		Status:    StatusQueued,
+		Request:   dto.BatchReportRequest{StudentIDs: []string{"1", "2"}},
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	// Execute
+	require.NoError(t, store.Save(job))
+	loaded, err := store.Get("job-1")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, job.Request, loaded.Request)
+	assert.Equal(t, StatusQueued, loaded.Status)
+	assert.True(t, job.CreatedAt.Equal(loaded.CreatedAt))
+}
+
+func TestFileStore_GetMissing(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = store.Get("missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	_, err = store.GetResult("missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestFileStore_ListSkipsCorruptDocuments(t *testing.T) {
+	// Setup
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Save(&Job{ID: "job-1", Status: StatusQueued}))
+	require.NoError(t, store.Save(&Job{ID: "job-2", Status: StatusCompleted}))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0644))
+
+	// Execute
+	jobs, err := store.List()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Len(t, jobs, 2)
+}
+
+func TestFileStore_ResultAndDelete(t *testing.T) {
+	// Setup
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Save(&Job{ID: "job-1"}))
+	require.NoError(t, store.SaveResult("job-1", []byte("zip content")))
+
+	data, err := store.GetResult("job-1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("zip content"), data)
+
+	// Execute
+	require.NoError(t, store.Delete("job-1"))
+
+	// Assert
+	_, err = store.Get("job-1")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/wbentaleb/student-report-service/internal/cache"
+	"github.com/wbentaleb/student-report-service/internal/external"
+)
+
+var (
+	cacheEntriesDesc     = prometheus.NewDesc("report_cache_entries", "Reports in the cache.", nil, nil)
+	cacheBytesDesc       = prometheus.NewDesc("report_cache_bytes", "Size of the reports in the cache.", nil, nil)
+	cacheHitsDesc        = prometheus.NewDesc("report_cache_hits_total", "Cache lookups that found a report.", nil, nil)
+	cacheMissesDesc      = prometheus.NewDesc("report_cache_misses_total", "Cache lookups that found no report.", nil, nil)
+	cacheEvictionsDesc   = prometheus.NewDesc("report_cache_evictions_total", "Reports removed to stay within the cache limits.", nil, nil)
+	cacheExpirationsDesc = prometheus.NewDesc("report_cache_expirations_total", "Reports removed because their TTL ran out.", nil, nil)
+
+	breakerStateDesc    = prometheus.NewDesc("backend_circuit_breaker_state", "Current state of the backend circuit breaker (1 for the current state).", []string{"state"}, nil)
+	breakerOpenedDesc   = prometheus.NewDesc("backend_circuit_breaker_opened_total", "Times the backend circuit breaker opened.", nil, nil)
+	breakerRejectedDesc = prometheus.NewDesc("backend_circuit_breaker_rejected_total", "Backend calls failed fast while the circuit breaker was open.", nil, nil)
+)
+
+// cacheCollector reads the cache statistics once per scrape. The size of a
+// shared cache is not reported.
+type cacheCollector struct {
+	stats cache.StatsProvider
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheEntriesDesc
+	ch <- cacheBytesDesc
+	ch <- cacheHitsDesc
+	ch <- cacheMissesDesc
+	ch <- cacheEvictionsDesc
+	ch <- cacheExpirationsDesc
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats.Stats()
+	if !stats.Shared {
+		ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(stats.Entries))
+		ch <- prometheus.MustNewConstMetric(cacheBytesDesc, prometheus.GaugeValue, float64(stats.Bytes))
+	}
+	ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(cacheExpirationsDesc, prometheus.CounterValue, float64(stats.Expirations))
+}
+
+// breakerCollector reports the breaker state as one series per state, set to
+// 1 for the current one
+type breakerCollector struct {
+	stats external.BreakerStatsProvider
+}
+
+func (c *breakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- breakerStateDesc
+	ch <- breakerOpenedDesc
+	ch <- breakerRejectedDesc
+}
+
+func (c *breakerCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats.BreakerStats()
+	for _, state := range []external.BreakerState{external.BreakerClosed, external.BreakerOpen, external.BreakerHalfOpen} {
+		value := 0.0
+		if state == stats.State {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(breakerStateDesc, prometheus.GaugeValue, value, state.String())
+	}
+	ch <- prometheus.MustNewConstMetric(breakerOpenedDesc, prometheus.CounterValue, float64(stats.Opened))
+	ch <- prometheus.MustNewConstMetric(breakerRejectedDesc, prometheus.CounterValue, float64(stats.Rejected))
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/external"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/service"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+)
+
+// InstrumentRenderer records the duration and size of the reports rendered
+// by renderer
+func (m *Metrics) InstrumentRenderer(renderer service.ReportRenderer) service.ReportRenderer {
+	return &instrumentedRenderer{ReportRenderer: renderer, metrics: m}
+}
+
+type instrumentedRenderer struct {
+	service.ReportRenderer
+	metrics *Metrics
+}
+
+func (r *instrumentedRenderer) GenerateStudentReport(student *dto.Student, tmpl *templates.Template, locale *i18n.Locale, issue service.ReportIssue) ([]byte, error) {
+	start := time.Now()
+	data, err := r.ReportRenderer.GenerateStudentReport(student, tmpl, locale, issue)
+	format := r.Format()
+	r.metrics.renderDuration.WithLabelValues(format).Observe(time.Since(start).Seconds())
+	if err == nil {
+		r.metrics.renderSize.WithLabelValues(format).Observe(float64(len(data)))
+	}
+	return data, err
+}
+
+// InstrumentBackend records the latency and outcome of the calls to backend
+func (m *Metrics) InstrumentBackend(backend external.BackendService) external.BackendService {
+	return &instrumentedBackend{backend: backend, metrics: m}
+}
+
+type instrumentedBackend struct {
+	backend external.BackendService
+	metrics *Metrics
+}
+
+func (b *instrumentedBackend) GetStudent(ctx context.Context, id string) (*dto.Student, error) {
+	start := time.Now()
+	student, err := b.backend.GetStudent(ctx, id)
+	b.observe("get_student", start, err)
+	return student, err
+}
+
+func (b *instrumentedBackend) ListStudents(ctx context.Context, filter dto.StudentFilter) ([]dto.StudentSummary, error) {
+	start := time.Now()
+	students, err := b.backend.ListStudents(ctx, filter)
+	b.observe("list_students", start, err)
+	return students, err
+}
+
+func (b *instrumentedBackend) CheckHealth(ctx context.Context) bool {
+	start := time.Now()
+	healthy := b.backend.CheckHealth(ctx)
+	outcome := "ok"
+	if !healthy {
+		outcome = "error"
+	}
+	b.metrics.backendDuration.WithLabelValues("check_health", outcome).Observe(time.Since(start).Seconds())
+	return healthy
+}
+
+func (b *instrumentedBackend) observe(operation string, start time.Time, err error) {
+	outcome := "ok"
+	switch {
+	case errors.IsNotFound(err):
+		outcome = "not_found"
+	case err != nil:
+		outcome = "error"
+	}
+	b.metrics.backendDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
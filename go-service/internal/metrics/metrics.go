@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/wbentaleb/student-report-service/internal/cache"
+	"github.com/wbentaleb/student-report-service/internal/external"
+	"github.com/wbentaleb/student-report-service/internal/middleware"
+	"github.com/wbentaleb/student-report-service/internal/retry"
+	"github.com/wbentaleb/student-report-service/internal/service"
+)
+
+// unmatchedRoute labels requests that matched no route, so that arbitrary
+// paths do not create new series
+const unmatchedRoute = "unmatched"
+
+// Metrics holds the Prometheus collectors of the service. Labels are limited
+// to route templates, methods, status codes, report formats and backend
+// operations, so the number of series is bounded whatever the traffic.
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	renderDuration  *prometheus.HistogramVec
+	renderSize      *prometheus.HistogramVec
+	backendDuration *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "report_render_duration_seconds",
+			Help:    "Time spent rendering reports by format.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"format"}),
+		renderSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "report_render_size_bytes",
+			Help:    "Size of the rendered reports by format.",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 8),
+		}, []string{"format"}),
+		backendDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "backend_request_duration_seconds",
+			Help:    "Latency of backend calls, retries included, by operation and outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.renderDuration,
+		m.renderSize,
+		m.backendDuration,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records the count and latency of every request, labelled with
+// the route template (e.g. /api/v1/students/:id/report) rather than the path
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := normalizeMethod(c.Request.Method)
+		m.requests.WithLabelValues(route, method, strconv.Itoa(c.Writer.Status())).Inc()
+		m.requestDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+	}
+}
+
+// normalizeMethod folds unknown methods into one label value
+func normalizeMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+// RegisterCache exposes the contents and activity of the report cache. The
+// statistics are read on every scrape.
+func (m *Metrics) RegisterCache(stats cache.StatsProvider) {
+	m.registry.MustRegister(&cacheCollector{stats: stats})
+}
+
+// RegisterCoalescing exposes how many report requests shared the work of
+// another request
+func (m *Metrics) RegisterCoalescing(stats service.CoalescingStatsProvider) {
+	m.registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "report_coalesced_fetches_total",
+			Help: "Report requests that shared another request's backend fetch.",
+		}, func() float64 { return float64(stats.CoalescingStats().DeduplicatedFetches) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "report_coalesced_renders_total",
+			Help: "Report requests that shared another request's rendering.",
+		}, func() float64 { return float64(stats.CoalescingStats().DeduplicatedRenders) }),
+	)
+}
+
+// RegisterRetries exposes the retries made by the backend retry policy
+func (m *Metrics) RegisterRetries(policy *retry.Policy) {
+	m.registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "backend_retries_total",
+			Help: "Backend calls retried after a transient failure.",
+		}, func() float64 { return float64(policy.Stats().Retries) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "backend_retry_budget_exhausted_total",
+			Help: "Backend retries not made because the retry budget was spent.",
+		}, func() float64 { return float64(policy.Stats().BudgetExhausted) }),
+	)
+}
+
+// RegisterBreaker exposes the state of the circuit breaker guarding the
+// backend
+func (m *Metrics) RegisterBreaker(stats external.BreakerStatsProvider) {
+	m.registry.MustRegister(&breakerCollector{stats: stats})
+}
+
+// RegisterRateLimiter exposes the requests rejected by the rate limiter
+func (m *Metrics) RegisterRateLimiter(limiter *middleware.RateLimiter) {
+	m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "rate_limit_rejections_total",
+		Help: "Requests rejected by the rate limiter.",
+	}, func() float64 { return float64(limiter.Rejected()) }))
+}
@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wbentaleb/student-report-service/internal/cache"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/external"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/service"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+)
+
+func newTestRouter(m *Metrics) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/metrics", gin.WrapH(m.Handler()))
+	router.GET("/api/v1/students/:id/report", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func scrape(t *testing.T, router *gin.Engine) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMiddleware_LabelsRequestsByRouteTemplate(t *testing.T) {
+	m := New()
+	router := newTestRouter(m)
+
+	for _, path := range []string{"/api/v1/students/1/report", "/api/v1/students/2/report", "/students/3", "/anything/else"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("/api/v1/students/:id/report", "GET", "200")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues(unmatchedRoute, "GET", "404")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.requests), "one series per route and status, none per student")
+	assert.Equal(t, 2, testutil.CollectAndCount(m.requestDuration))
+}
+
+func TestMiddleware_FoldsUnknownMethods(t *testing.T) {
+	m := New()
+	router := newTestRouter(m)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/api/v1/students/1/report", nil))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues(unmatchedRoute, "OTHER", "404")))
+}
+
+type stubRenderer struct {
+	data []byte
+	err  error
+}
+
+func (r stubRenderer) Format() string      { return "pdf" }
+func (r stubRenderer) ContentType() string { return "application/pdf" }
+func (r stubRenderer) GenerateStudentReport(*dto.Student, *templates.Template, *i18n.Locale, service.ReportIssue) ([]byte, error) {
+	return r.data, r.err
+}
+
+func TestInstrumentRenderer(t *testing.T) {
+	m := New()
+	renderer := m.InstrumentRenderer(stubRenderer{data: make([]byte, 3000)})
+
+	data, err := renderer.GenerateStudentReport(&dto.Student{}, templates.Default(), i18n.Default(), service.ReportIssue{})
+
+	require.NoError(t, err)
+	assert.Len(t, data, 3000)
+	assert.Equal(t, "pdf", renderer.Format())
+	assert.Equal(t, 1, testutil.CollectAndCount(m.renderDuration))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.renderSize))
+
+	// failed renders have a duration but no size
+	failing := m.InstrumentRenderer(stubRenderer{err: assert.AnError})
+	_, err = failing.GenerateStudentReport(&dto.Student{}, templates.Default(), i18n.Default(), service.ReportIssue{})
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+type stubBackend struct {
+	err error
+}
+
+func (b stubBackend) GetStudent(context.Context, string) (*dto.Student, error) {
+	return &dto.Student{}, b.err
+}
+
+func (b stubBackend) ListStudents(context.Context, dto.StudentFilter) ([]dto.StudentSummary, error) {
+	return nil, b.err
+}
+
+func (b stubBackend) CheckHealth(context.Context) bool {
+	return b.err == nil
+}
+
+func TestInstrumentBackend_LabelsOutcome(t *testing.T) {
+	m := New()
+	router := newTestRouter(m)
+
+	m.InstrumentBackend(stubBackend{}).GetStudent(context.Background(), "42")
+	m.InstrumentBackend(stubBackend{err: &errors.NotFoundError{Resource: "Student"}}).GetStudent(context.Background(), "43")
+	m.InstrumentBackend(stubBackend{err: &errors.ServiceError{Service: "backend", Err: assert.AnError}}).ListStudents(context.Background(), dto.StudentFilter{})
+
+	body := scrape(t, router)
+	assert.Contains(t, body, `backend_request_duration_seconds_count{operation="get_student",outcome="ok"} 1`)
+	assert.Contains(t, body, `backend_request_duration_seconds_count{operation="get_student",outcome="not_found"} 1`)
+	assert.Contains(t, body, `backend_request_duration_seconds_count{operation="list_students",outcome="error"} 1`)
+}
+
+type stubCacheStats cache.Stats
+
+func (s stubCacheStats) Stats() cache.Stats {
+	return cache.Stats(s)
+}
+
+type stubBreakerStats external.BreakerStats
+
+func (s stubBreakerStats) BreakerStats() external.BreakerStats {
+	return external.BreakerStats(s)
+}
+
+func TestRegisteredStats(t *testing.T) {
+	m := New()
+	router := newTestRouter(m)
+	m.RegisterCache(stubCacheStats{Entries: 3, Bytes: 4096, Hits: 10, Misses: 4, Evictions: 2, Expirations: 1})
+	m.RegisterBreaker(stubBreakerStats{State: external.BreakerOpen, Opened: 2, Rejected: 40})
+
+	body := scrape(t, router)
+
+	for _, line := range []string{
+		"report_cache_entries 3",
+		"report_cache_bytes 4096",
+		"report_cache_hits_total 10",
+		"report_cache_misses_total 4",
+		"report_cache_evictions_total 2",
+		"report_cache_expirations_total 1",
+		`backend_circuit_breaker_state{state="closed"} 0`,
+		`backend_circuit_breaker_state{state="open"} 1`,
+		`backend_circuit_breaker_state{state="half-open"} 0`,
+		"backend_circuit_breaker_opened_total 2",
+		"backend_circuit_breaker_rejected_total 40",
+	} {
+		assert.True(t, strings.Contains(body, "\n"+line+"\n"), "missing %q", line)
+	}
+}
+
+func TestRegisteredStats_SharedCache(t *testing.T) {
+	m := New()
+	router := newTestRouter(m)
+	m.RegisterCache(stubCacheStats{Shared: true, Hits: 10})
+
+	body := scrape(t, router)
+
+	assert.Contains(t, body, "\nreport_cache_hits_total 10\n")
+	assert.NotContains(t, body, "report_cache_entries")
+	assert.NotContains(t, body, "report_cache_bytes")
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wbentaleb/student-report-service/internal/apikeys"
+)
+
+// AdminAuth admits requests carrying the admin API key as a bearer token,
+// i.e. "Authorization: Bearer <key>", or, when keys is set, an API key
+// granted scope
+func AdminAuth(apiKey string, keys *apikeys.Store, scope string) gin.HandlerFunc {
+	// comparing digests keeps the comparison time independent of the length
+	// of the presented key
+	expected := sha256.Sum256([]byte(apiKey))
+
+	return func(c *gin.Context) {
+		token, found := bearerToken(c)
+		if !found {
+			abortUnauthorized(c, `Bearer realm="admin"`)
+			return
+		}
+
+		presented := sha256.Sum256([]byte(token))
+		if apiKey != "" && subtle.ConstantTimeCompare(presented[:], expected[:]) == 1 {
+			c.Next()
+			return
+		}
+
+		if keys != nil && scope != "" && apikeys.IsToken(token) {
+			key, err := keys.Authenticate(token)
+			if err == nil && key.HasScope(scope) {
+				c.Next()
+				return
+			}
+			if err == nil {
+				abortForbidden(c)
+				return
+			}
+		}
+
+		abortUnauthorized(c, `Bearer realm="admin"`)
+	}
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wbentaleb/student-report-service/internal/apikeys"
+)
+
+func TestAdminAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin", AdminAuth("s3cret", nil, ""), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	testCases := []struct {
+		name          string
+		authorization string
+		expected      int
+	}{
+		{name: "valid key", authorization: "Bearer s3cret", expected: http.StatusOK},
+		{name: "wrong key", authorization: "Bearer s3cre", expected: http.StatusUnauthorized},
+		{name: "missing header", authorization: "", expected: http.StatusUnauthorized},
+		{name: "empty token", authorization: "Bearer ", expected: http.StatusUnauthorized},
+		{name: "other scheme", authorization: "Basic s3cret", expected: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expected, rec.Code)
+			if tc.expected == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer realm="admin"`, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAdminAuth_APIKeys(t *testing.T) {
+	keys, err := apikeys.NewStore(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	_, adminToken, err := keys.Create("ops", []string{apikeys.ScopeCacheAdmin}, 0, nil)
+	require.NoError(t, err)
+	_, readerToken, err := keys.Create("portal", []string{apikeys.ScopeReportsRead}, 0, nil)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/cache", AdminAuth("s3cret", keys, apikeys.ScopeCacheAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	// without a scope, only the admin key is accepted
+	router.GET("/keys", AdminAuth("s3cret", nil, ""), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	testCases := []struct {
+		name     string
+		path     string
+		token    string
+		expected int
+	}{
+		{name: "admin key", path: "/cache", token: "s3cret", expected: http.StatusOK},
+		{name: "key with the scope", path: "/cache", token: adminToken, expected: http.StatusOK},
+		{name: "key without the scope", path: "/cache", token: readerToken, expected: http.StatusForbidden},
+		{name: "forged key", path: "/cache", token: adminToken + "x", expected: http.StatusUnauthorized},
+		{name: "key on admin-key-only routes", path: "/keys", token: adminToken, expected: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expected, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wbentaleb/student-report-service/internal/apikeys"
+	"github.com/wbentaleb/student-report-service/internal/auth"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+)
+
+// callerKey is the gin context key of the caller resolved from the bearer
+// token
+const callerKey = "Caller"
+
+// caller is the outcome of verifying a request's bearer token
+type caller struct {
+	principal *auth.Principal
+	key       *apikeys.Key // set when the token is an API key
+	err       error
+}
+
+// Authenticate admits requests carrying, as a bearer token, a valid JWT or
+// an API key granted scope, and makes the caller available to the handlers
+// through auth.FromContext. JWTs are refused when tokens is nil and API keys
+// when keys is nil. Whether the caller may read a given report is decided
+// once the report is requested.
+func Authenticate(tokens *auth.Verifier, keys *apikeys.Store, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resolved, found := resolveCaller(c, tokens, keys)
+		if !found {
+			abortUnauthorized(c, `Bearer realm="reports"`)
+			return
+		}
+		if resolved.err != nil {
+			abortUnauthorized(c, `Bearer realm="reports", error="invalid_token"`)
+			return
+		}
+		if resolved.key != nil && !resolved.key.HasScope(scope) {
+			abortForbidden(c)
+			return
+		}
+
+		c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), resolved.principal))
+		c.Next()
+	}
+}
+
+// resolveCaller verifies the bearer token of the request as a JWT of tokens
+// or an API key of keys, either of which may be nil. The outcome is kept in
+// the gin context, so that the rate limiter and the authentication of the
+// route verify a token once. found is false when there is no bearer token.
+func resolveCaller(c *gin.Context, tokens *auth.Verifier, keys *apikeys.Store) (resolved *caller, found bool) {
+	if value, ok := c.Get(callerKey); ok {
+		return value.(*caller), true
+	}
+	token, found := bearerToken(c)
+	if !found {
+		return nil, false
+	}
+
+	resolved = &caller{}
+	switch {
+	case apikeys.IsToken(token) && keys == nil:
+		resolved.err = apikeys.ErrInvalidKey
+	case apikeys.IsToken(token):
+		if resolved.key, resolved.err = keys.Authenticate(token); resolved.err == nil {
+			resolved.principal = keyPrincipal(resolved.key)
+		}
+	case tokens == nil:
+		resolved.err = errors.New("JWTs are not accepted")
+	default:
+		resolved.principal, resolved.err = tokens.Verify(token)
+	}
+	c.Set(callerKey, resolved)
+	return resolved, true
+}
+
+// keyPrincipal identifies the integration behind an API key by the key's
+// name, which survives rotations
+func keyPrincipal(key *apikeys.Key) *auth.Principal {
+	return &auth.Principal{Subject: "apikey:" + key.Name, Role: auth.RoleService}
+}
+
+func bearerToken(c *gin.Context) (string, bool) {
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return token, found && token != ""
+}
+
+func abortUnauthorized(c *gin.Context, challenge string) {
+	c.Header("WWW-Authenticate", challenge)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, dto.ErrorResponse{
+		Error:     "Unauthorized",
+		RequestID: getRequestID(c),
+	})
+}
+
+func abortForbidden(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, dto.ErrorResponse{
+		Error:     "Forbidden",
+		RequestID: getRequestID(c),
+	})
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForwardedChainHeader carries the addresses of a Forwarded header as a
+// comma separated list, the form gin reads client IPs from. It is always
+// replaced, so that clients cannot set it themselves.
+const ForwardedChainHeader = "X-Forwarded-Chain"
+
+// ForwardedChain copies the for= addresses of the RFC 7239 Forwarded header,
+// from the client to the closest proxy, into ForwardedChainHeader
+func ForwardedChain() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Header.Del(ForwardedChainHeader)
+		if chain := forwardedFor(c.Request.Header.Values("Forwarded")); len(chain) > 0 {
+			c.Request.Header.Set(ForwardedChainHeader, strings.Join(chain, ","))
+		}
+		c.Next()
+	}
+}
+
+// forwardedFor returns the for= node of every element of the headers, with
+// quotes, brackets and ports removed. Obfuscated nodes such as "unknown" are
+// kept, so that a chain containing them is not mistaken for a shorter one.
+func forwardedFor(headers []string) []string {
+	var chain []string
+	for _, header := range headers {
+		for _, element := range strings.Split(header, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if !found || !strings.EqualFold(key, "for") {
+					continue
+				}
+				chain = append(chain, forwardedNode(strings.Trim(value, `"`)))
+			}
+		}
+	}
+	return chain
+}
+
+// forwardedNode strips the port from "192.0.2.1:4711" and "[2001:db8::1]:4711"
+func forwardedNode(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if end := strings.Index(node, "]"); end > 0 {
+			return node[1:end]
+		}
+		return node
+	}
+	if host, _, found := strings.Cut(node, ":"); found && strings.Count(node, ":") == 1 {
+		return host
+	}
+	return node
+}
@@ -2,13 +2,19 @@ package middleware
 
 import (
 	"fmt"
+	"math"
 	"net/http"
-	"sync"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 
+	"github.com/wbentaleb/student-report-service/internal/apikeys"
+	"github.com/wbentaleb/student-report-service/internal/auth"
 	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/ratelimit"
 )
 
 func getRequestID(c *gin.Context) string {
@@ -20,47 +26,139 @@ func getRequestID(c *gin.Context) string {
 	return ""
 }
 
+// RateLimiter gives every client a token bucket, so that short bursts are
+// allowed while the average rate stays within the client's quota
 type RateLimiter struct {
-	requests map[string][]time.Time
-	mu       sync.Mutex
-	rate     int           // Maximum requests per window
-	window   time.Duration // Time window for rate limiting
+	store    ratelimit.Store
+	policy   ratelimit.Policy
+	routes   map[string]ratelimit.Policy
+	tokens   *auth.Verifier
+	keys     *apikeys.Store
+	logger   *zap.Logger
+	rejected atomic.Uint64
 }
 
-func NewRateLimiter(requestsPerMinute int) *RateLimiter {
+// NewRateLimiter limits each client IP to requestsPerMinute, with buckets kept
+// in store. Requests made with a valid JWT of tokens are counted against its
+// subject instead, and requests made with a valid API key of keys against
+// the key, with the key's own quota when it has one. tokens and keys may be
+// nil.
+// Routes listed in routes, by their gin pattern, are counted in a separate
+// bucket per client with the route's limit, or not at all when it is zero.
+// A key with its own quota is held to it on those routes too.
+func NewRateLimiter(store ratelimit.Store, requestsPerMinute int, routes map[string]ratelimit.Policy, tokens *auth.Verifier, keys *apikeys.Store, logger *zap.Logger) *RateLimiter {
 	return &RateLimiter{
-		requests: make(map[string][]time.Time),
-		rate:     requestsPerMinute,
-		window:   time.Minute,
+		store:  store,
+		policy: ratelimit.PerMinute(requestsPerMinute),
+		routes: routes,
+		tokens: tokens,
+		keys:   keys,
+		logger: logger,
 	}
 }
 
+// bucket is a token bucket a request is counted against
+type bucket struct {
+	identity string
+	policy   ratelimit.Policy
+}
+
 func (rl *RateLimiter) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		rl.mu.Lock()
-		defer rl.mu.Unlock()
-
-		ip := c.ClientIP()
-		now := time.Now()
-		windowStart := now.Add(-rl.window)
-
-		// Clean old requests
-		var validRequests []time.Time
-		for _, reqTime := range rl.requests[ip] {
-			if reqTime.After(windowStart) {
-				validRequests = append(validRequests, reqTime)
+		buckets := rl.buckets(c)
+		if len(buckets) == 0 {
+			c.Next()
+			return
+		}
+
+		// the request is rejected as soon as one of its buckets is empty, and
+		// the headers describe the bucket closest to running out
+		var decision ratelimit.Decision
+		var policy ratelimit.Policy
+		for i, b := range buckets {
+			d, err := rl.store.Take(c.Request.Context(), b.identity, b.policy)
+			if err != nil {
+				// an unavailable store must not take the service down with it
+				rl.logger.Warn("Rate limit store unavailable, allowing request",
+					zap.String("request_id", getRequestID(c)),
+					zap.Error(err),
+				)
+				c.Next()
+				return
+			}
+			if i == 0 || !d.Allowed || d.Remaining < decision.Remaining {
+				decision, policy = d, b.policy
+			}
+			if !d.Allowed {
+				break
 			}
 		}
 
-		if len(validRequests) >= rl.rate {
+		c.Header("X-RateLimit-Limit", strconv.Itoa(policy.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(seconds(decision.ResetAfter)))
+
+		if !decision.Allowed {
+			rl.rejected.Add(1)
+			c.Header("Retry-After", strconv.Itoa(seconds(decision.RetryAfter)))
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, dto.ErrorResponse{
-				Error:     "Rate limit exceeded. Maximum " + fmt.Sprintf("%d", rl.rate) + " requests per minute allowed.",
+				Error:     fmt.Sprintf("Rate limit exceeded. Maximum %d requests per minute allowed.", policy.Limit),
 				RequestID: getRequestID(c),
 			})
 			return
 		}
 
-		rl.requests[ip] = append(validRequests, now)
 		c.Next()
 	}
 }
+
+// buckets returns the buckets the request is counted against: the route's
+// bucket for the client on listed routes, plus the key's own bucket when the
+// key has a quota of its own, and the client's default bucket otherwise
+func (rl *RateLimiter) buckets(c *gin.Context) []bucket {
+	identity, policy, ownQuota := rl.identify(c)
+	route, listed := rl.routes[c.FullPath()]
+	if !listed {
+		if policy.Unlimited() {
+			return nil
+		}
+		return []bucket{{identity: identity, policy: policy}}
+	}
+	if route.Unlimited() {
+		return nil
+	}
+
+	buckets := []bucket{{identity: identity + "|" + c.FullPath(), policy: route}}
+	if ownQuota {
+		buckets = append(buckets, bucket{identity: identity, policy: policy})
+	}
+	return buckets
+}
+
+// identify returns who the request is counted against, their policy and
+// whether it is a key's own quota. Invalid tokens fall back to the client
+// IP, so that they cannot be used to dodge the limit.
+func (rl *RateLimiter) identify(c *gin.Context) (string, ratelimit.Policy, bool) {
+	if resolved, found := resolveCaller(c, rl.tokens, rl.keys); found && resolved.err == nil {
+		if key := resolved.key; key != nil {
+			if key.RateLimitPerMinute > 0 {
+				return "key:" + key.Name, ratelimit.PerMinute(key.RateLimitPerMinute), true
+			}
+			return "key:" + key.Name, rl.policy, false
+		}
+		if resolved.principal.Subject != "" {
+			return "sub:" + resolved.principal.Subject, rl.policy, false
+		}
+	}
+	return "ip:" + c.ClientIP(), rl.policy, false
+}
+
+// Rejected returns the number of requests rejected since startup
+func (rl *RateLimiter) Rejected() uint64 {
+	return rl.rejected.Load()
+}
+
+// seconds rounds d up to whole seconds, as clients must not retry early
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/apikeys"
+	"github.com/wbentaleb/student-report-service/internal/auth"
+	"github.com/wbentaleb/student-report-service/internal/ratelimit"
+)
+
+// failingStore stands for an unreachable shared store
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Policy) (ratelimit.Decision, error) {
+	return ratelimit.Decision{}, errors.New("connection refused")
+}
+
+func setupRateLimitRouter(limiter *RateLimiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(limiter.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/", ok)
+	router.GET("/health", ok)
+	router.POST("/reports/:id", ok)
+	return router
+}
+
+// sendRateLimited sends a request from the same address every time
+func sendRateLimited(router *gin.Engine, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimiter_Headers(t *testing.T) {
+	router := setupRateLimitRouter(NewRateLimiter(ratelimit.NewMemoryStore(), 2, nil, nil, nil, zap.NewNop()))
+
+	rec := sendRateLimited(router, http.MethodGet, "/", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "30", rec.Header().Get("X-RateLimit-Reset"))
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+
+	rec = sendRateLimited(router, http.MethodGet, "/", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", rec.Header().Get("X-RateLimit-Reset"))
+
+	rec = sendRateLimited(router, http.MethodGet, "/", "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), "Maximum 2 requests per minute")
+}
+
+func TestRateLimiter_RoutePolicies(t *testing.T) {
+	routes, err := ratelimit.ParseRoutes("/health=0,/reports/:id=1")
+	require.NoError(t, err)
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), 2, routes, nil, nil, zap.NewNop())
+	router := setupRateLimitRouter(limiter)
+
+	// exempt routes are never limited nor counted
+	for range 5 {
+		rec := sendRateLimited(router, http.MethodGet, "/health", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-RateLimit-Limit"))
+	}
+
+	// every student shares the route's bucket, apart from the default one
+	rec := sendRateLimited(router, http.MethodPost, "/reports/1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, http.StatusTooManyRequests, sendRateLimited(router, http.MethodPost, "/reports/2", "").Code)
+
+	for range 2 {
+		assert.Equal(t, http.StatusOK, sendRateLimited(router, http.MethodGet, "/", "").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, sendRateLimited(router, http.MethodGet, "/", "").Code)
+
+	assert.Equal(t, uint64(2), limiter.Rejected())
+}
+
+func TestRateLimiter_CountsAPIKeysSeparately(t *testing.T) {
+	keys, err := apikeys.NewStore(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	_, portalToken, err := keys.Create("portal", []string{apikeys.ScopeReportsRead}, 3, nil)
+	require.NoError(t, err)
+	_, exporterToken, err := keys.Create("exporter", []string{apikeys.ScopeBatchWrite}, 0, nil)
+	require.NoError(t, err)
+
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), 2, nil, nil, keys, zap.NewNop())
+	router := setupRateLimitRouter(limiter)
+	send := func(token string) int {
+		return sendRateLimited(router, http.MethodGet, "/", token).Code
+	}
+
+	// the portal key has its own quota of 3
+	for range 3 {
+		assert.Equal(t, http.StatusOK, send(portalToken))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, send(portalToken))
+
+	// the exporter key has the default quota, unaffected by the portal
+	for range 2 {
+		assert.Equal(t, http.StatusOK, send(exporterToken))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, send(exporterToken))
+
+	// anonymous requests and invalid keys share the address' quota
+	assert.Equal(t, http.StatusOK, send(""))
+	assert.Equal(t, http.StatusOK, send("srk_0123456789abcdef_forged"))
+	assert.Equal(t, http.StatusTooManyRequests, send(""))
+
+	assert.Equal(t, uint64(3), limiter.Rejected())
+}
+
+func TestRateLimiter_KeyQuotaAppliesToRoutePolicies(t *testing.T) {
+	keys, err := apikeys.NewStore(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	_, token, err := keys.Create("portal", []string{apikeys.ScopeReportsRead}, 2, nil)
+	require.NoError(t, err)
+	routes, err := ratelimit.ParseRoutes("/reports/:id=5")
+	require.NoError(t, err)
+
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), 10, routes, nil, keys, zap.NewNop())
+	router := setupRateLimitRouter(limiter)
+
+	// the route allows 5 requests, but the key only 2 in all
+	rec := sendRateLimited(router, http.MethodPost, "/reports/1", token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, http.StatusOK, sendRateLimited(router, http.MethodPost, "/reports/2", token).Code)
+
+	rec = sendRateLimited(router, http.MethodPost, "/reports/3", token)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Maximum 2 requests per minute")
+	assert.Equal(t, http.StatusTooManyRequests, sendRateLimited(router, http.MethodGet, "/", token).Code)
+
+	// without a key the route's own limit applies
+	for range 5 {
+		assert.Equal(t, http.StatusOK, sendRateLimited(router, http.MethodPost, "/reports/1", "").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, sendRateLimited(router, http.MethodPost, "/reports/1", "").Code)
+}
+
+func TestRateLimiter_CountsJWTSubjectsSeparately(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	verifier, err := auth.NewVerifier(secret, nil, "", "")
+	require.NoError(t, err)
+	sign := func(subject string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub":  subject,
+			"role": "teacher",
+			"exp":  time.Now().Add(time.Hour).Unix(),
+		}).SignedString(secret)
+		require.NoError(t, err)
+		return token
+	}
+
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), 1, nil, verifier, nil, zap.NewNop())
+	router := setupRateLimitRouter(limiter)
+	send := func(token string) int {
+		return sendRateLimited(router, http.MethodGet, "/", token).Code
+	}
+
+	// teachers behind one address have a bucket each
+	assert.Equal(t, http.StatusOK, send(sign("teacher-1")))
+	assert.Equal(t, http.StatusOK, send(sign("teacher-2")))
+	assert.Equal(t, http.StatusTooManyRequests, send(sign("teacher-1")))
+
+	// forged tokens share the address' bucket
+	assert.Equal(t, http.StatusOK, send("eyJhbGciOiJIUzI1NiJ9.e30.c2ln"))
+	assert.Equal(t, http.StatusTooManyRequests, send(""))
+}
+
+func TestRateLimiter_FailsOpen(t *testing.T) {
+	limiter := NewRateLimiter(failingStore{}, 1, nil, nil, nil, zap.NewNop())
+	router := setupRateLimitRouter(limiter)
+
+	for range 3 {
+		rec := sendRateLimited(router, http.MethodGet, "/", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-RateLimit-Limit"))
+	}
+	assert.Zero(t, limiter.Rejected())
+}
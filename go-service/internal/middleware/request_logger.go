@@ -21,6 +21,9 @@ func RequestLogger(logger *zap.Logger) gin.HandlerFunc {
 			zap.String("path", path),
 			zap.Int("status", c.Writer.Status()),
 			zap.Duration("duration", duration),
-			zap.String("remote_addr", c.ClientIP()))
+			// the client as resolved through the trusted proxies, and the
+			// peer that sent the request
+			zap.String("client_ip", c.ClientIP()),
+			zap.String("remote_addr", c.RemoteIP()))
 	}
 }
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storeBackend creates a store for the conformance suite. elapse advances the
+// time seen by the store by d.
+type storeBackend func(t *testing.T) (store Store, elapse func(d time.Duration))
+
+// testStoreConformance checks the behaviour every Store backend shares
+func testStoreConformance(t *testing.T, newStore storeBackend) {
+	ctx := context.Background()
+	policy := PerMinute(3)
+
+	take := func(t *testing.T, store Store, key string) Decision {
+		t.Helper()
+		decision, err := store.Take(ctx, key, policy)
+		require.NoError(t, err)
+		return decision
+	}
+
+	t.Run("burst up to the limit", func(t *testing.T) {
+		store, _ := newStore(t)
+
+		for remaining := 2; remaining >= 0; remaining-- {
+			decision := take(t, store, "client")
+			assert.True(t, decision.Allowed)
+			assert.Equal(t, remaining, decision.Remaining)
+		}
+
+		decision := take(t, store, "client")
+		assert.False(t, decision.Allowed)
+		assert.Zero(t, decision.Remaining)
+		assert.Equal(t, 20*time.Second, decision.RetryAfter)
+		assert.Equal(t, time.Minute, decision.ResetAfter)
+	})
+
+	t.Run("refills evenly", func(t *testing.T) {
+		store, elapse := newStore(t)
+		for range 3 {
+			take(t, store, "client")
+		}
+
+		elapse(10 * time.Second)
+		decision := take(t, store, "client")
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, 10*time.Second, decision.RetryAfter)
+
+		elapse(10 * time.Second)
+		decision = take(t, store, "client")
+		assert.True(t, decision.Allowed)
+		assert.Zero(t, decision.Remaining)
+		assert.False(t, take(t, store, "client").Allowed)
+	})
+
+	t.Run("never holds more than the limit", func(t *testing.T) {
+		store, elapse := newStore(t)
+		take(t, store, "client")
+
+		elapse(time.Hour)
+		for range 3 {
+			assert.True(t, take(t, store, "client").Allowed)
+		}
+		assert.False(t, take(t, store, "client").Allowed)
+	})
+
+	t.Run("keys are isolated", func(t *testing.T) {
+		store, _ := newStore(t)
+		for range 3 {
+			take(t, store, "first")
+		}
+
+		assert.False(t, take(t, store, "first").Allowed)
+		decision := take(t, store, "second")
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 2, decision.Remaining)
+	})
+}
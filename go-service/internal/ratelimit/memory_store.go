@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+	"time"
+
+	"github.com/wbentaleb/student-report-service/internal/clock"
+)
+
+// memoryShards spreads the buckets over independently locked maps, so that
+// concurrent requests of different clients rarely wait for each other
+const memoryShards = 32
+
+// MemoryStore keeps the buckets of a single replica in memory. Buckets that
+// have refilled completely are no different from new ones and are dropped by
+// Sweep.
+type MemoryStore struct {
+	seed   maphash.Seed
+	shards [memoryShards]memoryShard
+	clock  clock.Clock
+}
+
+type memoryShard struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	bucket
+	// full is when the bucket will have refilled completely
+	full time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	store := &MemoryStore{seed: maphash.MakeSeed(), clock: clock.System()}
+	for i := range store.shards {
+		store.shards[i].buckets = make(map[string]*memoryBucket)
+	}
+	return store
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, policy Policy) (Decision, error) {
+	now := s.clock.Now()
+	shard := &s.shards[maphash.String(s.seed, key)%memoryShards]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: bucket{tokens: float64(policy.Limit), updated: now}}
+		shard.buckets[key] = b
+	}
+	decision := b.take(policy, now)
+	b.full = now.Add(decision.ResetAfter)
+	return decision, nil
+}
+
+// Sweep drops the buckets that have refilled completely and returns how many
+// were dropped
+func (s *MemoryStore) Sweep() int {
+	now := s.clock.Now()
+	dropped := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		for key, b := range shard.buckets {
+			if !now.Before(b.full) {
+				delete(shard.buckets, key)
+				dropped++
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return dropped
+}
+
+// Len returns the number of buckets held
+func (s *MemoryStore) Len() int {
+	total := 0
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		total += len(s.shards[i].buckets)
+		s.shards[i].mu.Unlock()
+	}
+	return total
+}
+
+// RunSweeper sweeps the idle buckets every interval until ctx is done
+func (s *MemoryStore) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
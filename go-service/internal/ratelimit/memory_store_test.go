@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wbentaleb/student-report-service/internal/clock"
+)
+
+func newTestMemoryStore() (*MemoryStore, func(time.Duration)) {
+	store := NewMemoryStore()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store.clock = clock.Func(func() time.Time { return now })
+	return store, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestMemoryStore_Conformance(t *testing.T) {
+	testStoreConformance(t, func(t *testing.T) (Store, func(time.Duration)) {
+		return newTestMemoryStore()
+	})
+}
+
+func TestMemoryStore_SweepDropsIdleBuckets(t *testing.T) {
+	store, elapse := newTestMemoryStore()
+	ctx := context.Background()
+
+	_, err := store.Take(ctx, "idle", PerMinute(60))
+	require.NoError(t, err)
+	for range 30 {
+		_, err := store.Take(ctx, "busy", PerMinute(60))
+		require.NoError(t, err)
+	}
+
+	// the idle bucket is full again after a second, the busy one after 30
+	elapse(time.Second)
+	assert.Equal(t, 1, store.Sweep())
+	assert.Equal(t, 1, store.Len())
+
+	elapse(29 * time.Second)
+	assert.Equal(t, 1, store.Sweep())
+	assert.Zero(t, store.Len())
+}
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	store := NewMemoryStore()
+	var allowed atomic.Int64
+
+	var wg sync.WaitGroup
+	for i := range 200 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			decision, err := store.Take(context.Background(), fmt.Sprintf("client-%d", i%2), PerMinute(50))
+			if err == nil && decision.Allowed {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(100), allowed.Load())
+}
@@ -0,0 +1,107 @@
+// Package ratelimit implements token buckets shared by every request of a
+// client. Buckets live in memory or, so that limits hold across replicas, in
+// a Redis-compatible server.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Store backends selected by RATE_LIMIT_BACKEND
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Policy allows Limit requests per Period. A full bucket holds Limit tokens,
+// so up to Limit requests can be made at once, and tokens are refilled evenly
+// over Period. A Limit of zero or less means unlimited.
+type Policy struct {
+	Limit  int
+	Period time.Duration
+}
+
+// PerMinute returns the policy allowing limit requests per minute
+func PerMinute(limit int) Policy {
+	return Policy{Limit: limit, Period: time.Minute}
+}
+
+// Unlimited reports whether requests under p are never limited
+func (p Policy) Unlimited() bool {
+	return p.Limit <= 0
+}
+
+// Decision is the outcome of taking a token from a bucket
+type Decision struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	// RetryAfter is the time until the next token, when not allowed
+	RetryAfter time.Duration
+	// ResetAfter is the time until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// Store keeps the token buckets
+type Store interface {
+	// Take removes a token from the bucket of key, which is created full
+	Take(ctx context.Context, key string, policy Policy) (Decision, error)
+}
+
+// bucket is the state of a token bucket as of updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills b up to now and removes a token when one is available
+func (b *bucket) take(policy Policy, now time.Time) Decision {
+	limit := float64(policy.Limit)
+	perToken := policy.Period / time.Duration(policy.Limit)
+
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(limit, b.tokens+float64(elapsed)/float64(perToken))
+		b.updated = now
+	}
+
+	decision := Decision{Allowed: b.tokens >= 1}
+	if decision.Allowed {
+		b.tokens--
+	} else {
+		decision.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	decision.Remaining = int(b.tokens)
+	decision.ResetAfter = time.Duration((limit - b.tokens) * float64(perToken))
+	return decision
+}
+
+// ParseRoutes reads per-route limits written as comma separated
+// <route>=<requests per minute> pairs, e.g. "/health=0,/api/v1/reports/batch=5".
+// Routes are gin route patterns such as /api/v1/students/:id/report; a limit
+// of 0 exempts the route.
+func ParseRoutes(spec string) (map[string]Policy, error) {
+	routes := make(map[string]Policy)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		route, value, found := strings.Cut(pair, "=")
+		route = strings.TrimSpace(route)
+		if !found || !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("invalid route limit %q, expected <route>=<requests per minute>", pair)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid limit for route %s: %q", route, value)
+		}
+		routes[route] = PerMinute(limit)
+	}
+	return routes, nil
+}
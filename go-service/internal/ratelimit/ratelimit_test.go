@@ -0,0 +1,24 @@
+package ratelimit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes(" /health=0, /api/v1/students/:id/report=30,,")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]Policy{
+		"/health":                     PerMinute(0),
+		"/api/v1/students/:id/report": PerMinute(30),
+	}, routes)
+	assert.True(t, routes["/health"].Unlimited())
+
+	for _, spec := range []string{"/health", "health=1", "/health=-1", "/health=fast"} {
+		_, err := ParseRoutes(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills and takes from the bucket in KEYS[1] atomically. Time
+// is read from the server, so that replicas with skewed clocks agree, and a
+// bucket expires once it would have refilled completely.
+//
+// ARGV: limit, period in milliseconds. Returns: allowed (0 or 1), remaining
+// tokens, retry after and reset after in milliseconds.
+var takeScript = redis.NewScript(`
+redis.replicate_commands()
+local limit = tonumber(ARGV[1])
+local per_token = tonumber(ARGV[2]) / limit
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or limit
+local updated = tonumber(state[2]) or now
+if now > updated then
+  tokens = math.min(limit, tokens + (now - updated) / per_token)
+  updated = now
+end
+
+local allowed, retry_after = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry_after = math.ceil((1 - tokens) * per_token)
+end
+local reset_after = math.ceil((limit - tokens) * per_token)
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(updated))
+redis.call('PEXPIRE', KEYS[1], math.max(reset_after, 1))
+return {allowed, math.floor(tokens), retry_after, reset_after}
+`)
+
+// RedisStore keeps the buckets in a Redis-compatible server so that every
+// replica of the service draws from the same buckets. Keys are
+// <prefix><key> and expire on their own once idle.
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore connects to the server at url, e.g.
+// redis://:password@localhost:6379/0, and checks that it is reachable
+func NewRedisStore(url, prefix string) (*RedisStore, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+
+	client := redis.NewClient(options)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisStore{client: client, prefix: prefix}, nil
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, policy Policy) (Decision, error) {
+	result, err := takeScript.Run(ctx, s.client, []string{s.prefix + key}, policy.Limit, policy.Period.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	if len(result) != 4 {
+		return Decision{}, fmt.Errorf("unexpected rate limit script result %v", result)
+	}
+
+	return Decision{
+		Allowed:    result[0] == 1,
+		Remaining:  int(result[1]),
+		RetryAfter: time.Duration(result[2]) * time.Millisecond,
+		ResetAfter: time.Duration(result[3]) * time.Millisecond,
+	}, nil
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisStore(t *testing.T, server *miniredis.Miniredis) *RedisStore {
+	t.Helper()
+	store, err := NewRedisStore("redis://"+server.Addr()+"/0", "test:")
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// startTestRedis runs a server whose clock is advanced by the returned
+// function, expiring keys accordingly
+func startTestRedis(t *testing.T) (*miniredis.Miniredis, func(time.Duration)) {
+	server := miniredis.RunT(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	server.SetTime(now)
+	return server, func(d time.Duration) {
+		now = now.Add(d)
+		server.SetTime(now)
+		server.FastForward(d)
+	}
+}
+
+func TestRedisStore_Conformance(t *testing.T) {
+	testStoreConformance(t, func(t *testing.T) (Store, func(time.Duration)) {
+		server, elapse := startTestRedis(t)
+		return newTestRedisStore(t, server), elapse
+	})
+}
+
+func TestRedisStore_SharedByReplicas(t *testing.T) {
+	server, _ := startTestRedis(t)
+	first, second := newTestRedisStore(t, server), newTestRedisStore(t, server)
+	ctx := context.Background()
+
+	for range 2 {
+		decision, err := first.Take(ctx, "client", PerMinute(3))
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+	}
+	decision, err := second.Take(ctx, "client", PerMinute(3))
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = first.Take(ctx, "client", PerMinute(3))
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+}
+
+func TestRedisStore_IdleBucketsExpire(t *testing.T) {
+	server, elapse := startTestRedis(t)
+	store := newTestRedisStore(t, server)
+
+	_, err := store.Take(context.Background(), "client", PerMinute(60))
+	require.NoError(t, err)
+	assert.True(t, server.Exists("test:client"))
+
+	elapse(time.Second)
+	assert.False(t, server.Exists("test:client"))
+}
+
+func TestRedisStore_Unreachable(t *testing.T) {
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	_, err := NewRedisStore("redis://"+addr, "")
+
+	assert.Error(t, err)
+}
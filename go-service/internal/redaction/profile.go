@@ -0,0 +1,195 @@
+// Package redaction defines the profiles that mask or omit personal student
+// data before a report is rendered, so that every output format shows the
+// same fields.
+package redaction
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+)
+
+// Built-in profiles, from the least to the most redacted
+const (
+	Full       = "full"
+	Parent     = "parent"
+	Transcript = "transcript"
+	Public     = "public"
+)
+
+// Action is what a profile does to a student field. Higher actions hide more.
+type Action int
+
+const (
+	Keep Action = iota
+	// Mask replaces the value with a partial one, e.g. initials
+	Mask
+	// Omit removes the field from the report
+	Omit
+)
+
+// Profile decides which student fields, referenced by their JSON name, are
+// masked or omitted
+type Profile struct {
+	Name   string
+	fields map[string]Action
+}
+
+// staffFields are only meaningful to school staff
+var staffFields = map[string]Action{
+	"systemAccess": Omit,
+	"reporterName": Omit,
+}
+
+// contactFields identify or reach the student and their family
+var contactFields = map[string]Action{
+	"email":              Omit,
+	"phone":              Omit,
+	"fatherName":         Omit,
+	"fatherPhone":        Omit,
+	"motherName":         Omit,
+	"motherPhone":        Omit,
+	"guardianName":       Omit,
+	"guardianPhone":      Omit,
+	"relationOfGuardian": Omit,
+	"currentAddress":     Omit,
+	"permanentAddress":   Omit,
+}
+
+var profiles = []*Profile{
+	{Name: Full},
+	// parents see everything about their child but staff records
+	{Name: Parent, fields: merge(staffFields)},
+	// transcripts are shared outside the school and keep the academic record
+	{Name: Transcript, fields: merge(staffFields, contactFields)},
+	// public reports do not identify the student beyond initials and year of
+	// birth
+	{Name: Public, fields: merge(staffFields, contactFields, map[string]Action{
+		"name":   Mask,
+		"dob":    Mask,
+		"gender": Omit,
+	})},
+}
+
+// maskers produce the partial value of the masked fields. Other fields are
+// masked entirely.
+var maskers = map[string]func(string) string{
+	"name": initials,
+	"dob":  birthYear,
+}
+
+func merge(sets ...map[string]Action) map[string]Action {
+	merged := make(map[string]Action)
+	for _, set := range sets {
+		for field, action := range set {
+			merged[field] = max(merged[field], action)
+		}
+	}
+	return merged
+}
+
+// Lookup returns the built-in profile with the given name
+func Lookup(name string) (*Profile, bool) {
+	for _, profile := range profiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return nil, false
+}
+
+// Names lists the built-in profiles, from the least to the most redacted
+func Names() []string {
+	names := make([]string, len(profiles))
+	for i, profile := range profiles {
+		names[i] = profile.Name
+	}
+	return names
+}
+
+// Action returns what p does to the student field
+func (p *Profile) Action(field string) Action {
+	return p.fields[field]
+}
+
+// Covers reports whether p hides at least every field other hides, so that
+// p may be applied wherever other is required
+func (p *Profile) Covers(other *Profile) bool {
+	for field, action := range other.fields {
+		if p.Action(field) < action {
+			return false
+		}
+	}
+	return true
+}
+
+// CacheKey identifies the rendered variant produced by this profile
+func (p *Profile) CacheKey() string {
+	return "profile-" + p.Name
+}
+
+// Apply returns copies of student and tmpl with the profile applied: omitted
+// fields are cleared and removed from the template, and masked fields are
+// replaced by their partial value and printed as text.
+func (p *Profile) Apply(student *dto.Student, tmpl *templates.Template) (*dto.Student, *templates.Template) {
+	if len(p.fields) == 0 {
+		return student, tmpl
+	}
+
+	redacted := *student
+	value := reflect.ValueOf(&redacted).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+		switch p.Action(field) {
+		case Omit:
+			value.Field(i).SetZero()
+		case Mask:
+			if value.Field(i).Kind() != reflect.String {
+				value.Field(i).SetZero()
+				continue
+			}
+			value.Field(i).SetString(mask(field, value.Field(i).String()))
+		}
+	}
+
+	return &redacted, tmpl.MapFields(func(field templates.Field) (templates.Field, bool) {
+		switch p.Action(field.Field) {
+		case Omit:
+			return field, false
+		case Mask:
+			field.Format = templates.FormatText
+		}
+		return field, true
+	})
+}
+
+func mask(field, value string) string {
+	if value == "" {
+		return ""
+	}
+	if masker, ok := maskers[field]; ok {
+		return masker(value)
+	}
+	return "***"
+}
+
+// initials turns "Jane Mary Doe" into "J. M. D."
+func initials(name string) string {
+	var parts []string
+	for _, word := range strings.Fields(name) {
+		parts = append(parts, string([]rune(word)[0])+".")
+	}
+	return strings.Join(parts, " ")
+}
+
+// birthYear keeps the year of an RFC 3339 date
+func birthYear(date string) string {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return "***"
+	}
+	return t.Format("2006")
+}
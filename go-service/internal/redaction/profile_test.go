@@ -0,0 +1,135 @@
+package redaction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+)
+
+func testStudent() *dto.Student {
+	return &dto.Student{
+		ID:             42,
+		Name:           "Jane Mary Doe",
+		Email:          "jane@example.com",
+		SystemAccess:   true,
+		Phone:          "555-0100",
+		Gender:         "Female",
+		DOB:            "2008-05-14T00:00:00Z",
+		Class:          "10",
+		Section:        "A",
+		Roll:           7,
+		FatherPhone:    "555-0101",
+		CurrentAddress: "1 Main Street",
+		ReporterName:   "Mr. Smith",
+	}
+}
+
+// fieldsOf lists the fields left in the template
+func fieldsOf(tmpl *templates.Template) map[string]string {
+	fields := make(map[string]string)
+	for _, section := range tmpl.Sections {
+		for _, field := range section.Fields {
+			fields[field.Field] = field.Format
+		}
+	}
+	return fields
+}
+
+func TestLookup(t *testing.T) {
+	for _, name := range Names() {
+		profile, ok := Lookup(name)
+		require.True(t, ok, name)
+		assert.Equal(t, name, profile.Name)
+	}
+
+	_, ok := Lookup("secret")
+	assert.False(t, ok)
+	assert.Equal(t, []string{Full, Parent, Transcript, Public}, Names())
+}
+
+func TestProfile_FullKeepsEverything(t *testing.T) {
+	profile, _ := Lookup(Full)
+	student, tmpl := testStudent(), templates.Default()
+
+	redactedStudent, redactedTmpl := profile.Apply(student, tmpl)
+
+	assert.Equal(t, student, redactedStudent)
+	assert.Equal(t, tmpl, redactedTmpl)
+}
+
+func TestProfile_Parent(t *testing.T) {
+	profile, _ := Lookup(Parent)
+
+	student, tmpl := profile.Apply(testStudent(), templates.Default())
+
+	assert.Empty(t, student.ReporterName)
+	assert.False(t, student.SystemAccess)
+	assert.Equal(t, "jane@example.com", student.Email)
+	fields := fieldsOf(tmpl)
+	assert.NotContains(t, fields, "reporterName")
+	assert.NotContains(t, fields, "systemAccess")
+	assert.Contains(t, fields, "fatherPhone")
+}
+
+func TestProfile_Transcript(t *testing.T) {
+	profile, _ := Lookup(Transcript)
+
+	student, tmpl := profile.Apply(testStudent(), templates.Default())
+
+	assert.Empty(t, student.Email)
+	assert.Empty(t, student.Phone)
+	assert.Empty(t, student.FatherPhone)
+	assert.Empty(t, student.CurrentAddress)
+	assert.Equal(t, "Jane Mary Doe", student.Name)
+	assert.Equal(t, "2008-05-14T00:00:00Z", student.DOB)
+
+	// the parent, guardian and address sections are left empty and dropped
+	require.Len(t, tmpl.Sections, 2)
+	assert.Equal(t, "Personal Information", tmpl.Sections[0].Title)
+	assert.Equal(t, "Academic Information", tmpl.Sections[1].Title)
+}
+
+func TestProfile_PublicMasks(t *testing.T) {
+	profile, _ := Lookup(Public)
+	original := testStudent()
+
+	student, tmpl := profile.Apply(original, templates.Default())
+
+	assert.Equal(t, "J. M. D.", student.Name)
+	assert.Equal(t, "2008", student.DOB)
+	assert.Empty(t, student.Gender)
+	assert.Equal(t, 42, student.ID)
+	assert.Equal(t, "10", student.Class)
+
+	// masked dates are no longer dates
+	fields := fieldsOf(tmpl)
+	assert.Equal(t, templates.FormatText, fields["dob"])
+	assert.NotContains(t, fields, "gender")
+
+	// the inputs are left untouched
+	assert.Equal(t, "Jane Mary Doe", original.Name)
+	assert.Equal(t, templates.FormatDate, fieldsOf(templates.Default())["dob"])
+}
+
+func TestProfile_Covers(t *testing.T) {
+	names := Names()
+	for i, name := range names {
+		profile, _ := Lookup(name)
+		for j, other := range names {
+			otherProfile, _ := Lookup(other)
+			// every profile hides at least what the previous ones hide
+			assert.Equal(t, i >= j, profile.Covers(otherProfile), "%s covers %s", name, other)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	assert.Equal(t, "", mask("name", ""))
+	assert.Equal(t, "É. Z.", mask("name", "Élodie  Zhang"))
+	assert.Equal(t, "***", mask("dob", "14/05/2008"))
+	assert.Equal(t, "***", mask("email", "jane@example.com"))
+}
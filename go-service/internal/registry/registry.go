@@ -0,0 +1,139 @@
+// Package registry records every issued report so that the report ID printed
+// in its footer can later be checked for authenticity.
+package registry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// ErrReportNotFound is returned when no report was issued under an ID
+var ErrReportNotFound = errors.New("report not found")
+
+var idPattern = regexp.MustCompile(`^SR-[0-9]{1,20}-[0-9]{1,20}-[0-9a-f]{8}$`)
+
+// Record describes an issued report
+type Record struct {
+	ID        string    `json:"id"`
+	StudentID int       `json:"student_id"`
+	Format    string    `json:"format"`
+	Template  string    `json:"template"`
+	Locale    string    `json:"locale"`
+	Profile   string    `json:"profile,omitempty"` // redaction profile, empty for older reports
+	IssuedAt  time.Time `json:"issued_at"`
+
+	// ContentHash is the SHA-256 of the document as it was served
+	ContentHash string `json:"content_hash"`
+}
+
+// Registry persists issued reports
+type Registry interface {
+	Register(record *Record) error
+	Get(id string) (*Record, error)
+}
+
+// NewID returns a report ID of the form SR-<student>-<unix time>-<random>.
+// The random suffix keeps IDs unique when a student's report is rendered in
+// several variants within the same second.
+func NewID(studentID int, issuedAt time.Time) string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("SR-%d-%d-%s", studentID, issuedAt.Unix(), hex.EncodeToString(suffix))
+}
+
+// ValidID reports whether id has the form produced by NewID
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// FileRegistry keeps one JSON document per issued report
+type FileRegistry struct {
+	basePath string
+}
+
+func NewFileRegistry(basePath string) (*FileRegistry, error) {
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create report registry directory: %w", err)
+	}
+	return &FileRegistry{basePath: basePath}, nil
+}
+
+// Register stores record. Issued reports are immutable: registering an ID
+// again is only accepted for the same content, as happens when a
+// deterministic report is rendered a second time.
+func (r *FileRegistry) Register(record *Record) error {
+	if !ValidID(record.ID) {
+		return fmt.Errorf("invalid report ID %q", record.ID)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode report record: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(r.basePath, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	// a hard link, unlike a rename, fails instead of replacing an existing record
+	if err := os.Link(tmp.Name(), r.recordPath(record.ID)); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return r.checkExisting(record)
+		}
+		return fmt.Errorf("failed to store report record: %w", err)
+	}
+	return nil
+}
+
+func (r *FileRegistry) Get(id string) (*Record, error) {
+	// IDs become file names, so anything unexpected is simply unknown
+	if !ValidID(id) {
+		return nil, ErrReportNotFound
+	}
+
+	data, err := os.ReadFile(r.recordPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrReportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report record: %w", err)
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode report record %s: %w", id, err)
+	}
+	return &record, nil
+}
+
+func (r *FileRegistry) checkExisting(record *Record) error {
+	existing, err := r.Get(record.ID)
+	if err != nil {
+		return err
+	}
+	if existing.ContentHash != record.ContentHash || existing.StudentID != record.StudentID {
+		return fmt.Errorf("report %s is already registered", record.ID)
+	}
+	return nil
+}
+
+func (r *FileRegistry) recordPath(id string) string {
+	return filepath.Join(r.basePath, id+".json")
+}
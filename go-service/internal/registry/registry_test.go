@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewID(t *testing.T) {
+	issuedAt := time.Unix(1700000000, 0)
+
+	first := NewID(42, issuedAt)
+	second := NewID(42, issuedAt)
+
+	assert.Regexp(t, `^SR-42-1700000000-[0-9a-f]{8}$`, first)
+	assert.NotEqual(t, first, second)
+	assert.True(t, ValidID(first))
+}
+
+func TestValidID(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"SR-42-1700000000-0a1b2c3d", true},
+		{"SR-42-1700000000", false},
+		{"SR-42-1700000000-0A1B2C3D", false},
+		{"SR-abc-1700000000-0a1b2c3d", false},
+		{"../SR-42-1700000000-0a1b2c3d", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			assert.Equal(t, tt.valid, ValidID(tt.id))
+		})
+	}
+}
+
+func TestFileRegistry_RegisterAndGet(t *testing.T) {
+	// Setup
+	registry, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+
+	record := &Record{
+		ID:          "SR-42-1700000000-0a1b2c3d",
+		StudentID:   42,
+		Format:      "pdf",
+		Template:    "default",
+		Locale:      "en",
+		IssuedAt:    time.Unix(1700000000, 0).UTC(),
+		ContentHash: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}
+
+	// Execute
+	require.NoError(t, registry.Register(record))
+	loaded, err := registry.Get(record.ID)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, record.StudentID, loaded.StudentID)
+	assert.Equal(t, record.ContentHash, loaded.ContentHash)
+	assert.True(t, record.IssuedAt.Equal(loaded.IssuedAt))
+}
+
+func TestFileRegistry_RegisterTwice(t *testing.T) {
+	registry, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+
+	record := &Record{ID: "SR-42-1700000000-0a1b2c3d", ContentHash: "original"}
+	require.NoError(t, registry.Register(record))
+
+	err = registry.Register(&Record{ID: record.ID, ContentHash: "forged"})
+	assert.Error(t, err)
+
+	loaded, err := registry.Get(record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "original", loaded.ContentHash)
+}
+
+func TestFileRegistry_RegisterSameContentTwice(t *testing.T) {
+	registry, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+
+	record := &Record{ID: "SR-42-1700000000-0a1b2c3d", StudentID: 42, ContentHash: "original"}
+	require.NoError(t, registry.Register(record))
+
+	assert.NoError(t, registry.Register(&Record{ID: record.ID, StudentID: 42, ContentHash: "original"}))
+}
+
+func TestFileRegistry_RegisterInvalidID(t *testing.T) {
+	registry, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+
+	assert.Error(t, registry.Register(&Record{ID: "../escape"}))
+}
+
+func TestFileRegistry_GetMissing(t *testing.T) {
+	dir := t.TempDir()
+	registry, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "outside.json"), []byte("{}"), 0644))
+
+	_, err = registry.Get("SR-1-1-00000000")
+	assert.ErrorIs(t, err, ErrReportNotFound)
+
+	_, err = registry.Get("outside")
+	assert.ErrorIs(t, err, ErrReportNotFound)
+}
@@ -0,0 +1,41 @@
+package retry
+
+import "sync"
+
+// Budget caps retries at a fraction of the calls made, so that retries cannot
+// multiply the load on a backend that is already failing. Every call earns
+// ratio of a retry and every retry spends one; up to reserve retries can be
+// saved up, which lets bursts of failures after a quiet period be retried.
+type Budget struct {
+	mu      sync.Mutex
+	ratio   float64
+	reserve float64
+	balance float64
+}
+
+// NewBudget returns a budget that starts with its reserve available. The
+// reserve is at least one retry.
+func NewBudget(ratio float64, reserve int) *Budget {
+	reserve = max(reserve, 1)
+	return &Budget{
+		ratio:   ratio,
+		reserve: float64(reserve),
+		balance: float64(reserve),
+	}
+}
+
+func (b *Budget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.balance = min(b.balance+b.ratio, b.reserve)
+}
+
+func (b *Budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.balance < 1 {
+		return false
+	}
+	b.balance--
+	return true
+}
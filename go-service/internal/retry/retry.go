@@ -0,0 +1,175 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TransientError marks a failure worth retrying. After, when positive, is the
+// delay the server asked for (the Retry-After header).
+type TransientError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// Transient marks err as worth retrying
+func Transient(err error, after time.Duration) error {
+	return &TransientError{Err: err, After: after}
+}
+
+// Policy retries transient failures with capped exponential backoff and full
+// jitter: the delay before retry n is drawn uniformly from
+// [0, min(maxDelay, baseDelay*2^n)). Errors not marked as transient are
+// returned at once, as are failures once ctx is done.
+type Policy struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	budget    *Budget
+	jitter    func(time.Duration) time.Duration
+	logger    *zap.Logger
+
+	retries         atomic.Uint64
+	budgetExhausted atomic.Uint64
+}
+
+// Stats counts the retries made by a policy since startup, and the retries
+// not made because the budget was spent
+type Stats struct {
+	Retries         uint64
+	BudgetExhausted uint64
+}
+
+// NewPolicy returns a policy making up to attempts calls in total. Retries are
+// drawn from budget, which may be shared by several policies; a nil budget
+// does not limit them.
+func NewPolicy(attempts int, baseDelay, maxDelay time.Duration, budget *Budget, logger *zap.Logger) *Policy {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return &Policy{
+		attempts:  attempts,
+		baseDelay: baseDelay,
+		maxDelay:  maxDelay,
+		budget:    budget,
+		jitter:    fullJitter,
+		logger:    logger,
+	}
+}
+
+func fullJitter(ceiling time.Duration) time.Duration {
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+// Do calls op until it succeeds or the failure is final. fields are added to
+// the retry log entries.
+func (p *Policy) Do(ctx context.Context, op func(context.Context) error, fields ...zap.Field) error {
+	if p.budget != nil {
+		p.budget.deposit()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var transient *TransientError
+		if !errors.As(err, &transient) || ctx.Err() != nil {
+			return err
+		}
+		if attempt == p.attempts {
+			return fmt.Errorf("failed after %d attempts: %w", attempt, transient.Err)
+		}
+
+		delay := p.delay(attempt, transient.After)
+		if transient.After > p.maxDelay {
+			p.logger.Warn("Server asked to retry later than the maximum delay, giving up",
+				append(fields, zap.Duration("retry_after", transient.After), zap.Error(err))...)
+			return transient.Err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return transient.Err
+		}
+		if p.budget != nil && !p.budget.withdraw() {
+			p.budgetExhausted.Add(1)
+			p.logger.Warn("Retry budget exhausted, not retrying",
+				append(fields, zap.Int("attempt", attempt), zap.Error(err))...)
+			return transient.Err
+		}
+
+		p.logger.Warn("Request failed, retrying",
+			append(fields, zap.Int("attempt", attempt), zap.Duration("delay", delay), zap.Error(err))...)
+		if err := sleep(ctx, delay); err != nil {
+			return transient.Err
+		}
+		p.retries.Add(1)
+	}
+}
+
+// Stats returns the retry activity of the policy
+func (p *Policy) Stats() Stats {
+	return Stats{
+		Retries:         p.retries.Load(),
+		BudgetExhausted: p.budgetExhausted.Load(),
+	}
+}
+
+// delay returns how long to wait before the retry following attempt
+func (p *Policy) delay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	ceiling := p.maxDelay
+	if shift := attempt - 1; shift < 32 && p.baseDelay<<shift < ceiling && p.baseDelay<<shift > 0 {
+		ceiling = p.baseDelay << shift
+	}
+	return p.jitter(ceiling)
+}
+
+// sleep waits for d, returning early with ctx's error once it is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ParseRetryAfter returns the delay requested by a Retry-After header, given
+// either in seconds or as an HTTP date, or zero when there is none
+func ParseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
@@ -0,0 +1,197 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var errUnavailable = errors.New("service unavailable")
+
+// newTestPolicy returns a policy whose jitter always picks the ceiling and
+// records the delays it waits
+func newTestPolicy(attempts int, budget *Budget) (*Policy, *[]time.Duration) {
+	policy := NewPolicy(attempts, time.Millisecond, 4*time.Millisecond, budget, zap.NewNop())
+	var delays []time.Duration
+	policy.jitter = func(ceiling time.Duration) time.Duration {
+		delays = append(delays, ceiling)
+		return ceiling
+	}
+	return policy, &delays
+}
+
+// failing returns an operation failing with errs in turn, then succeeding
+func failing(calls *int, errs ...error) func(context.Context) error {
+	return func(context.Context) error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestDo_RetriesTransientErrors(t *testing.T) {
+	policy, delays := newTestPolicy(4, nil)
+	calls := 0
+
+	err := policy.Do(context.Background(), failing(&calls, Transient(errUnavailable, 0), Transient(errUnavailable, 0)))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond}, *delays)
+	assert.Equal(t, Stats{Retries: 2}, policy.Stats())
+}
+
+func TestDo_BackoffIsCappedAtMaxDelay(t *testing.T) {
+	policy, delays := newTestPolicy(6, nil)
+	calls := 0
+
+	err := policy.Do(context.Background(), failing(&calls,
+		Transient(errUnavailable, 0), Transient(errUnavailable, 0), Transient(errUnavailable, 0),
+		Transient(errUnavailable, 0), Transient(errUnavailable, 0)))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{1, 2, 4, 4, 4}, scale(*delays, time.Millisecond))
+}
+
+func TestDo_PermanentErrorsAreNotRetried(t *testing.T) {
+	policy, _ := newTestPolicy(3, nil)
+	calls := 0
+
+	err := policy.Do(context.Background(), failing(&calls, errUnavailable))
+
+	assert.Same(t, errUnavailable, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDo_GivesUpAfterAttempts(t *testing.T) {
+	policy, _ := newTestPolicy(3, nil)
+	calls := 0
+	transient := Transient(errUnavailable, 0)
+
+	err := policy.Do(context.Background(), failing(&calls, transient, transient, transient, transient))
+
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.EqualError(t, err, "failed after 3 attempts: service unavailable")
+	assert.Equal(t, 3, calls)
+}
+
+func TestDo_HonoursRetryAfter(t *testing.T) {
+	policy, delays := newTestPolicy(3, nil)
+	calls := 0
+	start := time.Now()
+
+	err := policy.Do(context.Background(), failing(&calls, Transient(errUnavailable, 3*time.Millisecond)))
+
+	assert.NoError(t, err)
+	assert.Empty(t, *delays, "the server's delay replaces the backoff")
+	assert.GreaterOrEqual(t, time.Since(start), 3*time.Millisecond)
+}
+
+func TestDo_RetryAfterBeyondMaxDelayGivesUp(t *testing.T) {
+	policy, _ := newTestPolicy(3, nil)
+	calls := 0
+
+	err := policy.Do(context.Background(), failing(&calls, Transient(errUnavailable, time.Minute)))
+
+	assert.Same(t, errUnavailable, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDo_CancelledContextAbortsTheWait(t *testing.T) {
+	policy := NewPolicy(3, time.Hour, time.Hour, nil, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	err := policy.Do(ctx, failing(&calls, Transient(errUnavailable, 0)))
+
+	assert.Same(t, errUnavailable, err)
+	assert.Equal(t, 1, calls)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestDo_DoesNotWaitPastTheDeadline(t *testing.T) {
+	policy := NewPolicy(3, time.Hour, time.Hour, nil, zap.NewNop())
+	policy.jitter = func(ceiling time.Duration) time.Duration { return ceiling }
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	calls := 0
+
+	start := time.Now()
+	err := policy.Do(ctx, failing(&calls, Transient(errUnavailable, 0)))
+
+	assert.Same(t, errUnavailable, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestDo_BudgetLimitsRetries(t *testing.T) {
+	budget := NewBudget(0.5, 1)
+	policy, _ := newTestPolicy(3, budget)
+	alwaysFailing := func(context.Context) error { return Transient(errUnavailable, 0) }
+
+	// the reserve pays for a single retry
+	calls := 0
+	policy.Do(context.Background(), func(ctx context.Context) error { calls++; return alwaysFailing(ctx) })
+	assert.Equal(t, 2, calls)
+
+	// then every other call earns one
+	calls = 0
+	policy.Do(context.Background(), func(ctx context.Context) error { calls++; return alwaysFailing(ctx) })
+	assert.Equal(t, 1, calls)
+	calls = 0
+	policy.Do(context.Background(), func(ctx context.Context) error { calls++; return alwaysFailing(ctx) })
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, Stats{Retries: 2, BudgetExhausted: 3}, policy.Stats())
+}
+
+func TestBudget_ReserveIsCapped(t *testing.T) {
+	budget := NewBudget(1, 2)
+	for i := 0; i < 10; i++ {
+		budget.deposit()
+	}
+
+	assert.True(t, budget.withdraw())
+	assert.True(t, budget.withdraw())
+	assert.False(t, budget.withdraw())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		header   string
+		expected time.Duration
+	}{
+		{header: "", expected: 0},
+		{header: "120", expected: 2 * time.Minute},
+		{header: "-5", expected: 0},
+		{header: now.Add(30 * time.Second).Format(http.TimeFormat), expected: 30 * time.Second},
+		{header: now.Add(-time.Minute).Format(http.TimeFormat), expected: 0},
+		{header: "soon", expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.header, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ParseRetryAfter(tc.header, now))
+		})
+	}
+}
+
+func scale(delays []time.Duration, unit time.Duration) []time.Duration {
+	scaled := make([]time.Duration, len(delays))
+	for i, delay := range delays {
+		scaled[i] = delay / unit
+	}
+	return scaled
+}
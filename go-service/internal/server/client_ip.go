@@ -0,0 +1,39 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wbentaleb/student-report-service/internal/middleware"
+)
+
+// Client IP headers selected by CLIENT_IP_HEADER
+const (
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderXRealIP       = "X-Real-IP"
+	HeaderForwarded     = "Forwarded"
+)
+
+// configureClientIP makes c.ClientIP() read header, but only on requests from
+// one of trustedProxies (IPs or CIDRs). The header is walked from the closest
+// proxy back, and the first address that is not a trusted proxy is the
+// client's, so that addresses prepended by the client are ignored. Without
+// trusted proxies the peer address is the client's.
+func configureClientIP(router *gin.Engine, trustedProxies []string, header string) error {
+	switch header {
+	case HeaderXForwardedFor, HeaderXRealIP:
+		router.RemoteIPHeaders = []string{header}
+	case HeaderForwarded:
+		router.Use(middleware.ForwardedChain())
+		router.RemoteIPHeaders = []string{middleware.ForwardedChainHeader}
+	default:
+		return fmt.Errorf("unknown client IP header %q, expected %s, %s or %s", header, HeaderXForwardedFor, HeaderXRealIP, HeaderForwarded)
+	}
+
+	router.ForwardedByClientIP = true
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	return nil
+}
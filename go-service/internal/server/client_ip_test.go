@@ -0,0 +1,212 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wbentaleb/student-report-service/internal/middleware"
+)
+
+const (
+	proxyAddr  = "10.0.0.5:443"
+	clientAddr = "203.0.113.9:51234"
+)
+
+// setupClientIPRouter answers every request with the client IP it resolved
+func setupClientIPRouter(t *testing.T, trustedProxies []string, header string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	require.NoError(t, configureClientIP(router, trustedProxies, header))
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+	return router
+}
+
+func resolveClientIP(router *gin.Engine, remoteAddr string, headers map[string][]string) string {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	for name, values := range headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Body.String()
+}
+
+func TestClientIP_NoTrustedProxies(t *testing.T) {
+	router := setupClientIPRouter(t, nil, HeaderXForwardedFor)
+
+	// nobody may claim another address
+	assert.Equal(t, "10.0.0.5", resolveClientIP(router, proxyAddr, map[string][]string{
+		"X-Forwarded-For": {"198.51.100.7"},
+		"X-Real-IP":       {"198.51.100.7"},
+	}))
+}
+
+func TestClientIP_XForwardedFor(t *testing.T) {
+	router := setupClientIPRouter(t, []string{"10.0.0.0/8"}, HeaderXForwardedFor)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{
+			name:       "client behind the proxy",
+			remoteAddr: proxyAddr,
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "through several trusted proxies",
+			remoteAddr: proxyAddr,
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.7, 10.0.0.3"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "address prepended by the client",
+			remoteAddr: proxyAddr,
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1, 198.51.100.7"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "trusted address prepended by the client",
+			remoteAddr: proxyAddr,
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.99, 198.51.100.7"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "header sent directly by the client",
+			remoteAddr: clientAddr,
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "other header ignored",
+			remoteAddr: proxyAddr,
+			headers:    map[string][]string{"X-Real-IP": {"198.51.100.7"}},
+			want:       "10.0.0.5",
+		},
+		{
+			name:       "garbage",
+			remoteAddr: proxyAddr,
+			headers:    map[string][]string{"X-Forwarded-For": {"not-an-ip"}},
+			want:       "10.0.0.5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, resolveClientIP(router, tt.remoteAddr, tt.headers))
+		})
+	}
+}
+
+func TestClientIP_XRealIP(t *testing.T) {
+	router := setupClientIPRouter(t, []string{"10.0.0.5"}, HeaderXRealIP)
+
+	assert.Equal(t, "198.51.100.7", resolveClientIP(router, proxyAddr, map[string][]string{
+		"X-Real-IP":       {"198.51.100.7"},
+		"X-Forwarded-For": {"192.0.2.1"},
+	}))
+	assert.Equal(t, "203.0.113.9", resolveClientIP(router, clientAddr, map[string][]string{
+		"X-Real-IP": {"198.51.100.7"},
+	}))
+}
+
+func TestClientIP_Forwarded(t *testing.T) {
+	router := setupClientIPRouter(t, []string{"10.0.0.0/8"}, HeaderForwarded)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{
+			name:       "client behind the proxy",
+			remoteAddr: proxyAddr,
+			headers:    map[string][]string{"Forwarded": {"for=198.51.100.7;proto=https;by=10.0.0.5"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "quoted IPv6 with port",
+			remoteAddr: proxyAddr,
+			headers:    map[string][]string{"Forwarded": {`For="[2001:db8:cafe::17]:4711"`}},
+			want:       "2001:db8:cafe::17",
+		},
+		{
+			name:       "IPv4 with port",
+			remoteAddr: proxyAddr,
+			headers:    map[string][]string{"Forwarded": {`for="198.51.100.7:4711"`}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "element prepended by the client",
+			remoteAddr: proxyAddr,
+			headers:    map[string][]string{"Forwarded": {"for=192.0.2.1", "for=198.51.100.7, for=10.0.0.3"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "obfuscated node",
+			remoteAddr: proxyAddr,
+			headers:    map[string][]string{"Forwarded": {"for=unknown"}},
+			want:       "10.0.0.5",
+		},
+		{
+			name:       "header sent directly by the client",
+			remoteAddr: clientAddr,
+			headers:    map[string][]string{"Forwarded": {"for=198.51.100.7"}},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "internal header sent by the client",
+			remoteAddr: proxyAddr,
+			headers: map[string][]string{
+				middleware.ForwardedChainHeader: {"192.0.2.1"},
+				"X-Forwarded-For":               {"192.0.2.1"},
+			},
+			want: "10.0.0.5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, resolveClientIP(router, tt.remoteAddr, tt.headers))
+		})
+	}
+}
+
+func TestClientIP_InvalidConfiguration(t *testing.T) {
+	assert.Error(t, configureClientIP(gin.New(), nil, "X-Client-IP"))
+	assert.Error(t, configureClientIP(gin.New(), []string{"10.0.0.0/33"}, HeaderXForwardedFor))
+	assert.Error(t, configureClientIP(gin.New(), []string{"proxy.internal"}, HeaderXForwardedFor))
+}
+
+func TestClientIP_Logged(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	router := setupClientIPRouter(t, []string{"10.0.0.0/8"}, HeaderXForwardedFor)
+	router.Use(middleware.RequestLogger(zap.New(core)))
+	router.GET("/logged", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/logged", nil)
+	req.RemoteAddr = proxyAddr
+	req.Header.Set("X-Forwarded-For", "192.0.2.1, 198.51.100.7")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "198.51.100.7", fields["client_ip"])
+	assert.Equal(t, "10.0.0.5", fields["remote_addr"])
+}
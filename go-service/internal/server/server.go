@@ -4,38 +4,83 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/wbentaleb/student-report-service/internal/apikeys"
+	"github.com/wbentaleb/student-report-service/internal/auth"
 	"github.com/wbentaleb/student-report-service/internal/config"
 	"github.com/wbentaleb/student-report-service/internal/handler"
+	"github.com/wbentaleb/student-report-service/internal/metrics"
 	"github.com/wbentaleb/student-report-service/internal/middleware"
+	"github.com/wbentaleb/student-report-service/internal/tracing"
 )
 
+// NewRouter builds the router. serviceMetrics and limiter may be nil when
+// metrics and rate limiting are disabled; the report endpoints are open to
+// anyone when both tokenVerifier and keyStore are nil. It fails when the
+// trusted proxies or the client IP header are invalid.
 func NewRouter(
 	cfg *config.Config,
 	log *zap.Logger,
+	serviceMetrics *metrics.Metrics,
+	tokenVerifier *auth.Verifier,
+	keyStore *apikeys.Store,
+	limiter *middleware.RateLimiter,
 	healthHandler *handler.HealthHandler,
 	reportHandler *handler.StudentReportHandler,
-) *gin.Engine {
+	batchHandler *handler.BatchReportHandler,
+	jobHandler *handler.ReportJobHandler,
+	verificationHandler *handler.ReportVerificationHandler,
+	eventHandler *handler.StudentEventHandler,
+	cacheAdminHandler *handler.CacheAdminHandler,
+	apiKeyHandler *handler.APIKeyHandler,
+) (*gin.Engine, error) {
 
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	router := gin.New()
-	applyMiddleware(router, cfg, log)
-	defineRoutes(router, healthHandler, reportHandler)
+	// before any middleware, which may log or limit by client IP
+	if err := configureClientIP(router, cfg.TrustedProxies, cfg.ClientIPHeader); err != nil {
+		return nil, err
+	}
+	applyMiddleware(router, serviceMetrics, limiter, log)
+	if serviceMetrics != nil {
+		router.GET("/metrics", gin.WrapH(serviceMetrics.Handler()))
+	}
+	authenticate := func(string) gin.HandlerFunc {
+		return func(c *gin.Context) { c.Next() }
+	}
+	if tokenVerifier != nil || keyStore != nil {
+		authenticate = func(scope string) gin.HandlerFunc {
+			return middleware.Authenticate(tokenVerifier, keyStore, scope)
+		}
+	}
+	defineRoutes(router, authenticate, healthHandler, reportHandler, batchHandler, jobHandler, verificationHandler, eventHandler)
+	if cacheAdminHandler != nil {
+		defineAdminRoutes(router, middleware.AdminAuth(cfg.AdminAPIKey, keyStore, apikeys.ScopeCacheAdmin), cacheAdminHandler)
+	}
+	if apiKeyHandler != nil {
+		defineKeyRoutes(router, middleware.AdminAuth(cfg.AdminAPIKey, nil, ""), apiKeyHandler)
+	}
 
-	return router
+	return router, nil
 }
 
-func applyMiddleware(router *gin.Engine, cfg *config.Config, log *zap.Logger) {
+func applyMiddleware(router *gin.Engine, serviceMetrics *metrics.Metrics, limiter *middleware.RateLimiter, log *zap.Logger) {
+	// outermost, so that recovered panics and rate-limited requests are counted
+	if serviceMetrics != nil {
+		router.Use(serviceMetrics.Middleware())
+	}
 	router.Use(gin.Recovery())
 	router.Use(middleware.RequestID())
+	router.Use(tracing.Middleware())
 	router.Use(middleware.BasicSecurity())
 
-	if cfg.EnableRateLimit {
-		limiter := middleware.NewRateLimiter(cfg.RateLimitPerMinute)
+	if limiter != nil {
 		router.Use(limiter.Middleware())
-		log.Info("Rate limiting enabled", zap.Int("requests_per_minute", cfg.RateLimitPerMinute))
+		if serviceMetrics != nil {
+			serviceMetrics.RegisterRateLimiter(limiter)
+		}
 	}
 
 	router.Use(middleware.RequestLogger(log))
@@ -43,8 +88,13 @@ func applyMiddleware(router *gin.Engine, cfg *config.Config, log *zap.Logger) {
 
 func defineRoutes(
 	router *gin.Engine,
+	authenticate func(scope string) gin.HandlerFunc,
 	healthHandler *handler.HealthHandler,
 	reportHandler *handler.StudentReportHandler,
+	batchHandler *handler.BatchReportHandler,
+	jobHandler *handler.ReportJobHandler,
+	verificationHandler *handler.ReportVerificationHandler,
+	eventHandler *handler.StudentEventHandler,
 ) {
 	// Health check endpoint
 	router.GET("/health", healthHandler.Handle)
@@ -52,6 +102,41 @@ func defineRoutes(
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
-		v1.GET("/students/:id/report", reportHandler.Handle)
+		// reports are only handed out to authenticated callers, when enabled
+		v1.GET("/students/:id/report", authenticate(apikeys.ScopeReportsRead), reportHandler.Handle)
+		v1.POST("/reports/batch", authenticate(apikeys.ScopeBatchWrite), batchHandler.Handle)
+		v1.POST("/reports/jobs", authenticate(apikeys.ScopeBatchWrite), jobHandler.Create)
+		v1.GET("/reports/jobs/:id", authenticate(apikeys.ScopeBatchWrite), jobHandler.Get)
+		v1.GET("/reports/jobs/:id/download", authenticate(apikeys.ScopeBatchWrite), jobHandler.Download)
+		v1.POST("/reports/verify", verificationHandler.VerifySignature)
+		v1.GET("/reports/verify/:reportId", verificationHandler.VerifyReport)
+
+		// signed deliveries from the backend, enabled by WEBHOOK_SECRET
+		if eventHandler != nil {
+			v1.POST("/events/student-updated", eventHandler.StudentUpdated)
+		}
+	}
+}
+
+// defineAdminRoutes registers the administration endpoints, all behind auth
+func defineAdminRoutes(router *gin.Engine, auth gin.HandlerFunc, cacheAdminHandler *handler.CacheAdminHandler) {
+	admin := router.Group("/api/v1/admin", auth)
+	{
+		admin.GET("/cache", cacheAdminHandler.List)
+		admin.DELETE("/cache", cacheAdminHandler.PurgeAll)
+		admin.DELETE("/cache/students/:id", cacheAdminHandler.PurgeStudent)
+		admin.POST("/cache/warm", cacheAdminHandler.Warm)
+	}
+}
+
+// defineKeyRoutes registers the API key administration endpoints, behind the
+// admin key only
+func defineKeyRoutes(router *gin.Engine, auth gin.HandlerFunc, apiKeyHandler *handler.APIKeyHandler) {
+	keys := router.Group("/api/v1/admin/keys", auth)
+	{
+		keys.GET("", apiKeyHandler.List)
+		keys.POST("", apiKeyHandler.Create)
+		keys.POST("/:id/rotate", apiKeyHandler.Rotate)
+		keys.DELETE("/:id", apiKeyHandler.Revoke)
 	}
 }
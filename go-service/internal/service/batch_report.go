@@ -0,0 +1,509 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/auth"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/external"
+)
+
+const (
+	BatchOutputZIP = "zip"
+	BatchOutputPDF = "pdf"
+
+	batchStatusOK     = "ok"
+	batchStatusFailed = "failed"
+)
+
+func init() {
+	// pdfcpu writes a configuration directory under the user's home by default
+	api.DisableConfigDir()
+}
+
+// BatchReport is the archive (or merged document) produced by a batch export
+type BatchReport struct {
+	Data        []byte
+	FileName    string
+	ContentType string
+	Manifest    dto.BatchManifest
+}
+
+type BatchReportService struct {
+	reportService ReportService
+	backendClient external.BackendService
+	tocGenerator  TOCGenerator
+	templates     TemplateProvider
+	signer        PDFSigner
+	concurrency   int
+	maxStudents   int
+	logger        *zap.Logger
+}
+
+func NewBatchReportService(
+	reportService ReportService,
+	backendClient external.BackendService,
+	tocGenerator TOCGenerator,
+	templates TemplateProvider,
+	signer PDFSigner,
+	concurrency int,
+	maxStudents int,
+	logger *zap.Logger,
+) *BatchReportService {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &BatchReportService{
+		reportService: reportService,
+		backendClient: backendClient,
+		tocGenerator:  tocGenerator,
+		templates:     templates,
+		signer:        signer,
+		concurrency:   concurrency,
+		maxStudents:   maxStudents,
+		logger:        logger,
+	}
+}
+
+// batchItem holds the outcome of a single student within a batch
+type batchItem struct {
+	studentID string
+	pdfData   []byte
+	fileName  string
+	stale     bool
+	err       error
+}
+
+// GenerateBatch builds the export described by req. progress may be nil.
+func (s *BatchReportService) GenerateBatch(ctx context.Context, req dto.BatchReportRequest, progress ProgressFunc) (*BatchReport, error) {
+	output := req.Output
+	if output == "" {
+		output = BatchOutputZIP
+	}
+	if output != BatchOutputZIP && output != BatchOutputPDF {
+		return nil, &errors.ValidationError{Message: fmt.Sprintf("unsupported output %q (expected zip or pdf)", req.Output)}
+	}
+
+	// reject an unknown template or profile once instead of failing every
+	// student
+	if _, err := resolveTemplate(s.templates, req.Template); err != nil {
+		return nil, err
+	}
+	if _, err := resolveProfile(ctx, req.Profile); err != nil {
+		return nil, err
+	}
+
+	studentIDs, err := s.resolveStudentIDs(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// a merged document is signed once, after the reports are combined
+	opts := ReportOptions{Template: req.Template, Format: FormatPDF, Profile: req.Profile, Unsigned: output == BatchOutputPDF}
+	items := s.generateAll(ctx, studentIDs, opts, progress)
+
+	var report *BatchReport
+	switch output {
+	case BatchOutputPDF:
+		report, err = s.buildMergedPDF(items)
+	default:
+		report, err = s.buildZIP(items)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Batch report generated",
+		zap.String("output", output),
+		zap.Int("total", report.Manifest.Total),
+		zap.Int("succeeded", report.Manifest.Succeeded),
+		zap.Int("failed", report.Manifest.Failed))
+
+	return report, nil
+}
+
+// WarmCache renders the reports of the selected students, so that later
+// requests for them are served from the cache. Students whose report fails,
+// or could only be served stale, are reported as failed.
+func (s *BatchReportService) WarmCache(ctx context.Context, req dto.CacheWarmRequest) (*dto.CacheWarmResponse, error) {
+	format := req.Format
+	if format == "" {
+		format = FormatPDF
+	}
+	switch format {
+	case FormatPDF, FormatHTML, FormatCSV, FormatJSON:
+	default:
+		return nil, &errors.ValidationError{Message: fmt.Sprintf("unsupported format %q", req.Format)}
+	}
+	if _, err := resolveTemplate(s.templates, req.Template); err != nil {
+		return nil, err
+	}
+	if _, err := resolveLocale(req.Language); err != nil {
+		return nil, err
+	}
+	if _, err := resolveProfile(ctx, req.Profile); err != nil {
+		return nil, err
+	}
+
+	studentIDs, err := s.resolveStudentIDs(ctx, dto.BatchReportRequest{StudentIDs: req.StudentIDs, Class: req.Class, Section: req.Section})
+	if err != nil {
+		return nil, err
+	}
+
+	opts := ReportOptions{Template: req.Template, Format: format, Locale: req.Language, Profile: req.Profile}
+	response := &dto.CacheWarmResponse{Total: len(studentIDs)}
+	for _, item := range s.generateAll(ctx, studentIDs, opts, nil) {
+		if item.err == nil && item.stale {
+			item.err = &errors.ServiceError{Service: "backend", Err: fmt.Errorf("served stale")}
+		}
+		if item.err != nil {
+			response.Entries = append(response.Entries, s.failedEntry(item))
+			response.Failed++
+			continue
+		}
+		response.Entries = append(response.Entries, dto.BatchManifestEntry{StudentID: item.studentID, Status: batchStatusOK})
+		response.Warmed++
+	}
+
+	s.logger.Info("Report cache warmed",
+		zap.String("format", format),
+		zap.Int("total", response.Total),
+		zap.Int("warmed", response.Warmed),
+		zap.Int("failed", response.Failed))
+
+	return response, nil
+}
+
+// resolveStudentIDs merges the explicit IDs with the class/section listing,
+// keeping the request order and dropping duplicates.
+func (s *BatchReportService) resolveStudentIDs(ctx context.Context, req dto.BatchReportRequest) ([]string, error) {
+	seen := make(map[string]bool)
+	var ids []string
+
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range req.StudentIDs {
+		add(id)
+	}
+
+	if req.Class != "" || req.Section != "" {
+		if principal, authenticated := auth.FromContext(ctx); authenticated && !principal.MayListClass(req.Class) {
+			s.logger.Warn("Class listing denied",
+				zap.String("class", req.Class),
+				zap.String("section", req.Section),
+				zap.String("subject", principal.Subject),
+				zap.String("role", string(principal.Role)))
+			return nil, &errors.ForbiddenError{Resource: "Class"}
+		}
+		students, err := s.backendClient.ListStudents(ctx, dto.StudentFilter{Class: req.Class, Section: req.Section})
+		if err != nil && !errors.IsNotFound(err) {
+			s.logger.Error("Failed to list students for batch",
+				zap.String("class", req.Class),
+				zap.String("section", req.Section),
+				zap.Error(err))
+			return nil, fmt.Errorf("failed to list students: %w", err)
+		}
+		for _, student := range students {
+			add(strconv.Itoa(student.ID))
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, &errors.NotFoundError{Resource: "Students"}
+	}
+	if s.maxStudents > 0 && len(ids) > s.maxStudents {
+		return nil, &errors.ValidationError{Message: fmt.Sprintf("batch exceeds the maximum of %d students", s.maxStudents)}
+	}
+
+	return ids, nil
+}
+
+// generateAll fans out to the report service with bounded concurrency.
+// Results keep the order of studentIDs.
+func (s *BatchReportService) generateAll(ctx context.Context, studentIDs []string, opts ReportOptions, progress ProgressFunc) []batchItem {
+	items := make([]batchItem, len(studentIDs))
+	sem := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+
+	var mu sync.Mutex
+	completed := 0
+	reportProgress := func() {
+		if progress == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		completed++
+		progress(completed, len(studentIDs))
+	}
+
+	for i, studentID := range studentIDs {
+		wg.Add(1)
+		go func(i int, studentID string) {
+			defer wg.Done()
+			defer reportProgress()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				items[i] = batchItem{studentID: studentID, err: ctx.Err()}
+				return
+			}
+
+			report, err := s.reportService.GenerateStudentReport(ctx, studentID, opts)
+			if err != nil {
+				items[i] = batchItem{studentID: studentID, err: err}
+				return
+			}
+			items[i] = batchItem{studentID: studentID, pdfData: report.Data, fileName: report.FileName, stale: report.Stale}
+		}(i, studentID)
+	}
+
+	wg.Wait()
+	return items
+}
+
+func (s *BatchReportService) buildZIP(items []batchItem) (*BatchReport, error) {
+	manifest := dto.BatchManifest{Output: BatchOutputZIP, Total: len(items)}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, item := range items {
+		if item.err != nil {
+			manifest.Entries = append(manifest.Entries, s.failedEntry(item))
+			continue
+		}
+
+		w, err := zw.Create(item.fileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to archive: %w", item.fileName, err)
+		}
+		if _, err := w.Write(item.pdfData); err != nil {
+			return nil, fmt.Errorf("failed to add %s to archive: %w", item.fileName, err)
+		}
+
+		manifest.Entries = append(manifest.Entries, dto.BatchManifestEntry{
+			StudentID: item.studentID,
+			Status:    batchStatusOK,
+			FileName:  item.fileName,
+		})
+	}
+
+	countEntries(&manifest)
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	w, err := zw.Create("manifest.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to add manifest to archive: %w", err)
+	}
+	if _, err := w.Write(manifestJSON); err != nil {
+		return nil, fmt.Errorf("failed to add manifest to archive: %w", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize archive: %w", err)
+	}
+
+	return &BatchReport{
+		Data:        buf.Bytes(),
+		FileName:    s.buildFileName("zip"),
+		ContentType: "application/zip",
+		Manifest:    manifest,
+	}, nil
+}
+
+// mergeConfig makes pdfcpu write a classic cross-reference table, the
+// structure the signer appends its update to
+func mergeConfig() *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	conf.WriteObjectStream = false
+	conf.WriteXRefStream = false
+	return conf
+}
+
+// buildMergedPDF prepends a table of contents to the concatenated reports and
+// adds one bookmark per student.
+func (s *BatchReportService) buildMergedPDF(items []batchItem) (*BatchReport, error) {
+	manifest := dto.BatchManifest{Output: BatchOutputPDF, Total: len(items)}
+
+	pageCounts := make([]int, len(items))
+	for i := range items {
+		if items[i].err != nil {
+			continue
+		}
+		pages, err := api.PageCount(bytes.NewReader(items[i].pdfData), nil)
+		if err != nil {
+			items[i].err = errors.NewPDFGenerationError(err)
+			continue
+		}
+		pageCounts[i] = pages
+	}
+
+	// The page numbers depend on the length of the table of contents itself,
+	// so render it once to measure it and once more with the final numbers.
+	tocData, err := s.renderTOC(items, pageCounts, 0)
+	if err != nil {
+		return nil, err
+	}
+	tocPages, err := api.PageCount(bytes.NewReader(tocData), nil)
+	if err != nil {
+		return nil, errors.NewPDFGenerationError(err)
+	}
+	if tocData, err = s.renderTOC(items, pageCounts, tocPages); err != nil {
+		return nil, err
+	}
+
+	sources := []io.ReadSeeker{bytes.NewReader(tocData)}
+	var bookmarks []pdfcpu.Bookmark
+	page := tocPages + 1
+
+	for i, item := range items {
+		if item.err != nil {
+			manifest.Entries = append(manifest.Entries, s.failedEntry(item))
+			continue
+		}
+
+		sources = append(sources, bytes.NewReader(item.pdfData))
+		bookmarks = append(bookmarks, pdfcpu.Bookmark{Title: tocTitle(item.studentID), PageFrom: page})
+		manifest.Entries = append(manifest.Entries, dto.BatchManifestEntry{
+			StudentID: item.studentID,
+			Status:    batchStatusOK,
+			FileName:  item.fileName,
+			Page:      page,
+		})
+		page += pageCounts[i]
+	}
+
+	countEntries(&manifest)
+
+	var merged bytes.Buffer
+	if err := api.MergeRaw(sources, &merged, false, mergeConfig()); err != nil {
+		s.logger.Error("Failed to merge batch reports", zap.Error(err))
+		return nil, errors.NewPDFGenerationError(err)
+	}
+
+	data := merged.Bytes()
+	if len(bookmarks) > 0 {
+		var withBookmarks bytes.Buffer
+		if err := api.AddBookmarks(bytes.NewReader(data), &withBookmarks, bookmarks, true, mergeConfig()); err != nil {
+			s.logger.Error("Failed to add batch bookmarks", zap.Error(err))
+			return nil, errors.NewPDFGenerationError(err)
+		}
+		data = withBookmarks.Bytes()
+	}
+
+	if s.signer != nil {
+		signed, err := s.signer.Sign(data)
+		if err != nil {
+			s.logger.Error("Failed to sign batch report", zap.Error(err))
+			return nil, errors.NewPDFGenerationError(err)
+		}
+		data = signed
+	}
+
+	return &BatchReport{
+		Data:        data,
+		FileName:    s.buildFileName("pdf"),
+		ContentType: "application/pdf",
+		Manifest:    manifest,
+	}, nil
+}
+
+func (s *BatchReportService) renderTOC(items []batchItem, pageCounts []int, tocPages int) ([]byte, error) {
+	entries := make([]TOCEntry, 0, len(items))
+	page := tocPages + 1
+
+	for i, item := range items {
+		if item.err != nil {
+			entries = append(entries, TOCEntry{Title: tocTitle(item.studentID), Error: DescribeReportError(item.err)})
+			continue
+		}
+		entries = append(entries, TOCEntry{Title: tocTitle(item.studentID), Page: page})
+		page += pageCounts[i]
+	}
+
+	tocData, err := s.tocGenerator.GenerateTableOfContents(entries)
+	if err != nil {
+		return nil, errors.NewPDFGenerationError(err)
+	}
+	return tocData, nil
+}
+
+func (s *BatchReportService) failedEntry(item batchItem) dto.BatchManifestEntry {
+	s.logger.Warn("Student report failed within batch",
+		zap.String("student_id", item.studentID),
+		zap.Error(item.err))
+
+	return dto.BatchManifestEntry{
+		StudentID: item.studentID,
+		Status:    batchStatusFailed,
+		Error:     DescribeReportError(item.err),
+	}
+}
+
+func (s *BatchReportService) buildFileName(extension string) string {
+	return fmt.Sprintf("student_reports_%s.%s", time.Now().Format("20060102_150405"), extension)
+}
+
+func countEntries(manifest *dto.BatchManifest) {
+	for _, entry := range manifest.Entries {
+		if entry.Status == batchStatusOK {
+			manifest.Succeeded++
+		} else {
+			manifest.Failed++
+		}
+	}
+}
+
+func tocTitle(studentID string) string {
+	return fmt.Sprintf("Student %s", studentID)
+}
+
+// DescribeReportError turns a report failure into a message that is safe to
+// hand back to API callers.
+func DescribeReportError(err error) string {
+	var notFoundErr *errors.NotFoundError
+	var validationErr *errors.ValidationError
+
+	switch {
+	case stderrors.As(err, &notFoundErr):
+		return notFoundErr.Error()
+	case stderrors.As(err, &validationErr):
+		return validationErr.Error()
+	case errors.IsForbidden(err):
+		return "Access denied"
+	case errors.IsServiceError(err):
+		return "Backend service unavailable"
+	case errors.IsPDFGenerationError(err):
+		return "Failed to generate PDF"
+	case stderrors.Is(err, context.Canceled) || stderrors.Is(err, context.DeadlineExceeded):
+		return "Request cancelled"
+	default:
+		return "Internal server error"
+	}
+}
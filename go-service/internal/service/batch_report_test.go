@@ -0,0 +1,390 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/auth"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	serviceErrors "github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/signing"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+	"github.com/wbentaleb/student-report-service/internal/typeset"
+)
+
+type MockReportService struct {
+	mock.Mock
+}
+
+func (m *MockReportService) GenerateStudentReport(ctx context.Context, studentID string, opts ReportOptions) (*Report, error) {
+	args := m.Called(ctx, studentID, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Report), args.Error(1)
+}
+
+func pdfReport(data []byte, fileName string) *Report {
+	return &Report{Data: data, FileName: fileName, ContentType: "application/pdf"}
+}
+
+func renderTestPDF(t *testing.T, id int) []byte {
+	t.Helper()
+	pdfData, err := NewPDFService(typeset.DefaultFontSet(), zap.NewNop()).GenerateStudentReport(&dto.Student{ID: id, Name: "Test Student"}, templates.Default(), i18n.Default(), testIssue())
+	require.NoError(t, err)
+	return pdfData
+}
+
+func readZIP(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[f.Name] = content
+	}
+	return files
+}
+
+func TestGenerateBatch_ZIP_WithFailures(t *testing.T) {
+	// Setup
+	mockReports := new(MockReportService)
+	mockBackend := new(MockBackendService)
+	service := NewBatchReportService(mockReports, mockBackend, NewPDFService(typeset.DefaultFontSet(), zap.NewNop()), nil, nil, 2, 10, zap.NewNop())
+
+	mockReports.On("GenerateStudentReport", mock.Anything, "1", mock.Anything).Return(pdfReport([]byte("pdf 1"), "student_1_report.pdf"), nil)
+	mockReports.On("GenerateStudentReport", mock.Anything, "2", mock.Anything).Return(nil, &serviceErrors.NotFoundError{Resource: "Student"})
+	mockReports.On("GenerateStudentReport", mock.Anything, "3", mock.Anything).Return(pdfReport([]byte("pdf 3"), "student_3_report.pdf"), nil)
+
+	// Execute
+	report, err := service.GenerateBatch(context.Background(), dto.BatchReportRequest{StudentIDs: []string{"1", "2", "3", "1"}}, nil)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "application/zip", report.ContentType)
+	assert.Equal(t, 3, report.Manifest.Total)
+	assert.Equal(t, 2, report.Manifest.Succeeded)
+	assert.Equal(t, 1, report.Manifest.Failed)
+
+	files := readZIP(t, report.Data)
+	assert.Equal(t, []byte("pdf 1"), files["student_1_report.pdf"])
+	assert.Equal(t, []byte("pdf 3"), files["student_3_report.pdf"])
+	require.Contains(t, files, "manifest.json")
+
+	var manifest dto.BatchManifest
+	require.NoError(t, json.Unmarshal(files["manifest.json"], &manifest))
+	require.Len(t, manifest.Entries, 3)
+	assert.Equal(t, "2", manifest.Entries[1].StudentID)
+	assert.Equal(t, "failed", manifest.Entries[1].Status)
+	assert.Equal(t, "Student not found", manifest.Entries[1].Error)
+
+	mockReports.AssertNumberOfCalls(t, "GenerateStudentReport", 3)
+}
+
+func TestGenerateBatch_ClassFilter(t *testing.T) {
+	// Setup
+	mockReports := new(MockReportService)
+	mockBackend := new(MockBackendService)
+	service := NewBatchReportService(mockReports, mockBackend, NewPDFService(typeset.DefaultFontSet(), zap.NewNop()), nil, nil, 4, 10, zap.NewNop())
+
+	filter := dto.StudentFilter{Class: "10", Section: "A"}
+	mockBackend.On("ListStudents", mock.Anything, filter).Return([]dto.StudentSummary{{ID: 7}, {ID: 8}}, nil)
+	mockReports.On("GenerateStudentReport", mock.Anything, "7", mock.Anything).Return(pdfReport([]byte("pdf 7"), "student_7_report.pdf"), nil)
+	mockReports.On("GenerateStudentReport", mock.Anything, "8", mock.Anything).Return(pdfReport([]byte("pdf 8"), "student_8_report.pdf"), nil)
+
+	// Execute
+	report, err := service.GenerateBatch(context.Background(), dto.BatchReportRequest{Class: "10", Section: "A"}, nil)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Manifest.Succeeded)
+	mockBackend.AssertExpectations(t)
+	mockReports.AssertExpectations(t)
+}
+
+func TestGenerateBatch_ClassFilterOutsidePrincipalScope(t *testing.T) {
+	mockReports := new(MockReportService)
+	mockBackend := new(MockBackendService)
+	service := NewBatchReportService(mockReports, mockBackend, NewPDFService(typeset.DefaultFontSet(), zap.NewNop()), nil, nil, 4, 10, zap.NewNop())
+	teacher := &auth.Principal{Subject: "t1", Role: auth.RoleTeacher, Classes: []string{"10"}}
+	parent := &auth.Principal{Subject: "p1", Role: auth.RoleParent, StudentIDs: []string{"7"}}
+
+	for name, tc := range map[string]struct {
+		principal *auth.Principal
+		req       dto.BatchReportRequest
+	}{
+		"teacher, other class":      {teacher, dto.BatchReportRequest{Class: "11"}},
+		"teacher, section only":     {teacher, dto.BatchReportRequest{Section: "A"}},
+		"parent, own child's class": {parent, dto.BatchReportRequest{StudentIDs: []string{"7"}, Class: "10"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			report, err := service.GenerateBatch(auth.NewContext(context.Background(), tc.principal), tc.req, nil)
+
+			assert.True(t, serviceErrors.IsForbidden(err))
+			assert.Nil(t, report)
+		})
+	}
+
+	// the roster is never listed
+	mockBackend.AssertNotCalled(t, "ListStudents")
+	mockReports.AssertNotCalled(t, "GenerateStudentReport")
+
+	_, err := service.WarmCache(auth.NewContext(context.Background(), teacher), dto.CacheWarmRequest{Class: "11"})
+	assert.True(t, serviceErrors.IsForbidden(err))
+}
+
+func TestGenerateBatch_ClassFilterOfTeacher(t *testing.T) {
+	mockReports := new(MockReportService)
+	mockBackend := new(MockBackendService)
+	service := NewBatchReportService(mockReports, mockBackend, NewPDFService(typeset.DefaultFontSet(), zap.NewNop()), nil, nil, 4, 10, zap.NewNop())
+	teacher := auth.NewContext(context.Background(), &auth.Principal{Subject: "t1", Role: auth.RoleTeacher, Classes: []string{"10"}})
+
+	mockBackend.On("ListStudents", mock.Anything, dto.StudentFilter{Class: "10", Section: "A"}).Return([]dto.StudentSummary{{ID: 7}}, nil)
+	mockReports.On("GenerateStudentReport", mock.Anything, "7", mock.Anything).Return(pdfReport([]byte("pdf 7"), "student_7_report.pdf"), nil)
+
+	report, err := service.GenerateBatch(teacher, dto.BatchReportRequest{Class: "10", Section: "A"}, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Manifest.Succeeded)
+}
+
+func TestGenerateBatch_EmptyClass(t *testing.T) {
+	mockReports := new(MockReportService)
+	mockBackend := new(MockBackendService)
+	service := NewBatchReportService(mockReports, mockBackend, NewPDFService(typeset.DefaultFontSet(), zap.NewNop()), nil, nil, 4, 10, zap.NewNop())
+
+	mockBackend.On("ListStudents", mock.Anything, dto.StudentFilter{Class: "99"}).Return(nil, &serviceErrors.NotFoundError{Resource: "Students"})
+
+	report, err := service.GenerateBatch(context.Background(), dto.BatchReportRequest{Class: "99"}, nil)
+
+	require.Error(t, err)
+	assert.True(t, serviceErrors.IsNotFound(err))
+	assert.Nil(t, report)
+	mockReports.AssertNotCalled(t, "GenerateStudentReport")
+}
+
+func TestGenerateBatch_TooManyStudents(t *testing.T) {
+	mockReports := new(MockReportService)
+	service := NewBatchReportService(mockReports, new(MockBackendService), NewPDFService(typeset.DefaultFontSet(), zap.NewNop()), nil, nil, 4, 2, zap.NewNop())
+
+	_, err := service.GenerateBatch(context.Background(), dto.BatchReportRequest{StudentIDs: []string{"1", "2", "3"}}, nil)
+
+	require.Error(t, err)
+	assert.True(t, serviceErrors.IsValidationError(err))
+	mockReports.AssertNotCalled(t, "GenerateStudentReport")
+}
+
+func TestGenerateBatch_UnsupportedOutput(t *testing.T) {
+	service := NewBatchReportService(new(MockReportService), new(MockBackendService), NewPDFService(typeset.DefaultFontSet(), zap.NewNop()), nil, nil, 4, 10, zap.NewNop())
+
+	_, err := service.GenerateBatch(context.Background(), dto.BatchReportRequest{StudentIDs: []string{"1"}, Output: "tar"}, nil)
+
+	require.Error(t, err)
+	assert.True(t, serviceErrors.IsValidationError(err))
+}
+
+func TestGenerateBatch_Profile(t *testing.T) {
+	mockReports := new(MockReportService)
+	service := NewBatchReportService(mockReports, new(MockBackendService), NewPDFService(typeset.DefaultFontSet(), zap.NewNop()), nil, nil, 4, 10, zap.NewNop())
+	parent := auth.NewContext(context.Background(), &auth.Principal{Subject: "p1", Role: auth.RoleParent, StudentIDs: []string{"1"}})
+
+	// a profile the caller may not request fails the whole batch
+	_, err := service.GenerateBatch(parent, dto.BatchReportRequest{StudentIDs: []string{"1"}, Profile: "full"}, nil)
+	assert.True(t, serviceErrors.IsForbidden(err))
+	mockReports.AssertNotCalled(t, "GenerateStudentReport")
+
+	mockReports.On("GenerateStudentReport", mock.Anything, "1", mock.MatchedBy(func(opts ReportOptions) bool {
+		return opts.Profile == "transcript"
+	})).Return(pdfReport([]byte("pdf 1"), "student_1_report.pdf"), nil)
+
+	report, err := service.GenerateBatch(parent, dto.BatchReportRequest{StudentIDs: []string{"1"}, Profile: "transcript"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Manifest.Succeeded)
+	mockReports.AssertExpectations(t)
+}
+
+func TestGenerateBatch_MergedPDF(t *testing.T) {
+	// Setup
+	mockReports := new(MockReportService)
+	service := NewBatchReportService(mockReports, new(MockBackendService), NewPDFService(typeset.DefaultFontSet(), zap.NewNop()), nil, nil, 2, 10, zap.NewNop())
+
+	firstPDF := renderTestPDF(t, 1)
+	reportPages, err := api.PageCount(bytes.NewReader(firstPDF), nil)
+	require.NoError(t, err)
+
+	mockReports.On("GenerateStudentReport", mock.Anything, "1", mock.Anything).Return(pdfReport(firstPDF, "student_1_report.pdf"), nil)
+	mockReports.On("GenerateStudentReport", mock.Anything, "2", mock.Anything).Return(nil, &serviceErrors.ServiceError{Service: "backend"})
+	mockReports.On("GenerateStudentReport", mock.Anything, "3", mock.Anything).Return(pdfReport(renderTestPDF(t, 3), "student_3_report.pdf"), nil)
+
+	// Execute
+	report, err := service.GenerateBatch(context.Background(), dto.BatchReportRequest{StudentIDs: []string{"1", "2", "3"}, Output: "pdf"}, nil)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "application/pdf", report.ContentType)
+	assert.True(t, bytes.HasPrefix(report.Data, []byte("%PDF-")))
+
+	// One table of contents page followed by the successful reports
+	pages, err := api.PageCount(bytes.NewReader(report.Data), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1+2*reportPages, pages)
+
+	require.Len(t, report.Manifest.Entries, 3)
+	assert.Equal(t, 2, report.Manifest.Entries[0].Page)
+	assert.Equal(t, "Backend service unavailable", report.Manifest.Entries[1].Error)
+	assert.Equal(t, 2+reportPages, report.Manifest.Entries[2].Page)
+
+	bookmarks, err := api.Bookmarks(bytes.NewReader(report.Data), nil)
+	require.NoError(t, err)
+	require.Len(t, bookmarks, 2)
+	assert.Equal(t, "Student 1", bookmarks[0].Title)
+	assert.Equal(t, 2+reportPages, bookmarks[1].PageFrom)
+}
+
+func TestGenerateBatch_MergedPDF_Signed(t *testing.T) {
+	// Setup
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Registrar"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	signer := signing.NewSigner(cert, key, nil, "", "")
+
+	mockReports := new(MockReportService)
+	service := NewBatchReportService(mockReports, new(MockBackendService), NewPDFService(typeset.DefaultFontSet(), zap.NewNop()), nil, signer, 2, 10, zap.NewNop())
+
+	// the reports are requested unsigned, only the merged document is signed
+	unsigned := ReportOptions{Format: FormatPDF, Unsigned: true}
+	mockReports.On("GenerateStudentReport", mock.Anything, "1", unsigned).Return(pdfReport(renderTestPDF(t, 1), "student_1_report.pdf"), nil)
+	mockReports.On("GenerateStudentReport", mock.Anything, "2", unsigned).Return(pdfReport(renderTestPDF(t, 2), "student_2_report.pdf"), nil)
+
+	// Execute
+	report, err := service.GenerateBatch(context.Background(), dto.BatchReportRequest{StudentIDs: []string{"1", "2"}, Output: "pdf"}, nil)
+
+	// Assert
+	require.NoError(t, err)
+	mockReports.AssertExpectations(t)
+
+	result, err := signing.NewVerifier(cert).Verify(report.Data)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.True(t, result.Trusted)
+	assert.False(t, result.Modified)
+
+	bookmarks, err := api.Bookmarks(bytes.NewReader(report.Data), nil)
+	require.NoError(t, err)
+	assert.Len(t, bookmarks, 2)
+}
+
+func TestGenerateBatch_ContextCancelled(t *testing.T) {
+	mockReports := new(MockReportService)
+	service := NewBatchReportService(mockReports, new(MockBackendService), NewPDFService(typeset.DefaultFontSet(), zap.NewNop()), nil, nil, 1, 10, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mockReports.On("GenerateStudentReport", mock.Anything, mock.Anything, mock.Anything).Return(nil, context.Canceled).Maybe()
+
+	report, err := service.GenerateBatch(ctx, dto.BatchReportRequest{StudentIDs: []string{"1", "2"}}, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Manifest.Failed)
+	assert.Equal(t, "Request cancelled", report.Manifest.Entries[0].Error)
+}
+
+func TestGenerateBatch_ReportsProgress(t *testing.T) {
+	mockReports := new(MockReportService)
+	service := NewBatchReportService(mockReports, new(MockBackendService), NewPDFService(typeset.DefaultFontSet(), zap.NewNop()), nil, nil, 2, 10, zap.NewNop())
+
+	mockReports.On("GenerateStudentReport", mock.Anything, mock.Anything, mock.Anything).Return(pdfReport([]byte("pdf"), "report.pdf"), nil)
+
+	var calls [][2]int
+	_, err := service.GenerateBatch(context.Background(), dto.BatchReportRequest{StudentIDs: []string{"1", "2", "3"}}, func(completed, total int) {
+		calls = append(calls, [2]int{completed, total})
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int{{1, 3}, {2, 3}, {3, 3}}, calls)
+}
+
+func TestWarmCache_ClassAndExplicitIDs(t *testing.T) {
+	mockReports := new(MockReportService)
+	mockBackend := new(MockBackendService)
+	service := NewBatchReportService(mockReports, mockBackend, nil, nil, nil, 2, 10, zap.NewNop())
+
+	mockBackend.On("ListStudents", mock.Anything, dto.StudentFilter{Class: "Grade 5"}).
+		Return([]dto.StudentSummary{{ID: 2}, {ID: 3}}, nil)
+	opts := ReportOptions{Format: FormatHTML, Locale: "fr"}
+	mockReports.On("GenerateStudentReport", mock.Anything, "1", opts).Return(pdfReport([]byte("1"), "student_1_report.html"), nil)
+	mockReports.On("GenerateStudentReport", mock.Anything, "2", opts).Return(nil, &serviceErrors.NotFoundError{Resource: "Student"})
+	stale := pdfReport([]byte("3"), "student_3_report.html")
+	stale.Stale = true
+	mockReports.On("GenerateStudentReport", mock.Anything, "3", opts).Return(stale, nil)
+
+	response, err := service.WarmCache(context.Background(), dto.CacheWarmRequest{
+		StudentIDs: []string{"1", "2"},
+		Class:      "Grade 5",
+		Format:     FormatHTML,
+		Language:   "fr",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, response.Total)
+	assert.Equal(t, 1, response.Warmed)
+	assert.Equal(t, 2, response.Failed)
+	assert.Equal(t, []dto.BatchManifestEntry{
+		{StudentID: "1", Status: "ok"},
+		{StudentID: "2", Status: "failed", Error: "Student not found"},
+		{StudentID: "3", Status: "failed", Error: "Backend service unavailable"},
+	}, response.Entries)
+	mockReports.AssertExpectations(t)
+}
+
+func TestWarmCache_InvalidOptions(t *testing.T) {
+	mockReports := new(MockReportService)
+	service := NewBatchReportService(mockReports, new(MockBackendService), nil, nil, nil, 2, 10, zap.NewNop())
+
+	for name, req := range map[string]dto.CacheWarmRequest{
+		"format":   {StudentIDs: []string{"1"}, Format: "docx"},
+		"template": {StudentIDs: []string{"1"}, Template: "missing"},
+		"language": {StudentIDs: []string{"1"}, Language: "xx"},
+		"profile":  {StudentIDs: []string{"1"}, Profile: "secret"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.WarmCache(context.Background(), req)
+
+			assert.True(t, serviceErrors.IsValidationError(err))
+		})
+	}
+	mockReports.AssertNotCalled(t, "GenerateStudentReport")
+}
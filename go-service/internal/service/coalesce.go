@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// flightGroup tracks the calls in flight per key, with the callers waiting
+// for each of them
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done    chan struct{}
+	value   any
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// coalesce runs fn once for all concurrent callers with the same key; every
+// caller receives its result or error. Each caller stops waiting when its own
+// context is done, and fn's context is cancelled once no caller waits for it
+// any more. fn keeps the values of the first caller's context, such as its
+// trace. Callers that did not run fn are counted in deduplicated.
+func coalesce[T any](ctx context.Context, group *flightGroup, key string, deduplicated *atomic.Uint64, fn func(context.Context) (T, error)) (T, error) {
+	group.mu.Lock()
+	if group.flights == nil {
+		group.flights = make(map[string]*flight)
+	}
+	f, joined := group.flights[key]
+	if !joined {
+		flightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		group.flights[key] = f
+		go group.run(key, f, func() (any, error) { return fn(flightCtx) })
+	}
+	f.waiters++
+	group.mu.Unlock()
+
+	select {
+	case <-f.done:
+		if joined {
+			deduplicated.Add(1)
+		}
+		value, _ := f.value.(T)
+		return value, f.err
+	case <-ctx.Done():
+		group.leave(key, f)
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func (g *flightGroup) run(key string, f *flight, fn func() (any, error)) {
+	defer f.cancel()
+	value, err := fn()
+
+	g.mu.Lock()
+	f.value, f.err = value, err
+	g.forget(key, f)
+	g.mu.Unlock()
+	close(f.done)
+}
+
+// leave removes a waiter from f, cancelling it when it was the last one so
+// that later callers start a new flight
+func (g *flightGroup) leave(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f.waiters--
+	if f.waiters == 0 {
+		f.cancel()
+		g.forget(key, f)
+	}
+}
+
+// forget stops new callers from joining f
+func (g *flightGroup) forget(key string, f *flight) {
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
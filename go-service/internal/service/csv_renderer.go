@@ -0,0 +1,61 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+)
+
+type CSVRenderer struct {
+	fieldFormatter
+}
+
+func NewCSVRenderer(logger *zap.Logger) *CSVRenderer {
+	return &CSVRenderer{
+		fieldFormatter: fieldFormatter{logger: logger},
+	}
+}
+
+func (r *CSVRenderer) Format() string {
+	return FormatCSV
+}
+
+func (r *CSVRenderer) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+// GenerateStudentReport writes a header row with the template labels followed
+// by a single row of values, so exports of several students can be appended
+// into one spreadsheet. The report ID is left out for the same reason.
+func (r *CSVRenderer) GenerateStudentReport(student *dto.Student, tmpl *templates.Template, locale *i18n.Locale, _ ReportIssue) ([]byte, error) {
+	var header, row []string
+	for _, section := range tmpl.Sections {
+		for _, field := range section.Fields {
+			header = append(header, locale.Translate(field.Label))
+			row = append(row, escapeFormula(r.formatField(student, field, locale)))
+		}
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll([][]string{header, row}); err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// escapeFormula keeps spreadsheet applications from evaluating student
+// supplied values such as "=HYPERLINK(...)" as formulas.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
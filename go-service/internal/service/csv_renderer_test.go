@@ -0,0 +1,59 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+)
+
+func TestCSVRenderer_GenerateStudentReport(t *testing.T) {
+	renderer := NewCSVRenderer(zap.NewNop())
+
+	data, err := renderer.GenerateStudentReport(createTestStudent(), templates.Default(), i18n.Default(), testIssue())
+	require.NoError(t, err)
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "Student ID", records[0][0])
+	assert.Equal(t, "Full Name", records[0][1])
+	assert.Equal(t, "12345", records[1][0])
+	assert.Equal(t, "John Doe", records[1][1])
+	assert.Len(t, records[1], len(records[0]))
+}
+
+func TestCSVRenderer_EscapesFormulas(t *testing.T) {
+	renderer := NewCSVRenderer(zap.NewNop())
+	student := &dto.Student{ID: 1, Name: "=HYPERLINK(\"http://evil\")", Email: "@sum(A1)"}
+
+	data, err := renderer.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
+	require.NoError(t, err)
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "'=HYPERLINK(\"http://evil\")", records[1][1])
+	assert.Equal(t, "'@sum(A1)", records[1][2])
+}
+
+func TestCSVRenderer_Localised(t *testing.T) {
+	renderer := NewCSVRenderer(zap.NewNop())
+	french, _ := i18n.Lookup("fr")
+
+	data, err := renderer.GenerateStudentReport(createTestStudent(), templates.Default(), french, testIssue())
+	require.NoError(t, err)
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "Nom complet", records[0][1])
+	assert.Equal(t, "Date de naissance", records[0][3])
+	assert.Equal(t, "1 janvier 2000", records[1][3])
+	assert.Equal(t, "Actif", records[1][6])
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+)
+
+// fieldFormatter turns student values into display text. It is shared by the
+// renderers so that every output format words the values the same way.
+type fieldFormatter struct {
+	logger *zap.Logger
+}
+
+// formatField renders the student property bound by field in the language
+// of locale. Templates are validated when loaded, so the value always has the
+// type its format expects.
+func (f fieldFormatter) formatField(student *dto.Student, field templates.Field, locale *i18n.Locale) string {
+	value, _ := templates.FieldValue(student, field.Field)
+
+	switch field.Format {
+	case templates.FormatDate:
+		return f.formatDate(value.(string), locale)
+	case templates.FormatNumber:
+		return f.formatIntValue(value.(int), locale)
+	case templates.FormatID:
+		return fmt.Sprintf("%d", value.(int))
+	case templates.FormatStatus:
+		return f.formatBool(value.(bool), locale)
+	default:
+		return f.formatValue(value.(string), locale)
+	}
+}
+
+func (f fieldFormatter) formatDate(isoDate string, locale *i18n.Locale) string {
+	if isoDate == "" {
+		return locale.Translate("N/A")
+	}
+	t, err := time.Parse(time.RFC3339, isoDate)
+	if err != nil {
+		f.logger.Warn("Failed to parse date", zap.String("date", isoDate), zap.Error(err))
+		return isoDate
+	}
+	return locale.FormatDate(t)
+}
+
+func (f fieldFormatter) formatValue(value string, locale *i18n.Locale) string {
+	if value == "" {
+		return locale.Translate("N/A")
+	}
+	return value
+}
+
+func (f fieldFormatter) formatIntValue(value int, locale *i18n.Locale) string {
+	if value == 0 {
+		return locale.Translate("N/A")
+	}
+	return fmt.Sprintf("%d", value)
+}
+
+func (f fieldFormatter) formatBool(value bool, locale *i18n.Locale) string {
+	if value {
+		return locale.Translate("Active")
+	}
+	return locale.Translate("Inactive")
+}
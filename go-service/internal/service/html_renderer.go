@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+)
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}" dir="{{.Dir}}">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: {{.Style.FontFamily}}, sans-serif; font-size: {{.Style.BodySize}}pt; color: {{.Style.TextColor}}; max-width: 800px; margin: 2em auto; }
+h1 { text-align: center; font-size: {{.Style.TitleSize}}pt; color: {{.Style.TitleColor}}; margin-bottom: 0.2em; }
+.generated-on { text-align: center; font-size: {{.Style.SubtitleSize}}pt; color: {{.Style.SubtitleColor}}; }
+.logo { float: left; }
+table { width: 100%; border-collapse: collapse; margin-top: 1.5em; }
+th.section { text-align: start; font-size: {{.Style.SectionSize}}pt; background: {{.Style.SectionFill}}; color: {{.Style.SectionText}}; padding: 4px 6px; }
+td { border: 1px solid #BDC3C7; padding: 4px 6px; }
+td.label { font-weight: bold; width: 32%; background: {{.Style.LabelFill}}; }
+td.value { background: {{.Style.ValueFill}}; }
+footer { text-align: center; font-style: italic; font-size: {{.Style.FooterSize}}pt; color: {{.Style.FooterColor}}; margin-top: 2em; }
+</style>
+</head>
+<body>
+{{- if .Logo}}
+<img class="logo" src="{{.Logo}}" width="{{.LogoWidth}}" alt="">
+{{- end}}
+<h1>{{.Title}}</h1>
+{{- if .GeneratedOn}}
+<p class="generated-on">{{.GeneratedOn}}</p>
+{{- end}}
+{{- if .Profile}}
+<p class="generated-on">{{.Profile}}</p>
+{{- end}}
+{{- range .Sections}}
+<table>
+<tr><th class="section" colspan="2">{{.Title}}</th></tr>
+{{- range .Rows}}
+<tr><td class="label">{{.Label}}</td><td class="value" dir="auto">{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+<footer>
+{{- range .Footer}}
+<p>{{.}}</p>
+{{- end}}
+{{- if .VerificationURL}}
+<p><a href="{{.VerificationURL}}">{{.VerifyLabel}}</a></p>
+{{- end}}
+</footer>
+</body>
+</html>
+`))
+
+type htmlPage struct {
+	Lang        string
+	Dir         string
+	Title       string
+	GeneratedOn string
+	Profile     string
+	Style       htmlStyle
+	Logo        template.URL
+	LogoWidth   string
+	Sections    []htmlSection
+	Footer      []string
+
+	VerificationURL string
+	VerifyLabel     string
+}
+
+// htmlStyle holds the template style as CSS values. Colors are validated as
+// #RRGGBB when the template is loaded, so they are safe to emit unescaped.
+type htmlStyle struct {
+	FontFamily                                                     string
+	TitleSize, SubtitleSize, SectionSize, BodySize, FooterSize     float64
+	TitleColor, SubtitleColor, SectionFill, SectionText, TextColor template.CSS
+	LabelFill, ValueFill, FooterColor                              template.CSS
+}
+
+type htmlSection struct {
+	Title string
+	Rows  []htmlRow
+}
+
+type htmlRow struct {
+	Label string
+	Value string
+}
+
+type HTMLRenderer struct {
+	fieldFormatter
+}
+
+func NewHTMLRenderer(logger *zap.Logger) *HTMLRenderer {
+	return &HTMLRenderer{
+		fieldFormatter: fieldFormatter{logger: logger},
+	}
+}
+
+func (r *HTMLRenderer) Format() string {
+	return FormatHTML
+}
+
+func (r *HTMLRenderer) ContentType() string {
+	return "text/html; charset=utf-8"
+}
+
+// GenerateStudentReport renders a standalone HTML page suitable for inline
+// previews. It follows the same template as the PDF, with a link in place of
+// the verification QR code.
+func (r *HTMLRenderer) GenerateStudentReport(student *dto.Student, tmpl *templates.Template, locale *i18n.Locale, issue ReportIssue) ([]byte, error) {
+	style := tmpl.Style
+	page := htmlPage{
+		Lang:  locale.Tag,
+		Dir:   locale.Direction,
+		Title: locale.Translate(tmpl.Title),
+		Style: htmlStyle{
+			FontFamily:    style.FontFamily,
+			TitleSize:     style.TitleSize,
+			SubtitleSize:  style.SubtitleSize,
+			SectionSize:   style.SectionSize,
+			BodySize:      style.BodySize,
+			FooterSize:    style.FooterSize,
+			TitleColor:    template.CSS(style.TitleColor),
+			SubtitleColor: template.CSS(style.SubtitleColor),
+			SectionFill:   template.CSS(style.SectionFill),
+			SectionText:   template.CSS(style.SectionText),
+			TextColor:     template.CSS(style.TextColor),
+			LabelFill:     template.CSS(style.LabelFill),
+			ValueFill:     template.CSS(style.ValueFill),
+			FooterColor:   template.CSS(style.FooterColor),
+		},
+	}
+
+	for _, line := range tmpl.Footer.Lines {
+		page.Footer = append(page.Footer, locale.Translate(line))
+	}
+
+	if tmpl.ShowGeneratedOn {
+		page.GeneratedOn = locale.Sprintf("Generated on: %s", locale.FormatDateTime(issue.IssuedAt))
+	}
+	if issue.Profile != "" {
+		page.Profile = locale.Sprintf("Redaction profile: %s", issue.Profile)
+	}
+
+	if tmpl.Logo != nil && len(tmpl.Logo.Data) > 0 {
+		mimeType := "image/" + strings.ToLower(tmpl.Logo.ImageType)
+		if tmpl.Logo.ImageType == "JPG" {
+			mimeType = "image/jpeg"
+		}
+		page.Logo = template.URL("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(tmpl.Logo.Data))
+		// The template width is in millimetres
+		page.LogoWidth = fmt.Sprintf("%.0f", tmpl.Logo.Width*96/25.4)
+	}
+
+	for _, section := range tmpl.Sections {
+		htmlSection := htmlSection{Title: locale.Translate(section.Title)}
+		for _, field := range section.Fields {
+			htmlSection.Rows = append(htmlSection.Rows, htmlRow{
+				Label: locale.Translate(field.Label),
+				Value: r.formatField(student, field, locale),
+			})
+		}
+		page.Sections = append(page.Sections, htmlSection)
+	}
+
+	if tmpl.Footer.ShowReportID {
+		page.Footer = append(page.Footer, locale.Sprintf("Report ID: %s", issue.ID))
+		if issue.VerificationURL != "" {
+			page.VerificationURL = issue.VerificationURL
+			page.VerifyLabel = locale.Translate("Verify this report")
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := htmlReport.Execute(&buf, page); err != nil {
+		return nil, fmt.Errorf("failed to render HTML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+)
+
+func TestHTMLRenderer_GenerateStudentReport(t *testing.T) {
+	renderer := NewHTMLRenderer(zap.NewNop())
+
+	data, err := renderer.GenerateStudentReport(createTestStudent(), templates.Default(), i18n.Default(), testIssue())
+
+	require.NoError(t, err)
+	html := string(data)
+	assert.Contains(t, html, "<h1>Student Report</h1>")
+	assert.Contains(t, html, `<th class="section" colspan="2">Personal Information</th>`)
+	assert.Contains(t, html, `<td class="label">Full Name</td><td class="value" dir="auto">John Doe</td>`)
+	assert.Contains(t, html, "background: #3498DB")
+	assert.Contains(t, html, "<p>Report ID: SR-12345-1700000000-0a1b2c3d</p>")
+	assert.Contains(t, html, `<a href="https://reports.example.com/api/v1/reports/verify/SR-12345-1700000000-0a1b2c3d">Verify this report</a>`)
+	assert.Contains(t, html, "Generated on: November 14, 2023 at 10:13 PM")
+	assert.Equal(t, FormatHTML, renderer.Format())
+	assert.Equal(t, "text/html; charset=utf-8", renderer.ContentType())
+}
+
+func TestHTMLRenderer_EscapesStudentValues(t *testing.T) {
+	renderer := NewHTMLRenderer(zap.NewNop())
+	student := &dto.Student{ID: 1, Name: `<script>alert("x")</script>`}
+
+	data, err := renderer.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
+
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "<script>")
+	assert.Contains(t, string(data), "&lt;script&gt;")
+}
+
+func TestHTMLRenderer_EmbedsLogo(t *testing.T) {
+	renderer := NewHTMLRenderer(zap.NewNop())
+	tmpl := *templates.Default()
+	tmpl.Logo = &templates.Logo{Data: []byte("png"), ImageType: "PNG", Width: 25.4}
+
+	data, err := renderer.GenerateStudentReport(createTestStudent(), &tmpl, i18n.Default(), testIssue())
+
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `src="data:image/png;base64,cG5n" width="96"`)
+}
+
+func TestHTMLRenderer_Localised(t *testing.T) {
+	renderer := NewHTMLRenderer(zap.NewNop())
+	arabic, _ := i18n.Lookup("ar")
+
+	data, err := renderer.GenerateStudentReport(createTestStudent(), templates.Default(), arabic, testIssue())
+
+	require.NoError(t, err)
+	html := string(data)
+	assert.Contains(t, html, `<html lang="ar" dir="rtl">`)
+	assert.Contains(t, html, "<h1>تقرير الطالب</h1>")
+	assert.Contains(t, html, `<td class="label">تاريخ الميلاد</td><td class="value" dir="auto">1 يناير 2000</td>`)
+}
+
+func TestHTMLRenderer_StatesProfile(t *testing.T) {
+	renderer := NewHTMLRenderer(zap.NewNop())
+	issue := testIssue()
+	issue.Profile = "parent"
+
+	data, err := renderer.GenerateStudentReport(createTestStudent(), templates.Default(), i18n.Default(), issue)
+
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<p class="generated-on">Redaction profile: parent</p>`)
+}
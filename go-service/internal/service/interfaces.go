@@ -2,14 +2,125 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/signing"
+	"github.com/wbentaleb/student-report-service/internal/templates"
 )
 
-type PDFGenerator interface {
-	GenerateStudentReport(student *dto.Student) ([]byte, error)
+// Report output formats
+const (
+	FormatPDF  = "pdf"
+	FormatHTML = "html"
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// ReportIssue identifies a single rendering of a report. Renderers print the
+// ID and, when set, point readers to the verification URL and state the
+// redaction profile applied.
+type ReportIssue struct {
+	ID              string
+	IssuedAt        time.Time
+	VerificationURL string
+	Profile         string
+}
+
+// ReportRenderer produces student reports in a single output format. The
+// template text is translated into the language of locale.
+type ReportRenderer interface {
+	Format() string
+	ContentType() string
+	GenerateStudentReport(student *dto.Student, tmpl *templates.Template, locale *i18n.Locale, issue ReportIssue) ([]byte, error)
+}
+
+// CoalescingStats counts the calls served by the in-flight work of another
+// call for the same student or report
+type CoalescingStats struct {
+	DeduplicatedFetches uint64
+	DeduplicatedRenders uint64
+}
+
+type CoalescingStatsProvider interface {
+	CoalescingStats() CoalescingStats
+}
+
+// PDFSigner applies a digital signature to rendered PDF reports. The
+// fingerprint identifies the signing certificate.
+type PDFSigner interface {
+	Sign(pdf []byte) ([]byte, error)
+	Fingerprint() string
+}
+
+type SignatureVerifier interface {
+	Verify(pdf []byte) (*signing.Verification, error)
+}
+
+type TemplateProvider interface {
+	Get(name string) (*templates.Template, error)
+}
+
+// TOCEntry is a single line of the table of contents of a merged batch export.
+// Entries carrying an Error are listed as failed instead of pointing to a page.
+type TOCEntry struct {
+	Title string
+	Page  int
+	Error string
+}
+
+type TOCGenerator interface {
+	GenerateTableOfContents(entries []TOCEntry) ([]byte, error)
+}
+
+// ProgressFunc is notified each time a student of a batch has been processed
+type ProgressFunc func(completed, total int)
+
+type BatchReportGenerator interface {
+	GenerateBatch(ctx context.Context, req dto.BatchReportRequest, progress ProgressFunc) (*BatchReport, error)
+}
+
+// CacheWarmer renders reports ahead of time to fill the report cache
+type CacheWarmer interface {
+	WarmCache(ctx context.Context, req dto.CacheWarmRequest) (*dto.CacheWarmResponse, error)
+}
+
+// StudentEventProcessor reacts to the changes announced by the backend
+type StudentEventProcessor interface {
+	StudentUpdated(ctx context.Context, event dto.StudentUpdatedEvent) (string, error)
+}
+
+// ReportOptions selects how a report is rendered. The zero value renders the
+// default template as an English PDF, signed when a signer is configured,
+// with the redaction profile of the caller.
+type ReportOptions struct {
+	Template string
+	Format   string
+	Locale   string
+	Profile  string
+
+	// Unsigned skips the signature of PDF reports that are combined into
+	// another document, which is then signed as a whole
+	Unsigned bool
+
+	// Fresh fetches and renders the report without joining the work in
+	// flight for other callers, which may predate an update of the student
+	Fresh bool
+}
+
+// Report is a rendered student report. Stale reports were served from the
+// cache while the backend was unreachable and may not reflect the latest
+// student data.
+type Report struct {
+	Data        []byte
+	FileName    string
+	ContentType string
+	Language    string
+	Profile     string
+	Stale       bool
 }
 
 type ReportService interface {
-	GenerateStudentReport(ctx context.Context, studentID string) (pdfData []byte, fileName string, err error)
+	GenerateStudentReport(ctx context.Context, studentID string, opts ReportOptions) (*Report, error)
 }
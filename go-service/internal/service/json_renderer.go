@@ -0,0 +1,69 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+)
+
+type JSONRenderer struct {
+	fieldFormatter
+}
+
+func NewJSONRenderer(logger *zap.Logger) *JSONRenderer {
+	return &JSONRenderer{
+		fieldFormatter: fieldFormatter{logger: logger},
+	}
+}
+
+func (r *JSONRenderer) Format() string {
+	return FormatJSON
+}
+
+func (r *JSONRenderer) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+// GenerateStudentReport renders the template sections as a dto.ReportDocument.
+// Titles, labels and display values are localised; raw values are not.
+func (r *JSONRenderer) GenerateStudentReport(student *dto.Student, tmpl *templates.Template, locale *i18n.Locale, issue ReportIssue) ([]byte, error) {
+	document := dto.ReportDocument{
+		ReportID:        issue.ID,
+		VerificationURL: issue.VerificationURL,
+		StudentID:       student.ID,
+		Template:        tmpl.Name,
+		Locale:          locale.Tag,
+		Profile:         issue.Profile,
+		Title:           locale.Translate(tmpl.Title),
+		GeneratedAt:     issue.IssuedAt,
+		Sections:        make([]dto.ReportSection, 0, len(tmpl.Sections)),
+	}
+
+	for _, section := range tmpl.Sections {
+		reportSection := dto.ReportSection{
+			Title:  locale.Translate(section.Title),
+			Fields: make([]dto.ReportField, 0, len(section.Fields)),
+		}
+		for _, field := range section.Fields {
+			value, _ := templates.FieldValue(student, field.Field)
+			reportSection.Fields = append(reportSection.Fields, dto.ReportField{
+				Label:   locale.Translate(field.Label),
+				Field:   field.Field,
+				Value:   value,
+				Display: r.formatField(student, field, locale),
+			})
+		}
+		document.Sections = append(document.Sections, reportSection)
+	}
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+)
+
+func TestJSONRenderer_GenerateStudentReport(t *testing.T) {
+	renderer := NewJSONRenderer(zap.NewNop())
+
+	data, err := renderer.GenerateStudentReport(createTestStudent(), templates.Default(), i18n.Default(), testIssue())
+	require.NoError(t, err)
+
+	var document dto.ReportDocument
+	require.NoError(t, json.Unmarshal(data, &document))
+
+	assert.Equal(t, 12345, document.StudentID)
+	assert.Equal(t, templates.DefaultName, document.Template)
+	assert.Equal(t, testIssue().ID, document.ReportID)
+	assert.Equal(t, testIssue().VerificationURL, document.VerificationURL)
+	assert.True(t, testIssue().IssuedAt.Equal(document.GeneratedAt))
+	assert.Empty(t, document.Profile)
+	require.Len(t, document.Sections, 5)
+
+	field := document.Sections[0].Fields[0]
+	assert.Equal(t, "Student ID", field.Label)
+	assert.Equal(t, "id", field.Field)
+	assert.Equal(t, float64(12345), field.Value)
+	assert.Equal(t, "12345", field.Display)
+}
+
+func TestJSONRenderer_Localised(t *testing.T) {
+	renderer := NewJSONRenderer(zap.NewNop())
+	french, _ := i18n.Lookup("fr")
+
+	data, err := renderer.GenerateStudentReport(createTestStudent(), templates.Default(), french, testIssue())
+	require.NoError(t, err)
+
+	var document dto.ReportDocument
+	require.NoError(t, json.Unmarshal(data, &document))
+
+	assert.Equal(t, "fr", document.Locale)
+	assert.Equal(t, "Rapport de l'élève", document.Title)
+	assert.Equal(t, "Informations personnelles", document.Sections[0].Title)
+
+	dob := document.Sections[0].Fields[3]
+	assert.Equal(t, "dob", dob.Field)
+	assert.Equal(t, "2000-01-01T00:00:00Z", dob.Value, "raw values are not localised")
+	assert.Equal(t, "1 janvier 2000", dob.Display)
+}
+
+func TestJSONRenderer_StatesProfile(t *testing.T) {
+	renderer := NewJSONRenderer(zap.NewNop())
+	issue := testIssue()
+	issue.Profile = "transcript"
+
+	data, err := renderer.GenerateStudentReport(createTestStudent(), templates.Default(), i18n.Default(), issue)
+	require.NoError(t, err)
+
+	var document dto.ReportDocument
+	require.NoError(t, json.Unmarshal(data, &document))
+	assert.Equal(t, "transcript", document.Profile)
+}
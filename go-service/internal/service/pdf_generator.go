@@ -3,87 +3,108 @@ package service
 import (
 	"bytes"
 	"fmt"
-	"time"
 
 	"github.com/jung-kurt/gofpdf"
 	"go.uber.org/zap"
 
 	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+	"github.com/wbentaleb/student-report-service/internal/typeset"
 )
 
 type PDFService struct {
-	logger *zap.Logger
+	fieldFormatter
+	fonts *typeset.FontSet
 }
 
-func NewPDFService(logger *zap.Logger) *PDFService {
+func NewPDFService(fonts *typeset.FontSet, logger *zap.Logger) *PDFService {
 	return &PDFService{
-		logger: logger,
+		fieldFormatter: fieldFormatter{logger: logger},
+		fonts:          fonts,
 	}
 }
 
-func (s *PDFService) GenerateStudentReport(student *dto.Student) ([]byte, error) {
+func (s *PDFService) Format() string {
+	return FormatPDF
+}
+
+func (s *PDFService) ContentType() string {
+	return "application/pdf"
+}
+
+// GenerateStudentReport renders the student according to tmpl. Right-to-left
+// locales mirror the table so that labels sit on the right. When the footer
+// shows the report ID, a QR code links to the verification URL of issue.
+// The redaction profile of issue, when set, is stated under the title.
+// The document metadata is dated at the issue time, so the same issue of the
+// same data always renders to the same bytes.
+func (s *PDFService) GenerateStudentReport(student *dto.Student, tmpl *templates.Template, locale *i18n.Locale, issue ReportIssue) ([]byte, error) {
+	style := tmpl.Style
+	width := style.LabelWidth + style.ValueWidth
+
 	pdf := gofpdf.New("P", "mm", "A4", "")
+	// fixed metadata dates and resource order make the output reproducible
+	pdf.SetCatalogSort(true)
+	pdf.SetCreationDate(issue.IssuedAt)
+	pdf.SetModificationDate(issue.IssuedAt)
 	pdf.AddPage()
+	text := newPDFTextWriter(pdf, s.fonts)
+
+	if tmpl.Logo != nil && len(tmpl.Logo.Data) > 0 {
+		s.addLogo(pdf, tmpl.Logo)
+	}
 
 	// Set font for header
-	pdf.SetFont("Arial", "B", 18)
-	pdf.SetTextColor(44, 62, 80)
-	pdf.CellFormat(190, 10, "Student Report", "", 1, "C", false, 0, "")
+	setTextColor(pdf, style.TitleColor)
+	text.cell(width, 10, locale.Translate(tmpl.Title), "", 1, "C", false, style.FontFamily, "B", style.TitleSize)
 	pdf.Ln(5)
 
 	// Add generation date
-	pdf.SetFont("Arial", "", 10)
-	pdf.SetTextColor(127, 140, 141)
-	currentTime := time.Now().Format("January 2, 2006 at 3:04 PM")
-	pdf.CellFormat(190, 6, fmt.Sprintf("Generated on: %s", currentTime), "", 1, "C", false, 0, "")
+	if tmpl.ShowGeneratedOn {
+		setTextColor(pdf, style.SubtitleColor)
+		currentTime := locale.FormatDateTime(issue.IssuedAt)
+		text.cell(width, 6, locale.Sprintf("Generated on: %s", currentTime), "", 1, "C", false, style.FontFamily, "", style.SubtitleSize)
+	}
+	if issue.Profile != "" {
+		setTextColor(pdf, style.SubtitleColor)
+		text.cell(width, 6, locale.Sprintf("Redaction profile: %s", issue.Profile), "", 1, "C", false, style.FontFamily, "", style.SubtitleSize)
+	}
 	pdf.Ln(10)
 
-	// Personal Information Section
-	s.addSectionHeader(pdf, "Personal Information")
-	s.addTableRow(pdf, "Student ID", fmt.Sprintf("%d", student.ID))
-	s.addTableRow(pdf, "Full Name", s.formatValue(student.Name))
-	s.addTableRow(pdf, "Email", s.formatValue(student.Email))
-	s.addTableRow(pdf, "Date of Birth", s.formatDate(student.DOB))
-	s.addTableRow(pdf, "Gender", s.formatValue(student.Gender))
-	s.addTableRow(pdf, "Phone", s.formatValue(student.Phone))
-	s.addTableRow(pdf, "System Access", s.formatBool(student.SystemAccess))
-	pdf.Ln(5)
-
-	// Academic Information Section
-	s.addSectionHeader(pdf, "Academic Information")
-	s.addTableRow(pdf, "Class", s.formatValue(student.Class))
-	s.addTableRow(pdf, "Section", s.formatValue(student.Section))
-	s.addTableRow(pdf, "Roll Number", s.formatIntValue(student.Roll))
-	s.addTableRow(pdf, "Admission Date", s.formatDate(student.AdmissionDate))
-	s.addTableRow(pdf, "Added By", s.formatValue(student.ReporterName))
-	pdf.Ln(5)
-
-	// Parent Information Section
-	s.addSectionHeader(pdf, "Parent Information")
-	s.addTableRow(pdf, "Father's Name", s.formatValue(student.FatherName))
-	s.addTableRow(pdf, "Father's Phone", s.formatValue(student.FatherPhone))
-	s.addTableRow(pdf, "Mother's Name", s.formatValue(student.MotherName))
-	s.addTableRow(pdf, "Mother's Phone", s.formatValue(student.MotherPhone))
-	pdf.Ln(5)
-
-	// Guardian Information Section
-	s.addSectionHeader(pdf, "Guardian Information")
-	s.addTableRow(pdf, "Guardian Name", s.formatValue(student.GuardianName))
-	s.addTableRow(pdf, "Guardian Phone", s.formatValue(student.GuardianPhone))
-	s.addTableRow(pdf, "Relationship", s.formatValue(student.RelationOfGuardian))
-	pdf.Ln(5)
-
-	// Address Information Section
-	s.addSectionHeader(pdf, "Address Information")
-	s.addTableRow(pdf, "Current Address", s.formatValue(student.CurrentAddress))
-	s.addTableRow(pdf, "Permanent Address", s.formatValue(student.PermanentAddress))
+	for i, section := range tmpl.Sections {
+		if i > 0 {
+			pdf.Ln(5)
+		}
+		s.addSectionHeader(pdf, text, style, locale.Translate(section.Title))
+		for _, field := range section.Fields {
+			label, value := locale.Translate(field.Label), s.formatField(student, field, locale)
+			if locale.RTL() {
+				s.addMirroredTableRow(pdf, text, style, label, value)
+			} else {
+				s.addTableRow(pdf, text, style, label, value)
+			}
+		}
+	}
 
 	// Footer - positioned at bottom of current page
-	pdf.SetY(-30)
-	pdf.SetFont("Arial", "I", 8)
-	pdf.SetTextColor(127, 140, 141)
-	pdf.CellFormat(190, 5, "This is an auto-generated report from the Student Management System", "", 1, "C", false, 0, "")
-	pdf.CellFormat(190, 5, fmt.Sprintf("Report ID: SR-%d-%d", student.ID, time.Now().Unix()), "", 1, "C", false, 0, "")
+	if len(tmpl.Footer.Lines) > 0 || tmpl.Footer.ShowReportID {
+		pdf.SetY(-30)
+		if tmpl.Footer.ShowReportID && issue.VerificationURL != "" {
+			if err := s.addVerificationCode(pdf, issue.VerificationURL, locale.RTL()); err != nil {
+				s.logger.Error("Failed to add verification code", zap.Error(err))
+				return nil, err
+			}
+		}
+
+		setTextColor(pdf, style.FooterColor)
+		for _, line := range tmpl.Footer.Lines {
+			text.cell(width, 5, locale.Translate(line), "", 1, "C", false, style.FontFamily, "I", style.FooterSize)
+		}
+		if tmpl.Footer.ShowReportID {
+			text.cell(width, 5, locale.Sprintf("Report ID: %s", issue.ID), "", 1, "C", false, style.FontFamily, "I", style.FooterSize)
+		}
+	}
 
 	// Generate PDF bytes
 	var buf bytes.Buffer
@@ -93,57 +114,102 @@ func (s *PDFService) GenerateStudentReport(student *dto.Student) ([]byte, error)
 		return nil, fmt.Errorf("failed to generate PDF: %w", err)
 	}
 
-	s.logger.Info("PDF generated successfully", zap.Int("student_id", student.ID))
+	s.logger.Info("PDF generated successfully",
+		zap.Int("student_id", student.ID),
+		zap.String("template", tmpl.Name))
 	return buf.Bytes(), nil
 }
 
-func (s *PDFService) formatDate(isoDate string) string {
-	if isoDate == "" {
-		return "N/A"
+// GenerateTableOfContents renders the cover pages of a merged batch export,
+// listing the first page of every included report and the failed students.
+func (s *PDFService) GenerateTableOfContents(entries []TOCEntry) ([]byte, error) {
+	style := templates.Default().Style
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AddPage()
+	text := newPDFTextWriter(pdf, s.fonts)
+
+	setTextColor(pdf, style.TitleColor)
+	text.cell(style.LabelWidth+style.ValueWidth, 10, "Table of Contents", "", 1, "C", false, style.FontFamily, "B", style.TitleSize)
+	pdf.Ln(10)
+
+	var failed []TOCEntry
+	s.addSectionHeader(pdf, text, style, "Reports")
+	for _, entry := range entries {
+		if entry.Error != "" {
+			failed = append(failed, entry)
+			continue
+		}
+		s.addTableRow(pdf, text, style, entry.Title, fmt.Sprintf("Page %d", entry.Page))
 	}
-	t, err := time.Parse(time.RFC3339, isoDate)
-	if err != nil {
-		s.logger.Warn("Failed to parse date", zap.String("date", isoDate), zap.Error(err))
-		return isoDate
+
+	if len(failed) > 0 {
+		pdf.Ln(5)
+		s.addSectionHeader(pdf, text, style, "Failed Reports")
+		for _, entry := range failed {
+			s.addTableRow(pdf, text, style, entry.Title, entry.Error)
+		}
 	}
-	return t.Format("January 2, 2006")
-}
 
-func (s *PDFService) formatValue(value string) string {
-	if value == "" {
-		return "N/A"
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		s.logger.Error("Failed to generate table of contents", zap.Error(err))
+		return nil, fmt.Errorf("failed to generate table of contents: %w", err)
 	}
-	return value
+
+	return buf.Bytes(), nil
 }
 
-func (s *PDFService) formatIntValue(value int) string {
-	if value == 0 {
-		return "N/A"
-	}
-	return fmt.Sprintf("%d", value)
+func (s *PDFService) addLogo(pdf *gofpdf.Fpdf, logo *templates.Logo) {
+	options := gofpdf.ImageOptions{ImageType: logo.ImageType}
+	pdf.RegisterImageOptionsReader("logo", options, bytes.NewReader(logo.Data))
+	pdf.ImageOptions("logo", 10, 10, logo.Width, 0, false, options, 0, "")
 }
 
-func (s *PDFService) formatBool(value bool) string {
-	if value {
-		return "Active"
+// addVerificationCode places a QR code of url in the footer, on the right or,
+// for right-to-left reports, on the left
+func (s *PDFService) addVerificationCode(pdf *gofpdf.Fpdf, url string, rtl bool) error {
+	const size = 20
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+
+	x := pageWidth - right - size
+	if rtl {
+		x = left
 	}
-	return "Inactive"
+	return addQRCode(pdf, url, x, pdf.GetY()-5, size)
+}
+
+func (s *PDFService) addSectionHeader(pdf *gofpdf.Fpdf, text *pdfTextWriter, style templates.Style, title string) {
+	setFillColor(pdf, style.SectionFill)
+	setTextColor(pdf, style.SectionText)
+	text.cell(style.LabelWidth+style.ValueWidth, style.RowHeight, title, "1", 1, "", true, style.FontFamily, "B", style.SectionSize)
+	setTextColor(pdf, style.TextColor)
 }
 
-func (s *PDFService) addSectionHeader(pdf *gofpdf.Fpdf, title string) {
-	pdf.SetFont("Arial", "B", 14)
-	pdf.SetFillColor(52, 152, 219)
-	pdf.SetTextColor(255, 255, 255)
-	pdf.CellFormat(190, 8, title, "1", 1, "L", true, 0, "")
-	pdf.SetTextColor(0, 0, 0)
+func (s *PDFService) addTableRow(pdf *gofpdf.Fpdf, text *pdfTextWriter, style templates.Style, label, value string) {
+	setFillColor(pdf, style.LabelFill)
+	text.cell(style.LabelWidth, style.RowHeight, label, "1", 0, "", true, style.FontFamily, "B", style.BodySize)
+
+	setFillColor(pdf, style.ValueFill)
+	text.cell(style.ValueWidth, style.RowHeight, value, "1", 1, "", true, style.FontFamily, "", style.BodySize)
 }
 
-func (s *PDFService) addTableRow(pdf *gofpdf.Fpdf, label, value string) {
-	pdf.SetFont("Arial", "B", 11)
-	pdf.SetFillColor(236, 240, 241)
-	pdf.CellFormat(60, 8, label, "1", 0, "L", true, 0, "")
+// addMirroredTableRow draws the value cell first so that the label ends up
+// on the right, where right-to-left readers start
+func (s *PDFService) addMirroredTableRow(pdf *gofpdf.Fpdf, text *pdfTextWriter, style templates.Style, label, value string) {
+	setFillColor(pdf, style.ValueFill)
+	text.cell(style.ValueWidth, style.RowHeight, value, "1", 0, "", true, style.FontFamily, "", style.BodySize)
+
+	setFillColor(pdf, style.LabelFill)
+	text.cell(style.LabelWidth, style.RowHeight, label, "1", 1, "", true, style.FontFamily, "B", style.BodySize)
+}
+
+func setTextColor(pdf *gofpdf.Fpdf, color templates.Color) {
+	pdf.SetTextColor(color.RGB())
+}
 
-	pdf.SetFont("Arial", "", 11)
-	pdf.SetFillColor(255, 255, 255)
-	pdf.CellFormat(130, 8, value, "1", 1, "L", true, 0, "")
+func setFillColor(pdf *gofpdf.Fpdf, color templates.Color) {
+	pdf.SetFillColor(color.RGB())
 }
@@ -2,8 +2,12 @@ package service
 
 import (
 	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/jung-kurt/gofpdf"
 	"github.com/stretchr/testify/assert"
@@ -11,11 +15,16 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+	"github.com/wbentaleb/student-report-service/internal/typeset"
 )
 
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
 func TestNewPDFService(t *testing.T) {
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	assert.NotNil(t, service)
 	assert.Equal(t, logger, service.logger)
@@ -24,7 +33,7 @@ func TestNewPDFService(t *testing.T) {
 func TestGenerateStudentReport_Success(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	student := &dto.Student{
 		ID:                 12345,
@@ -52,7 +61,7 @@ func TestGenerateStudentReport_Success(t *testing.T) {
 	}
 
 	// Execute
-	pdfData, err := service.GenerateStudentReport(student)
+	pdfData, err := service.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
 
 	// Assert
 	require.NoError(t, err)
@@ -63,10 +72,144 @@ func TestGenerateStudentReport_Success(t *testing.T) {
 	assert.True(t, bytes.HasPrefix(pdfData, []byte("%PDF-")))
 }
 
+func TestGenerateStudentReport_Golden(t *testing.T) {
+	// deterministic issues depend on the student data only, never on the clock
+	issuer := NewReportIssuer(fixedClock{now: time.Now()}, true, "https://reports.example.com/api/v1/reports/verify")
+
+	tests := []struct {
+		name   string
+		locale string
+	}{
+		{name: "report_en", locale: "en"},
+		{name: "report_fr", locale: "fr"},
+		{name: "report_ar", locale: "ar"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locale, ok := i18n.Lookup(tt.locale)
+			require.True(t, ok)
+			render := func() []byte {
+				student := createTestStudent()
+				issue := issuer.Issue(student, tt.locale, true)
+				data, err := NewPDFService(typeset.DefaultFontSet(), zap.NewNop()).GenerateStudentReport(student, templates.Default(), locale, issue)
+				require.NoError(t, err)
+				return data
+			}
+
+			got := render()
+			assert.True(t, bytes.Equal(got, render()), "rendering the same data twice must produce identical bytes")
+
+			golden := filepath.Join("testdata", tt.name+".pdf")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, got, 0644))
+			}
+
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(want, got), "PDF differs from %s, run the tests with -update to accept the change", golden)
+		})
+	}
+}
+
+func TestGenerateStudentReport_StatesProfile(t *testing.T) {
+	service := NewPDFService(typeset.DefaultFontSet(), zap.NewNop())
+	issue := testIssue()
+
+	plain, err := service.GenerateStudentReport(createTestStudent(), templates.Default(), i18n.Default(), issue)
+	require.NoError(t, err)
+	issue.Profile = "parent"
+	stated, err := service.GenerateStudentReport(createTestStudent(), templates.Default(), i18n.Default(), issue)
+	require.NoError(t, err)
+
+	// the page content is compressed, so only the difference can be seen
+	assert.False(t, bytes.Equal(plain, stated))
+}
+
+func TestGenerateStudentReport_CustomTemplate(t *testing.T) {
+	// Setup
+	service := NewPDFService(typeset.DefaultFontSet(), zap.NewNop())
+	tmpl, err := templates.Parse([]byte(`
+name: compact
+title: Enrollment Card
+style:
+  font_family: Courier
+  section_fill: "#AA0000"
+sections:
+  - title: Enrollment
+    fields:
+      - { label: Name, field: name }
+      - { label: Roll, field: roll, format: number }
+      - { label: Access, field: systemAccess, format: status }
+`), templates.Default())
+	require.NoError(t, err)
+
+	// Execute
+	pdfData, err := service.GenerateStudentReport(&dto.Student{ID: 1, Name: "Jane Doe", Roll: 4}, tmpl, i18n.Default(), testIssue())
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(pdfData, []byte("%PDF-")))
+	assert.Contains(t, string(pdfData), "Courier")
+}
+
+func TestGenerateStudentReport_NonLatinNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		studentName string
+		address     string
+	}{
+		{name: "arabic", studentName: "محمد عبد الله", address: "شارع الملك فهد، الرياض"},
+		{name: "hebrew", studentName: "שרה כהן", address: "רחוב הרצל 12, תל אביב"},
+		{name: "cyrillic", studentName: "Иван Петров", address: "ул. Ленина, 5"},
+		{name: "vietnamese", studentName: "Nguyễn Thị Ánh", address: "Phường Bến Nghé, Quận 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewPDFService(typeset.DefaultFontSet(), zap.NewNop())
+			student := &dto.Student{ID: 7, Name: tt.studentName, CurrentAddress: tt.address}
+
+			pdfData, err := service.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
+
+			require.NoError(t, err)
+			assert.True(t, bytes.HasPrefix(pdfData, []byte("%PDF-")))
+			assert.Contains(t, string(pdfData), "/FontFile2", "non-Latin glyphs must come from an embedded TrueType font")
+		})
+	}
+}
+
+func TestGenerateStudentReport_LatinNamesUseCoreFont(t *testing.T) {
+	service := NewPDFService(typeset.DefaultFontSet(), zap.NewNop())
+
+	pdfData, err := service.GenerateStudentReport(&dto.Student{ID: 7, Name: "Zoë Müller"}, templates.Default(), i18n.Default(), testIssue())
+
+	require.NoError(t, err)
+	assert.NotContains(t, string(pdfData), "/FontFile2")
+}
+
+func TestGenerateStudentReport_Localised(t *testing.T) {
+	service := NewPDFService(typeset.DefaultFontSet(), zap.NewNop())
+
+	for _, tag := range i18n.Tags() {
+		t.Run(tag, func(t *testing.T) {
+			locale, _ := i18n.Lookup(tag)
+
+			pdfData, err := service.GenerateStudentReport(createTestStudent(), templates.Default(), locale, testIssue())
+
+			require.NoError(t, err)
+			assert.True(t, bytes.HasPrefix(pdfData, []byte("%PDF-")))
+			if locale.RTL() {
+				assert.Contains(t, string(pdfData), "/FontFile2", "Arabic labels need the embedded font")
+			}
+		})
+	}
+}
+
 func TestGenerateStudentReport_MinimalData(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	// Minimal student with only required fields
 	student := &dto.Student{
@@ -75,7 +218,7 @@ func TestGenerateStudentReport_MinimalData(t *testing.T) {
 	}
 
 	// Execute
-	pdfData, err := service.GenerateStudentReport(student)
+	pdfData, err := service.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
 
 	// Assert
 	require.NoError(t, err)
@@ -89,13 +232,13 @@ func TestGenerateStudentReport_MinimalData(t *testing.T) {
 func TestGenerateStudentReport_AllFieldsEmpty(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	// Student with all empty/zero values
 	student := &dto.Student{}
 
 	// Execute
-	pdfData, err := service.GenerateStudentReport(student)
+	pdfData, err := service.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
 
 	// Assert
 	require.NoError(t, err)
@@ -109,7 +252,7 @@ func TestGenerateStudentReport_AllFieldsEmpty(t *testing.T) {
 func TestFormatDate_Success(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	testCases := []struct {
 		name     string
@@ -145,16 +288,40 @@ func TestFormatDate_Success(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := service.formatDate(tc.input)
+			result := service.formatDate(tc.input, i18n.Default())
 			assert.Equal(t, tc.expected, result)
 		})
 	}
 }
 
+func TestFormatDate_Localised(t *testing.T) {
+	// Setup
+	service := NewPDFService(typeset.DefaultFontSet(), zap.NewNop())
+
+	testCases := []struct {
+		locale   string
+		input    string
+		expected string
+	}{
+		{locale: "en", input: "2024-02-29T10:30:00Z", expected: "February 29, 2024"},
+		{locale: "fr", input: "2024-02-29T10:30:00Z", expected: "29 février 2024"},
+		{locale: "ar", input: "2024-02-29T10:30:00Z", expected: "29 فبراير 2024"},
+		{locale: "fr", input: "", expected: "N/D"},
+		{locale: "ar", input: "", expected: "غير متوفر"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.locale+" "+tc.input, func(t *testing.T) {
+			locale, _ := i18n.Lookup(tc.locale)
+			assert.Equal(t, tc.expected, service.formatDate(tc.input, locale))
+		})
+	}
+}
+
 func TestFormatValue(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	testCases := []struct {
 		name     string
@@ -185,7 +352,7 @@ func TestFormatValue(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := service.formatValue(tc.input)
+			result := service.formatValue(tc.input, i18n.Default())
 			assert.Equal(t, tc.expected, result)
 		})
 	}
@@ -194,7 +361,7 @@ func TestFormatValue(t *testing.T) {
 func TestFormatIntValue(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	testCases := []struct {
 		name     string
@@ -225,7 +392,7 @@ func TestFormatIntValue(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := service.formatIntValue(tc.input)
+			result := service.formatIntValue(tc.input, i18n.Default())
 			assert.Equal(t, tc.expected, result)
 		})
 	}
@@ -234,7 +401,7 @@ func TestFormatIntValue(t *testing.T) {
 func TestFormatBool(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	testCases := []struct {
 		name     string
@@ -255,7 +422,7 @@ func TestFormatBool(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := service.formatBool(tc.input)
+			result := service.formatBool(tc.input, i18n.Default())
 			assert.Equal(t, tc.expected, result)
 		})
 	}
@@ -264,13 +431,13 @@ func TestFormatBool(t *testing.T) {
 func TestAddSectionHeader(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
 
 	// Execute
-	service.addSectionHeader(pdf, "Test Section")
+	service.addSectionHeader(pdf, newPDFTextWriter(pdf, service.fonts), templates.Default().Style, "Test Section")
 
 	// Get PDF output
 	var buf bytes.Buffer
@@ -287,13 +454,13 @@ func TestAddSectionHeader(t *testing.T) {
 func TestAddTableRow(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
 
 	// Execute
-	service.addTableRow(pdf, "Test Label", "Test Value")
+	service.addTableRow(pdf, newPDFTextWriter(pdf, service.fonts), templates.Default().Style, "Test Label", "Test Value")
 
 	// Get PDF output
 	var buf bytes.Buffer
@@ -307,10 +474,32 @@ func TestAddTableRow(t *testing.T) {
 	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
 }
 
+func TestAddTableRow_RightToLeft(t *testing.T) {
+	// Setup
+	service := NewPDFService(typeset.DefaultFontSet(), zap.NewNop())
+	style := templates.Default().Style
+
+	cursorAfter := func(value string) (float64, float64) {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.AddPage()
+		service.addTableRow(pdf, newPDFTextWriter(pdf, service.fonts), style, "Name", value)
+		require.NoError(t, pdf.Error())
+		return pdf.GetXY()
+	}
+
+	// Execute
+	latinX, latinY := cursorAfter("Sara Cohen")
+	rtlX, rtlY := cursorAfter("שרה כהן")
+
+	// Assert - the row occupies the same cell as a left-to-right one
+	assert.Equal(t, latinX, rtlX)
+	assert.Equal(t, latinY, rtlY)
+}
+
 func TestGenerateStudentReport_LongValues(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	// Create student with very long values
 	longString := strings.Repeat("A very long string that goes on and on ", 10)
@@ -325,7 +514,7 @@ func TestGenerateStudentReport_LongValues(t *testing.T) {
 	}
 
 	// Execute
-	pdfData, err := service.GenerateStudentReport(student)
+	pdfData, err := service.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
 
 	// Assert
 	require.NoError(t, err)
@@ -339,7 +528,7 @@ func TestGenerateStudentReport_LongValues(t *testing.T) {
 func TestGenerateStudentReport_SpecialCharacters(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	// Create student with special characters
 	student := &dto.Student{
@@ -359,7 +548,7 @@ func TestGenerateStudentReport_SpecialCharacters(t *testing.T) {
 	}
 
 	// Execute
-	pdfData, err := service.GenerateStudentReport(student)
+	pdfData, err := service.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
 
 	// Assert
 	require.NoError(t, err)
@@ -373,7 +562,7 @@ func TestGenerateStudentReport_SpecialCharacters(t *testing.T) {
 func TestGenerateStudentReport_FutureDates(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	// Create student with future dates
 	student := &dto.Student{
@@ -385,7 +574,7 @@ func TestGenerateStudentReport_FutureDates(t *testing.T) {
 	}
 
 	// Execute
-	pdfData, err := service.GenerateStudentReport(student)
+	pdfData, err := service.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
 
 	// Assert
 	require.NoError(t, err)
@@ -399,7 +588,7 @@ func TestGenerateStudentReport_FutureDates(t *testing.T) {
 func TestGenerateStudentReport_InvalidDateFormats(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	// Create student with invalid date formats
 	student := &dto.Student{
@@ -411,7 +600,7 @@ func TestGenerateStudentReport_InvalidDateFormats(t *testing.T) {
 	}
 
 	// Execute - should handle gracefully
-	pdfData, err := service.GenerateStudentReport(student)
+	pdfData, err := service.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
 
 	// Assert
 	require.NoError(t, err)
@@ -425,7 +614,7 @@ func TestGenerateStudentReport_InvalidDateFormats(t *testing.T) {
 func TestGenerateStudentReport_ZeroID(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	// Create student with zero ID
 	student := &dto.Student{
@@ -434,7 +623,7 @@ func TestGenerateStudentReport_ZeroID(t *testing.T) {
 	}
 
 	// Execute
-	pdfData, err := service.GenerateStudentReport(student)
+	pdfData, err := service.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
 
 	// Assert
 	require.NoError(t, err)
@@ -448,7 +637,7 @@ func TestGenerateStudentReport_ZeroID(t *testing.T) {
 func TestGenerateStudentReport_NegativeValues(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	// Create student with negative roll number
 	student := &dto.Student{
@@ -458,7 +647,7 @@ func TestGenerateStudentReport_NegativeValues(t *testing.T) {
 	}
 
 	// Execute
-	pdfData, err := service.GenerateStudentReport(student)
+	pdfData, err := service.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
 
 	// Assert
 	require.NoError(t, err)
@@ -472,7 +661,7 @@ func TestGenerateStudentReport_NegativeValues(t *testing.T) {
 // Benchmark tests
 func BenchmarkGenerateStudentReport(b *testing.B) {
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	student := &dto.Student{
 		ID:                 12345,
@@ -501,18 +690,18 @@ func BenchmarkGenerateStudentReport(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = service.GenerateStudentReport(student)
+		_, _ = service.GenerateStudentReport(student, templates.Default(), i18n.Default(), testIssue())
 	}
 }
 
 func BenchmarkFormatDate(b *testing.B) {
 	logger := zap.NewNop()
-	service := NewPDFService(logger)
+	service := NewPDFService(typeset.DefaultFontSet(), logger)
 
 	dateStr := "2024-01-15T10:30:00Z"
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = service.formatDate(dateStr)
+		_ = service.formatDate(dateStr, i18n.Default())
 	}
 }
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/boombuler/barcode/qr"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// qrQuietZone is the light margin, in modules, that scanners need around
+// the code
+const qrQuietZone = 2
+
+// addQRCode draws content as a QR code of the given size with its top left
+// corner at x, y. Modules are drawn as vector rectangles so the code stays
+// sharp at any zoom level.
+func addQRCode(pdf *gofpdf.Fpdf, content string, x, y, size float64) error {
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	modules := code.Bounds().Dx()
+	module := size / float64(modules+2*qrQuietZone)
+	x += qrQuietZone * module
+	y += qrQuietZone * module
+
+	pdf.SetFillColor(0, 0, 0)
+	for row := 0; row < modules; row++ {
+		// merge each run of dark modules into a single rectangle
+		for col := 0; col < modules; {
+			if !isDark(code.At(col, row)) {
+				col++
+				continue
+			}
+			start := col
+			for col < modules && isDark(code.At(col, row)) {
+				col++
+			}
+			pdf.Rect(x+float64(start)*module, y+float64(row)*module, float64(col-start)*module, module, "F")
+		}
+	}
+	return nil
+}
+
+func isDark(c color.Color) bool {
+	return color.GrayModel.Convert(c).(color.Gray).Y < 128
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"testing"
+
+	"github.com/boombuler/barcode/qr"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var filledRect = regexp.MustCompile(`([0-9.]+) ([0-9.]+) ([0-9.]+) (-?[0-9.]+) re f`)
+
+func TestAddQRCode(t *testing.T) {
+	content := "https://reports.example.com/api/v1/reports/verify/SR-12345-1700000000-0a1b2c3d"
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddPage()
+
+	require.NoError(t, addQRCode(pdf, content, 10, 10, 20))
+
+	var buf bytes.Buffer
+	require.NoError(t, pdf.Output(&buf))
+
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	require.NoError(t, err)
+	modules := code.Bounds().Dx()
+	darkModules, runs := 0, 0
+	for row := 0; row < modules; row++ {
+		for col := 0; col < modules; col++ {
+			if isDark(code.At(col, row)) {
+				darkModules++
+				if col == 0 || !isDark(code.At(col-1, row)) {
+					runs++
+				}
+			}
+		}
+	}
+
+	// each run of dark modules is one rectangle one module high
+	rects := filledRect.FindAllStringSubmatch(buf.String(), -1)
+	require.Len(t, rects, runs)
+
+	modulePt := 20.0 / float64(modules+2*qrQuietZone) * pdf.GetConversionRatio()
+	drawn := 0.0
+	for _, rect := range rects {
+		var width float64
+		_, err := fmt.Sscan(rect[3], &width)
+		require.NoError(t, err)
+		drawn += width / modulePt
+	}
+	assert.Equal(t, darkModules, int(math.Round(drawn)))
+}
+
+func TestAddQRCode_TooLong(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+
+	err := addQRCode(pdf, string(bytes.Repeat([]byte("x"), 4000)), 10, 10, 20)
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"github.com/jung-kurt/gofpdf"
+
+	"github.com/wbentaleb/student-report-service/internal/typeset"
+)
+
+// pdfTextWriter draws Unicode text into a document. Text the core fonts can
+// encode is written as before; anything else is laid out by the typeset
+// package and drawn run by run, registering the TrueType faces it needs on
+// first use.
+type pdfTextWriter struct {
+	pdf        *gofpdf.Fpdf
+	fonts      *typeset.FontSet
+	registered map[string]bool
+	translate  func(string) string
+}
+
+func newPDFTextWriter(pdf *gofpdf.Fpdf, fonts *typeset.FontSet) *pdfTextWriter {
+	return &pdfTextWriter{
+		pdf:        pdf,
+		fonts:      fonts,
+		registered: make(map[string]bool),
+		translate:  pdf.UnicodeTranslatorFromDescriptor(""),
+	}
+}
+
+// cell behaves like gofpdf's CellFormat with the font given explicitly.
+// Right-to-left text is right-aligned unless align asks for centering.
+func (w *pdfTextWriter) cell(width, height float64, text, border string, ln int, align string, fill bool, family, style string, size float64) {
+	runs, rtl := w.fonts.Layout(text, family, style)
+
+	if len(runs) <= 1 && (len(runs) == 0 || runs[0].Core) {
+		w.pdf.SetFont(family, style, size)
+		if len(runs) == 1 {
+			text = w.translate(runs[0].Text)
+		}
+		w.pdf.CellFormat(width, height, text, border, ln, align, fill, 0, "")
+		return
+	}
+
+	x, y := w.pdf.GetXY()
+	w.pdf.SetFont(family, style, size)
+	_, fontSize := w.pdf.GetFontSize()
+	w.pdf.CellFormat(width, height, "", border, ln, "", fill, 0, "")
+	nextX, nextY := w.pdf.GetXY()
+
+	texts := make([]string, len(runs))
+	widths := make([]float64, len(runs))
+	total := 0.0
+	for i, run := range runs {
+		texts[i] = w.setFont(run, size)
+		widths[i] = w.pdf.GetStringWidth(texts[i])
+		total += widths[i]
+	}
+
+	margin := w.pdf.GetCellMargin()
+	cx := x + margin
+	switch {
+	case align == "C":
+		cx = x + (width-total)/2
+	case align == "R" || rtl:
+		cx = x + width - margin - total
+	}
+	baseline := y + 0.5*height + 0.3*fontSize
+
+	for i, run := range runs {
+		w.setFont(run, size)
+		w.pdf.Text(cx, baseline, texts[i])
+		cx += widths[i]
+	}
+
+	w.pdf.SetFont(family, style, size)
+	w.pdf.SetXY(nextX, nextY)
+}
+
+// setFont selects the font of run and returns its text as gofpdf expects it
+func (w *pdfTextWriter) setFont(run typeset.Run, size float64) string {
+	if run.Core {
+		w.pdf.SetFont(run.Font, run.Style, size)
+		return w.translate(run.Text)
+	}
+
+	key := run.Font + "/" + run.Style
+	if !w.registered[key] {
+		family, _ := w.fonts.Family(run.Font)
+		data, _ := family.Face(run.Style)
+		w.pdf.AddUTF8FontFromBytes(run.Font, run.Style, data)
+		w.registered[key] = true
+	}
+	w.pdf.SetFont(run.Font, run.Style, size)
+	return run.Text
+}
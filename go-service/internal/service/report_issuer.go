@@ -0,0 +1,74 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wbentaleb/student-report-service/internal/clock"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+	"github.com/wbentaleb/student-report-service/internal/registry"
+)
+
+// ReportIssuer assigns the ID and issue time printed on new reports.
+//
+// In deterministic mode the issue time is the student's LastUpdated time and
+// the ID is derived from the student data and the report variant, so the
+// same input always renders to the same bytes.
+type ReportIssuer struct {
+	clock         clock.Clock
+	deterministic bool
+	verifyURL     string
+}
+
+func NewReportIssuer(clk clock.Clock, deterministic bool, verifyURL string) *ReportIssuer {
+	if clk == nil {
+		clk = clock.System()
+	}
+	return &ReportIssuer{
+		clock:         clk,
+		deterministic: deterministic,
+		verifyURL:     strings.TrimRight(verifyURL, "/"),
+	}
+}
+
+// Issue returns the issue of a new report for student. variant identifies
+// the template, format and language the report is rendered in. Reports that
+// cannot be reproduced byte for byte, such as signed ones, pass reproducible
+// false and get a unique ID even in deterministic mode.
+func (i *ReportIssuer) Issue(student *dto.Student, variant string, reproducible bool) ReportIssue {
+	var issue ReportIssue
+	if i.deterministic && reproducible {
+		issue.IssuedAt = lastUpdated(student)
+		issue.ID = fmt.Sprintf("SR-%d-%d-%s", student.ID, issue.IssuedAt.Unix(), contentDigest(student, variant))
+	} else {
+		issue.IssuedAt = i.clock.Now().UTC()
+		issue.ID = registry.NewID(student.ID, issue.IssuedAt)
+	}
+
+	if i.verifyURL != "" {
+		issue.VerificationURL = i.verifyURL + "/" + issue.ID
+	}
+	return issue
+}
+
+// lastUpdated is the time the student data last changed, or the Unix epoch
+// when the backend did not supply a valid timestamp
+func lastUpdated(student *dto.Student) time.Time {
+	t, err := time.Parse(time.RFC3339, student.LastUpdated)
+	if err != nil {
+		return time.Unix(0, 0).UTC()
+	}
+	return t.UTC()
+}
+
+// contentDigest is the ID suffix of a deterministic report. It covers every
+// student field, so reports of different data never share an ID.
+func contentDigest(student *dto.Student, variant string) string {
+	data, _ := json.Marshal(student)
+	hash := sha256.Sum256(append(append(data, 0), variant...))
+	return hex.EncodeToString(hash[:4])
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestReportIssuer_UsesClock(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600))
+	issuer := NewReportIssuer(fixedClock{now: now}, false, "https://reports.example.com/verify/")
+
+	first := issuer.Issue(createTestStudent(), "variant", true)
+	second := issuer.Issue(createTestStudent(), "variant", true)
+
+	assert.Equal(t, now.UTC(), first.IssuedAt)
+	assert.Regexp(t, `^SR-12345-1709281800-[0-9a-f]{8}$`, first.ID)
+	assert.Equal(t, "https://reports.example.com/verify/"+first.ID, first.VerificationURL)
+	assert.NotEqual(t, first.ID, second.ID)
+}
+
+func TestReportIssuer_Deterministic(t *testing.T) {
+	issuer := NewReportIssuer(fixedClock{now: time.Now()}, true, "")
+	student := createTestStudent()
+
+	first := issuer.Issue(student, "variant", true)
+	second := issuer.Issue(createTestStudent(), "variant", true)
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), first.IssuedAt)
+	assert.Regexp(t, `^SR-12345-1704103200-[0-9a-f]{8}$`, first.ID)
+	assert.Empty(t, first.VerificationURL)
+
+	t.Run("variants get distinct IDs", func(t *testing.T) {
+		assert.NotEqual(t, first.ID, issuer.Issue(student, "other", true).ID)
+	})
+
+	t.Run("changed data gets a distinct ID", func(t *testing.T) {
+		changed := createTestStudent()
+		changed.Phone = "5550000000"
+		assert.NotEqual(t, first.ID, issuer.Issue(changed, "variant", true).ID)
+	})
+
+	t.Run("unreproducible reports stay unique", func(t *testing.T) {
+		assert.NotEqual(t, issuer.Issue(student, "variant", false).ID, issuer.Issue(student, "variant", false).ID)
+	})
+
+	t.Run("invalid last updated time", func(t *testing.T) {
+		changed := createTestStudent()
+		changed.LastUpdated = "yesterday"
+		assert.Equal(t, time.Unix(0, 0).UTC(), issuer.Issue(changed, "variant", true).IssuedAt)
+	})
+}
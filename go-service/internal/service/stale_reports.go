@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/errors"
+)
+
+// staleReports remembers the reports served stale by this replica, to be
+// refreshed once the backend is back. The content version to serve is read
+// from the cache, see cache.PDFCache.LatestVersion.
+type staleReports struct {
+	mu      sync.Mutex
+	pending map[staleReport]struct{}
+}
+
+type staleReport struct {
+	studentID string
+	opts      ReportOptions
+}
+
+func newStaleReports() *staleReports {
+	return &staleReports{pending: make(map[staleReport]struct{})}
+}
+
+func (r *staleReports) markStale(studentID string, opts ReportOptions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending[staleReport{studentID, opts}] = struct{}{}
+}
+
+func (r *staleReports) markFresh(report staleReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, report)
+}
+
+func (r *staleReports) stale() []staleReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reports := make([]staleReport, 0, len(r.pending))
+	for report := range r.pending {
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// RefreshStaleReports regenerates the reports served stale, provided the
+// backend is reachable again, and returns how many were refreshed. Reports
+// whose student no longer exists are dropped; the others are retried on the
+// next call.
+func (s *StudentReportService) RefreshStaleReports(ctx context.Context) int {
+	if s.stale == nil {
+		return 0
+	}
+	reports := s.stale.stale()
+	if len(reports) == 0 || !s.backendClient.CheckHealth(ctx) {
+		return 0
+	}
+
+	refreshed := 0
+	for _, report := range reports {
+		fresh, err := s.GenerateStudentReport(ctx, report.studentID, report.opts)
+		switch {
+		case err == nil && !fresh.Stale:
+			s.stale.markFresh(report)
+			refreshed++
+		case errors.IsNotFound(err):
+			s.stale.markFresh(report)
+		case ctx.Err() != nil:
+			return refreshed
+		}
+	}
+
+	s.logger.Info("Stale reports refreshed",
+		zap.Int("refreshed", refreshed),
+		zap.Int("stale", len(reports)))
+	return refreshed
+}
+
+// RunStaleRefresh refreshes the reports served stale every interval until ctx
+// is done
+func (s *StudentReportService) RunStaleRefresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RefreshStaleReports(ctx)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/auth"
+	"github.com/wbentaleb/student-report-service/internal/cache"
+	serviceErrors "github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+)
+
+var errBackendDown = &serviceErrors.ServiceError{Service: "backend", Err: assert.AnError}
+
+// newStaleTestService returns a service serving stale reports whose first
+// report for student 12345 has been generated and cached
+func newStaleTestService(t *testing.T) (*StudentReportService, *MockBackendService, *MockPDFGenerator) {
+	t.Helper()
+	pdfCache, err := cache.NewMemoryCache(1<<20, time.Hour)
+	require.NoError(t, err)
+
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, pdfCache, nil, nil, nil, nil, true, zap.NewNop())
+
+	student := createTestStudent()
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(student, nil).Once()
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return([]byte("first pdf"), nil).Once()
+
+	report, err := service.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+	require.NoError(t, err)
+	require.False(t, report.Stale)
+	return service, mockBackend, mockPDFGen
+}
+
+func TestGenerateStudentReport_ServesStaleWhenBackendDown(t *testing.T) {
+	service, mockBackend, mockPDFGen := newStaleTestService(t)
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(nil, errBackendDown)
+
+	report, err := service.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+
+	require.NoError(t, err)
+	assert.True(t, report.Stale)
+	assert.Equal(t, []byte("first pdf"), report.Data)
+	assert.Equal(t, "student_12345_report.pdf", report.FileName)
+	mockPDFGen.AssertNumberOfCalls(t, "GenerateStudentReport", 1)
+}
+
+func TestGenerateStudentReport_StaleOnlyForCachedVariants(t *testing.T) {
+	service, mockBackend, _ := newStaleTestService(t)
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(nil, errBackendDown)
+
+	// the French report was never rendered
+	report, err := service.GenerateStudentReport(context.Background(), "12345", ReportOptions{Locale: "fr"})
+
+	assert.ErrorIs(t, err, errBackendDown)
+	assert.Nil(t, report)
+}
+
+func TestGenerateStudentReport_NotFoundIsNotServedStale(t *testing.T) {
+	service, mockBackend, _ := newStaleTestService(t)
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(nil, &serviceErrors.NotFoundError{Resource: "Student"})
+
+	report, err := service.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+
+	assert.True(t, serviceErrors.IsNotFound(err))
+	assert.Nil(t, report)
+}
+
+func TestGenerateStudentReport_ServeStaleDisabled(t *testing.T) {
+	pdfCache, err := cache.NewMemoryCache(1<<20, time.Hour)
+	require.NoError(t, err)
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, pdfCache, nil, nil, nil, nil, false, zap.NewNop())
+
+	student := createTestStudent()
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(student, nil).Once()
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(nil, errBackendDown)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return([]byte("first pdf"), nil)
+
+	_, err = service.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+	require.NoError(t, err)
+	report, err := service.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+
+	assert.ErrorIs(t, err, errBackendDown)
+	assert.Nil(t, report)
+	assert.Zero(t, service.RefreshStaleReports(context.Background()))
+}
+
+func TestRefreshStaleReports_WaitsForBackend(t *testing.T) {
+	service, mockBackend, _ := newStaleTestService(t)
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(nil, errBackendDown).Once()
+	mockBackend.On("CheckHealth", mock.Anything).Return(false)
+
+	_, err := service.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+	require.NoError(t, err)
+
+	assert.Zero(t, service.RefreshStaleReports(context.Background()))
+	mockBackend.AssertNumberOfCalls(t, "GetStudent", 2)
+}
+
+func TestRefreshStaleReports_RegeneratesOnceBackendIsBack(t *testing.T) {
+	service, mockBackend, mockPDFGen := newStaleTestService(t)
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(nil, errBackendDown).Once()
+	_, err := service.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+	require.NoError(t, err)
+
+	// the student changed during the outage
+	updated := createTestStudent()
+	updated.LastUpdated = "2024-03-01T10:00:00Z"
+	mockBackend.On("CheckHealth", mock.Anything).Return(true)
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(updated, nil)
+	mockPDFGen.On("GenerateStudentReport", updated, templates.Default(), i18n.Default(), mock.Anything).Return([]byte("updated pdf"), nil).Once()
+
+	assert.Equal(t, 1, service.RefreshStaleReports(context.Background()))
+	assert.Zero(t, service.RefreshStaleReports(context.Background()))
+
+	// the refreshed report is served from the cache
+	report, err := service.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+	require.NoError(t, err)
+	assert.False(t, report.Stale)
+	assert.Equal(t, []byte("updated pdf"), report.Data)
+	mockPDFGen.AssertExpectations(t)
+	mockBackend.AssertNumberOfCalls(t, "CheckHealth", 1)
+}
+
+func TestGenerateStudentReport_StaleNotServedWhenAccessDependsOnData(t *testing.T) {
+	service, mockBackend, _ := newStaleTestService(t)
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(nil, errBackendDown)
+
+	// a teacher's access depends on the student's class, which is unknown
+	teacher := &auth.Principal{Subject: "t1", Role: auth.RoleTeacher, Classes: []string{"10"}}
+	report, err := service.GenerateStudentReport(auth.NewContext(context.Background(), teacher), "12345", ReportOptions{})
+	assert.ErrorIs(t, err, errBackendDown)
+	assert.Nil(t, report)
+
+	admin := &auth.Principal{Subject: "a1", Role: auth.RoleAdmin}
+	report, err = service.GenerateStudentReport(auth.NewContext(context.Background(), admin), "12345", ReportOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.Stale)
+}
+
+func TestGenerateStudentReport_StaleServedInCallersProfile(t *testing.T) {
+	service, mockBackend, mockPDFGen := newStaleTestService(t)
+	parent := auth.NewContext(context.Background(), &auth.Principal{Subject: "p1", Role: auth.RoleParent, StudentIDs: []string{"12345"}})
+
+	// the full report cached so far is not served to a parent
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(nil, errBackendDown).Once()
+	_, err := service.GenerateStudentReport(parent, "12345", ReportOptions{})
+	assert.ErrorIs(t, err, errBackendDown)
+
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(createTestStudent(), nil).Once()
+	mockPDFGen.On("GenerateStudentReport", mock.Anything, mock.Anything, i18n.Default(), mock.Anything).Return([]byte("parent pdf"), nil).Once()
+	_, err = service.GenerateStudentReport(parent, "12345", ReportOptions{})
+	require.NoError(t, err)
+
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(nil, errBackendDown)
+	report, err := service.GenerateStudentReport(parent, "12345", ReportOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.Stale)
+	assert.Equal(t, []byte("parent pdf"), report.Data)
+	assert.Equal(t, "parent", report.Profile)
+}
+
+func TestGenerateStudentReport_ServesStaleAfterRestart(t *testing.T) {
+	pdfCache, err := cache.NewMemoryCache(1<<20, time.Hour)
+	require.NoError(t, err)
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	student := createTestStudent()
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(student, nil).Once()
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(nil, errBackendDown)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return([]byte("first pdf"), nil).Once()
+
+	first := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, pdfCache, nil, nil, nil, nil, true, zap.NewNop())
+	_, err = first.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+	require.NoError(t, err)
+
+	// a restarted replica sharing the cache never fetched the student itself
+	restarted := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, pdfCache, nil, nil, nil, nil, true, zap.NewNop())
+	report, err := restarted.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+
+	require.NoError(t, err)
+	assert.True(t, report.Stale)
+	assert.Equal(t, []byte("first pdf"), report.Data)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/cache"
+	"github.com/wbentaleb/student-report-service/internal/clock"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+)
+
+// Outcomes of a student event
+const (
+	EventProcessed = "processed"
+	EventDuplicate = "duplicate" // the event ID was processed before
+	EventOutdated  = "outdated"  // a later update of the student was applied
+)
+
+// rerenderTimeout bounds the background rendering of an updated report
+const rerenderTimeout = time.Minute
+
+// StudentEventService keeps the report cache in step with the backend. An
+// update drops the student's cached reports and, when re-rendering is
+// enabled, renders the default report again in the background.
+//
+// Deliveries are idempotent: an event ID seen within the retention period
+// is ignored, and so is an event no newer than the latest update applied to
+// the student within that period. Older deliveries are rejected as replays
+// by the webhook's timestamp tolerance.
+type StudentEventService struct {
+	pdfCache      cache.PDFCache
+	reportService ReportService
+	rerender      bool
+	retention     time.Duration
+	clock         clock.Clock
+	logger        *zap.Logger
+
+	mu      sync.Mutex
+	seen    map[string]time.Time     // event ID → time processed
+	applied map[string]appliedUpdate // student ID → latest update applied
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	rerenders sync.WaitGroup
+}
+
+// appliedUpdate is the latest update applied to a student
+type appliedUpdate struct {
+	updatedAt time.Time // as stated by the event
+	appliedAt time.Time
+}
+
+// NewStudentEventService creates the event processor. pdfCache may be nil
+// when caching is disabled.
+func NewStudentEventService(pdfCache cache.PDFCache, reportService ReportService, rerender bool, retention time.Duration, clock clock.Clock, logger *zap.Logger) *StudentEventService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &StudentEventService{
+		pdfCache:      pdfCache,
+		reportService: reportService,
+		rerender:      rerender,
+		retention:     retention,
+		clock:         clock,
+		logger:        logger,
+		seen:          make(map[string]time.Time),
+		applied:       make(map[string]appliedUpdate),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// StudentUpdated invalidates the cached reports of the updated student and
+// returns the outcome. A failed event is not recorded, so its redelivery is
+// processed again.
+func (s *StudentEventService) StudentUpdated(ctx context.Context, event dto.StudentUpdatedEvent) (string, error) {
+	if status, done := s.admit(event); done {
+		return status, nil
+	}
+
+	// the cache is called without the lock, so that a slow backend does not
+	// hold up the events of other students
+	if s.pdfCache != nil {
+		if err := s.pdfCache.Delete(event.StudentID); err != nil {
+			s.mu.Lock()
+			delete(s.seen, event.EventID)
+			s.mu.Unlock()
+			s.logger.Error("Failed to invalidate cached reports",
+				zap.String("event_id", event.EventID),
+				zap.String("student_id", event.StudentID),
+				zap.Error(err))
+			return "", fmt.Errorf("failed to invalidate cached reports: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	if event.UpdatedAt.After(s.applied[event.StudentID].updatedAt) {
+		s.applied[event.StudentID] = appliedUpdate{updatedAt: event.UpdatedAt, appliedAt: s.clock.Now()}
+	}
+	s.mu.Unlock()
+	s.logger.Info("Cached reports invalidated",
+		zap.String("event_id", event.EventID),
+		zap.String("student_id", event.StudentID))
+
+	if s.rerender {
+		s.rerenders.Add(1)
+		go s.rerenderReport(event.StudentID)
+	}
+	return EventProcessed, nil
+}
+
+// admit records the event as seen and reports whether it is a duplicate or
+// outdated, with its outcome. A new event is recorded before it is applied,
+// so that a concurrent redelivery is not applied twice.
+func (s *StudentEventService) admit(event dto.StudentUpdatedEvent) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.forget(now)
+
+	if _, seen := s.seen[event.EventID]; seen {
+		s.logger.Debug("Duplicate student event ignored", zap.String("event_id", event.EventID))
+		return EventDuplicate, true
+	}
+	s.seen[event.EventID] = now
+	if applied, ok := s.applied[event.StudentID]; ok && !event.UpdatedAt.IsZero() && !event.UpdatedAt.After(applied.updatedAt) {
+		s.logger.Info("Outdated student event ignored",
+			zap.String("event_id", event.EventID),
+			zap.String("student_id", event.StudentID),
+			zap.Time("updated_at", event.UpdatedAt))
+		return EventOutdated, true
+	}
+	return "", false
+}
+
+// Close stops the background re-renders and waits for them to exit
+func (s *StudentEventService) Close() {
+	s.cancel()
+	s.rerenders.Wait()
+}
+
+// rerenderReport renders the default report of the student, which fetches
+// the updated record and caches the result
+func (s *StudentEventService) rerenderReport(studentID string) {
+	defer s.rerenders.Done()
+
+	ctx, cancel := context.WithTimeout(s.ctx, rerenderTimeout)
+	defer cancel()
+
+	// work in flight may have started before the update, so none is joined
+	if _, err := s.reportService.GenerateStudentReport(ctx, studentID, ReportOptions{Fresh: true}); err != nil {
+		s.logger.Warn("Failed to re-render updated report",
+			zap.String("student_id", studentID),
+			zap.Error(err))
+	}
+}
+
+// forget drops the event IDs seen and the updates applied before the
+// retention period. Callers must hold the lock.
+func (s *StudentEventService) forget(now time.Time) {
+	for eventID, seenAt := range s.seen {
+		if now.Sub(seenAt) > s.retention {
+			delete(s.seen, eventID)
+		}
+	}
+	for studentID, applied := range s.applied {
+		if now.Sub(applied.appliedAt) > s.retention {
+			delete(s.applied, studentID)
+		}
+	}
+}
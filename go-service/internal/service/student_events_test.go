@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wbentaleb/student-report-service/internal/cache"
+	"github.com/wbentaleb/student-report-service/internal/clock"
+	"github.com/wbentaleb/student-report-service/internal/dto"
+)
+
+func studentUpdated(eventID, studentID string, updatedAt time.Time) dto.StudentUpdatedEvent {
+	return dto.StudentUpdatedEvent{EventID: eventID, StudentID: studentID, UpdatedAt: updatedAt}
+}
+
+func TestStudentUpdated_InvalidatesCache(t *testing.T) {
+	mockCache := new(MockPDFCache)
+	mockCache.On("Delete", "42").Return(nil)
+	clock := &fixedClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	events := NewStudentEventService(mockCache, nil, false, time.Hour, clock, zap.NewNop())
+
+	status, err := events.StudentUpdated(context.Background(), studentUpdated("evt-1", "42", clock.now))
+
+	require.NoError(t, err)
+	assert.Equal(t, EventProcessed, status)
+	mockCache.AssertExpectations(t)
+}
+
+func TestStudentUpdated_Idempotent(t *testing.T) {
+	mockCache := new(MockPDFCache)
+	mockCache.On("Delete", "42").Return(nil)
+	clock := &fixedClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	events := NewStudentEventService(mockCache, nil, false, time.Hour, clock, zap.NewNop())
+	ctx := context.Background()
+	t1 := clock.now.Add(-2 * time.Minute)
+	t2 := clock.now.Add(-time.Minute)
+
+	testCases := []struct {
+		name     string
+		event    dto.StudentUpdatedEvent
+		expected string
+	}{
+		{name: "latest update", event: studentUpdated("evt-2", "42", t2), expected: EventProcessed},
+		{name: "redelivery", event: studentUpdated("evt-2", "42", t2), expected: EventDuplicate},
+		{name: "earlier update delivered late", event: studentUpdated("evt-1", "42", t1), expected: EventOutdated},
+		{name: "same update under another ID", event: studentUpdated("evt-3", "42", t2), expected: EventOutdated},
+		{name: "event without update time", event: studentUpdated("evt-4", "42", time.Time{}), expected: EventProcessed},
+	}
+
+	for _, tc := range testCases {
+		status, err := events.StudentUpdated(ctx, tc.event)
+
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, status, tc.name)
+	}
+	mockCache.AssertNumberOfCalls(t, "Delete", 2)
+}
+
+func TestStudentUpdated_FailedEventIsRetried(t *testing.T) {
+	mockCache := new(MockPDFCache)
+	mockCache.On("Delete", "42").Return(errors.New("connection refused")).Once()
+	mockCache.On("Delete", "42").Return(nil).Once()
+	clock := &fixedClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	events := NewStudentEventService(mockCache, nil, false, time.Hour, clock, zap.NewNop())
+	event := studentUpdated("evt-1", "42", clock.now)
+
+	_, err := events.StudentUpdated(context.Background(), event)
+	require.Error(t, err)
+	status, err := events.StudentUpdated(context.Background(), event)
+
+	require.NoError(t, err)
+	assert.Equal(t, EventProcessed, status)
+	mockCache.AssertExpectations(t)
+}
+
+func TestStudentUpdated_ForgetsEventIDsAfterRetention(t *testing.T) {
+	mockCache := new(MockPDFCache)
+	mockCache.On("Delete", "42").Return(nil)
+	clock := &fixedClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	events := NewStudentEventService(mockCache, nil, false, 10*time.Minute, clock, zap.NewNop())
+
+	_, err := events.StudentUpdated(context.Background(), studentUpdated("evt-1", "42", time.Time{}))
+	require.NoError(t, err)
+	clock.now = clock.now.Add(11 * time.Minute)
+	status, err := events.StudentUpdated(context.Background(), studentUpdated("evt-1", "42", time.Time{}))
+
+	require.NoError(t, err)
+	assert.Equal(t, EventProcessed, status)
+	assert.Len(t, events.seen, 1)
+}
+
+func TestStudentUpdated_ForgetsAppliedUpdatesAfterRetention(t *testing.T) {
+	mockCache := new(MockPDFCache)
+	mockCache.On("Delete", mock.Anything).Return(nil)
+	clock := &fixedClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	events := NewStudentEventService(mockCache, nil, false, 10*time.Minute, clock, zap.NewNop())
+
+	_, err := events.StudentUpdated(context.Background(), studentUpdated("evt-1", "42", clock.now))
+	require.NoError(t, err)
+	_, err = events.StudentUpdated(context.Background(), studentUpdated("evt-2", "43", clock.now))
+	require.NoError(t, err)
+	require.Len(t, events.applied, 2)
+
+	clock.now = clock.now.Add(11 * time.Minute)
+	_, err = events.StudentUpdated(context.Background(), studentUpdated("evt-3", "43", clock.now))
+	require.NoError(t, err)
+
+	assert.Len(t, events.applied, 1)
+	assert.Contains(t, events.applied, "43")
+}
+
+func TestStudentUpdated_RerendersDefaultReport(t *testing.T) {
+	mockCache := new(MockPDFCache)
+	mockCache.On("Delete", "42").Return(nil)
+	mockReports := new(MockReportService)
+	mockReports.On("GenerateStudentReport", mock.Anything, "42", ReportOptions{Fresh: true}).Return(pdfReport([]byte("pdf"), "student_42_report.pdf"), nil)
+	events := NewStudentEventService(mockCache, mockReports, true, time.Hour, clock.System(), zap.NewNop())
+
+	status, err := events.StudentUpdated(context.Background(), studentUpdated("evt-1", "42", time.Now()))
+	events.rerenders.Wait()
+
+	require.NoError(t, err)
+	assert.Equal(t, EventProcessed, status)
+	mockReports.AssertExpectations(t)
+}
+
+func TestStudentUpdated_NilCache(t *testing.T) {
+	events := NewStudentEventService(nil, nil, false, time.Hour, clock.System(), zap.NewNop())
+
+	status, err := events.StudentUpdated(context.Background(), studentUpdated("evt-1", "42", time.Now()))
+
+	require.NoError(t, err)
+	assert.Equal(t, EventProcessed, status)
+}
+
+func TestStudentUpdated_SlowCacheDoesNotBlockOtherStudents(t *testing.T) {
+	deleting := make(chan struct{})
+	release := make(chan struct{})
+	mockCache := new(MockPDFCache)
+	mockCache.On("Delete", "1").Run(func(mock.Arguments) {
+		close(deleting)
+		<-release
+	}).Return(nil)
+	mockCache.On("Delete", "2").Return(nil)
+	clock := &fixedClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	events := NewStudentEventService(mockCache, nil, false, time.Hour, clock, zap.NewNop())
+
+	slow := make(chan string)
+	go func() {
+		status, _ := events.StudentUpdated(context.Background(), studentUpdated("evt-1", "1", clock.now))
+		slow <- status
+	}()
+	<-deleting
+
+	status, err := events.StudentUpdated(context.Background(), studentUpdated("evt-2", "2", clock.now))
+	require.NoError(t, err)
+	assert.Equal(t, EventProcessed, status)
+
+	// a redelivery while the first delivery is applied is not applied twice
+	status, err = events.StudentUpdated(context.Background(), studentUpdated("evt-1", "1", clock.now))
+	require.NoError(t, err)
+	assert.Equal(t, EventDuplicate, status)
+
+	close(release)
+	assert.Equal(t, EventProcessed, <-slow)
+	mockCache.AssertNumberOfCalls(t, "Delete", 2)
+}
+
+func TestStudentUpdated_RerenderDoesNotJoinEarlierRender(t *testing.T) {
+	pdfCache, err := cache.NewMemoryCache(1<<20, time.Hour)
+	require.NoError(t, err)
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	reports := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, pdfCache, nil, nil, nil, nil, false, zap.NewNop())
+	events := NewStudentEventService(pdfCache, reports, true, time.Hour, clock.System(), zap.NewNop())
+
+	// the update changes a field the content version does not cover, so
+	// both renderings share one cache key
+	before := createTestStudent()
+	after := createTestStudent()
+	after.Phone = "5550000000"
+	rendering := make(chan struct{})
+	release := make(chan struct{})
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(before, nil).Once()
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(after, nil).Once()
+	mockPDFGen.On("GenerateStudentReport", before, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			close(rendering)
+			<-release
+		}).
+		Return([]byte("before pdf"), nil)
+	mockPDFGen.On("GenerateStudentReport", after, mock.Anything, mock.Anything, mock.Anything).Return([]byte("after pdf"), nil)
+
+	earlier := make(chan error, 1)
+	go func() {
+		_, err := reports.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+		earlier <- err
+	}()
+	<-rendering
+
+	status, err := events.StudentUpdated(context.Background(), studentUpdated("evt-1", "12345", time.Now()))
+	require.NoError(t, err)
+	assert.Equal(t, EventProcessed, status)
+
+	// the re-render completes while the earlier rendering is still running
+	events.rerenders.Wait()
+	entries, err := pdfCache.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	data, found := pdfCache.Get("12345", entries[0].Hash)
+	require.True(t, found)
+	assert.Equal(t, []byte("after pdf"), data)
+
+	close(release)
+	require.NoError(t, <-earlier)
+	mockBackend.AssertNumberOfCalls(t, "GetStudent", 2)
+}
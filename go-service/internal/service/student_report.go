@@ -2,73 +2,282 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
 	"fmt"
+	"sync/atomic"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 
+	"github.com/wbentaleb/student-report-service/internal/auth"
 	"github.com/wbentaleb/student-report-service/internal/cache"
+	"github.com/wbentaleb/student-report-service/internal/clock"
 	"github.com/wbentaleb/student-report-service/internal/dto"
 	"github.com/wbentaleb/student-report-service/internal/errors"
 	"github.com/wbentaleb/student-report-service/internal/external"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/redaction"
+	"github.com/wbentaleb/student-report-service/internal/registry"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+	"github.com/wbentaleb/student-report-service/internal/tracing"
 )
 
 type StudentReportService struct {
 	backendClient external.BackendService
-	pdfGenerator  PDFGenerator
+	renderers     map[string]ReportRenderer
 	pdfCache      cache.PDFCache
+	templates     TemplateProvider
+	signer        PDFSigner
+	registry      registry.Registry
+	issuer        *ReportIssuer
+	stale         *staleReports
 	logger        *zap.Logger
+
+	flights          flightGroup
+	coalescedFetches atomic.Uint64
+	coalescedRenders atomic.Uint64
 }
 
 func NewStudentReportService(
 	backendClient external.BackendService,
-	pdfGenerator PDFGenerator,
+	renderers []ReportRenderer,
 	pdfCache cache.PDFCache,
+	templates TemplateProvider,
+	signer PDFSigner,
+	reportRegistry registry.Registry,
+	issuer *ReportIssuer,
+	serveStale bool,
 	logger *zap.Logger,
 ) *StudentReportService {
+	byFormat := make(map[string]ReportRenderer, len(renderers))
+	for _, renderer := range renderers {
+		byFormat[renderer.Format()] = renderer
+	}
+	if issuer == nil {
+		issuer = NewReportIssuer(clock.System(), false, "")
+	}
+	var stale *staleReports
+	if serveStale {
+		stale = newStaleReports()
+	}
+
 	return &StudentReportService{
 		backendClient: backendClient,
-		pdfGenerator:  pdfGenerator,
+		renderers:     byFormat,
 		pdfCache:      pdfCache,
+		templates:     templates,
+		signer:        signer,
+		registry:      reportRegistry,
+		issuer:        issuer,
+		stale:         stale,
 		logger:        logger,
 	}
 }
 
-func (s *StudentReportService) GenerateStudentReport(ctx context.Context, studentID string) (pdfData []byte, fileName string, err error) {
+func (s *StudentReportService) GenerateStudentReport(ctx context.Context, studentID string, opts ReportOptions) (*Report, error) {
+	renderer, err := s.resolveRenderer(opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := resolveTemplate(s.templates, opts.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	locale, err := resolveLocale(opts.Locale)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := resolveProfile(ctx, opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+	// stale reports are refreshed without the caller, in the profile they
+	// were served with
+	opts.Profile = profile.Name
+
+	sign := s.signer != nil && renderer.Format() == FormatPDF && !opts.Unsigned
+
+	// each template, format, language and redaction profile renders a
+	// distinct variant of the same content, and so does each signing
+	// certificate
+	variants := []string{tmpl.CacheKey(), renderer.Format(), locale.CacheKey(), profile.CacheKey()}
+	if sign {
+		variants = append(variants, "signed-"+s.signer.Fingerprint())
+	}
+
+	// callers that cannot read the report are turned away before the backend
+	// is asked about the student, when that can be told from the ID alone
+	principal, authenticated := auth.FromContext(ctx)
+	if authenticated && !principal.MayRequest(studentID) {
+		return nil, s.denyAccess(principal, studentID)
+	}
+
 	// fetch student data from backend
-	student, err := s.fetchStudentData(ctx, studentID)
+	student, err := s.fetchStudentData(ctx, studentID, opts.Fresh)
 	if err != nil {
-		return nil, "", err
+		// a stale report is only served to callers who may read it without
+		// the student's data
+		if !authenticated || principal.MayRead(studentID, nil) {
+			if report := s.serveStale(ctx, studentID, opts, renderer, locale, variants, err); report != nil {
+				return report, nil
+			}
+		}
+		return nil, err
+	}
+	if authenticated && !principal.MayRead(studentID, student) {
+		return nil, s.denyAccess(principal, studentID)
 	}
 
-	contentHash := cache.GenerateStudentHash(student)
+	contentHash := cache.VariantKey(cache.GenerateStudentHash(student), variants...)
 
+	// concurrent requests for the same report share one cache lookup and
+	// rendering. A fresh report is rendered on its own, as a cached report
+	// or a rendering in flight may have been based on the previous data.
+	var data []byte
+	if opts.Fresh {
+		data, err = s.renderNewReport(ctx, studentID, student, renderer, tmpl, locale, profile, contentHash, sign)
+	} else {
+		data, err = coalesce(ctx, &s.flights, "render:"+studentID+":"+contentHash, &s.coalescedRenders, func(ctx context.Context) ([]byte, error) {
+			return s.produceReport(ctx, studentID, student, renderer, tmpl, locale, profile, contentHash, sign)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.buildReport(studentID, renderer, locale, profile.Name, data), nil
+}
+
+// produceReport returns the cached report or renders, signs, registers and
+// caches a new one
+func (s *StudentReportService) produceReport(ctx context.Context, studentID string, student *dto.Student, renderer ReportRenderer, tmpl *templates.Template, locale *i18n.Locale, profile *redaction.Profile, contentHash string, sign bool) ([]byte, error) {
 	// try to retrieve from cache
-	if cachedPDF := s.tryGetFromCache(studentID, contentHash); cachedPDF != nil {
+	if cachedData := s.tryGetFromCache(ctx, studentID, contentHash); cachedData != nil {
 		s.logger.Info("Report served from cache",
 			zap.String("student_id", studentID),
 			zap.String("content_hash", contentHash))
 
-		return cachedPDF, s.buildFileName(studentID), nil
+		return cachedData, nil
 	}
 
-	// if no cache found, generate new PDF
-	pdfData, err = s.generatePDF(student)
+	// if no cache found, render a new report
+	return s.renderNewReport(ctx, studentID, student, renderer, tmpl, locale, profile, contentHash, sign)
+}
+
+// renderNewReport renders, signs, registers and caches a new report
+func (s *StudentReportService) renderNewReport(ctx context.Context, studentID string, student *dto.Student, renderer ReportRenderer, tmpl *templates.Template, locale *i18n.Locale, profile *redaction.Profile, contentHash string, sign bool) ([]byte, error) {
+	// renderers only ever see the redacted student
+	student, tmpl = profile.Apply(student, tmpl)
+	// signatures carry their own signing time, so signed reports are never
+	// reproducible
+	issue := s.issuer.Issue(student, contentHash, !sign)
+	issue.Profile = profile.Name
+	data, err := s.renderReport(ctx, renderer, student, tmpl, locale, issue)
 	if err != nil {
-		return nil, "", err
+		return nil, err
+	}
+
+	if sign {
+		if data, err = s.signReport(student, data); err != nil {
+			return nil, err
+		}
+	}
+
+	// a report whose ID cannot be verified is not handed out
+	if err := s.registerReport(issue, student, renderer, tmpl, locale, data); err != nil {
+		return nil, err
 	}
 
 	// store in cache (non-blocking, failure is acceptable)
-	s.storePDFInCache(studentID, contentHash, pdfData)
+	s.storePDFInCache(ctx, studentID, contentHash, data)
 
 	s.logger.Info("Report generated successfully",
 		zap.String("student_id", studentID),
-		zap.Int("pdf_size_bytes", len(pdfData)))
+		zap.String("format", renderer.Format()),
+		zap.String("locale", locale.Tag),
+		zap.String("profile", profile.Name),
+		zap.Int("size_bytes", len(data)))
+
+	return data, nil
+}
 
-	return pdfData, s.buildFileName(studentID), nil
+// CoalescingStats returns how many calls since startup were served by an
+// in-flight backend fetch or rendering of another call
+func (s *StudentReportService) CoalescingStats() CoalescingStats {
+	return CoalescingStats{
+		DeduplicatedFetches: s.coalescedFetches.Load(),
+		DeduplicatedRenders: s.coalescedRenders.Load(),
+	}
 }
 
-func (s *StudentReportService) fetchStudentData(ctx context.Context, studentID string) (*dto.Student, error) {
-	student, err := s.backendClient.GetStudent(ctx, studentID)
+// serveStale returns the cached report of the latest content version cached
+// for the student when the backend could not be reached, and schedules it to
+// be refreshed once the backend is back. It returns nil when serving stale
+// reports is disabled, the student does not exist, the caller gave up or no
+// such report is cached.
+func (s *StudentReportService) serveStale(ctx context.Context, studentID string, opts ReportOptions, renderer ReportRenderer, locale *i18n.Locale, variants []string, fetchErr error) *Report {
+	if s.stale == nil || s.pdfCache == nil || errors.IsNotFound(fetchErr) || ctx.Err() != nil {
+		return nil
+	}
+
+	version, ok := s.pdfCache.LatestVersion(studentID)
+	if !ok {
+		return nil
+	}
+	data := s.tryGetFromCache(ctx, studentID, cache.VariantKey(version, variants...))
+	if data == nil {
+		return nil
+	}
+
+	s.stale.markStale(studentID, opts)
+	s.logger.Warn("Backend unreachable, serving stale report",
+		zap.String("student_id", studentID),
+		zap.String("content_version", version),
+		zap.Error(fetchErr))
+
+	report := s.buildReport(studentID, renderer, locale, opts.Profile, data)
+	report.Stale = true
+	return report
+}
+
+func (s *StudentReportService) denyAccess(principal *auth.Principal, studentID string) error {
+	s.logger.Warn("Report access denied",
+		zap.String("student_id", studentID),
+		zap.String("subject", principal.Subject),
+		zap.String("role", string(principal.Role)))
+	return &errors.ForbiddenError{Resource: "Student report"}
+}
+
+func (s *StudentReportService) resolveRenderer(format string) (ReportRenderer, error) {
+	if format == "" {
+		format = FormatPDF
+	}
+
+	renderer, ok := s.renderers[format]
+	if !ok {
+		return nil, &errors.ValidationError{Message: fmt.Sprintf("unsupported format %q", format)}
+	}
+	return renderer, nil
+}
+
+// fetchStudentData fetches the student from the backend. Unless fresh is
+// set, concurrent requests for the same student share one backend call.
+func (s *StudentReportService) fetchStudentData(ctx context.Context, studentID string, fresh bool) (*dto.Student, error) {
+	fetch := func(ctx context.Context) (*dto.Student, error) {
+		return s.backendClient.GetStudent(ctx, studentID)
+	}
+	var student *dto.Student
+	var err error
+	if fresh {
+		student, err = fetch(ctx)
+	} else {
+		student, err = coalesce(ctx, &s.flights, "fetch:"+studentID, &s.coalescedFetches, fetch)
+	}
 	if err != nil {
 		s.logger.Error("Failed to fetch student data",
 			zap.String("student_id", studentID),
@@ -78,12 +287,15 @@ func (s *StudentReportService) fetchStudentData(ctx context.Context, studentID s
 	return student, nil
 }
 
-func (s *StudentReportService) tryGetFromCache(studentID, contentHash string) []byte {
+func (s *StudentReportService) tryGetFromCache(ctx context.Context, studentID, contentHash string) []byte {
 	if s.pdfCache == nil {
 		return nil
 	}
 
+	_, span := tracing.Start(ctx, "cache.Get", attribute.String("student.id", studentID))
 	pdfData, found := s.pdfCache.Get(studentID, contentHash)
+	span.SetAttributes(attribute.Bool("cache.hit", found))
+	span.End()
 	if !found {
 		s.logger.Debug("Cache miss",
 			zap.String("student_id", studentID),
@@ -94,23 +306,137 @@ func (s *StudentReportService) tryGetFromCache(studentID, contentHash string) []
 	return pdfData
 }
 
-func (s *StudentReportService) generatePDF(student *dto.Student) ([]byte, error) {
-	pdfData, err := s.pdfGenerator.GenerateStudentReport(student)
+func (s *StudentReportService) renderReport(ctx context.Context, renderer ReportRenderer, student *dto.Student, tmpl *templates.Template, locale *i18n.Locale, issue ReportIssue) ([]byte, error) {
+	_, span := tracing.Start(ctx, "report.Render",
+		attribute.String("report.format", renderer.Format()),
+		attribute.String("report.template", tmpl.Name),
+		attribute.String("report.locale", locale.Tag))
+	data, err := renderer.GenerateStudentReport(student, tmpl, locale, issue)
+	span.SetAttributes(attribute.Int("report.size_bytes", len(data)))
+	tracing.End(span, err)
 	if err != nil {
-		s.logger.Error("PDF generation failed",
+		s.logger.Error("Report rendering failed",
 			zap.Int("student_id", student.ID),
+			zap.String("format", renderer.Format()),
 			zap.Error(err))
 		return nil, errors.NewPDFGenerationError(err)
 	}
-	return pdfData, nil
+	return data, nil
+}
+
+func (s *StudentReportService) signReport(student *dto.Student, data []byte) ([]byte, error) {
+	signed, err := s.signer.Sign(data)
+	if err != nil {
+		s.logger.Error("Report signing failed",
+			zap.Int("student_id", student.ID),
+			zap.Error(err))
+		return nil, errors.NewPDFGenerationError(err)
+	}
+	return signed, nil
+}
+
+// registerReport records the issued report with the hash of the exact bytes
+// served, so that the printed ID can be checked later
+func (s *StudentReportService) registerReport(issue ReportIssue, student *dto.Student, renderer ReportRenderer, tmpl *templates.Template, locale *i18n.Locale, data []byte) error {
+	if s.registry == nil {
+		return nil
+	}
+
+	hash := sha256.Sum256(data)
+	record := &registry.Record{
+		ID:          issue.ID,
+		StudentID:   student.ID,
+		Format:      renderer.Format(),
+		Template:    tmpl.Name,
+		Locale:      locale.Tag,
+		Profile:     issue.Profile,
+		IssuedAt:    issue.IssuedAt,
+		ContentHash: hex.EncodeToString(hash[:]),
+	}
+	if err := s.registry.Register(record); err != nil {
+		s.logger.Error("Failed to register report",
+			zap.Int("student_id", student.ID),
+			zap.String("report_id", issue.ID),
+			zap.Error(err))
+		return fmt.Errorf("failed to register report: %w", err)
+	}
+	return nil
 }
 
-func (s *StudentReportService) storePDFInCache(studentID, contentHash string, pdfData []byte) {
+// resolveTemplate looks up the named template, reporting unknown names as
+// validation errors. provider may be nil, in which case only the built-in
+// template is available.
+func resolveTemplate(provider TemplateProvider, name string) (*templates.Template, error) {
+	if provider == nil {
+		if name == "" || name == templates.DefaultName {
+			return templates.Default(), nil
+		}
+		return nil, &errors.ValidationError{Message: fmt.Sprintf("unknown template %q", name)}
+	}
+
+	tmpl, err := provider.Get(name)
+	if stderrors.Is(err, templates.ErrTemplateNotFound) {
+		return nil, &errors.ValidationError{Message: fmt.Sprintf("unknown template %q", name)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// resolveLocale looks up the requested language, English when tag is empty
+func resolveLocale(tag string) (*i18n.Locale, error) {
+	if tag == "" {
+		return i18n.Default(), nil
+	}
+
+	locale, ok := i18n.Lookup(tag)
+	if !ok {
+		return nil, &errors.ValidationError{Message: fmt.Sprintf("unsupported language %q", tag)}
+	}
+	return locale, nil
+}
+
+// roleProfiles are the least redacted profiles of the roles that may not
+// receive full reports
+var roleProfiles = map[auth.Role]string{
+	auth.RoleParent: redaction.Parent,
+}
+
+// resolveProfile looks up the requested redaction profile, or the default
+// one of the caller when name is empty. Callers may only request profiles
+// hiding at least what their default one hides.
+func resolveProfile(ctx context.Context, name string) (*redaction.Profile, error) {
+	least := redaction.Full
+	if principal, ok := auth.FromContext(ctx); ok {
+		if roleProfile, ok := roleProfiles[principal.Role]; ok {
+			least = roleProfile
+		}
+	}
+	required, _ := redaction.Lookup(least)
+	if name == "" {
+		return required, nil
+	}
+
+	profile, ok := redaction.Lookup(name)
+	if !ok {
+		return nil, &errors.ValidationError{Message: fmt.Sprintf("unknown redaction profile %q", name)}
+	}
+	if !profile.Covers(required) {
+		return nil, &errors.ForbiddenError{Resource: fmt.Sprintf("redaction profile %q", name)}
+	}
+	return profile, nil
+}
+
+func (s *StudentReportService) storePDFInCache(ctx context.Context, studentID, contentHash string, pdfData []byte) {
 	if s.pdfCache == nil {
 		return
 	}
 
-	if err := s.pdfCache.Set(studentID, pdfData, contentHash); err != nil {
+	_, span := tracing.Start(ctx, "cache.Set", attribute.String("student.id", studentID), attribute.Int("report.size_bytes", len(pdfData)))
+	err := s.pdfCache.Set(studentID, pdfData, contentHash)
+	tracing.End(span, err)
+	if err != nil {
 		s.logger.Warn("Failed to cache PDF (non-critical)",
 			zap.String("student_id", studentID),
 			zap.Error(err))
@@ -118,6 +444,16 @@ func (s *StudentReportService) storePDFInCache(studentID, contentHash string, pd
 	}
 }
 
-func (s *StudentReportService) buildFileName(studentID string) string {
-	return fmt.Sprintf("student_%s_report.pdf", studentID)
+func (s *StudentReportService) buildReport(studentID string, renderer ReportRenderer, locale *i18n.Locale, profile string, data []byte) *Report {
+	return &Report{
+		Data:        data,
+		FileName:    s.buildFileName(studentID, renderer.Format()),
+		ContentType: renderer.ContentType(),
+		Language:    locale.Tag,
+		Profile:     profile,
+	}
+}
+
+func (s *StudentReportService) buildFileName(studentID, format string) string {
+	return fmt.Sprintf("student_%s_report.%s", studentID, format)
 }
@@ -2,17 +2,31 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
 
+	"github.com/wbentaleb/student-report-service/internal/auth"
 	"github.com/wbentaleb/student-report-service/internal/cache"
 	"github.com/wbentaleb/student-report-service/internal/dto"
 	serviceErrors "github.com/wbentaleb/student-report-service/internal/errors"
+	"github.com/wbentaleb/student-report-service/internal/i18n"
+	"github.com/wbentaleb/student-report-service/internal/redaction"
+	"github.com/wbentaleb/student-report-service/internal/registry"
+	"github.com/wbentaleb/student-report-service/internal/templates"
+	"github.com/wbentaleb/student-report-service/internal/tracing"
 )
 
 // Mock implementations
@@ -28,6 +42,14 @@ func (m *MockBackendService) GetStudent(ctx context.Context, id string) (*dto.St
 	return args.Get(0).(*dto.Student), args.Error(1)
 }
 
+func (m *MockBackendService) ListStudents(ctx context.Context, filter dto.StudentFilter) ([]dto.StudentSummary, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dto.StudentSummary), args.Error(1)
+}
+
 func (m *MockBackendService) CheckHealth(ctx context.Context) bool {
 	args := m.Called(ctx)
 	return args.Bool(0)
@@ -37,14 +59,55 @@ type MockPDFGenerator struct {
 	mock.Mock
 }
 
-func (m *MockPDFGenerator) GenerateStudentReport(student *dto.Student) ([]byte, error) {
-	args := m.Called(student)
+func (m *MockPDFGenerator) GenerateStudentReport(student *dto.Student, tmpl *templates.Template, locale *i18n.Locale, issue ReportIssue) ([]byte, error) {
+	args := m.Called(student, tmpl, locale, issue)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+func (m *MockPDFGenerator) Format() string {
+	return FormatPDF
+}
+
+func (m *MockPDFGenerator) ContentType() string {
+	return "application/pdf"
+}
+
+type MockPDFSigner struct {
+	mock.Mock
+}
+
+func (m *MockPDFSigner) Sign(pdf []byte) ([]byte, error) {
+	args := m.Called(pdf)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *MockPDFSigner) Fingerprint() string {
+	return "0a1b2c3d"
+}
+
+type MockReportRegistry struct {
+	mock.Mock
+}
+
+func (m *MockReportRegistry) Register(record *registry.Record) error {
+	args := m.Called(record)
+	return args.Error(0)
+}
+
+func (m *MockReportRegistry) Get(id string) (*registry.Record, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*registry.Record), args.Error(1)
+}
+
 type MockPDFCache struct {
 	mock.Mock
 }
@@ -62,6 +125,34 @@ func (m *MockPDFCache) Set(studentID string, data []byte, hash string) error {
 	return args.Error(0)
 }
 
+func (m *MockPDFCache) Delete(studentID string) error {
+	args := m.Called(studentID)
+	return args.Error(0)
+}
+
+func (m *MockPDFCache) Purge() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockPDFCache) Entries() ([]cache.Entry, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]cache.Entry), args.Error(1)
+}
+
+func (m *MockPDFCache) LatestVersion(studentID string) (string, bool) {
+	args := m.Called(studentID)
+	return args.String(0), args.Bool(1)
+}
+
+func (m *MockPDFCache) Stats() cache.Stats {
+	args := m.Called()
+	return args.Get(0).(cache.Stats)
+}
+
 // Test helper functions
 func createTestStudent() *dto.Student {
 	return &dto.Student{
@@ -90,17 +181,25 @@ func createTestStudent() *dto.Student {
 	}
 }
 
+func testIssue() ReportIssue {
+	return ReportIssue{
+		ID:              "SR-12345-1700000000-0a1b2c3d",
+		IssuedAt:        time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC),
+		VerificationURL: "https://reports.example.com/api/v1/reports/verify/SR-12345-1700000000-0a1b2c3d",
+	}
+}
+
 func TestNewStudentReportService(t *testing.T) {
 	logger := zap.NewNop()
 	mockBackend := new(MockBackendService)
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	assert.NotNil(t, service)
 	assert.Equal(t, mockBackend, service.backendClient)
-	assert.Equal(t, mockPDFGen, service.pdfGenerator)
+	assert.Equal(t, mockPDFGen, service.renderers[FormatPDF])
 	assert.Equal(t, mockCache, service.pdfCache)
 	assert.Equal(t, logger, service.logger)
 }
@@ -112,7 +211,7 @@ func TestGenerateStudentReport_Success_WithCache(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	ctx := context.Background()
 	studentID := "12345"
@@ -120,19 +219,20 @@ func TestGenerateStudentReport_Success_WithCache(t *testing.T) {
 	cachedPDF := []byte("cached pdf content")
 
 	// Setup mocks
-	mockBackend.On("GetStudent", ctx, studentID).Return(student, nil)
+	mockBackend.On("GetStudent", mock.Anything, studentID).Return(student, nil)
 
 	// Calculate the expected hash
-	contentHash := cache.GenerateStudentHash(student)
+	contentHash := cache.VariantKey(cache.GenerateStudentHash(student), templates.Default().CacheKey(), FormatPDF, i18n.Default().CacheKey(), "profile-full")
 	mockCache.On("Get", studentID, contentHash).Return(cachedPDF, true)
 
 	// Execute
-	pdfData, fileName, err := service.GenerateStudentReport(ctx, studentID)
+	report, err := service.GenerateStudentReport(ctx, studentID, ReportOptions{})
 
 	// Assert
 	require.NoError(t, err)
-	assert.Equal(t, cachedPDF, pdfData)
-	assert.Equal(t, "student_12345_report.pdf", fileName)
+	assert.Equal(t, cachedPDF, report.Data)
+	assert.Equal(t, "student_12345_report.pdf", report.FileName)
+	assert.Equal(t, "application/pdf", report.ContentType)
 
 	// Verify mock expectations
 	mockBackend.AssertExpectations(t)
@@ -147,7 +247,7 @@ func TestGenerateStudentReport_Success_WithoutCache(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	ctx := context.Background()
 	studentID := "12345"
@@ -155,21 +255,22 @@ func TestGenerateStudentReport_Success_WithoutCache(t *testing.T) {
 	generatedPDF := []byte("generated pdf content")
 
 	// Setup mocks
-	mockBackend.On("GetStudent", ctx, studentID).Return(student, nil)
+	mockBackend.On("GetStudent", mock.Anything, studentID).Return(student, nil)
 
 	// Calculate the expected hash
-	contentHash := cache.GenerateStudentHash(student)
+	contentHash := cache.VariantKey(cache.GenerateStudentHash(student), templates.Default().CacheKey(), FormatPDF, i18n.Default().CacheKey(), "profile-full")
 	mockCache.On("Get", studentID, contentHash).Return(nil, false)
-	mockPDFGen.On("GenerateStudentReport", student).Return(generatedPDF, nil)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return(generatedPDF, nil)
 	mockCache.On("Set", studentID, generatedPDF, contentHash).Return(nil)
 
 	// Execute
-	pdfData, fileName, err := service.GenerateStudentReport(ctx, studentID)
+	report, err := service.GenerateStudentReport(ctx, studentID, ReportOptions{})
 
 	// Assert
 	require.NoError(t, err)
-	assert.Equal(t, generatedPDF, pdfData)
-	assert.Equal(t, "student_12345_report.pdf", fileName)
+	assert.Equal(t, generatedPDF, report.Data)
+	assert.Equal(t, "student_12345_report.pdf", report.FileName)
+	assert.Equal(t, "application/pdf", report.ContentType)
 
 	// Verify mock expectations
 	mockBackend.AssertExpectations(t)
@@ -184,7 +285,7 @@ func TestGenerateStudentReport_Success_NilCache(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 
 	// Create service without cache
-	service := NewStudentReportService(mockBackend, mockPDFGen, nil, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, nil, nil, nil, false, logger)
 
 	ctx := context.Background()
 	studentID := "12345"
@@ -192,16 +293,17 @@ func TestGenerateStudentReport_Success_NilCache(t *testing.T) {
 	generatedPDF := []byte("generated pdf content")
 
 	// Setup mocks
-	mockBackend.On("GetStudent", ctx, studentID).Return(student, nil)
-	mockPDFGen.On("GenerateStudentReport", student).Return(generatedPDF, nil)
+	mockBackend.On("GetStudent", mock.Anything, studentID).Return(student, nil)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return(generatedPDF, nil)
 
 	// Execute
-	pdfData, fileName, err := service.GenerateStudentReport(ctx, studentID)
+	report, err := service.GenerateStudentReport(ctx, studentID, ReportOptions{})
 
 	// Assert
 	require.NoError(t, err)
-	assert.Equal(t, generatedPDF, pdfData)
-	assert.Equal(t, "student_12345_report.pdf", fileName)
+	assert.Equal(t, generatedPDF, report.Data)
+	assert.Equal(t, "student_12345_report.pdf", report.FileName)
+	assert.Equal(t, "application/pdf", report.ContentType)
 
 	// Verify mock expectations
 	mockBackend.AssertExpectations(t)
@@ -215,23 +317,22 @@ func TestGenerateStudentReport_BackendError(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	ctx := context.Background()
 	studentID := "12345"
 	backendErr := errors.New("backend service error")
 
 	// Setup mocks
-	mockBackend.On("GetStudent", ctx, studentID).Return(nil, backendErr)
+	mockBackend.On("GetStudent", mock.Anything, studentID).Return(nil, backendErr)
 
 	// Execute
-	pdfData, fileName, err := service.GenerateStudentReport(ctx, studentID)
+	report, err := service.GenerateStudentReport(ctx, studentID, ReportOptions{})
 
 	// Assert
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to fetch student data")
-	assert.Nil(t, pdfData)
-	assert.Empty(t, fileName)
+	assert.Nil(t, report)
 
 	// Verify mock expectations
 	mockBackend.AssertExpectations(t)
@@ -239,6 +340,316 @@ func TestGenerateStudentReport_BackendError(t *testing.T) {
 	mockPDFGen.AssertNotCalled(t, "GenerateStudentReport")
 }
 
+func TestGenerateStudentReport_SelectsRendererByFormat(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	mockCache := new(MockPDFCache)
+
+	renderers := []ReportRenderer{mockPDFGen, NewCSVRenderer(logger)}
+	service := NewStudentReportService(mockBackend, renderers, mockCache, nil, nil, nil, nil, false, logger)
+
+	ctx := context.Background()
+	studentID := "12345"
+	student := createTestStudent()
+
+	// Setup mocks: the CSV variant has its own cache key
+	mockBackend.On("GetStudent", mock.Anything, studentID).Return(student, nil)
+	contentHash := cache.VariantKey(cache.GenerateStudentHash(student), templates.Default().CacheKey(), FormatCSV, i18n.Default().CacheKey(), "profile-full")
+	mockCache.On("Get", studentID, contentHash).Return(nil, false)
+	mockCache.On("Set", studentID, mock.Anything, contentHash).Return(nil)
+
+	// Execute
+	report, err := service.GenerateStudentReport(ctx, studentID, ReportOptions{Format: FormatCSV})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "student_12345_report.csv", report.FileName)
+	assert.Equal(t, "text/csv; charset=utf-8", report.ContentType)
+	assert.Contains(t, string(report.Data), "John Doe")
+
+	mockCache.AssertExpectations(t)
+	mockPDFGen.AssertNotCalled(t, "GenerateStudentReport")
+}
+
+func TestGenerateStudentReport_UnsupportedFormat(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, nil, nil, nil, false, logger)
+
+	// Execute
+	report, err := service.GenerateStudentReport(context.Background(), "12345", ReportOptions{Format: "docx"})
+
+	// Assert
+	require.Error(t, err)
+	assert.True(t, serviceErrors.IsValidationError(err))
+	assert.Nil(t, report)
+	mockBackend.AssertNotCalled(t, "GetStudent")
+}
+
+func TestGenerateStudentReport_LocaleSelectsCatalogAndCacheKey(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	mockCache := new(MockPDFCache)
+
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
+
+	ctx := context.Background()
+	studentID := "12345"
+	student := createTestStudent()
+	french, _ := i18n.Lookup("fr")
+	generatedPDF := []byte("%PDF-1.4 fr")
+
+	// Setup mocks: the French variant never shares the English cache entry
+	mockBackend.On("GetStudent", mock.Anything, studentID).Return(student, nil)
+	contentHash := cache.VariantKey(cache.GenerateStudentHash(student), templates.Default().CacheKey(), FormatPDF, french.CacheKey(), "profile-full")
+	mockCache.On("Get", studentID, contentHash).Return(nil, false)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), french, mock.Anything).Return(generatedPDF, nil)
+	mockCache.On("Set", studentID, generatedPDF, contentHash).Return(nil)
+
+	// Execute
+	report, err := service.GenerateStudentReport(ctx, studentID, ReportOptions{Locale: "fr-CA"})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, generatedPDF, report.Data)
+	assert.Equal(t, "fr", report.Language)
+	mockCache.AssertExpectations(t)
+	mockPDFGen.AssertExpectations(t)
+}
+
+func TestGenerateStudentReport_Signed(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	mockCache := new(MockPDFCache)
+	mockSigner := new(MockPDFSigner)
+
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, mockSigner, nil, nil, false, logger)
+
+	ctx := context.Background()
+	studentID := "12345"
+	student := createTestStudent()
+	generatedPDF := []byte("%PDF-1.4 unsigned")
+	signedPDF := []byte("%PDF-1.4 signed")
+
+	// Setup mocks: signed reports are cached per signing certificate
+	mockBackend.On("GetStudent", mock.Anything, studentID).Return(student, nil)
+	contentHash := cache.VariantKey(cache.GenerateStudentHash(student), templates.Default().CacheKey(), FormatPDF, i18n.Default().CacheKey(), "profile-full", "signed-0a1b2c3d")
+	mockCache.On("Get", studentID, contentHash).Return(nil, false)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return(generatedPDF, nil)
+	mockSigner.On("Sign", generatedPDF).Return(signedPDF, nil)
+	mockCache.On("Set", studentID, signedPDF, contentHash).Return(nil)
+
+	// Execute
+	report, err := service.GenerateStudentReport(ctx, studentID, ReportOptions{})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, signedPDF, report.Data)
+	mockCache.AssertExpectations(t)
+	mockSigner.AssertExpectations(t)
+}
+
+func TestGenerateStudentReport_UnsignedOption(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	mockSigner := new(MockPDFSigner)
+
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, mockSigner, nil, nil, false, logger)
+
+	ctx := context.Background()
+	student := createTestStudent()
+	generatedPDF := []byte("%PDF-1.4 unsigned")
+
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(student, nil)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return(generatedPDF, nil)
+
+	// Execute
+	report, err := service.GenerateStudentReport(ctx, "12345", ReportOptions{Unsigned: true})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, generatedPDF, report.Data)
+	mockSigner.AssertNotCalled(t, "Sign", mock.Anything)
+}
+
+func TestGenerateStudentReport_SigningError(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	mockCache := new(MockPDFCache)
+	mockSigner := new(MockPDFSigner)
+
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, mockSigner, nil, nil, false, logger)
+
+	ctx := context.Background()
+	student := createTestStudent()
+	generatedPDF := []byte("%PDF-1.4 unsigned")
+
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(student, nil)
+	mockCache.On("Get", "12345", mock.Anything).Return(nil, false)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return(generatedPDF, nil)
+	mockSigner.On("Sign", generatedPDF).Return(nil, errors.New("signature too large"))
+
+	// Execute
+	report, err := service.GenerateStudentReport(ctx, "12345", ReportOptions{})
+
+	// Assert
+	require.Error(t, err)
+	assert.IsType(t, &serviceErrors.PDFGenerationError{}, err)
+	assert.Nil(t, report)
+	mockCache.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGenerateStudentReport_RegistersIssuedReport(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	mockRegistry := new(MockReportRegistry)
+
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, nil, mockRegistry, NewReportIssuer(nil, false, "https://reports.example.com/api/v1/reports/verify/"), false, logger)
+
+	ctx := context.Background()
+	student := createTestStudent()
+	generatedPDF := []byte("generated pdf content")
+
+	var issue ReportIssue
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(student, nil)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).
+		Run(func(args mock.Arguments) { issue = args.Get(3).(ReportIssue) }).
+		Return(generatedPDF, nil)
+	mockRegistry.On("Register", mock.Anything).Return(nil)
+
+	// Execute
+	_, err := service.GenerateStudentReport(ctx, "12345", ReportOptions{})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Regexp(t, `^SR-12345-[0-9]+-[0-9a-f]{8}$`, issue.ID)
+	assert.Equal(t, "https://reports.example.com/api/v1/reports/verify/"+issue.ID, issue.VerificationURL)
+
+	record := mockRegistry.Calls[0].Arguments.Get(0).(*registry.Record)
+	hash := sha256.Sum256(generatedPDF)
+	assert.Equal(t, issue.ID, record.ID)
+	assert.Equal(t, 12345, record.StudentID)
+	assert.Equal(t, FormatPDF, record.Format)
+	assert.Equal(t, templates.DefaultName, record.Template)
+	assert.Equal(t, i18n.Default().Tag, record.Locale)
+	assert.Equal(t, issue.IssuedAt, record.IssuedAt)
+	assert.Equal(t, hex.EncodeToString(hash[:]), record.ContentHash)
+}
+
+func TestGenerateStudentReport_DeterministicIssue(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	issuer := NewReportIssuer(fixedClock{now: time.Now()}, true, "")
+
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, nil, nil, issuer, false, logger)
+
+	ctx := context.Background()
+	student := createTestStudent()
+
+	var issues []ReportIssue
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(student, nil)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).
+		Run(func(args mock.Arguments) { issues = append(issues, args.Get(3).(ReportIssue)) }).
+		Return([]byte("pdf"), nil)
+
+	// Execute
+	for i := 0; i < 2; i++ {
+		_, err := service.GenerateStudentReport(ctx, "12345", ReportOptions{})
+		require.NoError(t, err)
+	}
+
+	// Assert
+	require.Len(t, issues, 2)
+	assert.Equal(t, issues[0], issues[1])
+	assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), issues[0].IssuedAt)
+}
+
+func TestGenerateStudentReport_RegistryError(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	mockCache := new(MockPDFCache)
+	mockRegistry := new(MockReportRegistry)
+
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, mockRegistry, nil, false, logger)
+
+	ctx := context.Background()
+	student := createTestStudent()
+
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(student, nil)
+	mockCache.On("Get", "12345", mock.Anything).Return(nil, false)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return([]byte("pdf"), nil)
+	mockRegistry.On("Register", mock.Anything).Return(errors.New("disk full"))
+
+	// Execute
+	report, err := service.GenerateStudentReport(ctx, "12345", ReportOptions{})
+
+	// Assert
+	require.Error(t, err)
+	assert.Nil(t, report)
+	mockCache.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGenerateStudentReport_UnsupportedLanguage(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, nil, nil, nil, false, logger)
+
+	// Execute
+	report, err := service.GenerateStudentReport(context.Background(), "12345", ReportOptions{Locale: "de"})
+
+	// Assert
+	require.Error(t, err)
+	assert.True(t, serviceErrors.IsValidationError(err))
+	assert.Nil(t, report)
+	mockBackend.AssertNotCalled(t, "GetStudent")
+}
+
+func TestGenerateStudentReport_UnknownTemplate(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	mockCache := new(MockPDFCache)
+
+	registry, err := templates.NewRegistry("", false, false, logger)
+	require.NoError(t, err)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, registry, nil, nil, nil, false, logger)
+
+	// Execute
+	report, err := service.GenerateStudentReport(context.Background(), "12345", ReportOptions{Template: "missing"})
+
+	// Assert
+	require.Error(t, err)
+	assert.True(t, serviceErrors.IsValidationError(err))
+	assert.Nil(t, report)
+
+	// Verify the backend is not queried for an invalid request
+	mockBackend.AssertNotCalled(t, "GetStudent")
+	mockPDFGen.AssertNotCalled(t, "GenerateStudentReport")
+}
+
 func TestGenerateStudentReport_PDFGenerationError(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
@@ -246,7 +657,7 @@ func TestGenerateStudentReport_PDFGenerationError(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	ctx := context.Background()
 	studentID := "12345"
@@ -254,21 +665,20 @@ func TestGenerateStudentReport_PDFGenerationError(t *testing.T) {
 	pdfGenErr := errors.New("pdf generation failed")
 
 	// Setup mocks
-	mockBackend.On("GetStudent", ctx, studentID).Return(student, nil)
+	mockBackend.On("GetStudent", mock.Anything, studentID).Return(student, nil)
 
 	// Calculate the expected hash
-	contentHash := cache.GenerateStudentHash(student)
+	contentHash := cache.VariantKey(cache.GenerateStudentHash(student), templates.Default().CacheKey(), FormatPDF, i18n.Default().CacheKey(), "profile-full")
 	mockCache.On("Get", studentID, contentHash).Return(nil, false)
-	mockPDFGen.On("GenerateStudentReport", student).Return(nil, pdfGenErr)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return(nil, pdfGenErr)
 
 	// Execute
-	pdfData, fileName, err := service.GenerateStudentReport(ctx, studentID)
+	report, err := service.GenerateStudentReport(ctx, studentID, ReportOptions{})
 
 	// Assert
 	require.Error(t, err)
 	assert.IsType(t, &serviceErrors.PDFGenerationError{}, err)
-	assert.Nil(t, pdfData)
-	assert.Empty(t, fileName)
+	assert.Nil(t, report)
 
 	// Verify mock expectations
 	mockBackend.AssertExpectations(t)
@@ -283,7 +693,7 @@ func TestGenerateStudentReport_CacheSetError(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	ctx := context.Background()
 	studentID := "12345"
@@ -292,21 +702,22 @@ func TestGenerateStudentReport_CacheSetError(t *testing.T) {
 	cacheErr := errors.New("cache write failed")
 
 	// Setup mocks
-	mockBackend.On("GetStudent", ctx, studentID).Return(student, nil)
+	mockBackend.On("GetStudent", mock.Anything, studentID).Return(student, nil)
 
 	// Calculate the expected hash
-	contentHash := cache.GenerateStudentHash(student)
+	contentHash := cache.VariantKey(cache.GenerateStudentHash(student), templates.Default().CacheKey(), FormatPDF, i18n.Default().CacheKey(), "profile-full")
 	mockCache.On("Get", studentID, contentHash).Return(nil, false)
-	mockPDFGen.On("GenerateStudentReport", student).Return(generatedPDF, nil)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return(generatedPDF, nil)
 	mockCache.On("Set", studentID, generatedPDF, contentHash).Return(cacheErr)
 
 	// Execute
-	pdfData, fileName, err := service.GenerateStudentReport(ctx, studentID)
+	report, err := service.GenerateStudentReport(ctx, studentID, ReportOptions{})
 
 	// Assert - should still succeed despite cache error
 	require.NoError(t, err)
-	assert.Equal(t, generatedPDF, pdfData)
-	assert.Equal(t, "student_12345_report.pdf", fileName)
+	assert.Equal(t, generatedPDF, report.Data)
+	assert.Equal(t, "student_12345_report.pdf", report.FileName)
+	assert.Equal(t, "application/pdf", report.ContentType)
 
 	// Verify mock expectations
 	mockBackend.AssertExpectations(t)
@@ -321,17 +732,17 @@ func TestFetchStudentData_Success(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	ctx := context.Background()
 	studentID := "12345"
 	expectedStudent := createTestStudent()
 
 	// Setup mocks
-	mockBackend.On("GetStudent", ctx, studentID).Return(expectedStudent, nil)
+	mockBackend.On("GetStudent", mock.Anything, studentID).Return(expectedStudent, nil)
 
 	// Execute
-	student, err := service.fetchStudentData(ctx, studentID)
+	student, err := service.fetchStudentData(ctx, studentID, false)
 
 	// Assert
 	require.NoError(t, err)
@@ -348,17 +759,17 @@ func TestFetchStudentData_Error(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	ctx := context.Background()
 	studentID := "12345"
 	backendErr := errors.New("backend error")
 
 	// Setup mocks
-	mockBackend.On("GetStudent", ctx, studentID).Return(nil, backendErr)
+	mockBackend.On("GetStudent", mock.Anything, studentID).Return(nil, backendErr)
 
 	// Execute
-	student, err := service.fetchStudentData(ctx, studentID)
+	student, err := service.fetchStudentData(ctx, studentID, false)
 
 	// Assert
 	require.Error(t, err)
@@ -376,7 +787,7 @@ func TestTryGetFromCache_Found(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	studentID := "12345"
 	contentHash := "abcd1234"
@@ -386,7 +797,7 @@ func TestTryGetFromCache_Found(t *testing.T) {
 	mockCache.On("Get", studentID, contentHash).Return(cachedPDF, true)
 
 	// Execute
-	result := service.tryGetFromCache(studentID, contentHash)
+	result := service.tryGetFromCache(context.Background(), studentID, contentHash)
 
 	// Assert
 	assert.Equal(t, cachedPDF, result)
@@ -402,7 +813,7 @@ func TestTryGetFromCache_NotFound(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	studentID := "12345"
 	contentHash := "abcd1234"
@@ -411,7 +822,7 @@ func TestTryGetFromCache_NotFound(t *testing.T) {
 	mockCache.On("Get", studentID, contentHash).Return(nil, false)
 
 	// Execute
-	result := service.tryGetFromCache(studentID, contentHash)
+	result := service.tryGetFromCache(context.Background(), studentID, contentHash)
 
 	// Assert
 	assert.Nil(t, result)
@@ -426,32 +837,32 @@ func TestTryGetFromCache_NilCache(t *testing.T) {
 	mockBackend := new(MockBackendService)
 	mockPDFGen := new(MockPDFGenerator)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, nil, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, nil, nil, nil, false, logger)
 
 	// Execute
-	result := service.tryGetFromCache("12345", "abcd1234")
+	result := service.tryGetFromCache(context.Background(), "12345", "abcd1234")
 
 	// Assert
 	assert.Nil(t, result)
 }
 
-func TestGeneratePDF_Success(t *testing.T) {
+func TestRenderReport_Success(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
 	mockBackend := new(MockBackendService)
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	student := createTestStudent()
 	expectedPDF := []byte("generated pdf content")
 
 	// Setup mocks
-	mockPDFGen.On("GenerateStudentReport", student).Return(expectedPDF, nil)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return(expectedPDF, nil)
 
 	// Execute
-	pdfData, err := service.generatePDF(student)
+	pdfData, err := service.renderReport(context.Background(), mockPDFGen, student, templates.Default(), i18n.Default(), testIssue())
 
 	// Assert
 	require.NoError(t, err)
@@ -461,23 +872,23 @@ func TestGeneratePDF_Success(t *testing.T) {
 	mockPDFGen.AssertExpectations(t)
 }
 
-func TestGeneratePDF_Error(t *testing.T) {
+func TestRenderReport_Error(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
 	mockBackend := new(MockBackendService)
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	student := createTestStudent()
 	pdfErr := errors.New("pdf generation failed")
 
 	// Setup mocks
-	mockPDFGen.On("GenerateStudentReport", student).Return(nil, pdfErr)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return(nil, pdfErr)
 
 	// Execute
-	pdfData, err := service.generatePDF(student)
+	pdfData, err := service.renderReport(context.Background(), mockPDFGen, student, templates.Default(), i18n.Default(), testIssue())
 
 	// Assert
 	require.Error(t, err)
@@ -495,7 +906,7 @@ func TestStorePDFInCache_Success(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	studentID := "12345"
 	contentHash := "abcd1234"
@@ -505,7 +916,7 @@ func TestStorePDFInCache_Success(t *testing.T) {
 	mockCache.On("Set", studentID, pdfData, contentHash).Return(nil)
 
 	// Execute
-	service.storePDFInCache(studentID, contentHash, pdfData)
+	service.storePDFInCache(context.Background(), studentID, contentHash, pdfData)
 
 	// Verify mock expectations
 	mockCache.AssertExpectations(t)
@@ -518,7 +929,7 @@ func TestStorePDFInCache_Error(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	studentID := "12345"
 	contentHash := "abcd1234"
@@ -529,7 +940,7 @@ func TestStorePDFInCache_Error(t *testing.T) {
 	mockCache.On("Set", studentID, pdfData, contentHash).Return(cacheErr)
 
 	// Execute - should not panic
-	service.storePDFInCache(studentID, contentHash, pdfData)
+	service.storePDFInCache(context.Background(), studentID, contentHash, pdfData)
 
 	// Verify mock expectations
 	mockCache.AssertExpectations(t)
@@ -541,10 +952,10 @@ func TestStorePDFInCache_NilCache(t *testing.T) {
 	mockBackend := new(MockBackendService)
 	mockPDFGen := new(MockPDFGenerator)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, nil, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, nil, nil, nil, false, logger)
 
 	// Execute - should not panic
-	service.storePDFInCache("12345", "abcd1234", []byte("pdf content"))
+	service.storePDFInCache(context.Background(), "12345", "abcd1234", []byte("pdf content"))
 }
 
 func TestBuildFileName(t *testing.T) {
@@ -554,7 +965,7 @@ func TestBuildFileName(t *testing.T) {
 	mockPDFGen := new(MockPDFGenerator)
 	mockCache := new(MockPDFCache)
 
-	service := NewStudentReportService(mockBackend, mockPDFGen, mockCache, logger)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, logger)
 
 	testCases := []struct {
 		name      string
@@ -580,8 +991,324 @@ func TestBuildFileName(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			fileName := service.buildFileName(tc.studentID)
+			fileName := service.buildFileName(tc.studentID, FormatPDF)
 			assert.Equal(t, tc.expected, fileName)
 		})
 	}
 }
+
+// generateConcurrently starts n report requests for the same student and
+// returns their results once all have completed
+func generateConcurrently(service *StudentReportService, n int) ([]*Report, []error) {
+	reports := make([]*Report, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reports[i], errs[i] = service.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+		}(i)
+	}
+	wg.Wait()
+	return reports, errs
+}
+
+// waiting returns the number of callers waiting for the call in flight for
+// key
+func (g *flightGroup) waiting(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if f, ok := g.flights[key]; ok {
+		return f.waiters
+	}
+	return 0
+}
+
+func TestGenerateStudentReport_CoalescesConcurrentCalls(t *testing.T) {
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	mockCache := new(MockPDFCache)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, zap.NewNop())
+
+	// the backend and the renderer take long enough for all callers to join
+	// the in-flight call
+	student := createTestStudent()
+	generatedPDF := []byte("generated pdf content")
+	mockBackend.On("GetStudent", mock.Anything, "12345").
+		Run(func(mock.Arguments) { time.Sleep(100 * time.Millisecond) }).
+		Return(student, nil)
+	mockCache.On("Get", "12345", mock.Anything).Return(nil, false)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).
+		Run(func(mock.Arguments) { time.Sleep(100 * time.Millisecond) }).
+		Return(generatedPDF, nil)
+	mockCache.On("Set", "12345", generatedPDF, mock.Anything).Return(nil)
+
+	const callers = 5
+	reports, errs := generateConcurrently(service, callers)
+
+	for i := 0; i < callers; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, generatedPDF, reports[i].Data)
+		assert.Equal(t, "student_12345_report.pdf", reports[i].FileName)
+	}
+	mockBackend.AssertNumberOfCalls(t, "GetStudent", 1)
+	mockCache.AssertNumberOfCalls(t, "Get", 1)
+	mockPDFGen.AssertNumberOfCalls(t, "GenerateStudentReport", 1)
+	mockCache.AssertNumberOfCalls(t, "Set", 1)
+	assert.Equal(t, CoalescingStats{DeduplicatedFetches: callers - 1, DeduplicatedRenders: callers - 1}, service.CoalescingStats())
+}
+
+func TestGenerateStudentReport_CoalescedCallsShareError(t *testing.T) {
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, nil, nil, nil, false, zap.NewNop())
+
+	student := createTestStudent()
+	mockBackend.On("GetStudent", mock.Anything, "12345").
+		Run(func(mock.Arguments) { time.Sleep(100 * time.Millisecond) }).
+		Return(student, nil)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).
+		Run(func(mock.Arguments) { time.Sleep(100 * time.Millisecond) }).
+		Return(nil, errors.New("pdf generation failed"))
+
+	const callers = 3
+	reports, errs := generateConcurrently(service, callers)
+
+	for i := 0; i < callers; i++ {
+		var pdfErr *serviceErrors.PDFGenerationError
+		require.ErrorAs(t, errs[i], &pdfErr)
+		assert.Nil(t, reports[i])
+	}
+	mockPDFGen.AssertNumberOfCalls(t, "GenerateStudentReport", 1)
+	assert.Equal(t, uint64(callers-1), service.CoalescingStats().DeduplicatedRenders)
+}
+
+func TestGenerateStudentReport_CancelledCallerStopsWaiting(t *testing.T) {
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, nil, nil, nil, false, zap.NewNop())
+
+	// the backend call is cancelled once its only caller has left
+	fetched := make(chan error, 1)
+	mockBackend.On("GetStudent", mock.Anything, "12345").
+		Run(func(args mock.Arguments) {
+			ctx := args.Get(0).(context.Context)
+			<-ctx.Done()
+			fetched <- ctx.Err()
+		}).
+		Return(nil, context.Canceled)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	report, err := service.GenerateStudentReport(ctx, "12345", ReportOptions{})
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, report)
+	assert.ErrorIs(t, <-fetched, context.Canceled)
+}
+
+func TestGenerateStudentReport_CoalescedCallOutlivesFirstCaller(t *testing.T) {
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, nil, nil, nil, false, zap.NewNop())
+
+	student := createTestStudent()
+	generatedPDF := []byte("generated pdf content")
+	started, release := make(chan struct{}), make(chan struct{})
+	fetched := make(chan error, 1)
+	mockBackend.On("GetStudent", mock.Anything, "12345").
+		Run(func(args mock.Arguments) {
+			close(started)
+			<-release
+			fetched <- args.Get(0).(context.Context).Err()
+		}).
+		Return(student, nil).Once()
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return(generatedPDF, nil)
+
+	// the first caller starts the fetch and leaves while a second one waits
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := service.GenerateStudentReport(firstCtx, "12345", ReportOptions{})
+		firstErr <- err
+	}()
+	<-started
+
+	second := make(chan *Report, 1)
+	go func() {
+		report, err := service.GenerateStudentReport(context.Background(), "12345", ReportOptions{})
+		assert.NoError(t, err)
+		second <- report
+	}()
+	require.Eventually(t, func() bool {
+		return service.flights.waiting("fetch:12345") == 2
+	}, time.Second, time.Millisecond)
+
+	cancelFirst()
+	require.ErrorIs(t, <-firstErr, context.Canceled)
+	close(release)
+
+	assert.NoError(t, <-fetched)
+	report := <-second
+	require.NotNil(t, report)
+	assert.Equal(t, generatedPDF, report.Data)
+	mockBackend.AssertNumberOfCalls(t, "GetStudent", 1)
+}
+
+// recordSpans installs a tracer provider keeping the ended spans in memory
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+func TestGenerateStudentReport_TracesCacheAndRendering(t *testing.T) {
+	exporter := recordSpans(t)
+	pdfCache, err := cache.NewMemoryCache(1<<20, time.Hour)
+	require.NoError(t, err)
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, pdfCache, nil, nil, nil, nil, false, zap.NewNop())
+
+	student := createTestStudent()
+	mockBackend.On("GetStudent", mock.Anything, "12345").Return(student, nil)
+	mockPDFGen.On("GenerateStudentReport", student, templates.Default(), i18n.Default(), mock.Anything).Return([]byte("pdf content"), nil).Once()
+
+	ctx, request := tracing.Start(context.Background(), "request")
+	_, err = service.GenerateStudentReport(ctx, "12345", ReportOptions{})
+	require.NoError(t, err)
+	_, err = service.GenerateStudentReport(ctx, "12345", ReportOptions{})
+	require.NoError(t, err)
+	request.End()
+
+	spans := exporter.GetSpans()
+	var names []string
+	for _, span := range spans[:len(spans)-1] {
+		names = append(names, span.Name)
+		assert.Equal(t, request.SpanContext().SpanID(), span.Parent.SpanID(), span.Name)
+	}
+	assert.Equal(t, []string{"cache.Get", "report.Render", "cache.Set", "cache.Get"}, names)
+	assert.Contains(t, spans[0].Attributes, attribute.Bool("cache.hit", false))
+	assert.Contains(t, spans[1].Attributes, attribute.String("report.format", FormatPDF))
+	assert.Contains(t, spans[1].Attributes, attribute.Int("report.size_bytes", len("pdf content")))
+	assert.Contains(t, spans[3].Attributes, attribute.Bool("cache.hit", true))
+}
+
+func TestGenerateStudentReport_AuthorizesCaller(t *testing.T) {
+	testCases := []struct {
+		name      string
+		principal *auth.Principal
+		allowed   bool
+	}{
+		{name: "admin", principal: &auth.Principal{Subject: "a1", Role: auth.RoleAdmin}, allowed: true},
+		{name: "teacher of the class", principal: &auth.Principal{Subject: "t1", Role: auth.RoleTeacher, Classes: []string{"9", "10"}}, allowed: true},
+		{name: "teacher of another class", principal: &auth.Principal{Subject: "t2", Role: auth.RoleTeacher, Classes: []string{"9"}}},
+		{name: "parent of the student", principal: &auth.Principal{Subject: "p1", Role: auth.RoleParent, StudentIDs: []string{"12345"}}, allowed: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockBackend := new(MockBackendService)
+			mockPDFGen := new(MockPDFGenerator)
+			service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, nil, nil, nil, false, zap.NewNop())
+
+			student := createTestStudent()
+			mockBackend.On("GetStudent", mock.Anything, "12345").Return(student, nil)
+			// parents are served a redacted report
+			mockPDFGen.On("GenerateStudentReport", mock.Anything, mock.Anything, i18n.Default(), mock.Anything).Return([]byte("pdf"), nil)
+
+			report, err := service.GenerateStudentReport(auth.NewContext(context.Background(), tc.principal), "12345", ReportOptions{})
+
+			if tc.allowed {
+				require.NoError(t, err)
+				assert.Equal(t, []byte("pdf"), report.Data)
+				return
+			}
+			assert.True(t, serviceErrors.IsForbidden(err))
+			assert.Nil(t, report)
+			mockPDFGen.AssertNotCalled(t, "GenerateStudentReport")
+		})
+	}
+}
+
+func TestGenerateStudentReport_ParentDeniedBeforeBackendCall(t *testing.T) {
+	mockBackend := new(MockBackendService)
+	mockPDFGen := new(MockPDFGenerator)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, nil, nil, nil, nil, nil, false, zap.NewNop())
+	parent := &auth.Principal{Subject: "p1", Role: auth.RoleParent, StudentIDs: []string{"12344"}}
+
+	report, err := service.GenerateStudentReport(auth.NewContext(context.Background(), parent), "12345", ReportOptions{})
+
+	assert.True(t, serviceErrors.IsForbidden(err))
+	assert.Nil(t, report)
+	mockBackend.AssertNotCalled(t, "GetStudent")
+}
+
+func TestGenerateStudentReport_RedactionProfiles(t *testing.T) {
+	parent := &auth.Principal{Subject: "p1", Role: auth.RoleParent, StudentIDs: []string{"12345"}}
+	teacher := &auth.Principal{Subject: "t1", Role: auth.RoleTeacher, Classes: []string{"10"}}
+
+	testCases := []struct {
+		name      string
+		principal *auth.Principal
+		profile   string
+		applied   string
+	}{
+		{name: "unauthenticated default", applied: "full"},
+		{name: "parent default", principal: parent, applied: "parent"},
+		{name: "parent asking for less", principal: parent, profile: "public", applied: "public"},
+		{name: "teacher asking for a transcript", principal: teacher, profile: "transcript", applied: "transcript"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockBackend := new(MockBackendService)
+			mockPDFGen := new(MockPDFGenerator)
+			mockCache := new(MockPDFCache)
+			service := NewStudentReportService(mockBackend, []ReportRenderer{mockPDFGen}, mockCache, nil, nil, nil, nil, false, zap.NewNop())
+
+			student := createTestStudent()
+			profile, _ := redaction.Lookup(tc.applied)
+			redactedStudent, redactedTmpl := profile.Apply(student, templates.Default())
+			contentHash := cache.VariantKey(cache.GenerateStudentHash(student), templates.Default().CacheKey(), FormatPDF, i18n.Default().CacheKey(), "profile-"+tc.applied)
+
+			mockBackend.On("GetStudent", mock.Anything, "12345").Return(student, nil)
+			mockCache.On("Get", "12345", contentHash).Return(nil, false)
+			mockCache.On("Set", "12345", []byte("pdf"), contentHash).Return(nil)
+			mockPDFGen.On("GenerateStudentReport", redactedStudent, redactedTmpl, i18n.Default(), mock.MatchedBy(func(issue ReportIssue) bool {
+				return issue.Profile == tc.applied
+			})).Return([]byte("pdf"), nil)
+
+			ctx := context.Background()
+			if tc.principal != nil {
+				ctx = auth.NewContext(ctx, tc.principal)
+			}
+			report, err := service.GenerateStudentReport(ctx, "12345", ReportOptions{Profile: tc.profile})
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.applied, report.Profile)
+			mockPDFGen.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGenerateStudentReport_RejectsProfile(t *testing.T) {
+	mockBackend := new(MockBackendService)
+	service := NewStudentReportService(mockBackend, []ReportRenderer{new(MockPDFGenerator)}, nil, nil, nil, nil, nil, false, zap.NewNop())
+	parent := auth.NewContext(context.Background(), &auth.Principal{Subject: "p1", Role: auth.RoleParent, StudentIDs: []string{"12345"}})
+
+	// parents may not lift the redaction of their role
+	_, err := service.GenerateStudentReport(parent, "12345", ReportOptions{Profile: "full"})
+	assert.True(t, serviceErrors.IsForbidden(err))
+
+	_, err = service.GenerateStudentReport(context.Background(), "12345", ReportOptions{Profile: "secret"})
+	assert.True(t, serviceErrors.IsValidationError(err))
+	mockBackend.AssertNotCalled(t, "GetStudent")
+}
@@ -0,0 +1,582 @@
+package signing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf16"
+)
+
+// signatureSize is the room reserved for the DER encoded signature. It fits a
+// signing certificate with a few intermediates.
+const signatureSize = 8192
+
+// byteRangePlaceholder is overwritten once the offsets are known. The numbers
+// are zero padded so that filling them in never moves a byte.
+const byteRangePlaceholder = "[0000000000 0000000000 0000000000 0000000000]"
+
+var (
+	// ErrNotPDF is returned for input that does not look like a PDF document
+	ErrNotPDF = errors.New("not a PDF document")
+
+	// ErrUnsupportedPDF is returned for documents whose structure cannot be
+	// signed, such as those using cross-reference streams
+	ErrUnsupportedPDF = errors.New("unsupported PDF structure")
+)
+
+var (
+	startXrefPattern = regexp.MustCompile(`startxref\s+(\d+)`)
+	referencePattern = regexp.MustCompile(`^\s*(\d+)\s+(\d+)\s+R`)
+	objectPattern    = regexp.MustCompile(`^(\d+)\s+(\d+)\s+obj`)
+	integerPattern   = regexp.MustCompile(`^\s*(\d+)`)
+)
+
+// signatureInfo is written to the signature dictionary
+type signatureInfo struct {
+	name     string
+	reason   string
+	location string
+	signedAt time.Time
+}
+
+// objectRef identifies an indirect object
+type objectRef struct {
+	number     int
+	generation int
+}
+
+func (r objectRef) String() string {
+	return fmt.Sprintf("%d %d R", r.number, r.generation)
+}
+
+// pdfUpdate is a document with an incremental update appended that holds an
+// empty signature
+type pdfUpdate struct {
+	data      []byte
+	byteRange [4]int
+}
+
+// signedContent returns the bytes covered by the signature, everything but
+// the /Contents hex string
+func (u *pdfUpdate) signedContent() []byte {
+	content := make([]byte, 0, u.byteRange[1]+u.byteRange[3])
+	content = append(content, u.data[:u.byteRange[1]]...)
+	return append(content, u.data[u.byteRange[2]:u.byteRange[2]+u.byteRange[3]]...)
+}
+
+// setContents writes the signature into the reserved /Contents string
+func (u *pdfUpdate) setContents(signature []byte) error {
+	encoded := hex.EncodeToString(signature)
+	// the placeholder is delimited by < and >
+	if room := u.byteRange[2] - u.byteRange[1] - 2; len(encoded) > room {
+		return fmt.Errorf("signature of %d bytes exceeds the reserved %d bytes", len(signature), room/2)
+	}
+	copy(u.data[u.byteRange[1]+1:], encoded)
+	return nil
+}
+
+// xrefSection is the cross-reference data of a document: where each object
+// lives and what the trailer points at
+type xrefSection struct {
+	offset  int
+	size    int
+	root    objectRef
+	info    string
+	objects map[int]xrefEntry
+}
+
+type xrefEntry struct {
+	offset     int
+	generation int
+}
+
+// prepareSignature appends an incremental update to pdf that adds an
+// invisible signature field on the first page and reserves space for the
+// signature itself. The original bytes are left untouched, which keeps the
+// document readable by viewers that ignore signatures.
+func prepareSignature(pdf []byte, info signatureInfo) (*pdfUpdate, error) {
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		return nil, ErrNotPDF
+	}
+
+	xref, err := readXref(pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog, err := readObject(pdf, xref, xref.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document catalog: %w", err)
+	}
+	if dictValue(catalog, "/AcroForm") != nil {
+		return nil, fmt.Errorf("%w: document already has a form", ErrUnsupportedPDF)
+	}
+
+	pageRef, page, err := firstPage(pdf, xref, catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	sigRef := objectRef{number: xref.size}
+	widgetRef := objectRef{number: xref.size + 1}
+
+	page, err = addAnnotation(page, widgetRef)
+	if err != nil {
+		return nil, err
+	}
+	catalog = appendEntry(catalog, fmt.Sprintf("/AcroForm << /Fields [%s] /SigFlags 3 >>", widgetRef))
+
+	var out bytes.Buffer
+	out.Grow(len(pdf) + 2*signatureSize + 1024)
+	out.Write(pdf)
+	if pdf[len(pdf)-1] != '\n' {
+		out.WriteByte('\n')
+	}
+
+	offsets := make(map[objectRef]int, 4)
+	writeObject := func(ref objectRef, body []byte) {
+		offsets[ref] = out.Len()
+		fmt.Fprintf(&out, "%d %d obj\n", ref.number, ref.generation)
+		out.Write(body)
+		out.WriteString("\nendobj\n")
+	}
+
+	writeObject(sigRef, signatureDict(info))
+	writeObject(widgetRef, []byte(fmt.Sprintf(
+		"<< /Type /Annot /Subtype /Widget /FT /Sig /Rect [0 0 0 0] /F 132 /T (Signature1) /V %s /P %s >>",
+		sigRef, pageRef)))
+	writeObject(xref.root, catalog)
+	writeObject(pageRef, page)
+
+	xrefOffset := out.Len()
+	writeXref(&out, offsets)
+
+	out.WriteString("trailer\n<<\n")
+	fmt.Fprintf(&out, "/Size %d\n/Root %s\n", xref.size+2, xref.root)
+	if xref.info != "" {
+		fmt.Fprintf(&out, "/Info %s\n", xref.info)
+	}
+	fmt.Fprintf(&out, "/Prev %d\n>>\nstartxref\n%d\n%%%%EOF\n", xref.offset, xrefOffset)
+
+	return fillByteRange(out.Bytes())
+}
+
+// signatureDict builds the signature value with a zeroed /Contents string
+// and a placeholder /ByteRange
+func signatureDict(info signatureInfo) []byte {
+	var dict bytes.Buffer
+	dict.WriteString("<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter /adbe.pkcs7.detached\n")
+	dict.WriteString("/ByteRange " + byteRangePlaceholder + "\n")
+	dict.WriteString("/Contents <")
+	dict.Write(bytes.Repeat([]byte("0"), 2*signatureSize))
+	dict.WriteString(">\n")
+	dict.WriteString("/M " + pdfString(pdfDate(info.signedAt)))
+	if info.name != "" {
+		dict.WriteString(" /Name " + pdfString(info.name))
+	}
+	if info.reason != "" {
+		dict.WriteString(" /Reason " + pdfString(info.reason))
+	}
+	if info.location != "" {
+		dict.WriteString(" /Location " + pdfString(info.location))
+	}
+	dict.WriteString(" >>")
+	return dict.Bytes()
+}
+
+// fillByteRange locates the /Contents placeholder of the last signature and
+// records the ranges around it
+func fillByteRange(data []byte) (*pdfUpdate, error) {
+	placeholder := bytes.LastIndex(data, []byte("/ByteRange "+byteRangePlaceholder))
+	if placeholder < 0 {
+		return nil, errors.New("signature placeholder not found")
+	}
+	contents := bytes.Index(data[placeholder:], []byte("/Contents <"))
+	if contents < 0 {
+		return nil, errors.New("signature placeholder not found")
+	}
+
+	start := placeholder + contents + len("/Contents ")
+	end := start + 2*signatureSize + 2
+	byteRange := [4]int{0, start, end, len(data) - end}
+
+	filled := fmt.Sprintf("[%010d %010d %010d %010d]", byteRange[0], byteRange[1], byteRange[2], byteRange[3])
+	copy(data[placeholder+len("/ByteRange "):], filled)
+
+	return &pdfUpdate{data: data, byteRange: byteRange}, nil
+}
+
+// writeXref writes a cross-reference section for the given objects, one
+// subsection per run of consecutive object numbers
+func writeXref(out *bytes.Buffer, offsets map[objectRef]int) {
+	refs := make([]objectRef, 0, len(offsets))
+	for ref := range offsets {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].number < refs[j].number })
+
+	out.WriteString("xref\n")
+	for i := 0; i < len(refs); {
+		j := i + 1
+		for j < len(refs) && refs[j].number == refs[j-1].number+1 {
+			j++
+		}
+		fmt.Fprintf(out, "%d %d\n", refs[i].number, j-i)
+		for _, ref := range refs[i:j] {
+			fmt.Fprintf(out, "%010d %05d n \n", offsets[ref], ref.generation)
+		}
+		i = j
+	}
+}
+
+// readXref reads the cross-reference tables of the document, following /Prev
+// links so that later sections take precedence
+func readXref(pdf []byte) (*xrefSection, error) {
+	matches := startXrefPattern.FindAllSubmatch(pdf, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: startxref not found", ErrUnsupportedPDF)
+	}
+	last, _ := strconv.Atoi(string(matches[len(matches)-1][1]))
+
+	section := &xrefSection{offset: last, objects: make(map[int]xrefEntry)}
+	visited := make(map[int]bool)
+	for offset := last; ; {
+		if visited[offset] {
+			return nil, fmt.Errorf("%w: cross-reference loop", ErrUnsupportedPDF)
+		}
+		visited[offset] = true
+
+		trailer, err := readXrefTable(pdf, offset, section.objects)
+		if err != nil {
+			return nil, err
+		}
+
+		// the newest trailer describes the document
+		if offset == last {
+			if err := section.readTrailer(trailer); err != nil {
+				return nil, err
+			}
+		}
+
+		prev := dictValue(trailer, "/Prev")
+		if prev == nil {
+			break
+		}
+		match := integerPattern.FindSubmatch(prev)
+		if match == nil {
+			return nil, fmt.Errorf("%w: invalid /Prev", ErrUnsupportedPDF)
+		}
+		offset, _ = strconv.Atoi(string(match[1]))
+	}
+	return section, nil
+}
+
+func (x *xrefSection) readTrailer(trailer []byte) error {
+	size := integerPattern.FindSubmatch(dictValue(trailer, "/Size"))
+	if size == nil {
+		return fmt.Errorf("%w: trailer without /Size", ErrUnsupportedPDF)
+	}
+	x.size, _ = strconv.Atoi(string(size[1]))
+
+	root, ok := parseReference(dictValue(trailer, "/Root"))
+	if !ok {
+		return fmt.Errorf("%w: trailer without /Root", ErrUnsupportedPDF)
+	}
+	x.root = root
+
+	if info, ok := parseReference(dictValue(trailer, "/Info")); ok {
+		x.info = info.String()
+	}
+	return nil
+}
+
+// readXrefTable parses a classic cross-reference table at offset, adding
+// entries not already known, and returns the trailer dictionary after it
+func readXrefTable(pdf []byte, offset int, objects map[int]xrefEntry) ([]byte, error) {
+	if offset < 0 || offset >= len(pdf) || !bytes.HasPrefix(pdf[offset:], []byte("xref")) {
+		return nil, fmt.Errorf("%w: cross-reference streams are not supported", ErrUnsupportedPDF)
+	}
+
+	fields := newFieldReader(pdf[offset+len("xref"):])
+	for {
+		token := fields.next()
+		if token == "trailer" {
+			break
+		}
+		first, err1 := strconv.Atoi(token)
+		count, err2 := strconv.Atoi(fields.next())
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("%w: malformed cross-reference table", ErrUnsupportedPDF)
+		}
+
+		for i := 0; i < count; i++ {
+			entryOffset, err1 := strconv.Atoi(fields.next())
+			generation, err2 := strconv.Atoi(fields.next())
+			kind := fields.next()
+			if err1 != nil || err2 != nil || (kind != "n" && kind != "f") {
+				return nil, fmt.Errorf("%w: malformed cross-reference entry", ErrUnsupportedPDF)
+			}
+			if _, known := objects[first+i]; !known && kind == "n" {
+				objects[first+i] = xrefEntry{offset: entryOffset, generation: generation}
+			}
+		}
+	}
+
+	trailer, _, err := readDict(pdf, offset+len("xref")+fields.pos)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed trailer", ErrUnsupportedPDF)
+	}
+	return trailer, nil
+}
+
+// readObject returns the dictionary of an indirect object
+func readObject(pdf []byte, xref *xrefSection, ref objectRef) ([]byte, error) {
+	entry, ok := xref.objects[ref.number]
+	if !ok || entry.offset >= len(pdf) {
+		return nil, fmt.Errorf("%w: object %d not found", ErrUnsupportedPDF, ref.number)
+	}
+
+	header := objectPattern.FindSubmatchIndex(pdf[entry.offset:])
+	if header == nil {
+		return nil, fmt.Errorf("%w: object %d not found", ErrUnsupportedPDF, ref.number)
+	}
+
+	dict, _, err := readDict(pdf, entry.offset+header[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: object %d is not a dictionary", ErrUnsupportedPDF, ref.number)
+	}
+	return dict, nil
+}
+
+// firstPage walks the page tree down to its first leaf
+func firstPage(pdf []byte, xref *xrefSection, catalog []byte) (objectRef, []byte, error) {
+	ref, ok := parseReference(dictValue(catalog, "/Pages"))
+	for depth := 0; ok && depth < 32; depth++ {
+		node, err := readObject(pdf, xref, ref)
+		if err != nil {
+			return objectRef{}, nil, err
+		}
+
+		nodeType := dictValue(node, "/Type")
+		if hasName(nodeType, "/Page") {
+			return ref, node, nil
+		}
+		if !hasName(nodeType, "/Pages") {
+			break
+		}
+
+		kids := dictValue(node, "/Kids")
+		if len(kids) == 0 || kids[0] != '[' {
+			break
+		}
+		ref, ok = parseReference(kids[1:])
+	}
+	return objectRef{}, nil, fmt.Errorf("%w: document has no pages", ErrUnsupportedPDF)
+}
+
+// addAnnotation adds ref to the /Annots array of a page dictionary
+func addAnnotation(page []byte, ref objectRef) ([]byte, error) {
+	annots := dictValue(page, "/Annots")
+	if annots == nil {
+		return appendEntry(page, fmt.Sprintf("/Annots [%s]", ref)), nil
+	}
+	if len(annots) == 0 || annots[0] != '[' {
+		return nil, fmt.Errorf("%w: indirect /Annots arrays are not supported", ErrUnsupportedPDF)
+	}
+
+	end := bytes.IndexByte(annots, ']')
+	if end < 0 {
+		return nil, fmt.Errorf("%w: malformed /Annots array", ErrUnsupportedPDF)
+	}
+
+	// annots aliases page, so find its position to splice in the reference
+	at := len(page) - len(annots) + end
+	updated := make([]byte, 0, len(page)+16)
+	updated = append(updated, page[:at]...)
+	updated = append(updated, ' ')
+	updated = append(updated, ref.String()...)
+	return append(updated, page[at:]...), nil
+}
+
+// appendEntry adds a key and value at the end of a dictionary
+func appendEntry(dict []byte, entry string) []byte {
+	end := bytes.LastIndex(dict, []byte(">>"))
+	updated := make([]byte, 0, len(dict)+len(entry)+2)
+	updated = append(updated, bytes.TrimRight(dict[:end], " \r\n\t")...)
+	updated = append(updated, '\n')
+	updated = append(updated, entry...)
+	updated = append(updated, '\n')
+	return append(updated, dict[end:]...)
+}
+
+// dictValue returns the bytes following a top level key of the dictionary,
+// up to the end of the dictionary, or nil when the key is absent
+func dictValue(dict []byte, key string) []byte {
+	depth := 0
+	for i := 0; i < len(dict); i++ {
+		switch {
+		case dict[i] == '(':
+			i = skipString(dict, i)
+		case bytes.HasPrefix(dict[i:], []byte("<<")):
+			depth++
+			i++
+		case bytes.HasPrefix(dict[i:], []byte(">>")):
+			depth--
+			i++
+		case dict[i] == '<':
+			i = skipHexString(dict, i)
+		case depth == 1 && bytes.HasPrefix(dict[i:], []byte(key)) && isDelimiter(dict, i+len(key)):
+			return bytes.TrimLeft(dict[i+len(key):], " \r\n\t")
+		}
+	}
+	return nil
+}
+
+// readDict returns the dictionary starting at the first << after pos and the
+// offset just past it
+func readDict(pdf []byte, pos int) ([]byte, int, error) {
+	start := bytes.Index(pdf[pos:], []byte("<<"))
+	if start < 0 {
+		return nil, 0, errors.New("dictionary not found")
+	}
+	start += pos
+
+	depth := 0
+	for i := start; i < len(pdf); i++ {
+		switch {
+		case pdf[i] == '(':
+			i = skipString(pdf, i)
+		case bytes.HasPrefix(pdf[i:], []byte("<<")):
+			depth++
+			i++
+		case bytes.HasPrefix(pdf[i:], []byte(">>")):
+			depth--
+			i++
+			if depth == 0 {
+				return pdf[start : i+1], i + 1, nil
+			}
+		case pdf[i] == '<':
+			i = skipHexString(pdf, i)
+		}
+	}
+	return nil, 0, errors.New("unterminated dictionary")
+}
+
+// skipString returns the offset of the parenthesis closing the literal string
+// opened at pos
+func skipString(data []byte, pos int) int {
+	depth := 0
+	for i := pos; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(data)
+}
+
+// skipHexString returns the offset of the > closing the hex string opened at
+// pos
+func skipHexString(data []byte, pos int) int {
+	if end := bytes.IndexByte(data[pos:], '>'); end >= 0 {
+		return pos + end
+	}
+	return len(data)
+}
+
+// isDelimiter reports whether a name ending at pos is complete
+func isDelimiter(data []byte, pos int) bool {
+	if pos >= len(data) {
+		return true
+	}
+	return bytes.IndexByte([]byte(" \r\n\t\f()<>[]{}/%"), data[pos]) >= 0
+}
+
+// hasName reports whether value starts with the given name
+func hasName(value []byte, name string) bool {
+	return bytes.HasPrefix(value, []byte(name)) && isDelimiter(value, len(name))
+}
+
+// parseReference reads an indirect reference such as "12 0 R"
+func parseReference(value []byte) (objectRef, bool) {
+	match := referencePattern.FindSubmatch(value)
+	if match == nil {
+		return objectRef{}, false
+	}
+	number, _ := strconv.Atoi(string(match[1]))
+	generation, _ := strconv.Atoi(string(match[2]))
+	return objectRef{number: number, generation: generation}, true
+}
+
+// pdfString encodes a text string, as a literal when it is plain ASCII and
+// as UTF-16 otherwise
+func pdfString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			ascii = false
+			break
+		}
+	}
+
+	if ascii {
+		var escaped bytes.Buffer
+		escaped.WriteByte('(')
+		for i := 0; i < len(s); i++ {
+			if s[i] == '(' || s[i] == ')' || s[i] == '\\' {
+				escaped.WriteByte('\\')
+			}
+			escaped.WriteByte(s[i])
+		}
+		escaped.WriteByte(')')
+		return escaped.String()
+	}
+
+	encoded := []byte{0xfe, 0xff}
+	for _, unit := range utf16.Encode([]rune(s)) {
+		encoded = append(encoded, byte(unit>>8), byte(unit))
+	}
+	return "<" + hex.EncodeToString(encoded) + ">"
+}
+
+// pdfDate formats t as a PDF date string
+func pdfDate(t time.Time) string {
+	return t.UTC().Format("D:20060102150405") + "Z"
+}
+
+// fieldReader splits whitespace separated tokens
+type fieldReader struct {
+	data []byte
+	pos  int
+}
+
+func newFieldReader(data []byte) *fieldReader {
+	return &fieldReader{data: data}
+}
+
+func (r *fieldReader) next() string {
+	for r.pos < len(r.data) && isSpace(r.data[r.pos]) {
+		r.pos++
+	}
+	start := r.pos
+	for r.pos < len(r.data) && !isSpace(r.data[r.pos]) {
+		r.pos++
+	}
+	return string(r.data[start:r.pos])
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
@@ -0,0 +1,117 @@
+// Package signing applies and checks PKCS#7 (CMS) detached signatures on the
+// PDF reports, the adbe.pkcs7.detached scheme understood by PDF readers.
+package signing
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hhrutter/pkcs7"
+)
+
+// Signer signs PDFs with a certificate and its private key
+type Signer struct {
+	cert     *x509.Certificate
+	key      crypto.Signer
+	chain    []*x509.Certificate
+	reason   string
+	location string
+}
+
+// LoadSigner reads a PEM certificate (optionally followed by its
+// intermediates) and the matching PEM private key
+func LoadSigner(certFile, keyFile, reason, location string) (*Signer, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing certificate: %w", err)
+	}
+
+	certs := make([]*x509.Certificate, 0, len(pair.Certificate))
+	for _, der := range pair.Certificate {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("signing key does not support signatures")
+	}
+
+	return NewSigner(certs[0], key, certs[1:], reason, location), nil
+}
+
+// NewSigner creates a signer from an already parsed certificate and key.
+// chain lists the intermediates, closest to cert first.
+func NewSigner(cert *x509.Certificate, key crypto.Signer, chain []*x509.Certificate, reason, location string) *Signer {
+	return &Signer{
+		cert:     cert,
+		key:      key,
+		chain:    chain,
+		reason:   reason,
+		location: location,
+	}
+}
+
+// Certificates returns the signing certificate followed by its chain
+func (s *Signer) Certificates() []*x509.Certificate {
+	return append([]*x509.Certificate{s.cert}, s.chain...)
+}
+
+// Fingerprint identifies the signing certificate. It takes part in cache keys
+// so that reports signed with a replaced certificate are not served.
+func (s *Signer) Fingerprint() string {
+	hash := sha256.Sum256(s.cert.Raw)
+	return hex.EncodeToString(hash[:])[:8]
+}
+
+// Sign appends an invisible signature field to pdf and signs the whole
+// document, returning the signed copy
+func (s *Signer) Sign(pdf []byte) ([]byte, error) {
+	update, err := prepareSignature(pdf, signatureInfo{
+		name:     s.cert.Subject.CommonName,
+		reason:   s.reason,
+		location: s.location,
+		signedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := s.signDetached(update.signedContent())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := update.setContents(signature); err != nil {
+		return nil, err
+	}
+	return update.data, nil
+}
+
+func (s *Signer) signDetached(content []byte) ([]byte, error) {
+	signedData, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare signature: %w", err)
+	}
+	signedData.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+
+	if err := signedData.AddSignerChain(s.cert, s.key, s.chain, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, fmt.Errorf("failed to sign report: %w", err)
+	}
+	signedData.Detach()
+
+	signature, err := signedData.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signature: %w", err)
+	}
+	return signature, nil
+}
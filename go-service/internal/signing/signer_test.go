@@ -0,0 +1,164 @@
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	// pdfcpu writes a configuration directory under the user's home by default
+	api.DisableConfigDir()
+}
+
+// newTestCertificate creates a self-signed certificate valid around now
+func newTestCertificate(t *testing.T, commonName string) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"Test School"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+	cert, key := newTestCertificate(t, "Registrar")
+	return NewSigner(cert, key, nil, "Official transcript", "Campus")
+}
+
+// renderTestPDF builds a small document with the given number of pages
+func renderTestPDF(t *testing.T, pages int, withLink bool) []byte {
+	t.Helper()
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Arial", "", 12)
+	for i := 0; i < pages; i++ {
+		pdf.AddPage()
+		pdf.Cell(40, 10, "Student Report")
+		if withLink {
+			pdf.LinkString(10, 10, 40, 10, "https://example.com")
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, pdf.Output(&buf))
+	return buf.Bytes()
+}
+
+func TestSign_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		pages    int
+		withLink bool
+	}{
+		{name: "single page", pages: 1},
+		{name: "several pages", pages: 3},
+		{name: "page with annotations", pages: 1, withLink: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := newTestSigner(t)
+			original := renderTestPDF(t, tt.pages, tt.withLink)
+
+			signed, err := signer.Sign(original)
+			require.NoError(t, err)
+
+			// an incremental update keeps the original bytes
+			assert.True(t, bytes.HasPrefix(signed, original))
+			assert.Contains(t, string(signed), "/SubFilter /adbe.pkcs7.detached")
+
+			// other readers still accept the updated document
+			require.NoError(t, api.Validate(bytes.NewReader(signed), nil))
+			pageCount, err := api.PageCount(bytes.NewReader(signed), nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.pages, pageCount)
+
+			result, err := NewVerifier(signer.Certificates()...).Verify(signed)
+			require.NoError(t, err)
+			assert.True(t, result.Signed)
+			assert.True(t, result.Valid)
+			assert.False(t, result.Modified)
+			assert.True(t, result.Trusted)
+			assert.Empty(t, result.Problem)
+			require.NotNil(t, result.Signer)
+			assert.Equal(t, "Registrar", result.Signer.Subject.CommonName)
+			assert.WithinDuration(t, time.Now(), result.SignedAt, time.Minute)
+		})
+	}
+}
+
+func TestSign_NotAPDF(t *testing.T) {
+	_, err := newTestSigner(t).Sign([]byte("hello"))
+	assert.ErrorIs(t, err, ErrNotPDF)
+}
+
+func TestSign_AlreadySigned(t *testing.T) {
+	signer := newTestSigner(t)
+	signed, err := signer.Sign(renderTestPDF(t, 1, false))
+	require.NoError(t, err)
+
+	_, err = signer.Sign(signed)
+	assert.ErrorIs(t, err, ErrUnsupportedPDF)
+}
+
+func TestSign_NonASCIIMetadata(t *testing.T) {
+	cert, key := newTestCertificate(t, "Secrétariat")
+	signer := NewSigner(cert, key, nil, "Relevé officiel", "")
+
+	signed, err := signer.Sign(renderTestPDF(t, 1, false))
+	require.NoError(t, err)
+	assert.Contains(t, string(signed), "/Name <feff")
+	assert.NotContains(t, string(signed), "/Location")
+
+	result, err := NewVerifier(cert).Verify(signed)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+}
+
+func TestLoadSigner(t *testing.T) {
+	cert, key := newTestCertificate(t, "Registrar")
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	signer, err := LoadSigner(certFile, keyFile, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, cert.Raw, signer.Certificates()[0].Raw)
+	assert.Len(t, signer.Fingerprint(), 8)
+
+	_, err = LoadSigner(filepath.Join(dir, "missing.pem"), keyFile, "", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hhrutter/pkcs7"
+)
+
+var byteRangePattern = regexp.MustCompile(`/ByteRange\s*\[\s*(\d+)\s+(\d+)\s+(\d+)\s+(\d+)\s*\]`)
+
+// Verification is the outcome of checking the signature of a PDF
+type Verification struct {
+	// Signed is false when the document carries no signature at all
+	Signed bool
+
+	// Valid reports whether the signature matches the bytes it covers
+	Valid bool
+
+	// Modified is set when the document changed after it was signed, either
+	// because the signed bytes differ or because content was appended
+	Modified bool
+
+	// Trusted reports whether the signer chains to a trusted certificate
+	Trusted bool
+
+	Signer   *x509.Certificate
+	SignedAt time.Time
+
+	// Problem explains why the signature is not valid or not trusted
+	Problem string
+}
+
+// Verifier checks PDF signatures against a set of trusted certificates
+type Verifier struct {
+	roots *x509.CertPool
+}
+
+// NewVerifier creates a verifier that trusts signers chaining to any of the
+// given certificates. Without certificates no signer is trusted, but
+// signatures are still checked for integrity.
+func NewVerifier(trusted ...*x509.Certificate) *Verifier {
+	roots := x509.NewCertPool()
+	for _, cert := range trusted {
+		roots.AddCert(cert)
+	}
+	return &Verifier{roots: roots}
+}
+
+// Verify checks the last signature of pdf. Only input that is not a PDF at
+// all is reported as an error, problems with the signature are described by
+// the returned Verification.
+func (v *Verifier) Verify(pdf []byte) (*Verification, error) {
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		return nil, ErrNotPDF
+	}
+
+	matches := byteRangePattern.FindAllSubmatch(pdf, -1)
+	if len(matches) == 0 {
+		return &Verification{}, nil
+	}
+
+	result := &Verification{Signed: true}
+
+	byteRange, err := parseByteRange(matches[len(matches)-1], len(pdf))
+	if err != nil {
+		result.Problem = err.Error()
+		return result, nil
+	}
+
+	signature, err := decodeContents(pdf[byteRange[1]:byteRange[2]])
+	if err != nil {
+		result.Problem = err.Error()
+		return result, nil
+	}
+
+	p7, err := pkcs7.Parse(signature)
+	if err != nil {
+		result.Problem = fmt.Sprintf("malformed signature: %v", err)
+		return result, nil
+	}
+
+	content := make([]byte, 0, byteRange[1]+byteRange[3])
+	content = append(content, pdf[:byteRange[1]]...)
+	p7.Content = append(content, pdf[byteRange[2]:byteRange[2]+byteRange[3]]...)
+
+	result.Signer = p7.GetOnlySigner()
+	if err := p7.UnmarshalSignedAttribute(pkcs7.OIDAttributeSigningTime, &result.SignedAt); err != nil {
+		result.SignedAt = time.Time{}
+	}
+
+	// anything after the signed range was added later
+	if len(bytes.TrimRight(pdf[byteRange[2]+byteRange[3]:], " \r\n\t")) > 0 {
+		result.Modified = true
+		result.Problem = "document was changed after signing"
+	}
+
+	if err := p7.Verify(); err != nil {
+		var mismatch *pkcs7.MessageDigestMismatchError
+		if errors.As(err, &mismatch) {
+			result.Modified = true
+			result.Problem = "document was changed after signing"
+		} else {
+			result.Problem = fmt.Sprintf("invalid signature: %v", err)
+		}
+		return result, nil
+	}
+	result.Valid = true
+
+	if result.Signer != nil {
+		v.checkTrust(result, p7.Certificates)
+	}
+	return result, nil
+}
+
+func (v *Verifier) checkTrust(result *Verification, certs []*x509.Certificate) {
+	at := result.SignedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	if _, err := pkcs7.VerifyCertChain(result.Signer, certs, v.roots, at); err != nil {
+		if result.Problem == "" {
+			result.Problem = fmt.Sprintf("signer is not trusted: %v", err)
+		}
+		return
+	}
+	result.Trusted = true
+}
+
+// parseByteRange checks that the range covers the document from its first
+// byte, skipping only the signature itself
+func parseByteRange(match [][]byte, size int) ([4]int, error) {
+	var byteRange [4]int
+	for i := range byteRange {
+		value, err := strconv.Atoi(string(match[i+1]))
+		if err != nil {
+			return byteRange, errors.New("malformed /ByteRange")
+		}
+		byteRange[i] = value
+	}
+
+	if byteRange[0] != 0 || byteRange[1] >= byteRange[2] || byteRange[2]+byteRange[3] > size {
+		return byteRange, errors.New("/ByteRange does not cover the document")
+	}
+	return byteRange, nil
+}
+
+// decodeContents decodes the hex string holding the signature, dropping the
+// padding after the DER encoding
+func decodeContents(contents []byte) ([]byte, error) {
+	if len(contents) < 2 || contents[0] != '<' || contents[len(contents)-1] != '>' {
+		return nil, errors.New("/ByteRange does not exclude the signature")
+	}
+
+	decoded, err := hex.DecodeString(string(contents[1 : len(contents)-1]))
+	if err != nil {
+		return nil, errors.New("malformed signature contents")
+	}
+
+	var der asn1.RawValue
+	if _, err := asn1.Unmarshal(decoded, &der); err != nil {
+		return nil, errors.New("malformed signature contents")
+	}
+	return der.FullBytes, nil
+}
@@ -0,0 +1,101 @@
+package signing
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify_Unsigned(t *testing.T) {
+	result, err := NewVerifier().Verify(renderTestPDF(t, 1, false))
+	require.NoError(t, err)
+	assert.False(t, result.Signed)
+	assert.False(t, result.Valid)
+}
+
+func TestVerify_NotAPDF(t *testing.T) {
+	_, err := NewVerifier().Verify([]byte("PK\x03\x04"))
+	assert.ErrorIs(t, err, ErrNotPDF)
+}
+
+func TestVerify_TamperedContent(t *testing.T) {
+	signer := newTestSigner(t)
+	signed, err := signer.Sign(renderTestPDF(t, 1, false))
+	require.NoError(t, err)
+
+	// change a byte covered by the signature
+	at := bytes.Index(signed, []byte("/Type /Catalog"))
+	require.Positive(t, at)
+	tampered := bytes.Clone(signed)
+	tampered[at+len("/Type /")] = 'K'
+
+	result, err := NewVerifier(signer.Certificates()...).Verify(tampered)
+	require.NoError(t, err)
+	assert.True(t, result.Signed)
+	assert.False(t, result.Valid)
+	assert.True(t, result.Modified)
+	assert.Equal(t, "document was changed after signing", result.Problem)
+}
+
+func TestVerify_AppendedContent(t *testing.T) {
+	signer := newTestSigner(t)
+	signed, err := signer.Sign(renderTestPDF(t, 1, false))
+	require.NoError(t, err)
+
+	appended := append(bytes.Clone(signed), []byte("99 0 obj\n<< >>\nendobj\n")...)
+
+	result, err := NewVerifier(signer.Certificates()...).Verify(appended)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.True(t, result.Modified)
+}
+
+func TestVerify_UntrustedSigner(t *testing.T) {
+	signer := newTestSigner(t)
+	signed, err := signer.Sign(renderTestPDF(t, 1, false))
+	require.NoError(t, err)
+
+	other, _ := newTestCertificate(t, "Someone Else")
+
+	tests := []struct {
+		name     string
+		verifier *Verifier
+	}{
+		{name: "no trusted certificates", verifier: NewVerifier()},
+		{name: "different certificate", verifier: NewVerifier(other)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.verifier.Verify(signed)
+			require.NoError(t, err)
+			assert.True(t, result.Valid)
+			assert.False(t, result.Modified)
+			assert.False(t, result.Trusted)
+			assert.Contains(t, result.Problem, "signer is not trusted")
+			require.NotNil(t, result.Signer)
+			assert.Equal(t, "Registrar", result.Signer.Subject.CommonName)
+		})
+	}
+}
+
+func TestVerify_MalformedSignature(t *testing.T) {
+	signer := newTestSigner(t)
+	signed, err := signer.Sign(renderTestPDF(t, 1, false))
+	require.NoError(t, err)
+
+	// blank the signature while keeping the byte range intact
+	start := bytes.LastIndex(signed, []byte("/Contents <")) + len("/Contents <")
+	broken := bytes.Clone(signed)
+	for i := start; broken[i] != '>'; i++ {
+		broken[i] = '0'
+	}
+
+	result, err := NewVerifier(signer.Certificates()...).Verify(broken)
+	require.NoError(t, err)
+	assert.True(t, result.Signed)
+	assert.False(t, result.Valid)
+	assert.NotEmpty(t, result.Problem)
+}
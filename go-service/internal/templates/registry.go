@@ -0,0 +1,225 @@
+package templates
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// DefaultName is the name of the built-in template
+const DefaultName = "default"
+
+var ErrTemplateNotFound = errors.New("template not found")
+
+//go:embed default.yaml
+var defaultSource []byte
+
+var defaultTemplate = func() *Template {
+	tmpl, err := Parse(defaultSource, nil)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in template: %v", err))
+	}
+	return tmpl
+}()
+
+var nameRegex = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// Default returns the built-in template
+func Default() *Template {
+	return defaultTemplate
+}
+
+// ValidName reports whether name can be used as a template name
+func ValidName(name string) bool {
+	return nameRegex.MatchString(name)
+}
+
+// Registry holds the templates available to the report renderers: the
+// built-in default plus every *.yaml, *.yml or *.json file of a directory.
+type Registry struct {
+	dir             string
+	reload          bool
+	overrideDefault bool
+	templates       map[string]*Template
+	mu              sync.RWMutex
+	logger          *zap.Logger
+}
+
+// NewRegistry loads the templates found in dir (which may be empty). Each
+// file must declare the name it is stored under, and only overrideDefault
+// lets a default.yaml replace the built-in template. With reload enabled,
+// templates are read again from disk on every lookup so that layout changes
+// apply without a restart.
+func NewRegistry(dir string, reload, overrideDefault bool, logger *zap.Logger) (*Registry, error) {
+	r := &Registry{
+		dir:             dir,
+		reload:          reload,
+		overrideDefault: overrideDefault,
+		templates:       map[string]*Template{DefaultName: defaultTemplate},
+		logger:          logger,
+	}
+
+	if dir == "" {
+		return r, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template directory: %w", err)
+	}
+
+	loadedFrom := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !isTemplateFile(entry.Name()) {
+			continue
+		}
+		tmpl, err := r.loadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if file, ok := loadedFrom[tmpl.Name]; ok {
+			return nil, fmt.Errorf("template %q is defined by both %s and %s", tmpl.Name, file, entry.Name())
+		}
+		loadedFrom[tmpl.Name] = entry.Name()
+		r.templates[tmpl.Name] = tmpl
+		logger.Info("Report template loaded", zap.String("template", tmpl.Name), zap.String("file", entry.Name()))
+	}
+
+	return r, nil
+}
+
+// Get returns the named template, or the default one when name is empty
+func (r *Registry) Get(name string) (*Template, error) {
+	if name == "" {
+		name = DefaultName
+	}
+	if !ValidName(name) {
+		return nil, ErrTemplateNotFound
+	}
+
+	if r.reload && r.dir != "" && (name != DefaultName || r.overrideDefault) {
+		if tmpl, err := r.reloadTemplate(name); err != nil {
+			r.logger.Warn("Failed to reload template, using the loaded version",
+				zap.String("template", name),
+				zap.Error(err))
+		} else if tmpl != nil {
+			return tmpl, nil
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tmpl, ok := r.templates[name]
+	if !ok {
+		return nil, ErrTemplateNotFound
+	}
+	return tmpl, nil
+}
+
+// Names lists the available templates
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reloadTemplate reads the template file named after name, if any
+func (r *Registry) reloadTemplate(name string) (*Template, error) {
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		path := filepath.Join(r.dir, name+ext)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		tmpl, err := r.loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		r.mu.Lock()
+		r.templates[tmpl.Name] = tmpl
+		r.mu.Unlock()
+		return tmpl, nil
+	}
+	return nil, nil
+}
+
+// loadFile reads a template file, which must declare the template named
+// after the file, so that a lookup by name never returns another template
+func (r *Registry) loadFile(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+	}
+
+	tmpl, err := Parse(data, defaultTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", path, err)
+	}
+	if name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)); tmpl.Name != name {
+		return nil, fmt.Errorf("invalid template %s: declares name %q instead of %q", path, tmpl.Name, name)
+	}
+	if tmpl.Name == DefaultName && !r.overrideDefault {
+		return nil, fmt.Errorf("invalid template %s: the built-in %q template can only be replaced with TEMPLATE_OVERRIDE_DEFAULT", path, DefaultName)
+	}
+
+	if tmpl.Logo != nil && tmpl.Logo.Path != "" {
+		if err := loadLogo(tmpl.Logo, filepath.Dir(path)); err != nil {
+			return nil, fmt.Errorf("invalid template %s: %w", path, err)
+		}
+		// A new logo image changes the rendered output as well
+		tmpl.fingerprint = fingerprint(data, tmpl.Logo.Data)
+	}
+
+	return tmpl, nil
+}
+
+func loadLogo(logo *Logo, dir string) error {
+	path := logo.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		logo.ImageType = "PNG"
+	case ".jpg", ".jpeg":
+		logo.ImageType = "JPG"
+	default:
+		return fmt.Errorf("logo %s must be a PNG or JPEG image", logo.Path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read logo: %w", err)
+	}
+	logo.Data = data
+
+	if logo.Width == 0 {
+		logo.Width = 30
+	}
+	return nil
+}
+
+func isTemplateFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const brandedTemplate = `
+name: branded
+title: Academy Report
+sections:
+  - title: Identity
+    fields:
+      - { label: Name, field: name }
+`
+
+func TestNewRegistry_NoDirectory(t *testing.T) {
+	registry, err := NewRegistry("", false, false, zap.NewNop())
+	require.NoError(t, err)
+
+	tmpl, err := registry.Get("")
+	require.NoError(t, err)
+	assert.Same(t, Default(), tmpl)
+	assert.Equal(t, []string{DefaultName}, registry.Names())
+}
+
+func TestNewRegistry_LoadsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "branded.yaml"), []byte(brandedTemplate), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0644))
+
+	registry, err := NewRegistry(dir, false, false, zap.NewNop())
+	require.NoError(t, err)
+
+	tmpl, err := registry.Get("branded")
+	require.NoError(t, err)
+	assert.Equal(t, "Academy Report", tmpl.Title)
+	assert.Equal(t, []string{"branded", DefaultName}, registry.Names())
+}
+
+func TestNewRegistry_InvalidTemplate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("name: broken"), 0644))
+
+	_, err := NewRegistry(dir, false, false, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestNewRegistry_LogoMustBeImage(t *testing.T) {
+	dir := t.TempDir()
+	source := brandedTemplate + "logo: { path: logo.gif }\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "branded.yaml"), []byte(source), 0644))
+
+	_, err := NewRegistry(dir, false, false, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestRegistry_Get_NotFound(t *testing.T) {
+	registry, err := NewRegistry("", false, false, zap.NewNop())
+	require.NoError(t, err)
+
+	_, err = registry.Get("missing")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+
+	_, err = registry.Get("../etc/passwd")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestRegistry_Get_Reload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "branded.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(brandedTemplate), 0644))
+
+	registry, err := NewRegistry(dir, true, false, zap.NewNop())
+	require.NoError(t, err)
+
+	first, err := registry.Get("branded")
+	require.NoError(t, err)
+
+	updated := []byte(brandedTemplate + "show_generated_on: true\n")
+	require.NoError(t, os.WriteFile(path, updated, 0644))
+
+	second, err := registry.Get("branded")
+	require.NoError(t, err)
+	assert.True(t, second.ShowGeneratedOn)
+	assert.NotEqual(t, first.CacheKey(), second.CacheKey())
+
+	// A broken edit keeps serving the last valid version
+	require.NoError(t, os.WriteFile(path, []byte("name: branded"), 0644))
+
+	third, err := registry.Get("branded")
+	require.NoError(t, err)
+	assert.Equal(t, second.CacheKey(), third.CacheKey())
+}
+
+func TestNewRegistry_NameMustMatchFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "brand-a.yaml"), []byte(brandedTemplate), 0644))
+
+	_, err := NewRegistry(dir, false, false, zap.NewNop())
+	assert.ErrorContains(t, err, `declares name "branded" instead of "brand-a"`)
+}
+
+func TestRegistry_Get_ReloadRejectsRenamedTemplate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "branded.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(brandedTemplate), 0644))
+	registry, err := NewRegistry(dir, true, false, zap.NewNop())
+	require.NoError(t, err)
+	loaded, err := registry.Get("branded")
+	require.NoError(t, err)
+
+	// an edit declaring another name neither answers for nor replaces it
+	renamed := strings.Replace(brandedTemplate, "name: branded", "name: other", 1)
+	require.NoError(t, os.WriteFile(path, []byte(renamed), 0644))
+
+	tmpl, err := registry.Get("branded")
+	require.NoError(t, err)
+	assert.Equal(t, loaded.CacheKey(), tmpl.CacheKey())
+	_, err = registry.Get("other")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestNewRegistry_DuplicateNames(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "branded.yaml"), []byte(brandedTemplate), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "branded.yml"), []byte(brandedTemplate), 0644))
+
+	_, err := NewRegistry(dir, false, false, zap.NewNop())
+	assert.ErrorContains(t, err, `template "branded" is defined by both branded.yaml and branded.yml`)
+}
+
+func TestNewRegistry_OverrideDefault(t *testing.T) {
+	dir := t.TempDir()
+	source := strings.Replace(brandedTemplate, "name: branded", "name: default", 1)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "default.yaml"), []byte(source), 0644))
+
+	_, err := NewRegistry(dir, false, false, zap.NewNop())
+	assert.ErrorContains(t, err, "TEMPLATE_OVERRIDE_DEFAULT")
+
+	registry, err := NewRegistry(dir, false, true, zap.NewNop())
+	require.NoError(t, err)
+	tmpl, err := registry.Get("")
+	require.NoError(t, err)
+	assert.Equal(t, "Academy Report", tmpl.Title)
+}
@@ -0,0 +1,286 @@
+package templates
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+)
+
+// Field formats understood by the renderers
+const (
+	FormatText   = "text"   // string value, "N/A" when empty
+	FormatDate   = "date"   // RFC 3339 date, "N/A" when empty
+	FormatNumber = "number" // integer, "N/A" when zero
+	FormatID     = "id"     // integer printed as-is
+	FormatStatus = "status" // boolean printed as Active/Inactive
+)
+
+// Template describes the layout of a student report
+type Template struct {
+	Name            string    `yaml:"name"`
+	Title           string    `yaml:"title"`
+	ShowGeneratedOn bool      `yaml:"show_generated_on"`
+	Logo            *Logo     `yaml:"logo"`
+	Style           Style     `yaml:"style"`
+	Sections        []Section `yaml:"sections"`
+	Footer          Footer    `yaml:"footer"`
+
+	fingerprint string
+}
+
+type Style struct {
+	FontFamily    string  `yaml:"font_family"`
+	TitleSize     float64 `yaml:"title_size"`
+	SubtitleSize  float64 `yaml:"subtitle_size"`
+	SectionSize   float64 `yaml:"section_size"`
+	BodySize      float64 `yaml:"body_size"`
+	FooterSize    float64 `yaml:"footer_size"`
+	TitleColor    Color   `yaml:"title_color"`
+	SubtitleColor Color   `yaml:"subtitle_color"`
+	SectionFill   Color   `yaml:"section_fill"`
+	SectionText   Color   `yaml:"section_text"`
+	LabelFill     Color   `yaml:"label_fill"`
+	ValueFill     Color   `yaml:"value_fill"`
+	TextColor     Color   `yaml:"text_color"`
+	FooterColor   Color   `yaml:"footer_color"`
+	LabelWidth    float64 `yaml:"label_width"`
+	ValueWidth    float64 `yaml:"value_width"`
+	RowHeight     float64 `yaml:"row_height"`
+}
+
+// Logo is an image drawn at the top of the first page. Path is resolved
+// relative to the template directory and read when the template is loaded.
+type Logo struct {
+	Path  string  `yaml:"path"`
+	Width float64 `yaml:"width"`
+
+	Data      []byte `yaml:"-"`
+	ImageType string `yaml:"-"`
+}
+
+type Section struct {
+	Title  string  `yaml:"title"`
+	Fields []Field `yaml:"fields"`
+}
+
+// Field binds a label to a dto.Student property, referenced by its JSON name
+type Field struct {
+	Label  string `yaml:"label"`
+	Field  string `yaml:"field"`
+	Format string `yaml:"format"`
+}
+
+type Footer struct {
+	Lines        []string `yaml:"lines"`
+	ShowReportID bool     `yaml:"show_report_id"`
+}
+
+// Color is a hex RGB color such as "#3498DB"
+type Color string
+
+func (c Color) RGB() (r, g, b int) {
+	v, _ := strconv.ParseUint(strings.TrimPrefix(string(c), "#"), 16, 32)
+	return int(v >> 16 & 0xFF), int(v >> 8 & 0xFF), int(v & 0xFF)
+}
+
+func (c Color) valid() bool {
+	s := strings.TrimPrefix(string(c), "#")
+	if len(s) != 6 || !strings.HasPrefix(string(c), "#") {
+		return false
+	}
+	_, err := strconv.ParseUint(s, 16, 32)
+	return err == nil
+}
+
+// Fingerprint identifies the template content. It changes whenever the
+// template source changes, so it can take part in cache keys.
+func (t *Template) Fingerprint() string {
+	return t.fingerprint
+}
+
+// CacheKey identifies the rendered variant produced by this template
+func (t *Template) CacheKey() string {
+	return t.Name + "-" + t.fingerprint
+}
+
+// MapFields returns a copy of t in which every field is replaced by the
+// result of fn, or dropped when fn returns false. Sections left without
+// fields are dropped as well. The copy keeps the fingerprint of t.
+func (t *Template) MapFields(fn func(Field) (Field, bool)) *Template {
+	mapped := *t
+	mapped.Sections = make([]Section, 0, len(t.Sections))
+	for _, section := range t.Sections {
+		fields := make([]Field, 0, len(section.Fields))
+		for _, field := range section.Fields {
+			if field, keep := fn(field); keep {
+				fields = append(fields, field)
+			}
+		}
+		if len(fields) > 0 {
+			mapped.Sections = append(mapped.Sections, Section{Title: section.Title, Fields: fields})
+		}
+	}
+	return &mapped
+}
+
+// studentFields maps the JSON name of every dto.Student property to its index
+var studentFields = func() map[string]int {
+	fields := make(map[string]int)
+	st := reflect.TypeOf(dto.Student{})
+	for i := 0; i < st.NumField(); i++ {
+		name := strings.Split(st.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}()
+
+// FieldValue returns the value of the student property bound by field
+func FieldValue(student *dto.Student, field string) (interface{}, bool) {
+	index, ok := studentFields[field]
+	if !ok {
+		return nil, false
+	}
+	return reflect.ValueOf(student).Elem().Field(index).Interface(), true
+}
+
+// Parse decodes a YAML (or JSON) template, fills omitted style values from
+// base and validates the result. base may be nil.
+func Parse(data []byte, base *Template) (*Template, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var tmpl Template
+	if err := decoder.Decode(&tmpl); err != nil {
+		return nil, fmt.Errorf("failed to decode template: %w", err)
+	}
+
+	if base != nil {
+		tmpl.inherit(base)
+	}
+
+	if err := tmpl.Validate(); err != nil {
+		return nil, err
+	}
+
+	tmpl.fingerprint = fingerprint(data)
+	return &tmpl, nil
+}
+
+func fingerprint(parts ...[]byte) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write(part)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:8]
+}
+
+// Validate checks the bindings, formats and colors of the template
+func (t *Template) Validate() error {
+	if !ValidName(t.Name) {
+		return fmt.Errorf("template name %q must match [a-z0-9_-]{1,64}", t.Name)
+	}
+	if len(t.Sections) == 0 {
+		return fmt.Errorf("template %q has no sections", t.Name)
+	}
+
+	for _, section := range t.Sections {
+		for _, field := range section.Fields {
+			value, ok := FieldValue(&dto.Student{}, field.Field)
+			if !ok {
+				return fmt.Errorf("template %q: unknown student field %q", t.Name, field.Field)
+			}
+			if err := checkFormat(field, value); err != nil {
+				return fmt.Errorf("template %q: %w", t.Name, err)
+			}
+		}
+	}
+
+	colors := map[string]Color{
+		"title_color":    t.Style.TitleColor,
+		"subtitle_color": t.Style.SubtitleColor,
+		"section_fill":   t.Style.SectionFill,
+		"section_text":   t.Style.SectionText,
+		"label_fill":     t.Style.LabelFill,
+		"value_fill":     t.Style.ValueFill,
+		"text_color":     t.Style.TextColor,
+		"footer_color":   t.Style.FooterColor,
+	}
+	for name, color := range colors {
+		if !color.valid() {
+			return fmt.Errorf("template %q: %s %q is not a #RRGGBB color", t.Name, name, color)
+		}
+	}
+
+	return nil
+}
+
+func checkFormat(field Field, value interface{}) error {
+	format := field.Format
+	if format == "" {
+		format = FormatText
+	}
+
+	var ok bool
+	switch format {
+	case FormatText, FormatDate:
+		_, ok = value.(string)
+	case FormatNumber, FormatID:
+		_, ok = value.(int)
+	case FormatStatus:
+		_, ok = value.(bool)
+	default:
+		return fmt.Errorf("field %q: unknown format %q", field.Field, field.Format)
+	}
+
+	if !ok {
+		return fmt.Errorf("field %q: format %q does not apply to its type", field.Field, format)
+	}
+	return nil
+}
+
+// inherit fills the style values left empty with the ones of base
+func (t *Template) inherit(base *Template) {
+	s, b := &t.Style, base.Style
+
+	if s.FontFamily == "" {
+		s.FontFamily = b.FontFamily
+	}
+	for _, pair := range []struct{ dst, src *float64 }{
+		{&s.TitleSize, &b.TitleSize},
+		{&s.SubtitleSize, &b.SubtitleSize},
+		{&s.SectionSize, &b.SectionSize},
+		{&s.BodySize, &b.BodySize},
+		{&s.FooterSize, &b.FooterSize},
+		{&s.LabelWidth, &b.LabelWidth},
+		{&s.ValueWidth, &b.ValueWidth},
+		{&s.RowHeight, &b.RowHeight},
+	} {
+		if *pair.dst == 0 {
+			*pair.dst = *pair.src
+		}
+	}
+	for _, pair := range []struct{ dst, src *Color }{
+		{&s.TitleColor, &b.TitleColor},
+		{&s.SubtitleColor, &b.SubtitleColor},
+		{&s.SectionFill, &b.SectionFill},
+		{&s.SectionText, &b.SectionText},
+		{&s.LabelFill, &b.LabelFill},
+		{&s.ValueFill, &b.ValueFill},
+		{&s.TextColor, &b.TextColor},
+		{&s.FooterColor, &b.FooterColor},
+	} {
+		if *pair.dst == "" {
+			*pair.dst = *pair.src
+		}
+	}
+}
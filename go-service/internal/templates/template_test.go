@@ -0,0 +1,119 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wbentaleb/student-report-service/internal/dto"
+)
+
+func TestDefault_ReproducesBuiltInLayout(t *testing.T) {
+	tmpl := Default()
+
+	assert.Equal(t, DefaultName, tmpl.Name)
+	assert.Equal(t, "Student Report", tmpl.Title)
+	assert.True(t, tmpl.ShowGeneratedOn)
+	require.Len(t, tmpl.Sections, 5)
+	assert.Equal(t, "Personal Information", tmpl.Sections[0].Title)
+	assert.Equal(t, "Address Information", tmpl.Sections[4].Title)
+	assert.True(t, tmpl.Footer.ShowReportID)
+	assert.NotEmpty(t, tmpl.Fingerprint())
+}
+
+func TestParse_InheritsStyleFromBase(t *testing.T) {
+	source := []byte(`
+name: branded
+title: Academy Report
+style:
+  section_fill: "#AA0000"
+sections:
+  - title: Identity
+    fields:
+      - { label: Name, field: name }
+`)
+
+	tmpl, err := Parse(source, Default())
+	require.NoError(t, err)
+
+	assert.Equal(t, Color("#AA0000"), tmpl.Style.SectionFill)
+	assert.Equal(t, Default().Style.FontFamily, tmpl.Style.FontFamily)
+	assert.Equal(t, Default().Style.LabelWidth, tmpl.Style.LabelWidth)
+	assert.Equal(t, "branded-"+tmpl.Fingerprint(), tmpl.CacheKey())
+}
+
+func TestParse_JSON(t *testing.T) {
+	source := []byte(`{"name": "compact", "sections": [{"title": "Student", "fields": [{"label": "Roll", "field": "roll", "format": "number"}]}]}`)
+
+	tmpl, err := Parse(source, Default())
+	require.NoError(t, err)
+
+	assert.Equal(t, "compact", tmpl.Name)
+	assert.Equal(t, FormatNumber, tmpl.Sections[0].Fields[0].Format)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		source string
+	}{
+		{"unknown field binding", `{name: bad, sections: [{title: S, fields: [{label: L, field: nickname}]}]}`},
+		{"format mismatch", `{name: bad, sections: [{title: S, fields: [{label: L, field: roll, format: date}]}]}`},
+		{"unknown format", `{name: bad, sections: [{title: S, fields: [{label: L, field: name, format: upper}]}]}`},
+		{"invalid color", `{name: bad, style: {title_color: red}, sections: [{title: S, fields: [{label: L, field: name}]}]}`},
+		{"invalid name", `{name: "Bad Name", sections: [{title: S, fields: [{label: L, field: name}]}]}`},
+		{"no sections", `{name: bad}`},
+		{"unknown key", `{name: bad, colour: red, sections: [{title: S, fields: [{label: L, field: name}]}]}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.source), Default())
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	student := &dto.Student{ID: 7, Name: "Jane", SystemAccess: true}
+
+	value, ok := FieldValue(student, "name")
+	assert.True(t, ok)
+	assert.Equal(t, "Jane", value)
+
+	value, ok = FieldValue(student, "id")
+	assert.True(t, ok)
+	assert.Equal(t, 7, value)
+
+	_, ok = FieldValue(student, "unknown")
+	assert.False(t, ok)
+}
+
+func TestTemplate_MapFields(t *testing.T) {
+	tmpl := Default()
+
+	// keep the guardian section only, with its phone printed as a date
+	mapped := tmpl.MapFields(func(field Field) (Field, bool) {
+		if field.Field == "guardianPhone" {
+			field.Format = FormatDate
+		}
+		return field, strings.HasPrefix(field.Field, "guardian") || field.Field == "relationOfGuardian"
+	})
+
+	require.Len(t, mapped.Sections, 1)
+	assert.Equal(t, "Guardian Information", mapped.Sections[0].Title)
+	require.Len(t, mapped.Sections[0].Fields, 3)
+	assert.Equal(t, FormatDate, mapped.Sections[0].Fields[1].Format)
+	assert.Equal(t, tmpl.CacheKey(), mapped.CacheKey())
+
+	// the original is left untouched
+	require.Len(t, tmpl.Sections, 5)
+	assert.Empty(t, tmpl.Sections[3].Fields[1].Format)
+}
+
+func TestColor_RGB(t *testing.T) {
+	r, g, b := Color("#3498DB").RGB()
+	assert.Equal(t, []int{52, 152, 219}, []int{r, g, b})
+}
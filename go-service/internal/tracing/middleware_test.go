@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider keeping the ended spans in memory
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return exporter
+}
+
+func newTracedRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("RequestID", "req-1")
+		c.Next()
+	})
+	router.Use(Middleware())
+	router.GET("/api/v1/students/:id/report", func(c *gin.Context) {
+		_, span := Start(c.Request.Context(), "child")
+		span.End()
+		c.Status(http.StatusServiceUnavailable)
+	})
+	return router
+}
+
+func TestMiddleware_StartsServerSpanPerRoute(t *testing.T) {
+	exporter := recordSpans(t)
+	router := newTracedRouter()
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/students/42/report", nil))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	child, server := spans[0], spans[1]
+	assert.Equal(t, "GET /api/v1/students/:id/report", server.Name)
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, codes.Error, server.Status.Code)
+	assert.Contains(t, server.Attributes, attribute.String("http.route", "/api/v1/students/:id/report"))
+	assert.Contains(t, server.Attributes, attribute.Int("http.response.status_code", http.StatusServiceUnavailable))
+	assert.Contains(t, server.Attributes, attribute.String("request.id", "req-1"))
+	assert.Equal(t, server.SpanContext.SpanID(), child.Parent.SpanID())
+}
+
+func TestMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	exporter := recordSpans(t)
+	router := newTracedRouter()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/students/42/report", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	server := exporter.GetSpans()[1]
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+	assert.True(t, server.Parent.IsRemote())
+}
+
+func TestSetup_WithoutEndpointOnlyPropagates(t *testing.T) {
+	recordSpans(t)
+
+	shutdown, err := Setup(t.Context(), Options{ServiceName: "test"})
+
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(t.Context()))
+	assert.Contains(t, otel.GetTextMapPropagator().Fields(), "traceparent")
+}
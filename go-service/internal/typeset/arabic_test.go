@@ -0,0 +1,52 @@
+package typeset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShapeArabic(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []rune
+	}{
+		{
+			name: "positional forms",
+			text: "بيت",
+			want: []rune{0xFE91, 0xFEF4, 0xFE96},
+		},
+		{
+			name: "right-joining letter breaks the word",
+			text: "دار",
+			want: []rune{0xFEA9, 0xFE8D, 0xFEAD},
+		},
+		{
+			name: "isolated lam-alef",
+			text: "لا",
+			want: []rune{0xFEFB},
+		},
+		{
+			name: "final lam-alef",
+			text: "سلام",
+			want: []rune{0xFEB3, 0xFEFC, 0xFEE1},
+		},
+		{
+			name: "harakat do not break joining",
+			text: "بَت",
+			want: []rune{0xFE91, 0x064E, 0xFE96},
+		},
+		{
+			name: "non-Arabic text is untouched",
+			text: "Ali 12",
+			want: []rune("Ali 12"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, shapeArabic([]rune(tt.text)))
+		})
+	}
+}
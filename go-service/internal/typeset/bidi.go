@@ -0,0 +1,261 @@
+package typeset
+
+import "golang.org/x/text/unicode/bidi"
+
+// The reordering below is a reduced form of the Unicode Bidirectional
+// Algorithm (UAX #9) for single-line text: explicit embeddings, isolates and
+// bracket pairs are not resolved, which is enough for the short values
+// printed in a report.
+
+// mirrored holds the characters whose glyph is mirrored in right-to-left runs
+var mirrored = map[rune]rune{
+	'(': ')', ')': '(',
+	'[': ']', ']': '[',
+	'{': '}', '}': '{',
+	'<': '>', '>': '<',
+	'«': '»', '»': '«',
+}
+
+// paragraphLevel returns 1 when the first strong character of text is
+// right-to-left, 0 otherwise
+func paragraphLevel(classes []bidi.Class) int {
+	for _, class := range classes {
+		switch class {
+		case bidi.L:
+			return 0
+		case bidi.R, bidi.AL:
+			return 1
+		}
+	}
+	return 0
+}
+
+func classify(text []rune) []bidi.Class {
+	classes := make([]bidi.Class, len(text))
+	for i, r := range text {
+		props, _ := bidi.LookupRune(r)
+		class := props.Class()
+		if class >= bidi.Control {
+			// Explicit formatting characters are not supported
+			class = bidi.BN
+		}
+		classes[i] = class
+	}
+	return classes
+}
+
+func isNeutral(class bidi.Class) bool {
+	switch class {
+	case bidi.B, bidi.S, bidi.WS, bidi.ON, bidi.BN:
+		return true
+	}
+	return false
+}
+
+// resolveWeak applies rules W1 to W7
+func resolveWeak(classes []bidi.Class, sor bidi.Class) {
+	// W1: non-spacing marks take the type of the previous character
+	prev := sor
+	for i, class := range classes {
+		if class == bidi.NSM {
+			classes[i] = prev
+		}
+		prev = classes[i]
+	}
+
+	// W2 and W3: European numbers after Arabic letters are Arabic numbers,
+	// and Arabic letters are right-to-left
+	lastStrong := sor
+	for i, class := range classes {
+		switch class {
+		case bidi.L, bidi.R, bidi.AL:
+			lastStrong = class
+		case bidi.EN:
+			if lastStrong == bidi.AL {
+				classes[i] = bidi.AN
+			}
+		}
+	}
+	for i, class := range classes {
+		if class == bidi.AL {
+			classes[i] = bidi.R
+		}
+	}
+
+	// W4: a single separator between two numbers of the same type joins them
+	for i := 1; i+1 < len(classes); i++ {
+		before, after := classes[i-1], classes[i+1]
+		switch {
+		case classes[i] == bidi.ES && before == bidi.EN && after == bidi.EN:
+			classes[i] = bidi.EN
+		case classes[i] == bidi.CS && before == after && (before == bidi.EN || before == bidi.AN):
+			classes[i] = before
+		}
+	}
+
+	// W5: terminators next to European numbers are European numbers
+	for i := 0; i < len(classes); {
+		if classes[i] != bidi.ET {
+			i++
+			continue
+		}
+		end := i
+		for end < len(classes) && classes[end] == bidi.ET {
+			end++
+		}
+		if (i > 0 && classes[i-1] == bidi.EN) || (end < len(classes) && classes[end] == bidi.EN) {
+			for j := i; j < end; j++ {
+				classes[j] = bidi.EN
+			}
+		}
+		i = end
+	}
+
+	// W6: remaining separators and terminators are neutral
+	for i, class := range classes {
+		switch class {
+		case bidi.ES, bidi.ET, bidi.CS:
+			classes[i] = bidi.ON
+		}
+	}
+
+	// W7: European numbers in left-to-right context are left-to-right
+	lastStrong = sor
+	for i, class := range classes {
+		switch class {
+		case bidi.L, bidi.R:
+			lastStrong = class
+		case bidi.EN:
+			if lastStrong == bidi.L {
+				classes[i] = bidi.L
+			}
+		}
+	}
+}
+
+// resolveNeutral applies rules N1 and N2: a sequence of neutrals takes the
+// direction of the text around it when both sides agree, the paragraph
+// direction otherwise
+func resolveNeutral(classes []bidi.Class, sor bidi.Class) {
+	strong := func(class bidi.Class) bidi.Class {
+		if class == bidi.EN || class == bidi.AN {
+			return bidi.R
+		}
+		return class
+	}
+
+	for i := 0; i < len(classes); {
+		if !isNeutral(classes[i]) {
+			i++
+			continue
+		}
+		end := i
+		for end < len(classes) && isNeutral(classes[end]) {
+			end++
+		}
+
+		before, after := sor, sor
+		if i > 0 {
+			before = strong(classes[i-1])
+		}
+		if end < len(classes) {
+			after = strong(classes[end])
+		}
+
+		resolved := sor
+		if before == after {
+			resolved = before
+		}
+		for j := i; j < end; j++ {
+			classes[j] = resolved
+		}
+		i = end
+	}
+}
+
+// reorder returns text in visual order, left to right, with the characters of
+// right-to-left runs mirrored. rtl reports the paragraph direction.
+func reorder(text []rune) (visual []rune, rtl bool) {
+	classes := classify(text)
+	base := paragraphLevel(classes)
+	sor := bidi.L
+	if base == 1 {
+		sor = bidi.R
+	}
+
+	original := append([]bidi.Class(nil), classes...)
+	resolveWeak(classes, sor)
+	resolveNeutral(classes, sor)
+
+	// I1 and I2: implicit levels
+	levels := make([]int, len(text))
+	maxLevel, minOddLevel := base, 0
+	for i, class := range classes {
+		level := base
+		switch {
+		case base%2 == 0 && class == bidi.R:
+			level++
+		case base%2 == 0 && (class == bidi.AN || class == bidi.EN):
+			level += 2
+		case base%2 == 1 && (class == bidi.L || class == bidi.EN || class == bidi.AN):
+			level++
+		}
+		levels[i] = level
+	}
+
+	// L1: trailing whitespace goes back to the paragraph level
+	for i := len(text) - 1; i >= 0; i-- {
+		if original[i] != bidi.WS && original[i] != bidi.S && original[i] != bidi.BN {
+			break
+		}
+		levels[i] = base
+	}
+
+	for _, level := range levels {
+		if level > maxLevel {
+			maxLevel = level
+		}
+		if level%2 == 1 && (minOddLevel == 0 || level < minOddLevel) {
+			minOddLevel = level
+		}
+	}
+
+	visual = make([]rune, len(text))
+	for i, r := range text {
+		if levels[i]%2 == 1 {
+			if m, ok := mirrored[r]; ok {
+				r = m
+			}
+		}
+		visual[i] = r
+	}
+
+	// L2: reverse every run at or above each level, from the highest level
+	// down to the lowest odd one
+	if minOddLevel == 0 {
+		return visual, base == 1
+	}
+	for level := maxLevel; level >= minOddLevel; level-- {
+		for i := 0; i < len(visual); {
+			if levels[i] < level {
+				i++
+				continue
+			}
+			end := i
+			for end < len(visual) && levels[end] >= level {
+				end++
+			}
+			reverseRange(visual[i:end])
+			reverseRange(levels[i:end])
+			i = end
+		}
+	}
+
+	return visual, base == 1
+}
+
+func reverseRange[T any](s []T) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
@@ -0,0 +1,33 @@
+package typeset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReorder(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantRTL bool
+	}{
+		{name: "left to right", text: "abc def", want: "abc def"},
+		{name: "right to left", text: "אבג דהו", want: "והד גבא", wantRTL: true},
+		{name: "numbers keep their order", text: "אבג 123", want: "123 גבא", wantRTL: true},
+		{name: "embedded right to left", text: "ab אבג cd", want: "ab גבא cd"},
+		{name: "brackets are mirrored", text: "א (ב)", want: "(ב) א", wantRTL: true},
+		{name: "trailing spaces follow the paragraph", text: "אב  ", want: "  בא", wantRTL: true},
+		{name: "no strong character", text: "12-34", want: "12-34"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visual, rtl := reorder([]rune(tt.text))
+
+			assert.Equal(t, tt.want, string(visual))
+			assert.Equal(t, tt.wantRTL, rtl)
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Package typeset prepares Unicode text for the PDF renderer: it picks a font
+// for every glyph, shapes Arabic letters and lays bidirectional text out in
+// visual order.
+package typeset
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Font styles, as understood by gofpdf
+const (
+	StyleRegular = ""
+	StyleBold    = "B"
+	StyleItalic  = "I"
+)
+
+// BundledFamily is the TrueType family shipped with the service. It covers
+// Latin, Greek, Cyrillic, Hebrew and Arabic.
+const BundledFamily = "DejaVuSansCondensed"
+
+//go:embed fonts/*.ttf
+var bundledFonts embed.FS
+
+// coreFonts are the standard PDF fonts built into gofpdf. They only encode
+// the Windows-1252 character set.
+var coreFonts = map[string]bool{
+	"arial":     true,
+	"helvetica": true,
+	"courier":   true,
+	"times":     true,
+}
+
+// Family is a TrueType font family with up to three styles
+type Family struct {
+	Name  string
+	faces map[string]*face
+}
+
+type face struct {
+	data []byte
+	font *sfnt.Font
+}
+
+// Face returns the font file of the given style, falling back to the regular
+// face when the family has no such style.
+func (f *Family) Face(style string) (data []byte, actualStyle string) {
+	if face, ok := f.faces[style]; ok {
+		return face.data, style
+	}
+	return f.faces[StyleRegular].data, StyleRegular
+}
+
+func (f *Family) covers(style string, r rune) bool {
+	face, ok := f.faces[style]
+	if !ok {
+		return false
+	}
+	var buf sfnt.Buffer
+	index, err := face.font.GlyphIndex(&buf, r)
+	return err == nil && index != 0
+}
+
+// FontSet is the ordered list of families used for glyph fallback
+type FontSet struct {
+	families []*Family
+	byName   map[string]*Family
+}
+
+// LoadFontSet returns the bundled family followed by the families found in
+// dir, which may be empty. Files are grouped by family name: Name.ttf is the
+// regular face, Name-Bold.ttf and Name-Italic.ttf (or Name-Oblique.ttf) the
+// other styles.
+func LoadFontSet(dir string) (*FontSet, error) {
+	set := &FontSet{byName: make(map[string]*Family)}
+
+	bundled, err := loadBundledFamily()
+	if err != nil {
+		return nil, err
+	}
+	set.add(bundled)
+
+	if dir == "" {
+		return set, nil
+	}
+
+	families, err := loadFamilies(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, family := range families {
+		if _, exists := set.byName[family.Name]; exists {
+			return nil, fmt.Errorf("font family %s is already defined", family.Name)
+		}
+		set.add(family)
+	}
+	return set, nil
+}
+
+// DefaultFontSet returns a set holding only the bundled family
+func DefaultFontSet() *FontSet {
+	return defaultFontSet
+}
+
+var defaultFontSet = func() *FontSet {
+	set, err := LoadFontSet("")
+	if err != nil {
+		panic(fmt.Sprintf("invalid bundled fonts: %v", err))
+	}
+	return set
+}()
+
+// Families lists the families in fallback order
+func (s *FontSet) Families() []*Family {
+	return s.families
+}
+
+// Family returns the named family, if it is part of the set
+func (s *FontSet) Family(name string) (*Family, bool) {
+	family, ok := s.byName[name]
+	return family, ok
+}
+
+// IsCoreFont reports whether name designates a standard PDF font
+func IsCoreFont(name string) bool {
+	return coreFonts[strings.ToLower(name)]
+}
+
+func (s *FontSet) add(family *Family) {
+	s.families = append(s.families, family)
+	s.byName[family.Name] = family
+}
+
+func loadBundledFamily() (*Family, error) {
+	family := &Family{Name: BundledFamily, faces: make(map[string]*face)}
+	for style, file := range map[string]string{
+		StyleRegular: "fonts/DejaVuSansCondensed.ttf",
+		StyleBold:    "fonts/DejaVuSansCondensed-Bold.ttf",
+		StyleItalic:  "fonts/DejaVuSansCondensed-Oblique.ttf",
+	} {
+		data, err := bundledFonts.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundled font: %w", err)
+		}
+		if err := family.addFace(style, data); err != nil {
+			return nil, fmt.Errorf("invalid bundled font %s: %w", file, err)
+		}
+	}
+	return family, nil
+}
+
+func loadFamilies(dir string) ([]*Family, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font directory: %w", err)
+	}
+
+	byName := make(map[string]*Family)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".ttf") {
+			continue
+		}
+
+		name, style := parseFontFileName(entry.Name())
+		family, ok := byName[name]
+		if !ok {
+			family = &Family{Name: name, faces: make(map[string]*face)}
+			byName[name] = family
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read font %s: %w", entry.Name(), err)
+		}
+		if err := family.addFace(style, data); err != nil {
+			return nil, fmt.Errorf("invalid font %s: %w", entry.Name(), err)
+		}
+	}
+
+	families := make([]*Family, 0, len(byName))
+	for _, family := range byName {
+		if _, ok := family.faces[StyleRegular]; !ok {
+			return nil, fmt.Errorf("font family %s has no regular face (%s.ttf)", family.Name, family.Name)
+		}
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+	return families, nil
+}
+
+func parseFontFileName(fileName string) (name, style string) {
+	base := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	for suffix, style := range map[string]string{
+		"-Bold":    StyleBold,
+		"-Italic":  StyleItalic,
+		"-Oblique": StyleItalic,
+	} {
+		if strings.HasSuffix(base, suffix) {
+			return strings.TrimSuffix(base, suffix), style
+		}
+	}
+	return base, StyleRegular
+}
+
+func (f *Family) addFace(style string, data []byte) error {
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return err
+	}
+	f.faces[style] = &face{data: data, font: font}
+	return nil
+}
+
+func coreCovers(r rune) bool {
+	_, ok := charmap.Windows1252.EncodeRune(r)
+	return ok
+}
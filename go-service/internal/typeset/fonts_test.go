@@ -0,0 +1,79 @@
+package typeset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func copyBundledFont(t *testing.T, dir, name string) {
+	t.Helper()
+	data, err := bundledFonts.ReadFile("fonts/DejaVuSansCondensed.ttf")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0644))
+}
+
+func TestDefaultFontSet(t *testing.T) {
+	families := DefaultFontSet().Families()
+
+	require.Len(t, families, 1)
+	assert.Equal(t, BundledFamily, families[0].Name)
+	for _, style := range []string{StyleRegular, StyleBold, StyleItalic} {
+		data, actual := families[0].Face(style)
+		assert.NotEmpty(t, data)
+		assert.Equal(t, style, actual)
+	}
+}
+
+func TestLoadFontSet_Directory(t *testing.T) {
+	dir := t.TempDir()
+	copyBundledFont(t, dir, "Campus.ttf")
+	copyBundledFont(t, dir, "Campus-Bold.ttf")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "LICENSE.txt"), []byte("ignored"), 0644))
+
+	set, err := LoadFontSet(dir)
+	require.NoError(t, err)
+
+	require.Len(t, set.Families(), 2)
+	assert.Equal(t, BundledFamily, set.Families()[0].Name)
+
+	family, ok := set.Family("Campus")
+	require.True(t, ok)
+	_, actual := family.Face(StyleBold)
+	assert.Equal(t, StyleBold, actual)
+	_, actual = family.Face(StyleItalic)
+	assert.Equal(t, StyleRegular, actual)
+}
+
+func TestLoadFontSet_MissingRegularFace(t *testing.T) {
+	dir := t.TempDir()
+	copyBundledFont(t, dir, "Campus-Bold.ttf")
+
+	_, err := LoadFontSet(dir)
+	assert.Error(t, err)
+}
+
+func TestLoadFontSet_DuplicateFamily(t *testing.T) {
+	dir := t.TempDir()
+	copyBundledFont(t, dir, BundledFamily+".ttf")
+
+	_, err := LoadFontSet(dir)
+	assert.Error(t, err)
+}
+
+func TestLoadFontSet_InvalidFont(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Broken.ttf"), []byte("not a font"), 0644))
+
+	_, err := LoadFontSet(dir)
+	assert.Error(t, err)
+}
+
+func TestIsCoreFont(t *testing.T) {
+	assert.True(t, IsCoreFont("Arial"))
+	assert.True(t, IsCoreFont("helvetica"))
+	assert.False(t, IsCoreFont(BundledFamily))
+}
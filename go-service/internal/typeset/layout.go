@@ -0,0 +1,179 @@
+package typeset
+
+import "strings"
+
+// Run is a piece of text drawn with a single font, in visual order
+type Run struct {
+	Text  string
+	Font  string
+	Style string
+	// Core is set when Font is a standard PDF font rather than a family of
+	// the set
+	Core bool
+}
+
+type fontChoice struct {
+	font  string
+	style string
+	core  bool
+}
+
+// Layout shapes text, reorders it for display and splits it into runs, each
+// drawn with the first font able to render its glyphs: primary when it covers
+// them, then the families of the set in order. rtl reports whether the
+// paragraph reads right to left, in which case it should be right-aligned.
+func (s *FontSet) Layout(text, primary, style string) (runs []Run, rtl bool) {
+	if text == "" {
+		return nil, false
+	}
+
+	visual, rtl := reorder(shapeArabic([]rune(text)))
+
+	var current *fontChoice
+	var builder strings.Builder
+	flush := func() {
+		if current != nil && builder.Len() > 0 {
+			runs = append(runs, Run{Text: builder.String(), Font: current.font, Style: current.style, Core: current.core})
+		}
+		builder.Reset()
+	}
+
+	write := func(choice *fontChoice, r rune) {
+		if current == nil || *choice != *current {
+			flush()
+			current = choice
+		}
+		builder.WriteRune(r)
+	}
+
+	for _, word := range splitWords(visual) {
+		// A word is kept in a single font whenever possible, so that a
+		// missing accent does not switch fonts in the middle of it
+		if choice := s.chooseWord(word, primary, style, current); choice != nil {
+			for _, r := range word {
+				write(choice, r)
+			}
+			continue
+		}
+		for _, r := range word {
+			write(s.choose(r, primary, style, current), r)
+		}
+	}
+	flush()
+
+	return runs, rtl
+}
+
+// splitWords cuts text before and after every space
+func splitWords(text []rune) [][]rune {
+	var words [][]rune
+	start := 0
+	for i, r := range text {
+		if r == ' ' {
+			if i > start {
+				words = append(words, text[start:i])
+			}
+			words = append(words, text[i:i+1])
+			start = i + 1
+		}
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+// chooseWord returns the font able to draw the whole word, or nil when the
+// glyphs have to be spread over several fonts
+func (s *FontSet) chooseWord(word []rune, primary, style string, current *fontChoice) *fontChoice {
+	// Spaces stay in the run they separate
+	if current != nil && len(word) == 1 && word[0] == ' ' && s.choiceCovers(current, ' ') {
+		return current
+	}
+
+	candidates := make([]*fontChoice, 0, 2*len(s.families)+2)
+	if IsCoreFont(primary) {
+		candidates = append(candidates, &fontChoice{font: primary, style: style, core: true})
+	} else if family, ok := s.byName[primary]; ok {
+		_, actual := family.Face(style)
+		candidates = append(candidates, &fontChoice{font: family.Name, style: actual})
+	}
+	if current != nil {
+		candidates = append(candidates, current)
+	}
+	for _, family := range s.families {
+		if _, ok := family.faces[style]; ok {
+			candidates = append(candidates, &fontChoice{font: family.Name, style: style})
+		}
+	}
+	for _, family := range s.families {
+		candidates = append(candidates, &fontChoice{font: family.Name, style: StyleRegular})
+	}
+
+	for _, candidate := range candidates {
+		coversAll := true
+		for _, r := range word {
+			if !s.choiceCovers(candidate, r) {
+				coversAll = false
+				break
+			}
+		}
+		if coversAll {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// choose picks the font of r, preferring primary, then the font of the run in
+// progress so that spaces and punctuation do not split it
+func (s *FontSet) choose(r rune, primary, style string, current *fontChoice) *fontChoice {
+	if choice, ok := s.primaryChoice(r, primary, style); ok {
+		return choice
+	}
+	if current != nil && s.choiceCovers(current, r) {
+		return current
+	}
+
+	for _, family := range s.families {
+		if family.covers(style, r) {
+			return &fontChoice{font: family.Name, style: style}
+		}
+	}
+	for _, family := range s.families {
+		if family.covers(StyleRegular, r) {
+			return &fontChoice{font: family.Name, style: StyleRegular}
+		}
+	}
+
+	// No font has the glyph; the bundled family draws a placeholder box
+	_, actual := s.families[0].Face(style)
+	return &fontChoice{font: s.families[0].Name, style: actual}
+}
+
+func (s *FontSet) primaryChoice(r rune, primary, style string) (*fontChoice, bool) {
+	if IsCoreFont(primary) {
+		if coreCovers(r) {
+			return &fontChoice{font: primary, style: style, core: true}, true
+		}
+		return nil, false
+	}
+
+	family, ok := s.byName[primary]
+	if !ok {
+		return nil, false
+	}
+	_, actual := family.Face(style)
+	if family.covers(actual, r) {
+		return &fontChoice{font: family.Name, style: actual}, true
+	}
+	return nil, false
+}
+
+func (s *FontSet) choiceCovers(choice *fontChoice, r rune) bool {
+	if choice.core {
+		return coreCovers(r)
+	}
+	family, ok := s.byName[choice.font]
+	return ok && family.covers(choice.style, r)
+}
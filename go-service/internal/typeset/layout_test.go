@@ -0,0 +1,94 @@
+package typeset
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// describeLayout prints the runs of a layout along with their code points, so
+// that shaped and reordered text stays readable in the golden files
+func describeLayout(runs []Run, rtl bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rtl: %v\n", rtl)
+	for _, run := range runs {
+		font := run.Font
+		if run.Core {
+			font += " (core)"
+		}
+		fmt.Fprintf(&b, "[%s %q] %s\n", font, run.Style, run.Text)
+		for _, r := range run.Text {
+			fmt.Fprintf(&b, " U+%04X", r)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestLayout_Golden(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		style string
+	}{
+		{name: "latin", text: "John Doe"},
+		{name: "latin_accents", text: "Zoë Müller-Ibáñez"},
+		{name: "arabic", text: "محمد عبد الله"},
+		{name: "arabic_bold", text: "فاطمة الزهراء", style: StyleBold},
+		{name: "arabic_digits", text: "الصف 10 (أ)"},
+		{name: "persian", text: "پگاه چیذری"},
+		{name: "hebrew", text: "שרה כהן"},
+		{name: "cyrillic", text: "Иван Петров"},
+		{name: "greek", text: "Νίκος Παπαδόπουλος"},
+		{name: "vietnamese", text: "Nguyễn Thị Ánh"},
+		{name: "mixed_ltr", text: "Omar عمر Class 5"},
+		// No bundled face has CJK glyphs; they need a family in FONT_DIR
+		{name: "cjk", text: "王小明"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := describeLayout(DefaultFontSet().Layout(tt.text, "Arial", tt.style))
+
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, []byte(got), 0644))
+			}
+
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), got)
+		})
+	}
+}
+
+func TestLayout_Empty(t *testing.T) {
+	runs, rtl := DefaultFontSet().Layout("", "Arial", StyleRegular)
+
+	assert.Empty(t, runs)
+	assert.False(t, rtl)
+}
+
+func TestLayout_ItalicFallsBackToRegularForArabic(t *testing.T) {
+	// The bundled oblique face has no Arabic glyphs
+	runs, _ := DefaultFontSet().Layout("سارة", "Arial", StyleItalic)
+
+	require.Len(t, runs, 1)
+	assert.Equal(t, BundledFamily, runs[0].Font)
+	assert.Equal(t, StyleRegular, runs[0].Style)
+}
+
+func TestLayout_TrueTypePrimary(t *testing.T) {
+	runs, rtl := DefaultFontSet().Layout("Anna", BundledFamily, StyleBold)
+
+	assert.False(t, rtl)
+	assert.Equal(t, []Run{{Text: "Anna", Font: BundledFamily, Style: StyleBold}}, runs)
+}